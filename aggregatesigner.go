@@ -0,0 +1,176 @@
+package viracochan
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+)
+
+// AggregateSigners returns a Signer for a version approved by all of
+// signers at once. Its public key is the MuSig2 (BIP-327) aggregate of their
+// keys, and each Sign runs the two MuSig2 rounds among them with fresh random
+// nonces, producing one ordinary 64-byte BIP-340 signature. The result fits
+// wherever a Signer does (WithSigner, Delegate, ...) and verifies with the
+// standard Verify and VerifyConfigSignature under the aggregate key, so an
+// N-party approval costs no more to store or check than a single signature.
+//
+// The security model is n-of-n: a signature under the aggregate key proves
+// that every participant signed, but not which key is which, and there is no
+// threshold. Verifiers must derive the aggregate key themselves with
+// AggregatePublicKeys from individually trusted keys rather than accept one
+// they are handed; MuSig2 key aggregation then rules out rogue-key attacks
+// without proofs of possession. Keys are aggregated in sorted order, so the
+// order of signers does not matter.
+//
+// All private keys must be held locally: remote signers cannot take part,
+// as NewRemoteSigner only exposes complete signatures. Parties on different
+// machines run the rounds with the musig2 package themselves and hand the
+// signed version to Submit. Without aggregation each version simply carries
+// the single signature of the manager's signer.
+func AggregateSigners(signers []*Signer) (*Signer, error) {
+	if len(signers) < 2 {
+		return nil, errors.New("aggregation needs at least two signers")
+	}
+
+	keys := make([]*btcec.PrivateKey, len(signers))
+	pubKeys := make([]*btcec.PublicKey, len(signers))
+	participants := make([]string, len(signers))
+	seen := make(map[string]bool, len(signers))
+	for i, s := range signers {
+		if s == nil || s.privateKey == "" {
+			return nil, fmt.Errorf("signer %d has no local private key", i)
+		}
+		if seen[s.publicKey] {
+			return nil, fmt.Errorf("signer %d repeats key %s", i, s.publicKey)
+		}
+		seen[s.publicKey] = true
+
+		priv, err := decodePrivateKey(s.privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signer %d: %w", i, err)
+		}
+		keys[i] = evenKey(priv)
+		pubKeys[i] = keys[i].PubKey()
+		participants[i] = s.publicKey
+	}
+
+	aggregate, _, _, err := musig2.AggregateKeys(pubKeys, true)
+	if err != nil {
+		return nil, err
+	}
+	aggregateKey := hex.EncodeToString(schnorr.SerializePubKey(aggregate.FinalKey))
+
+	signer, err := NewRemoteSigner(func(msg []byte) (string, error) {
+		return musigSign(keys, pubKeys, msg)
+	}, aggregateKey)
+	if err != nil {
+		return nil, err
+	}
+	signer.participants = participants
+	return signer, nil
+}
+
+// AggregatePublicKeys returns the hex x-only MuSig2 aggregate of publicKeys,
+// the key a signature of AggregateSigners over the same keys verifies
+// under, in any order
+func AggregatePublicKeys(publicKeys []string) (string, error) {
+	if len(publicKeys) < 2 {
+		return "", errors.New("aggregation needs at least two keys")
+	}
+
+	pubKeys := make([]*btcec.PublicKey, len(publicKeys))
+	seen := make(map[string]bool, len(publicKeys))
+	for i, key := range publicKeys {
+		if seen[key] {
+			return "", fmt.Errorf("key %d repeats %s", i, key)
+		}
+		seen[key] = true
+
+		raw, err := hex.DecodeString(key)
+		if err != nil {
+			return "", fmt.Errorf("key %d: %w", i, err)
+		}
+		// x-only keys parse with even y, matching evenKey
+		pubKeys[i], err = schnorr.ParsePubKey(raw)
+		if err != nil {
+			return "", fmt.Errorf("key %d: %w", i, err)
+		}
+	}
+
+	aggregate, _, _, err := musig2.AggregateKeys(pubKeys, true)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(schnorr.SerializePubKey(aggregate.FinalKey)), nil
+}
+
+// Participants returns the public keys of the signers an AggregateSigners
+// signer combines, in the order given, or nil for any other signer
+func (s *Signer) Participants() []string {
+	return append([]string(nil), s.participants...)
+}
+
+// evenKey returns priv, negated if needed so its public key has even y.
+// Aggregation then depends only on the x-only public keys, which is all
+// AggregatePublicKeys has; the BIP-340 key of the signer is unchanged.
+func evenKey(priv *btcec.PrivateKey) *btcec.PrivateKey {
+	if priv.PubKey().Y().Bit(0) == 0 {
+		return priv
+	}
+	var k btcec.ModNScalar
+	k.Set(&priv.Key)
+	return btcec.PrivKeyFromScalar(k.Negate())
+}
+
+// musigSign runs both MuSig2 rounds among keys over the 32-byte msg and
+// returns the combined signature hex-encoded
+func musigSign(keys []*btcec.PrivateKey, pubKeys []*btcec.PublicKey, msg []byte) (string, error) {
+	if len(msg) != 32 {
+		return "", fmt.Errorf("message must be 32 bytes, got %d", len(msg))
+	}
+	var digest [32]byte
+	copy(digest[:], msg)
+
+	sessions := make([]*musig2.Session, len(keys))
+	for i, key := range keys {
+		ctx, err := musig2.NewContext(key, true, musig2.WithKnownSigners(pubKeys))
+		if err != nil {
+			return "", err
+		}
+		if sessions[i], err = ctx.NewSession(); err != nil {
+			return "", err
+		}
+	}
+
+	// Round one: every party learns every other party's public nonce
+	for i, session := range sessions {
+		for j, other := range sessions {
+			if i == j {
+				continue
+			}
+			if _, err := session.RegisterPubNonce(other.PublicNonce()); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// Round two: partial signatures, combined by the first party
+	var partials []*musig2.PartialSignature
+	for _, session := range sessions {
+		partial, err := session.Sign(digest, musig2.WithSortedKeys())
+		if err != nil {
+			return "", err
+		}
+		partials = append(partials, partial)
+	}
+	for _, partial := range partials[1:] {
+		if _, err := sessions[0].CombineSig(partial); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(sessions[0].FinalSig().Serialize()), nil
+}
@@ -0,0 +1,94 @@
+package viracochan
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestAggregateSigners(t *testing.T) {
+	ctx := context.Background()
+
+	// Enough keys that some have odd y
+	var signers []*Signer
+	var keys []string
+	for i := 0; i < 6; i++ {
+		s, err := NewSigner()
+		if err != nil {
+			t.Fatalf("NewSigner failed: %v", err)
+		}
+		signers = append(signers, s)
+		keys = append(keys, s.PublicKey())
+	}
+
+	agg, err := AggregateSigners(signers)
+	if err != nil {
+		t.Fatalf("AggregateSigners failed: %v", err)
+	}
+	if !slices.Equal(agg.Participants(), keys) {
+		t.Errorf("unexpected participants %v", agg.Participants())
+	}
+	slices.Reverse(keys)
+	derived, err := AggregatePublicKeys(keys)
+	if err != nil {
+		t.Fatalf("AggregatePublicKeys failed: %v", err)
+	}
+	if derived != agg.PublicKey() {
+		t.Fatalf("derived aggregate key %s, signer has %s", derived, agg.PublicKey())
+	}
+
+	manager, err := NewManager(NewMemoryStorage(), WithSigner(agg), WithConfigStorageOptions(WithVerifyKeys([]string{derived})))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	cfg, err := manager.Create(ctx, "governance", map[string]interface{}{"quorum": 6})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(cfg.Meta.Signature) != 128 {
+		t.Errorf("expected one 64-byte signature, got %d hex chars", len(cfg.Meta.Signature))
+	}
+	if err := VerifyConfigSignature(cfg, derived); err != nil {
+		t.Errorf("aggregate signature does not verify: %v", err)
+	}
+	if err := VerifyConfigSignature(cfg, signers[0].PublicKey()); err == nil {
+		t.Error("aggregate signature verified under a single participant's key")
+	}
+	if _, err := manager.Update(ctx, "governance", map[string]interface{}{"quorum": 5}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := manager.GetLatest(ctx, "governance"); err != nil {
+		t.Errorf("GetLatest failed: %v", err)
+	}
+
+	subset, err := AggregatePublicKeys(keys[1:])
+	if err != nil {
+		t.Fatalf("AggregatePublicKeys failed: %v", err)
+	}
+	if err := VerifyConfigSignature(cfg, subset); err == nil {
+		t.Error("aggregate signature verified without every participant")
+	}
+}
+
+func TestAggregateSignersRejects(t *testing.T) {
+	a, _ := NewSigner()
+	b, _ := NewSigner()
+	remote, err := NewRemoteSigner(func(msg []byte) (string, error) { return a.signHash(msg) }, a.PublicKey())
+	if err != nil {
+		t.Fatalf("NewRemoteSigner failed: %v", err)
+	}
+
+	for name, signers := range map[string][]*Signer{
+		"single":    {a},
+		"duplicate": {a, b, a},
+		"remote":    {b, remote},
+		"nil":       {a, nil},
+	} {
+		if _, err := AggregateSigners(signers); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := AggregatePublicKeys([]string{a.PublicKey(), a.PublicKey()}); err == nil {
+		t.Error("expected an error for repeated keys")
+	}
+}
@@ -0,0 +1,315 @@
+package viracochan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// AuditIssueKind classifies a problem found by Audit
+type AuditIssueKind string
+
+const (
+	// AuditJournalUnreadable is a journal line that does not parse
+	AuditJournalUnreadable AuditIssueKind = "journal_unreadable"
+	// AuditMissingConfig is a journaled version without a config file
+	AuditMissingConfig AuditIssueKind = "missing_config"
+	// AuditOrphanConfig is a config file for a version never journaled
+	AuditOrphanConfig AuditIssueKind = "orphan_config"
+	// AuditInvalidConfig is a config file that cannot be read or whose
+	// checksum does not match its content
+	AuditInvalidConfig AuditIssueKind = "invalid_config"
+	// AuditChecksumMismatch is a config file whose checksum differs from the
+	// journal entry for the same version
+	AuditChecksumMismatch AuditIssueKind = "checksum_mismatch"
+	// AuditBadSignature is a signed config that verifies under none of the
+	// trusted keys
+	AuditBadSignature AuditIssueKind = "bad_signature"
+	// AuditBrokenChain is a journal chain that does not resequence or link
+	AuditBrokenChain AuditIssueKind = "broken_chain"
+)
+
+// AuditIssue is one line item of an AuditReport. Version is 0 when the issue
+// concerns the whole id (or, with an empty ID, the journal itself).
+type AuditIssue struct {
+	ID      string         `json:"id,omitempty"`
+	Version uint64         `json:"v,omitempty"`
+	Kind    AuditIssueKind `json:"kind"`
+	Detail  string         `json:"detail"`
+}
+
+// AuditReport is the result of a store-wide Audit
+type AuditReport struct {
+	Time       time.Time    `json:"time"`
+	IDs        int          `json:"ids"`
+	Entries    int          `json:"entries"`
+	Files      int          `json:"files"`
+	Signed     int          `json:"signed"`
+	Unverified int          `json:"unverified"`
+	Issues     []AuditIssue `json:"issues,omitempty"`
+}
+
+// OK reports whether the audit found no issues
+func (r *AuditReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Audit cross-checks the journal against the config files for every id in
+// the store: each journaled version must have a config file with a valid
+// checksum equal to the journaled one, each config file must be journaled,
+// each journal chain must link, and each signed config must verify under one
+// of trustedKeys (the config store's verify keys, or else the signer's own
+// key, when none are given). Without any key, signatures are counted as
+// Unverified rather than checked. Problems never stop the audit; each becomes
+// an issue in the report, and err is reserved for storage failures.
+func (m *Manager) Audit(ctx context.Context, trustedKeys ...string) (*AuditReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	return m.audit(ctx, trustedKeys)
+}
+
+// audit is Audit without the lock. Callers hold mu.
+func (m *Manager) audit(ctx context.Context, trustedKeys []string) (*AuditReport, error) {
+	if len(trustedKeys) == 0 {
+		trustedKeys = m.defaultTrustedKeys()
+	}
+
+	report := &AuditReport{Time: time.Now().UTC()}
+
+	byID, err := m.auditJournal(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	fileIDs, err := m.configIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range fileIDs {
+		if _, ok := byID[id]; !ok {
+			byID[id] = nil
+		}
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	report.IDs = len(ids)
+
+	for _, id := range ids {
+		if err := m.auditID(ctx, report, id, byID[id], trustedKeys); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// auditJournal reads the journal line by line, reporting unreadable lines
+// instead of failing, and groups the readable entries by id
+func (m *Manager) auditJournal(ctx context.Context, report *AuditReport) (map[string][]*JournalEntry, error) {
+	byID := make(map[string][]*JournalEntry)
+
+	data, err := m.journal.storage.Read(ctx, m.journal.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return byID, nil
+		}
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			report.Issues = append(report.Issues, AuditIssue{
+				Kind:   AuditJournalUnreadable,
+				Detail: fmt.Sprintf("line %d: %v", line, err),
+			})
+			continue
+		}
+		report.Entries++
+		byID[entry.ID] = append(byID[entry.ID], &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		report.Issues = append(report.Issues, AuditIssue{Kind: AuditJournalUnreadable, Detail: err.Error()})
+	}
+	return byID, nil
+}
+
+func (m *Manager) auditID(ctx context.Context, report *AuditReport, id string, entries []*JournalEntry, trustedKeys []string) error {
+	issue := func(version uint64, kind AuditIssueKind, format string, args ...any) {
+		report.Issues = append(report.Issues, AuditIssue{ID: id, Version: version, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if len(entries) > 0 {
+		ordered, err := m.journal.Resequence(entries)
+		if err == nil {
+			err = m.checkChainRoot(ctx, id, ordered[0])
+		}
+		if err == nil {
+			err = m.journal.ValidateChain(ordered)
+		}
+		if err != nil {
+			issue(0, AuditBrokenChain, "%v", err)
+		}
+	}
+
+	journaled := make(map[uint64]*JournalEntry, len(entries))
+	for _, entry := range entries {
+		journaled[entry.Version] = entry
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return err
+	}
+	stored := make(map[uint64]bool, len(versions))
+
+	for _, v := range versions {
+		stored[v] = true
+		report.Files++
+
+		cfg, err := loadConfigAtPath(ctx, m.storage, m.configStore.makeKey(id, v))
+		if err != nil {
+			issue(v, AuditInvalidConfig, "unreadable: %v", err)
+			continue
+		}
+		if err := cfg.Validate(); err != nil {
+			issue(v, AuditInvalidConfig, "%v", err)
+			continue
+		}
+
+		entry, ok := journaled[v]
+		switch {
+		case !ok:
+			issue(v, AuditOrphanConfig, "config file cs=%s has no journal entry", cfg.Meta.CS)
+		case entry.CS != cfg.Meta.CS:
+			issue(v, AuditChecksumMismatch, "config file cs=%s, journal cs=%s", cfg.Meta.CS, entry.CS)
+		}
+
+		if cfg.Meta.Signature == "" {
+			continue
+		}
+		if len(trustedKeys) == 0 {
+			report.Unverified++
+			continue
+		}
+		if !verifiesUnderAny(cfg, trustedKeys) {
+			issue(v, AuditBadSignature, "signature not valid for any trusted key")
+			continue
+		}
+		report.Signed++
+	}
+
+	for _, entry := range entries {
+		if !stored[entry.Version] {
+			issue(entry.Version, AuditMissingConfig, "journal entry cs=%s has no config file", entry.CS)
+		}
+	}
+	return nil
+}
+
+// defaultTrustedKeys returns the config store's verify keys, or else the
+// signer's own key
+func (m *Manager) defaultTrustedKeys() []string {
+	if len(m.configStore.verifyKeys) > 0 {
+		return m.configStore.verifyKeys
+	}
+	if m.signer != nil {
+		return []string{m.signer.PublicKey()}
+	}
+	return nil
+}
+
+func verifiesUnderAny(cfg *Config, keys []string) bool {
+	return verifyingKey(cfg, keys) != ""
+}
+
+// verifyingKey returns the first of keys that cfg's signature verifies under
+func verifyingKey(cfg *Config, keys []string) string {
+	for _, key := range keys {
+		if VerifyConfigSignature(cfg, key) == nil {
+			return key
+		}
+	}
+	return ""
+}
+
+// AuditRecord is one line of ExportAuditLog's NDJSON output. Actor is the
+// trusted public key the version's signature verifies under, empty when it
+// is unsigned or verifies under none; SignatureValid is true exactly when
+// Actor is set.
+type AuditRecord struct {
+	Timestamp      time.Time `json:"timestamp"`
+	Op             string    `json:"op"`
+	ID             string    `json:"id"`
+	Version        uint64    `json:"version"`
+	CS             string    `json:"cs"`
+	PrevCS         string    `json:"prev_cs"`
+	Actor          string    `json:"actor"`
+	SignatureValid bool      `json:"signature_valid"`
+	Message        string    `json:"message,omitempty"`
+}
+
+// ExportAuditLog writes one AuditRecord per journal entry timestamped after
+// since (the zero time exports everything), in journal order, as
+// newline-delimited JSON. Records are derived from the journal; signatures
+// are checked against the config store's verify keys, or else the signer's
+// key, using the embedded config or the config file. Passing the timestamp of
+// the last exported record as since continues an incremental export.
+func (m *Manager) ExportAuditLog(ctx context.Context, w io.Writer, since time.Time) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	entries, err := m.journal.ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := m.defaultTrustedKeys()
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if !entry.Time.After(since) {
+			continue
+		}
+
+		rec := AuditRecord{
+			Timestamp: entry.Time,
+			Op:        entry.Operation,
+			ID:        entry.ID,
+			Version:   entry.Version,
+			CS:        entry.CS,
+			PrevCS:    entry.PrevCS,
+			Message:   entry.Message,
+		}
+		if cfg, err := m.entryConfig(ctx, entry); err == nil && cfg.Meta.Signature != "" {
+			rec.Actor = verifyingKey(cfg, keys)
+			rec.SignatureValid = rec.Actor != ""
+		}
+
+		if err := enc.Encode(&rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
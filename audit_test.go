@@ -0,0 +1,141 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManagerAudit(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Create(ctx, "db", map[string]interface{}{"host": "a"})
+
+	report, err := manager.Audit(ctx)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected clean audit, got %+v", report.Issues)
+	}
+	if report.IDs != 2 || report.Entries != 3 || report.Files != 3 || report.Signed != 3 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+
+	other, _ := NewSigner()
+	if report, _ := manager.Audit(ctx, other.PublicKey()); len(report.Issues) != 3 || report.Issues[0].Kind != AuditBadSignature {
+		t.Errorf("expected bad signatures under an untrusted key, got %+v", report.Issues)
+	}
+
+	// Damage the store in every way Audit knows about
+	storage.Write(ctx, "configs/app/v1.json", []byte(`not json`))
+	storage.Delete(ctx, "configs/app/v2.json")
+	stray, _ := newConfig(map[string]interface{}{"stray": true}, "")
+	manager.configStore.Save(ctx, "stray", stray)
+	journal, _ := storage.Read(ctx, "journal.jsonl")
+	storage.Write(ctx, "journal.jsonl", append(journal, "{broken\n"...))
+
+	report, err = manager.Audit(ctx)
+	if err != nil {
+		t.Fatalf("Audit failed on a damaged store: %v", err)
+	}
+
+	kinds := make(map[AuditIssueKind]int)
+	for _, issue := range report.Issues {
+		kinds[issue.Kind]++
+	}
+	want := map[AuditIssueKind]int{
+		AuditJournalUnreadable: 1,
+		AuditInvalidConfig:     1,
+		AuditMissingConfig:     1,
+		AuditOrphanConfig:      1,
+	}
+	for kind, n := range want {
+		if kinds[kind] != n {
+			t.Errorf("expected %d %s issues, got %d (%+v)", n, kind, kinds[kind], report.Issues)
+		}
+	}
+	if report.IDs != 3 {
+		t.Errorf("expected stray id to be audited, got %d ids", report.IDs)
+	}
+}
+
+func TestManagerAuditChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	replaced, _ := newConfig(map[string]interface{}{"n": "forged"}, "")
+	manager.configStore.Save(ctx, "app", replaced)
+
+	report, err := manager.Audit(ctx)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != AuditChecksumMismatch || report.Issues[0].Version != 1 {
+		t.Fatalf("expected a checksum mismatch on v1, got %+v", report.Issues)
+	}
+	if report.Unverified != 0 || report.Signed != 0 {
+		t.Errorf("unsigned store should count no signatures: %+v", report)
+	}
+}
+
+func TestManagerExportAuditLog(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	second, _ := manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	unsigned, _ := NewManager(storage)
+	unsigned.Update(ctx, "app", map[string]interface{}{"n": 3})
+
+	var buf bytes.Buffer
+	if err := manager.ExportAuditLog(ctx, &buf, time.Time{}); err != nil {
+		t.Fatalf("ExportAuditLog failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 records, got %d:\n%s", len(lines), buf.String())
+	}
+
+	var records []AuditRecord
+	for _, line := range lines {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("record is not JSON: %v", err)
+		}
+		for _, key := range []string{"timestamp", "op", "id", "version", "cs", "prev_cs", "actor", "signature_valid"} {
+			if _, ok := fields[key]; !ok {
+				t.Errorf("record lacks %q: %s", key, line)
+			}
+		}
+		var rec AuditRecord
+		json.Unmarshal([]byte(line), &rec)
+		records = append(records, rec)
+	}
+
+	if r := records[1]; r.Op != "update" || r.Version != 2 || r.CS != second.Meta.CS || r.PrevCS != second.Meta.PrevCS ||
+		!r.SignatureValid || r.Actor != signer.PublicKey() {
+		t.Errorf("unexpected signed record %+v", r)
+	}
+	if r := records[2]; r.SignatureValid || r.Actor != "" {
+		t.Errorf("unsigned version should not verify: %+v", r)
+	}
+
+	buf.Reset()
+	manager.ExportAuditLog(ctx, &buf, records[1].Timestamp)
+	if got := strings.Count(buf.String(), "\n"); got != 1 {
+		t.Errorf("expected 1 record after since, got %d", got)
+	}
+}
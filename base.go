@@ -0,0 +1,212 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// basePrefix is where derived configs record their base, one file per id
+const basePrefix = "bases"
+
+// maxBaseDepth bounds chains of derived configs
+const maxBaseDepth = 16
+
+// MergeOverlay applies overlay to base as a JSON merge patch (RFC 7396):
+// objects are merged key by key and recursively, a null in overlay deletes
+// the key from base, and any other overlay value (including arrays) replaces
+// the base value. The result is canonical JSON.
+func MergeOverlay(base, overlay json.RawMessage) (json.RawMessage, error) {
+	var b, o interface{}
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &b); err != nil {
+			return nil, fmt.Errorf("base: %w", err)
+		}
+	}
+	if len(overlay) > 0 {
+		if err := json.Unmarshal(overlay, &o); err != nil {
+			return nil, fmt.Errorf("overlay: %w", err)
+		}
+	}
+	return canonicalJSON(mergePatch(b, o))
+}
+
+func mergePatch(base, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	baseObj, ok := base.(map[string]interface{})
+	if !ok {
+		baseObj = map[string]interface{}{}
+	}
+
+	merged := make(map[string]interface{}, len(baseObj)+len(patchObj))
+	for k, v := range baseObj {
+		merged[k] = v
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = mergePatch(merged[k], v)
+	}
+	return merged
+}
+
+type baseLink struct {
+	Base string `json:"base"`
+}
+
+func (m *Manager) basePath(id string) string {
+	return filepath.Join(basePrefix, id+".json")
+}
+
+// baseOf returns the base id of a derived config, or "" for a plain one
+func (m *Manager) baseOf(ctx context.Context, id string) (string, error) {
+	data, err := m.storage.Read(ctx, m.basePath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var link baseLink
+	if err := json.Unmarshal(data, &link); err != nil {
+		return "", fmt.Errorf("base of %q: %w", id, err)
+	}
+	return link.Base, nil
+}
+
+// CreateFromBase creates id as a config derived from baseID: its stored
+// versions hold only overlay, a merge patch against the base (see
+// MergeOverlay), and GetResolved returns the base merged with it. Later
+// Updates of id replace the overlay; updates of the base show through
+// automatically. The base may itself be derived, but not from id.
+func (m *Manager) CreateFromBase(ctx context.Context, id, baseID string, overlay interface{}, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "create_from_base", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+	if err := m.validateID(baseID); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	if err := m.checkNew(ctx, id); err != nil {
+		return nil, err
+	}
+	if _, err := m.resolve(ctx, baseID, id); err != nil {
+		return nil, fmt.Errorf("base %q: %w", baseID, err)
+	}
+
+	link, err := json.Marshal(baseLink{Base: baseID})
+	if err != nil {
+		return nil, err
+	}
+	if err := m.storage.Write(ctx, m.basePath(id), link); err != nil {
+		return nil, err
+	}
+
+	cfg, err = m.create(ctx, id, overlay, "create_from_base", newWriteOptions(opts))
+	if err != nil {
+		if derr := m.storage.Delete(ctx, m.basePath(id)); derr != nil {
+			m.logger.Warn("create from base: failed to remove base link", "id", id, "error", derr)
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// GetResolved returns the effective latest config of id: for a derived
+// config, its base (itself resolved) merged with its overlay, otherwise the
+// latest version unchanged. A resolved config is computed, not stored: its
+// metadata carries the overlay's version and hash algorithm, the later of the
+// two timestamps and a checksum over the merged content, so equal inputs
+// always resolve to the same checksum. It is unsigned; verify the stored
+// versions instead.
+func (m *Manager) GetResolved(ctx context.Context, id string) (*Config, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	return m.resolve(ctx, id, "")
+}
+
+// resolve resolves id. Its base chain must not loop or pass through
+// forbidden, which CreateFromBase uses to reject a cycle before creating it.
+func (m *Manager) resolve(ctx context.Context, id, forbidden string) (*Config, error) {
+	if id == forbidden {
+		return nil, fmt.Errorf("%w: %q cannot be its own base", ErrInvalidChain, id)
+	}
+
+	chain := []string{id}
+	for {
+		base, err := m.baseOf(ctx, chain[len(chain)-1])
+		if err != nil {
+			return nil, err
+		}
+		if base == "" {
+			break
+		}
+		if base == forbidden || slices.Contains(chain, base) {
+			return nil, fmt.Errorf("%w: base cycle through %q", ErrInvalidChain, base)
+		}
+		if len(chain) == maxBaseDepth {
+			return nil, fmt.Errorf("%w: base chain of %q deeper than %d", ErrInvalidChain, id, maxBaseDepth)
+		}
+		chain = append(chain, base)
+	}
+
+	resolved, err := m.getLatest(ctx, chain[len(chain)-1])
+	if err != nil {
+		return nil, err
+	}
+	for i := len(chain) - 2; i >= 0; i-- {
+		overlay, err := m.getLatest(ctx, chain[i])
+		if err != nil {
+			return nil, err
+		}
+		content, err := MergeOverlay(resolved.Content, overlay.Content)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %q: %w", chain[i], err)
+		}
+
+		next := &Config{
+			Meta: Meta{
+				Version: overlay.Meta.Version,
+				Time:    overlay.Meta.Time,
+				HashAlg: overlay.Meta.HashAlg,
+			},
+			Content: content,
+		}
+		if resolved.Meta.Time.After(next.Meta.Time) {
+			next.Meta.Time = resolved.Meta.Time
+		}
+		if next.Meta.CS, err = computeChecksum(next); err != nil {
+			return nil, err
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
@@ -0,0 +1,84 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMergeOverlay(t *testing.T) {
+	base := json.RawMessage(`{"db":{"host":"db.internal","port":5432},"replicas":[1,2],"debug":false,"legacy":1}`)
+	overlay := json.RawMessage(`{"db":{"host":"db.prod"},"replicas":[3],"legacy":null,"region":"eu"}`)
+
+	merged, err := MergeOverlay(base, overlay)
+	if err != nil {
+		t.Fatalf("MergeOverlay failed: %v", err)
+	}
+	want := `{"db":{"host":"db.prod","port":5432},"debug":false,"region":"eu","replicas":[3]}`
+	if string(merged) != want {
+		t.Errorf("expected %s, got %s", want, merged)
+	}
+
+	if merged, _ := MergeOverlay(base, json.RawMessage(`"scalar"`)); string(merged) != `"scalar"` {
+		t.Errorf("non-object overlay should replace base, got %s", merged)
+	}
+}
+
+func TestCreateFromBase(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	manager.Create(ctx, "common", map[string]interface{}{"timeout": 30, "log": map[string]interface{}{"level": "info", "json": true}})
+	if _, err := manager.CreateFromBase(ctx, "prod", "common", map[string]interface{}{"log": map[string]interface{}{"level": "warn"}}); err != nil {
+		t.Fatalf("CreateFromBase failed: %v", err)
+	}
+	manager.CreateFromBase(ctx, "prod-eu", "prod", map[string]interface{}{"region": "eu"})
+
+	resolved, err := manager.GetResolved(ctx, "prod-eu")
+	if err != nil {
+		t.Fatalf("GetResolved failed: %v", err)
+	}
+	if want := `{"log":{"json":true,"level":"warn"},"region":"eu","timeout":30}`; string(resolved.Content) != want {
+		t.Errorf("expected %s, got %s", want, resolved.Content)
+	}
+	if err := resolved.Validate(); err != nil {
+		t.Errorf("resolved config does not validate: %v", err)
+	}
+	again, _ := manager.GetResolved(ctx, "prod-eu")
+	if again.Meta.CS != resolved.Meta.CS {
+		t.Error("resolving twice gave different checksums")
+	}
+
+	// Base changes show through; the overlay still wins where it is set
+	manager.Update(ctx, "common", map[string]interface{}{"timeout": 60, "log": map[string]interface{}{"level": "debug"}})
+	reopened, _ := NewManager(storage)
+	resolved, _ = reopened.GetResolved(ctx, "prod-eu")
+	if want := `{"log":{"level":"warn"},"region":"eu","timeout":60}`; string(resolved.Content) != want {
+		t.Errorf("expected %s after base update, got %s", want, resolved.Content)
+	}
+	if resolved.Meta.CS == again.Meta.CS {
+		t.Error("checksum did not change with the base")
+	}
+
+	stored, _ := reopened.GetLatest(ctx, "prod")
+	if string(stored.Content) != `{"log":{"level":"warn"}}` {
+		t.Errorf("stored version should hold only the overlay, got %s", stored.Content)
+	}
+	plain, _ := reopened.GetResolved(ctx, "common")
+	common, _ := reopened.GetLatest(ctx, "common")
+	if !plain.Equal(common) {
+		t.Error("GetResolved of a plain config should return its latest version")
+	}
+
+	if _, err := reopened.CreateFromBase(ctx, "orphan", "missing", map[string]interface{}{}); err == nil {
+		t.Error("expected CreateFromBase with a missing base to fail")
+	}
+	if _, err := reopened.CreateFromBase(ctx, "self", "self", map[string]interface{}{}); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain for self base, got %v", err)
+	}
+	if _, err := reopened.CreateFromBase(ctx, "prod", "common", map[string]interface{}{}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict for existing id, got %v", err)
+	}
+}
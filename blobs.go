@@ -0,0 +1,266 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// blobPrefix is where CompactToBlobs stores content, relative to the config
+// storage root
+const blobPrefix = "blobs"
+
+// ErrBlobMismatch is returned when a content blob does not hash to the
+// reference that points at it
+var ErrBlobMismatch = errors.New("content blob does not match its reference")
+
+// configFile is the on-disk form of a version file. Content is either inline,
+// replaced after CompactToBlobs by ContentRef: the hex SHA-256 of the exact
+// content bytes, stored at blobs/<ContentRef>, or with WithDeltaStorage
+// replaced by a Delta against an earlier version.
+type configFile struct {
+	Meta       Meta            `json:"_meta"`
+	Content    json.RawMessage `json:"content,omitempty"`
+	ContentRef string          `json:"content_ref,omitempty"`
+	Delta      *contentDelta   `json:"delta,omitempty"`
+	Codec      string          `json:"codec,omitempty"`      // compression of Compressed; see WithCompression
+	Compressed []byte          `json:"compressed,omitempty"` // content, when Codec is set
+}
+
+func blobKey(ref string) string {
+	return blobPrefix + "/" + ref
+}
+
+// blobRef is the content reference for content: the SHA-256 of its bytes
+// (not of its canonical form, because signatures cover the bytes)
+func blobRef(content json.RawMessage) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// decodeConfigFile decodes the version file at path, resolving a content
+// reference or delta through storage
+func decodeConfigFile(ctx context.Context, storage Storage, path string, data []byte) (*Config, error) {
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Meta: file.Meta, Content: compactContent(file.Content)}
+	if file.Codec != "" {
+		content, err := decompressContent(&file)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Content = content
+		return cfg, nil
+	}
+	if file.Delta != nil {
+		content, err := resolveDelta(ctx, storage, path, file.Meta.Version, file.Delta)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Content = content
+		return cfg, nil
+	}
+	if file.ContentRef == "" {
+		return cfg, nil
+	}
+
+	content, err := storage.Read(ctx, blobKey(file.ContentRef))
+	if err != nil {
+		return nil, fmt.Errorf("content blob %s: %w", file.ContentRef, err)
+	}
+	if blobRef(content) != file.ContentRef {
+		return nil, fmt.Errorf("%w: %s", ErrBlobMismatch, file.ContentRef)
+	}
+	cfg.Content = content
+	return cfg, nil
+}
+
+// compactContent strips the whitespace an indented version file adds to its
+// content, restoring the bytes json.Marshal writes, which signatures are
+// computed over. Compact content is returned as is.
+func compactContent(content json.RawMessage) json.RawMessage {
+	if !bytes.ContainsAny(content, " \t\r\n") {
+		return content
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, content); err != nil {
+		return content
+	}
+	return buf.Bytes()
+}
+
+// BlobCompactResult reports what CompactToBlobs rewrote
+type BlobCompactResult struct {
+	Versions    int // version files rewritten as pointers
+	Blobs       int // distinct content blobs referenced
+	BytesBefore int // config files plus journal, before
+	BytesAfter  int // pointers, blobs written, plus journal, after
+}
+
+// blobRewrite is one version being moved to a blob
+type blobRewrite struct {
+	id      string
+	cfg     *Config
+	key     string
+	pointer []byte
+}
+
+// CompactToBlobs rewrites every stored version so that identical content is
+// stored once, under blobs/<sha256 of the content bytes>, and version files
+// become small pointers to their blob. Versions known only from the journal's
+// embedded configs are written out as pointers too, and the journal is then
+// rewritten without embedded configs; each entry keeps its ContentHash.
+//
+// It runs in three steps. Blobs and candidate pointers are built first and
+// every pointer is decoded back through its blob and checked to validate and
+// to reproduce the original version exactly (metadata, content bytes and so
+// signatures); any failure aborts with the original files untouched. Only
+// then are the version files overwritten and the journal rewritten.
+//
+// Afterwards every version still loads, validates and reconstructs, but the
+// journal alone can no longer rebuild lost version files (MaterializeConfigs
+// needs embedded configs): back up the config storage, which now holds both
+// the pointers and the blobs.
+func (m *Manager) CompactToBlobs(ctx context.Context) (result *BlobCompactResult, err error) {
+	ctx, call := m.begin(ctx, "compact_to_blobs", "")
+	defer func() { call.end(nil, err) }()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	entries, err := m.journal.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	journalBefore, err := journalSize(ctx, m.journal)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &BlobCompactResult{BytesBefore: journalBefore}
+	var rewrites []blobRewrite
+	seen := make(map[string]bool)
+
+	ids, err := m.configIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		versions, err := m.configStore.ListVersions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			key := m.configStore.makeKey(id, v)
+			data, err := m.storage.Read(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			cfg, err := decodeConfigFile(ctx, m.storage, key, data)
+			if err != nil {
+				return nil, fmt.Errorf("config %q v%d: %w", id, v, err)
+			}
+			if err := cfg.Validate(); err != nil {
+				return nil, fmt.Errorf("config %q v%d: %w", id, v, err)
+			}
+			result.BytesBefore += len(data)
+			rewrites = append(rewrites, blobRewrite{id: id, cfg: cfg, key: key})
+			seen[key] = true
+		}
+	}
+
+	for _, entry := range entries {
+		key := m.configStore.makeKey(entry.ID, entry.Version)
+		if seen[key] || entry.Config == nil {
+			continue
+		}
+		if err := entry.Config.Validate(); err != nil || entry.Config.Meta.CS != entry.CS {
+			return nil, fmt.Errorf("journal entry %q v%d: embedded config does not match its entry", entry.ID, entry.Version)
+		}
+		rewrites = append(rewrites, blobRewrite{id: entry.ID, cfg: entry.Config, key: key})
+		seen[key] = true
+	}
+
+	// Step 1: write blobs and build pointers
+	written := make(map[string]bool)
+	for i := range rewrites {
+		rw := &rewrites[i]
+		ref := blobRef(rw.cfg.Content)
+		if !written[ref] {
+			if err := m.storage.Write(ctx, blobKey(ref), rw.cfg.Content); err != nil {
+				return nil, err
+			}
+			written[ref] = true
+			result.BytesAfter += len(rw.cfg.Content)
+		}
+		rw.pointer, err = m.configStore.encode(configFile{Meta: rw.cfg.Meta, ContentRef: ref})
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.Blobs = len(written)
+
+	// Step 2: every pointer must reproduce its version before anything is
+	// replaced
+	for _, rw := range rewrites {
+		got, err := decodeConfigFile(ctx, m.storage, rw.key, rw.pointer)
+		if err == nil {
+			err = got.Validate()
+		}
+		if err == nil && (!bytes.Equal(got.Content, rw.cfg.Content) || !got.Equal(rw.cfg) || got.Meta.Signature != rw.cfg.Meta.Signature) {
+			err = errors.New("pointer does not reproduce the original version")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("verify %q v%d: %w", rw.id, rw.cfg.Meta.Version, err)
+		}
+	}
+
+	// Step 3: replace version files and drop embedded configs
+	for _, rw := range rewrites {
+		if err := m.storage.Write(ctx, rw.key, rw.pointer); err != nil {
+			return nil, err
+		}
+		result.BytesAfter += len(rw.pointer)
+		result.Versions++
+	}
+
+	for _, entry := range entries {
+		if entry.ContentHash == "" && entry.Config != nil {
+			entry.ContentHash, _ = ContentHash(entry.Config.Content)
+		}
+		entry.Config = nil
+	}
+	if err := m.journal.Rewrite(ctx, entries); err != nil {
+		return nil, err
+	}
+	journalAfter, err := journalSize(ctx, m.journal)
+	if err != nil {
+		return nil, err
+	}
+	result.BytesAfter += journalAfter
+
+	m.cacheReset()
+	return result, nil
+}
+
+func journalSize(ctx context.Context, j *Journal) (int, error) {
+	data, err := j.storage.Read(ctx, j.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return len(data), nil
+}
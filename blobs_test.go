@@ -0,0 +1,94 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompactToBlobs(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	large := strings.Repeat("x", 4096)
+	manager.Create(ctx, "app", map[string]interface{}{"blob": large, "n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"blob": large, "n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"blob": large, "n": 2})
+	manager.Create(ctx, "other", map[string]interface{}{"blob": large, "n": 1})
+
+	before, _ := manager.GetHistory(ctx, "app")
+
+	result, err := manager.CompactToBlobs(ctx)
+	if err != nil {
+		t.Fatalf("CompactToBlobs failed: %v", err)
+	}
+	if result.Versions != 4 || result.Blobs != 2 {
+		t.Errorf("expected 4 versions in 2 blobs, got %+v", result)
+	}
+	if result.BytesAfter >= result.BytesBefore {
+		t.Errorf("expected compaction to save space, got %d -> %d", result.BytesBefore, result.BytesAfter)
+	}
+
+	entries, _ := manager.journal.ReadAll(ctx)
+	for _, entry := range entries {
+		if entry.Config != nil {
+			t.Fatalf("journal entry %s v%d still embeds its config", entry.ID, entry.Version)
+		}
+		if entry.ContentHash == "" {
+			t.Errorf("journal entry %s v%d lost its content hash", entry.ID, entry.Version)
+		}
+	}
+
+	restarted, _ := NewManager(storage, WithSigner(signer))
+	after, err := restarted.GetHistory(ctx, "app")
+	if err != nil || len(after) != len(before) {
+		t.Fatalf("expected %d versions after compaction, got %d, %v", len(before), len(after), err)
+	}
+	for i := range before {
+		if !after[i].Equal(before[i]) || after[i].Meta.Signature != before[i].Meta.Signature {
+			t.Errorf("v%d changed by compaction", before[i].Meta.Version)
+		}
+		if err := signer.Verify(after[i], signer.PublicKey()); err != nil {
+			t.Errorf("v%d signature no longer verifies: %v", after[i].Meta.Version, err)
+		}
+	}
+	if err := restarted.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain invalid after compaction: %v", err)
+	}
+	if _, err := restarted.Reconstruct(ctx, "app"); err != nil {
+		t.Errorf("Reconstruct failed after compaction: %v", err)
+	}
+	report, err := restarted.Audit(ctx)
+	if err != nil || !report.OK() {
+		t.Errorf("expected clean audit, got %+v, %v", report, err)
+	}
+
+	// Running again only rewrites the pointers
+	if _, err := restarted.CompactToBlobs(ctx); err != nil {
+		t.Errorf("second CompactToBlobs failed: %v", err)
+	}
+	if _, err := restarted.Update(ctx, "app", map[string]interface{}{"n": 3}); err != nil {
+		t.Errorf("Update after compaction failed: %v", err)
+	}
+}
+
+func TestCompactToBlobsTamperedBlob(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	cfg, _ := manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	if _, err := manager.CompactToBlobs(ctx); err != nil {
+		t.Fatalf("CompactToBlobs failed: %v", err)
+	}
+
+	storage.Write(ctx, blobKey(blobRef(cfg.Content)), []byte(`{"n":2}`))
+
+	restarted, _ := NewManager(storage)
+	if _, err := restarted.GetLatest(ctx, "app"); !errors.Is(err, ErrBlobMismatch) {
+		t.Errorf("expected ErrBlobMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package viracochan
+
+import (
+	"context"
+	"io"
+	"text/template"
+	"time"
+)
+
+// VersionChange describes how one version differs from its predecessor.
+type VersionChange struct {
+	Version     uint64    `json:"v"`
+	PrevVersion uint64    `json:"prev_v"`
+	Time        time.Time `json:"t"`
+	Operation   string    `json:"op,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Changes     []Change  `json:"changes"`
+}
+
+// DefaultChangelogTemplate renders one block per version with one line per
+// change.
+var DefaultChangelogTemplate = template.Must(template.New("changelog").Parse(
+	`{{range .}}v{{.Version}} ({{.Time.Format "2006-01-02T15:04:05Z07:00"}}{{if .Operation}}, {{.Operation}}{{end}}){{if .Message}} {{.Message}}{{end}}
+{{range .Changes}}  {{.Kind}} {{if .Path}}{{.Path}}{{else}}(root){{end}}{{if .Old}} {{printf "%s" .Old}}{{end}}{{if and .Old .New}} ->{{end}}{{if .New}} {{printf "%s" .New}}{{end}}
+{{else}}  (no content changes)
+{{end}}{{end}}`))
+
+// Changelog walks the stored history of id and returns, for every version
+// after the first, its diff from the preceding stored version together with
+// the journaled operation and message. Ordering and gap handling follow GetHistory.
+// Values are masked by the manager's redactor, if any.
+func (m *Manager) Changelog(ctx context.Context, id string) ([]VersionChange, error) {
+	history, err := m.GetHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	journaled := make(map[string]*JournalEntry, len(entries))
+	for _, entry := range entries {
+		journaled[entry.CS] = entry
+	}
+
+	var changelog []VersionChange
+	for i := 1; i < len(history); i++ {
+		prev, cur := history[i-1], history[i]
+		changes, err := Diff(m.redact(prev.Content), m.redact(cur.Content))
+		if err != nil {
+			return nil, err
+		}
+		change := VersionChange{
+			Version:     cur.Meta.Version,
+			PrevVersion: prev.Meta.Version,
+			Time:        cur.Meta.Time,
+			Changes:     changes,
+		}
+		if entry, ok := journaled[cur.Meta.CS]; ok {
+			change.Operation = entry.Operation
+			change.Message = entry.Message
+		}
+		changelog = append(changelog, change)
+	}
+	return changelog, nil
+}
+
+// RenderChangelog writes changelog as text using tmpl, or
+// DefaultChangelogTemplate when tmpl is nil. The template receives the
+// []VersionChange slice. For JSON output marshal the slice directly.
+func RenderChangelog(w io.Writer, changelog []VersionChange, tmpl *template.Template) error {
+	if tmpl == nil {
+		tmpl = DefaultChangelogTemplate
+	}
+	return tmpl.Execute(w, changelog)
+}
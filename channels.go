@@ -0,0 +1,158 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// channelPrefix is where channel pointers are kept in the config storage,
+// one file per id
+const channelPrefix = "channels"
+
+// ChannelRef is where a channel of a config points: the promoted version,
+// its checksum at promotion time and when it was promoted
+type ChannelRef struct {
+	Version    uint64    `json:"v"`
+	CS         string    `json:"cs"`
+	PromotedAt time.Time `json:"t"`
+}
+
+// PromoteOption configures Promote
+type PromoteOption func(*promoteOptions)
+
+type promoteOptions struct {
+	force bool
+}
+
+// ForcePromote lets Promote move a channel back to an older version
+func ForcePromote() PromoteOption {
+	return func(o *promoteOptions) {
+		o.force = true
+	}
+}
+
+func (m *Manager) channelPath(id string) string {
+	return filepath.Join(channelPrefix, id+".json")
+}
+
+func (m *Manager) readChannels(ctx context.Context, id string) (map[string]ChannelRef, error) {
+	data, err := m.storage.Read(ctx, m.channelPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]ChannelRef{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	channels := map[string]ChannelRef{}
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return nil, fmt.Errorf("channels of %q: %w", id, err)
+	}
+	return channels, nil
+}
+
+// Promote points channel (e.g. "stable" or "canary") of id at version, which
+// must exist and validate. Channels only move forward: promoting a version
+// older than the one the channel points at fails with ErrVersionConflict
+// unless ForcePromote is given. Channel pointers are stored with the configs
+// under channels/<id>.json.
+func (m *Manager) Promote(ctx context.Context, id string, version uint64, channel string, opts ...PromoteOption) (err error) {
+	ctx, call := m.begin(ctx, "promote", id)
+	defer func() { call.end(nil, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+	if err := DefaultIDValidator(channel); err != nil {
+		return fmt.Errorf("invalid channel name: %w", err)
+	}
+
+	var po promoteOptions
+	for _, opt := range opts {
+		opt(&po)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	cfg, err := m.configStore.Load(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	channels, err := m.readChannels(ctx, id)
+	if err != nil {
+		return err
+	}
+	if current, ok := channels[channel]; ok && version < current.Version && !po.force {
+		return fmt.Errorf("%w: channel %q of %q is at version %d, not moving back to %d", ErrVersionConflict, channel, id, current.Version, version)
+	}
+
+	channels[channel] = ChannelRef{Version: version, CS: cfg.Meta.CS, PromotedAt: time.Now().UTC()}
+	data, err := json.Marshal(channels)
+	if err != nil {
+		return err
+	}
+	return m.storage.Write(ctx, m.channelPath(id), data)
+}
+
+// GetChannel returns the version of id that channel points at. It fails with
+// os.ErrNotExist for a channel never promoted, and with ErrChecksumMismatch
+// if the version file no longer carries the promoted checksum.
+func (m *Manager) GetChannel(ctx context.Context, id, channel string) (*Config, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	channels, err := m.readChannels(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ref, ok := channels[channel]
+	if !ok {
+		return nil, fmt.Errorf("channel %q of %q: %w", channel, id, os.ErrNotExist)
+	}
+
+	cfg, err := m.configStore.Load(ctx, id, ref.Version)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Meta.CS != ref.CS {
+		return nil, fmt.Errorf("%w: channel %q of %q promoted cs=%s, version %d has cs=%s", ErrChecksumMismatch, channel, id, ref.CS, ref.Version, cfg.Meta.CS)
+	}
+	return cfg, nil
+}
+
+// Channels returns every channel of id and where it points
+func (m *Manager) Channels(ctx context.Context, id string) (map[string]ChannelRef, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	return m.readChannels(ctx, id)
+}
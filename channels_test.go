@@ -0,0 +1,59 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestChannels(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+
+	if _, err := manager.GetChannel(ctx, "app", "stable"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for unpromoted channel, got %v", err)
+	}
+
+	if err := manager.Promote(ctx, "app", 2, "stable"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	manager.Promote(ctx, "app", 3, "canary")
+
+	reopened, _ := NewManager(storage)
+	stable, err := reopened.GetChannel(ctx, "app", "stable")
+	if err != nil || stable.Meta.Version != 2 {
+		t.Fatalf("expected stable at v2, got %v, %v", stable, err)
+	}
+	channels, _ := reopened.Channels(ctx, "app")
+	if len(channels) != 2 || channels["canary"].Version != 3 {
+		t.Errorf("unexpected channels %+v", channels)
+	}
+
+	if err := reopened.Promote(ctx, "app", 1, "stable"); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict moving stable back, got %v", err)
+	}
+	if err := reopened.Promote(ctx, "app", 1, "stable", ForcePromote()); err != nil {
+		t.Errorf("forced promote failed: %v", err)
+	}
+	if err := reopened.Promote(ctx, "app", 9, "stable"); err == nil {
+		t.Error("expected promoting a missing version to fail")
+	}
+	if err := reopened.Promote(ctx, "app", 2, "a/b"); err == nil {
+		t.Error("expected invalid channel name to fail")
+	}
+
+	// A rewritten version file no longer matches its promotion
+	rewritten, _ := reopened.Get(ctx, "app", 3)
+	rewritten.Content = []byte(`{"n":30}`)
+	rewritten.Meta.CS, _ = computeChecksum(rewritten)
+	reopened.configStore.Save(ctx, "app", rewritten)
+	if _, err := reopened.GetChannel(ctx, "app", "canary"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
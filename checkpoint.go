@@ -0,0 +1,140 @@
+package viracochan
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrInvalidCheckpoint is returned by VerifyFromCheckpoint for a checkpoint
+// that is forged, signed by an untrusted key, or does not match the journal
+var ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+
+// Checkpoint is a signed statement that version Version of config ID, with
+// checksum CS, was the head of a chain that validated when it was made.
+// Every checksum covers the prev_cs before it, so CS commits to the whole
+// history up to Version and the checkpoint stays this small however long
+// that history is. Keep it outside the storage it attests, where whoever
+// can rewrite the journal cannot rewrite it too.
+type Checkpoint struct {
+	ID        string    `json:"id"`
+	Version   uint64    `json:"v"`
+	CS        string    `json:"cs"`
+	Time      time.Time `json:"t"`
+	PublicKey string    `json:"public_key"`
+	Signature string    `json:"sig"`
+}
+
+// Verify checks that the checkpoint was signed by its PublicKey.
+func (cp *Checkpoint) Verify() error {
+	hash := cp.signingHash()
+	if err := verifyHash(hash[:], cp.Signature, cp.PublicKey); err != nil {
+		return fmt.Errorf("checkpoint of %q v%d: %w", cp.ID, cp.Version, err)
+	}
+	return nil
+}
+
+func (cp *Checkpoint) signingHash() [32]byte {
+	payload := fmt.Sprintf("viracochan:checkpoint:v1:%s:%d:%s:%s:%s",
+		cp.ID, cp.Version, cp.CS, cp.Time.UTC().Format(time.RFC3339Nano), cp.PublicKey)
+	return sha256.Sum256([]byte(payload))
+}
+
+// CreateCheckpoint validates the whole chain of id, as ValidateChain does,
+// and returns a checkpoint of its head signed by the manager's signer. It
+// needs WithSigner.
+func (m *Manager) CreateCheckpoint(ctx context.Context, id string) (Checkpoint, error) {
+	if err := m.validateID(id); err != nil {
+		return Checkpoint{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return Checkpoint{}, ErrClosed
+	}
+	if m.signer == nil {
+		return Checkpoint{}, errors.New("checkpoints need a signer")
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	if len(entries) == 0 {
+		return Checkpoint{}, fmt.Errorf("%w for %q", ErrNoJournalEntries, id)
+	}
+	ordered, err := m.journal.Resequence(entries)
+	if err != nil {
+		return Checkpoint{}, m.chainFailure("create_checkpoint", err)
+	}
+	if err := m.chainFailure("create_checkpoint", m.validateOrdered(ctx, id, ordered)); err != nil {
+		return Checkpoint{}, err
+	}
+
+	head := ordered[len(ordered)-1]
+	cp := Checkpoint{
+		ID:        id,
+		Version:   head.Version,
+		CS:        head.CS,
+		Time:      time.Now().UTC(),
+		PublicKey: m.signer.PublicKey(),
+	}
+	hash := cp.signingHash()
+	if cp.Signature, err = m.signer.signHash(hash[:]); err != nil {
+		return Checkpoint{}, err
+	}
+	return cp, nil
+}
+
+// VerifyFromCheckpoint validates the chain of id from the version cp
+// attests, trusting everything before it: only the entries from cp.Version
+// on are checked, so a long history verifies in time proportional to what
+// was written since. cp must be for id, correctly signed by one of
+// the manager's trusted keys (WithVerifyKeys, or the signer's own key), and
+// match the journal entry at its version; otherwise the error wraps
+// ErrInvalidCheckpoint. A broken chain after it wraps ErrInvalidChain as in
+// ValidateChain.
+func (m *Manager) VerifyFromCheckpoint(ctx context.Context, id string, cp Checkpoint) error {
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+	if cp.ID != id {
+		return fmt.Errorf("%w: checkpoint is of %q, not %q", ErrInvalidCheckpoint, cp.ID, id)
+	}
+	if err := cp.Verify(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCheckpoint, err)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	if !slices.Contains(m.defaultTrustedKeys(), cp.PublicKey) {
+		return fmt.Errorf("%w: checkpoint of %q v%d is signed by untrusted key %s", ErrInvalidCheckpoint, id, cp.Version, cp.PublicKey)
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(entries, func(e *JournalEntry) bool { return e.Version == cp.Version && e.CS == cp.CS }) {
+		return m.chainFailure("verify_checkpoint", fmt.Errorf("%w: journal has no %q v%d with checksum %s",
+			ErrInvalidCheckpoint, id, cp.Version, cp.CS))
+	}
+	ordered, err := m.journal.ResequenceFrom(entries, cp.Version)
+	if err != nil {
+		return m.chainFailure("verify_checkpoint", err)
+	}
+	if ordered[0].CS != cp.CS {
+		return m.chainFailure("verify_checkpoint", fmt.Errorf("%w: %q v%d chain continues from checksum %s, checkpoint attests %s",
+			ErrInvalidCheckpoint, id, cp.Version, ordered[0].CS, cp.CS))
+	}
+	return m.chainFailure("verify_checkpoint", m.journal.ValidateChain(ordered))
+}
@@ -0,0 +1,113 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for i := 2; i <= 3; i++ {
+		if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	cp, err := manager.CreateCheckpoint(ctx, "app")
+	if err != nil {
+		t.Fatalf("CreateCheckpoint failed: %v", err)
+	}
+	if cp.Version != 3 || cp.Verify() != nil {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+	for i := 4; i <= 5; i++ {
+		if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	if err := manager.VerifyFromCheckpoint(ctx, "app", cp); err != nil {
+		t.Fatalf("VerifyFromCheckpoint failed: %v", err)
+	}
+
+	entries, _ := manager.journal.ReadAll(ctx)
+	tamper := func(version uint64) {
+		t.Helper()
+		var out []*JournalEntry
+		for _, e := range entries {
+			c := *e
+			if c.Version == version {
+				c.CS = "0000"
+			}
+			out = append(out, &c)
+		}
+		if err := manager.journal.Rewrite(ctx, out); err != nil {
+			t.Fatalf("Rewrite failed: %v", err)
+		}
+	}
+
+	// History before the checkpoint is trusted, not re-read
+	tamper(1)
+	if err := manager.VerifyFromCheckpoint(ctx, "app", cp); err != nil {
+		t.Errorf("tampering before the checkpoint should not matter, got %v", err)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err == nil {
+		t.Error("expected the full chain to fail")
+	}
+
+	tamper(3)
+	if err := manager.VerifyFromCheckpoint(ctx, "app", cp); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("expected ErrInvalidCheckpoint for a rewritten checkpoint entry, got %v", err)
+	}
+
+	tamper(4)
+	if err := manager.VerifyFromCheckpoint(ctx, "app", cp); err == nil || errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("expected a chain failure after the checkpoint, got %v", err)
+	}
+}
+
+func TestCheckpointForged(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	cp, err := manager.CreateCheckpoint(ctx, "app")
+	if err != nil {
+		t.Fatalf("CreateCheckpoint failed: %v", err)
+	}
+
+	moved := cp
+	moved.Version = 1
+	if err := manager.VerifyFromCheckpoint(ctx, "app", moved); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("expected ErrInvalidCheckpoint for a modified checkpoint, got %v", err)
+	}
+	if err := manager.VerifyFromCheckpoint(ctx, "other", cp); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("expected ErrInvalidCheckpoint for another id, got %v", err)
+	}
+
+	// Correctly signed, but by a key the manager does not trust
+	rogue, _ := NewSigner()
+	forged := cp
+	forged.PublicKey = rogue.PublicKey()
+	hash := forged.signingHash()
+	forged.Signature, _ = rogue.signHash(hash[:])
+	if err := forged.Verify(); err != nil {
+		t.Fatalf("forged checkpoint should be well signed: %v", err)
+	}
+	if err := manager.VerifyFromCheckpoint(ctx, "app", forged); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("expected ErrInvalidCheckpoint for an untrusted signer, got %v", err)
+	}
+
+	unsigned, _ := NewManager(NewMemoryStorage())
+	unsigned.Create(ctx, "app", map[string]interface{}{"n": 1})
+	if _, err := unsigned.CreateCheckpoint(ctx, "app"); err == nil {
+		t.Error("expected CreateCheckpoint without a signer to fail")
+	}
+}
@@ -0,0 +1,89 @@
+package viracochan
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// WithClockSkewTolerance lets a version's timestamp fall up to d before its
+// predecessor's without failing chain validation, for histories written or
+// imported from hosts whose clocks disagree. It applies to ValidateChain,
+// ValidateChainFrom, Reconstruct, FirstInvalid, Audit and the import paths;
+// a regression larger than d is still rejected. New versions written by this
+// manager are unaffected.
+//
+// Monotonic timestamps are part of what the chain proves: they order the
+// versions in time independently of the checksums. With a tolerance, a
+// version can claim to predate its predecessor by up to d, so timestamps
+// within that window no longer establish which of two versions came first.
+// Keep d as small as the skew actually observed, and prefer zero (the
+// default) for chains written by a single clock.
+func WithClockSkewTolerance(d time.Duration) ManagerOption {
+	return func(m *Manager) error {
+		if d < 0 {
+			return errors.New("clock skew tolerance must not be negative")
+		}
+		m.clockSkew = d
+		return nil
+	}
+}
+
+// SetClockSkewTolerance lets ValidateChain accept an entry up to d older than
+// its predecessor; see WithClockSkewTolerance
+func (j *Journal) SetClockSkewTolerance(d time.Duration) {
+	j.clockSkew = max(d, 0)
+}
+
+// timeRegressed reports whether t falls more than skew before prev
+func timeRegressed(t, prev time.Time, skew time.Duration) bool {
+	return t.Before(prev.Add(-skew))
+}
+
+// versionClock stamps the versions written by this process; see UpdateMeta
+var versionClock = &monotonicClock{now: time.Now}
+
+// monotonicClock issues wall-clock timestamps that never go backwards. Each
+// stamp is later than both the previous stamp it issued and the predecessor
+// it is given. While the wall clock reads later than that it is used as is.
+// When it reads earlier, as after an NTP step, stamps advance from the last
+// one by the time elapsed on the monotonic clock instead, so their spacing
+// stays true until the wall clock catches up.
+//
+// The monotonic clock only orders events within one process. Across
+// processes ordering rests on the predecessor: a version is always stamped
+// after the head it extends, even when that head came from a host whose
+// clock runs ahead. Versions written concurrently on different hosts, as in
+// a fork, are ordered by nothing but their wall clocks; that is what
+// WithClockSkewTolerance is for.
+type monotonicClock struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	last    time.Time // last stamp issued
+	reading time.Time // now() when it was issued, with its monotonic reading
+}
+
+// stamp returns the timestamp for a new version succeeding one stamped prev
+func (c *monotonicClock) stamp(prev time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reading := c.now()
+	t := reading.UTC().Truncate(time.Microsecond)
+	floor := c.last
+	if prev.After(floor) {
+		floor = prev.UTC()
+	}
+	if !t.After(floor) {
+		t = floor.Add(time.Microsecond)
+		if !c.reading.IsZero() {
+			if resumed := c.last.Add(reading.Sub(c.reading)).Truncate(time.Microsecond); resumed.After(t) {
+				t = resumed
+			}
+		}
+	}
+
+	c.last, c.reading = t, reading
+	return t
+}
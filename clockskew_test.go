@@ -0,0 +1,159 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// skewedPair returns v1 and a v2 stamped skew before it
+func skewedPair(t *testing.T, skew time.Duration) (v1, v2 []byte) {
+	t.Helper()
+	cfg1 := &Config{Content: json.RawMessage(`{"v":1}`)}
+	cfg1.UpdateMeta()
+	cfg2 := &Config{Meta: cfg1.Meta, Content: json.RawMessage(`{"v":2}`)}
+	cfg2.UpdateMeta()
+	cfg2.Meta.Time = cfg1.Meta.Time.Add(-skew)
+	cs, err := computeChecksum(cfg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg2.Meta.CS = cs
+
+	v1, _ = json.Marshal(cfg1)
+	v2, _ = json.Marshal(cfg2)
+	return v1, v2
+}
+
+func TestClockSkewTolerance(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := NewManager(NewMemoryStorage(), WithClockSkewTolerance(-time.Second)); err == nil {
+		t.Error("expected error for a negative tolerance")
+	}
+
+	v1, v2 := skewedPair(t, 2*time.Second)
+
+	strict, _ := NewManager(NewMemoryStorage())
+	strict.Import(ctx, "app", v1)
+	if err := strict.Import(ctx, "app", v2); err == nil {
+		t.Error("expected a strict manager to reject the regression")
+	}
+
+	tolerant, _ := NewManager(NewMemoryStorage(), WithClockSkewTolerance(5*time.Second))
+	if err := tolerant.Import(ctx, "app", v1); err != nil {
+		t.Fatalf("Import v1 failed: %v", err)
+	}
+	if err := tolerant.Import(ctx, "app", v2); err != nil {
+		t.Fatalf("Import within tolerance failed: %v", err)
+	}
+	if err := tolerant.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("ValidateChain within tolerance failed: %v", err)
+	}
+	if v, _, _ := tolerant.FirstInvalid(ctx, "app"); v != 0 {
+		t.Errorf("expected no invalid version, got v%d", v)
+	}
+	if cfg, err := tolerant.Reconstruct(ctx, "app"); err != nil || cfg.Meta.Version != 2 {
+		t.Errorf("Reconstruct within tolerance: %v", err)
+	}
+
+	v1, v2 = skewedPair(t, time.Hour)
+	tolerant.Import(ctx, "gross", v1)
+	if err := tolerant.Import(ctx, "gross", v2); err == nil {
+		t.Error("expected a regression beyond the tolerance to be rejected")
+	}
+}
+
+func TestClockSkewFirstInvalidAgreesWithValidateChain(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	v1, v2 := skewedPair(t, 2*time.Second)
+
+	// Store the regression through a manager that tolerates it
+	writer, _ := NewManager(storage, WithClockSkewTolerance(time.Hour))
+	writer.Import(ctx, "app", v1)
+	if err := writer.Import(ctx, "app", v2); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		skew  time.Duration
+		valid bool
+	}{
+		{0, false},
+		{time.Second, false},
+		{2 * time.Second, true},
+		{5 * time.Second, true},
+	} {
+		manager, _ := NewManager(storage, WithClockSkewTolerance(tc.skew))
+		chainErr := manager.ValidateChain(ctx, "app")
+		v, reason, err := manager.FirstInvalid(ctx, "app")
+		if err != nil {
+			t.Fatalf("FirstInvalid with skew %v failed: %v", tc.skew, err)
+		}
+		if (chainErr == nil) != tc.valid || (v == 0) != tc.valid {
+			t.Errorf("skew %v: ValidateChain %v, FirstInvalid v%d %q; want valid=%v", tc.skew, chainErr, v, reason, tc.valid)
+		}
+		if !tc.valid && v != 2 {
+			t.Errorf("skew %v: expected v2 flagged, got v%d", tc.skew, v)
+		}
+	}
+}
+
+func TestMonotonicClock(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	readings := []time.Time{
+		t0,
+		t0,                        // same microsecond
+		t0.Add(time.Second),       //
+		t0.Add(-10 * time.Second), // stepped back 11s
+		t0.Add(-9 * time.Second),  // one second later
+		t0.Add(5 * time.Second),   // caught up
+		t0.Add(6 * time.Second),
+	}
+	next := 0
+	clock := &monotonicClock{now: func() time.Time { next++; return readings[next-1] }}
+
+	want := []time.Time{
+		t0,
+		t0.Add(time.Microsecond),
+		t0.Add(time.Second),
+		t0.Add(time.Second + time.Microsecond),
+		t0.Add(2*time.Second + time.Microsecond),
+		t0.Add(5 * time.Second),
+	}
+	var prev time.Time
+	for i, w := range want {
+		got := clock.stamp(prev)
+		if !got.Equal(w) {
+			t.Errorf("stamp %d: expected %s, got %s", i, w, got)
+		}
+		prev = got
+	}
+
+	// A predecessor from a clock running ahead is still succeeded
+	ahead := t0.Add(time.Hour)
+	if got := clock.stamp(ahead); !got.After(ahead) {
+		t.Errorf("expected a stamp after %s, got %s", ahead, got)
+	}
+}
+
+func TestUpdateMetaMonotonic(t *testing.T) {
+	saved := versionClock
+	versionClock = &monotonicClock{now: time.Now}
+	defer func() { versionClock = saved }()
+
+	cfg := &Config{Content: json.RawMessage(`{}`)}
+	cfg.UpdateMeta()
+
+	// A predecessor stamped in the future, as by a host whose clock runs
+	// ahead, does not make the next version regress
+	cfg.Meta.Time = time.Now().Add(time.Minute).UTC().Truncate(time.Microsecond)
+	cfg.Meta.CS, _ = computeChecksum(cfg)
+	prev := *cfg
+	cfg.UpdateMeta()
+	if err := cfg.NextOf(&prev); err != nil {
+		t.Errorf("expected the next version to chain despite the future predecessor: %v", err)
+	}
+}
@@ -100,33 +100,37 @@ func allCompliant(flags map[string]bool) bool {
 	return true
 }
 
-type ComplianceChecker struct {
-	rules map[string]func(*viracochan.Config) bool
+// shortCS abbreviates a checksum for display; the empty prev_cs of v1 stays
+// visible as "-"
+func shortCS(cs string) string {
+	if len(cs) < 8 {
+		return "-"
+	}
+	return cs[:8]
 }
 
-func NewComplianceChecker() *ComplianceChecker {
-	return &ComplianceChecker{
-		rules: map[string]func(*viracochan.Config) bool{
-			"has_signature": func(cfg *viracochan.Config) bool {
-				return cfg.Meta.Signature != ""
-			},
-			"valid_checksum": func(cfg *viracochan.Config) bool {
-				return cfg.Validate() == nil
-			},
-			"recent_update": func(cfg *viracochan.Config) bool {
-				return time.Since(cfg.Meta.Time) < 30*24*time.Hour
-			},
-			"version_continuity": func(cfg *viracochan.Config) bool {
-				return cfg.Meta.Version > 0
-			},
-		},
+// compliancePolicies are enforced by every actor's manager: an unsigned write
+// is rejected outright, the other rules feed the compliance report
+func compliancePolicies() []viracochan.Policy {
+	signed := viracochan.HasSignaturePolicy()
+	signed.Critical = true
+	return []viracochan.Policy{
+		signed,
+		viracochan.ValidChecksumPolicy(),
+		viracochan.RecentUpdatePolicy(30 * 24 * time.Hour),
+		viracochan.VersionContinuityPolicy(),
 	}
 }
 
+// ComplianceChecker reports a config against the manager's policies
+type ComplianceChecker struct {
+	manager *viracochan.Manager
+}
+
 func (c *ComplianceChecker) Check(cfg *viracochan.Config) map[string]bool {
 	results := make(map[string]bool)
-	for name, rule := range c.rules {
-		results[name] = rule(cfg)
+	for name, result := range c.manager.EvaluatePolicies(cfg) {
+		results[name] = result.Pass
 	}
 	return results
 }
@@ -154,7 +158,6 @@ func main() {
 	}
 
 	auditLog := NewAuditLog(storage)
-	complianceChecker := NewComplianceChecker()
 
 	// Create signers for different actors
 	signers := make([]*viracochan.Signer, *actors)
@@ -177,10 +180,13 @@ func main() {
 		storage,
 		viracochan.WithSigner(signers[0]),
 		viracochan.WithJournalPath("actor-1.journal"),
+		viracochan.WithPolicies(compliancePolicies()),
+		viracochan.WithRequireSignature(), // no unsigned change enters the trail
 	)
 	if err != nil {
 		log.Fatal("Failed to create manager:", err)
 	}
+	complianceChecker := &ComplianceChecker{manager: manager1}
 
 	configID := "compliance-config"
 	initialConfig := map[string]interface{}{
@@ -232,6 +238,8 @@ func main() {
 			storage,
 			viracochan.WithSigner(signers[i]),
 			viracochan.WithJournalPath(fmt.Sprintf("actor-%d.journal", i+1)),
+			viracochan.WithPolicies(compliancePolicies()),
+			viracochan.WithRequireSignature(),
 		)
 		if err != nil {
 			log.Fatal("Failed to create manager:", err)
@@ -333,7 +341,8 @@ func main() {
 		content["last_modified_at"] = time.Now().UTC().Format(time.RFC3339)
 
 		// Update configuration
-		newCfg, err := managers[update.actor].Update(ctx, configID, content)
+		newCfg, err := managers[update.actor].Update(ctx, configID, content,
+			viracochan.WithMessage(update.description))
 		if err != nil {
 			fmt.Printf("✗ %s failed to update: %v\n", actorNames[update.actor], err)
 			continue
@@ -469,6 +478,30 @@ func main() {
 		}
 	}
 
+	// The same per-version view straight from the journal, in one call
+	fmt.Println("\nVersion table:")
+	candidateKeys := make([]string, len(signers))
+	signerNames := make(map[string]string, len(signers))
+	for i, signer := range signers {
+		candidateKeys[i] = signer.PublicKey()
+		signerNames[signer.PublicKey()] = actorNames[i]
+	}
+	rows, err := managers[0].VersionTable(ctx, configID, candidateKeys)
+	if err != nil {
+		log.Fatal("Failed to build version table:", err)
+	}
+	for _, row := range rows {
+		signedBy := "unsigned"
+		switch {
+		case row.Signer != "":
+			signedBy = signerNames[row.Signer]
+		case row.Signed:
+			signedBy = "unknown key"
+		}
+		fmt.Printf("  v%-3d %s  %-16s cs=%s prev=%s  %s\n", row.Version, row.Time.Format(time.RFC3339),
+			row.Op, shortCS(row.CS), shortCS(row.PrevCS), signedBy)
+	}
+
 	// Phase 5: Compliance Report
 	fmt.Println("\n--- Phase 5: Compliance Analysis ---")
 
@@ -525,6 +558,47 @@ func main() {
 		}
 	}
 
+	// The timeline above trusts the writers' clocks; anchor it to an authority
+	fmt.Println("\nAnchored Timestamps:")
+	tsaKey, err := viracochan.NewSigner()
+	if err != nil {
+		log.Fatal("Failed to create timestamp authority key:", err)
+	}
+	tsa := viracochan.NewSignerTimestampAuthority(tsaKey)
+	anchored, _ := manager1.GetHistory(ctx, configID)
+	for _, version := range anchored {
+		v := version.Meta.Version
+		if err := manager1.Countersign(ctx, configID, v, tsa); err != nil {
+			fmt.Printf("  ✗ v%d countersign failed: %v\n", v, err)
+			continue
+		}
+		cfg, err := manager1.Get(ctx, configID, v)
+		if err != nil {
+			fmt.Printf("  ✗ v%d reload failed: %v\n", v, err)
+			continue
+		}
+		var cs *viracochan.Countersignature
+		for _, signer := range signers {
+			if cs, err = viracochan.VerifyCountersigned(cfg, signer.PublicKey(), tsa); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			fmt.Printf("  ✗ v%d: %v\n", v, err)
+			continue
+		}
+		fmt.Printf("  ✓ v%d claimed %s, attested by %s... at %s\n", v,
+			cfg.Meta.Time.Format("15:04:05.000000"), cs.Authority[:8], cs.Time.Format("15:04:05.000000"))
+	}
+
+	// Changelog derived from the stored history rather than the manual log
+	fmt.Println("\nGenerated Changelog:")
+	if changelog, err := manager1.Changelog(ctx, configID); err != nil {
+		fmt.Printf("  ✗ Changelog failed: %v\n", err)
+	} else if err := viracochan.RenderChangelog(os.Stdout, changelog, nil); err != nil {
+		fmt.Printf("  ✗ Rendering changelog failed: %v\n", err)
+	}
+
 	// Phase 7: Export Audit Report
 	fmt.Println("\n--- Phase 7: Audit Report Generation ---")
 
@@ -551,6 +625,37 @@ func main() {
 	fmt.Printf("Total Configurations Audited: %d\n", len(history))
 	fmt.Printf("Total Audit Events: %d\n", len(auditLog.Events))
 
+	trustedKeys := make([]string, len(signers))
+	for i, signer := range signers {
+		trustedKeys[i] = signer.PublicKey()
+	}
+	storeAudit, err := manager1.Audit(ctx, trustedKeys...)
+	if err != nil {
+		log.Fatal("Failed to audit store:", err)
+	}
+	fmt.Printf("Store Audit: %d ids, %d journal entries, %d config files, %d signatures verified\n",
+		storeAudit.IDs, storeAudit.Entries, storeAudit.Files, storeAudit.Signed)
+	for _, issue := range storeAudit.Issues {
+		fmt.Printf("  ✗ %s v%d: %s (%s)\n", issue.ID, issue.Version, issue.Kind, issue.Detail)
+	}
+
+	signatures, err := manager1.VerifyAllSignatures(ctx, trustedKeys)
+	if err != nil {
+		log.Fatal("Failed to verify signatures:", err)
+	}
+	fmt.Printf("Signature Coverage: %d/%d versions validly signed (%d unsigned, %d invalid, %d unreadable)\n",
+		signatures.Valid, signatures.Versions, signatures.Unsigned, signatures.Invalid, signatures.Unreadable)
+	for _, issue := range signatures.Issues {
+		fmt.Printf("  ✗ %s v%d: %s\n", issue.ID, issue.Version, issue.Kind)
+	}
+	signatureFile := filepath.Join(*dataDir, "signature-report.json")
+	if data, err := json.MarshalIndent(signatures, "", "  "); err != nil {
+		log.Fatal("Failed to marshal signature report:", err)
+	} else if err := os.WriteFile(signatureFile, data, 0o600); err != nil {
+		log.Fatal("Failed to save signature report:", err)
+	}
+	fmt.Printf("Signature Report: %s\n", signatureFile)
+
 	compliantCount := 0
 	for _, event := range auditLog.Events {
 		if event.Verified && allCompliant(event.ComplianceFlags) {
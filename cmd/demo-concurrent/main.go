@@ -5,6 +5,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -149,6 +150,8 @@ func main() {
 		duration   = flag.Duration("duration", 10*time.Second, "test duration")
 		strategy   = flag.String("strategy", "merge", "conflict resolution strategy")
 		updateRate = flag.Duration("rate", 200*time.Millisecond, "update rate per worker")
+		syncEvery  = flag.Duration("sync-interval", 0, "journal group commit interval (0 syncs every append)")
+		maxEntries = flag.Int("max-journal-entries", 0, "compact each worker's journal past this many entries (0 for no cap)")
 	)
 	flag.Parse()
 
@@ -158,8 +161,8 @@ func main() {
 	os.RemoveAll(*dataDir)
 
 	fmt.Println("=== Concurrent Operations Demo ===")
-	fmt.Printf("Workers: %d, Duration: %v, Strategy: %s\n\n",
-		*workers, *duration, *strategy)
+	fmt.Printf("Workers: %d, Duration: %v, Strategy: %s, Journal sync: %s\n\n",
+		*workers, *duration, *strategy, syncMode(*syncEvery))
 
 	// Initialize storage
 	storage, err := viracochan.NewFileStorage(*dataDir)
@@ -180,11 +183,17 @@ func main() {
 			log.Fatal("Failed to create signer:", err)
 		}
 
-		manager, err := viracochan.NewManager(
-			storage,
+		opts := []viracochan.ManagerOption{
 			viracochan.WithSigner(signer),
 			viracochan.WithJournalPath(fmt.Sprintf("worker-%d.journal", i)),
-		)
+			viracochan.WithJournalSyncInterval(*syncEvery),
+		}
+		if *maxEntries > 0 {
+			// A hard ceiling against runaway growth; a journal that cannot be
+			// compacted under it is only warned about
+			opts = append(opts, viracochan.WithMaxJournalEntries(*maxEntries, viracochan.JournalCapWarn))
+		}
+		manager, err := viracochan.NewManager(storage, opts...)
 		if err != nil {
 			log.Fatal("Failed to create manager:", err)
 		}
@@ -226,15 +235,15 @@ func main() {
 	defer watchCancel()
 
 	// Each worker watches for changes
-	watchers := make([]<-chan *viracochan.Config, *workers)
+	watchersDone := make([]<-chan struct{}, *workers)
 	for i, worker := range workerList {
-		ch, err := worker.Manager.Watch(watchCtx, configID, 100*time.Millisecond)
+		ch, done, err := worker.Manager.WatchWithDone(watchCtx, configID, 100*time.Millisecond)
 		if err != nil {
 			log.Printf("Failed to setup watch: %v", err)
 			watchCancel()
 			return
 		}
-		watchers[i] = ch
+		watchersDone[i] = done
 
 		// Start watcher goroutine
 		go func(w *Worker, ch <-chan *viracochan.Config) {
@@ -355,8 +364,8 @@ func main() {
 	if err != nil {
 		fmt.Printf("Worker-1 update failed (expected): %v\n", err)
 
-		// Resolve conflict
-		latest, _ := workerList[1].Manager.GetLatest(ctx, configID)
+		// Resolve conflict against what storage holds, not the cached head
+		latest, _ := workerList[1].Manager.GetLatestFresh(ctx, configID)
 		resolved, resolution := resolver.Resolve(workerList[0], workerList[1], latest, &viracochan.Config{
 			Meta:    current1.Meta,
 			Content: mustMarshal(content1),
@@ -435,6 +444,39 @@ func main() {
 		fmt.Printf("⚠ Checksum divergence detected: %d different checksums\n", len(checksums))
 	}
 
+	// Head probe: lag behind the most advanced worker, and forks (same
+	// version, different checksum)
+	fmt.Println("\nHead probe:")
+	type head struct {
+		version uint64
+		cs      string
+	}
+	heads := make([]head, len(workerList))
+	var newest uint64
+	for i, worker := range workerList {
+		v, cs, err := worker.Manager.HeadVersion(ctx, configID)
+		if err != nil {
+			fmt.Printf("  %s: ERROR - %v\n", worker.Name, err)
+			continue
+		}
+		heads[i] = head{version: v, cs: cs}
+		newest = max(newest, v)
+	}
+	for i, worker := range workerList {
+		h := heads[i]
+		switch {
+		case h.cs == "":
+			continue
+		case h.version < newest:
+			fmt.Printf("  %s: v%d, %d behind\n", worker.Name, h.version, newest-h.version)
+		case h.cs != heads[0].cs && h.version == heads[0].version:
+			fmt.Printf("  %s: v%d ⚠ forked from %s\n", worker.Name, h.version, workerList[0].Name)
+			reportSplit(ctx, storage, workerList[0], worker, configID)
+		default:
+			fmt.Printf("  %s: v%d, up to date\n", worker.Name, h.version)
+		}
+	}
+
 	// Phase 7: Chain validation
 	fmt.Println("\n--- Phase 7: Chain Validation ---")
 
@@ -469,8 +511,12 @@ func main() {
 		totalConflicts += conflicts
 		totalResolved += resolved
 
-		fmt.Printf("%s: %d updates, %d conflicts, %d resolved\n",
-			w.Name, updates, conflicts, resolved)
+		entries := "?"
+		if status, err := w.Manager.Status(ctx); err == nil {
+			entries = fmt.Sprint(status.JournalEntries)
+		}
+		fmt.Printf("%s: %d updates, %d conflicts, %d resolved, %s journal entries\n",
+			w.Name, updates, conflicts, resolved, entries)
 	}
 
 	fmt.Printf("\nTotals:\n")
@@ -485,11 +531,27 @@ func main() {
 
 	// Cancel watchers
 	watchCancel()
-	time.Sleep(200 * time.Millisecond) // Allow goroutines to clean up
+	for _, done := range watchersDone {
+		<-done
+	}
+
+	// Close flushes journal appends a group commit has not synced yet
+	for _, worker := range workerList {
+		if err := worker.Manager.Close(); err != nil {
+			fmt.Printf("  %s: close failed: %v\n", worker.Name, err)
+		}
+	}
 
 	fmt.Println("\n✓ Concurrent operations demo completed")
 }
 
+func syncMode(interval time.Duration) string {
+	if interval == 0 {
+		return "every append"
+	}
+	return fmt.Sprintf("group commit every %s", interval)
+}
+
 func performUpdate(ctx context.Context, w *Worker, configID string, conflictChan chan<- struct{}) error {
 	// Get current config
 	current, err := w.Manager.GetLatest(ctx, configID)
@@ -552,3 +614,36 @@ func performUpdate(ctx context.Context, w *Worker, configID string, conflictChan
 
 	return nil
 }
+
+// reportSplit locates where two workers' histories of id diverged. The
+// workers share config files, so each history is taken from the configs
+// embedded in the worker's own journal.
+func reportSplit(ctx context.Context, storage viracochan.Storage, a, b *Worker, id string) {
+	historyA, errA := journaledHistory(ctx, storage, a, id)
+	historyB, errB := journaledHistory(ctx, storage, b, id)
+	if err := errors.Join(errA, errB); err != nil {
+		fmt.Printf("    cannot compare histories: %v\n", err)
+		return
+	}
+	report, err := viracochan.CompareHistories(historyA, historyB)
+	if err != nil {
+		fmt.Printf("    cannot compare histories: %v\n", err)
+		return
+	}
+	fmt.Printf("    split after v%d: %d versions only on %s, %d only on %s\n",
+		report.CommonVersion, len(report.ATail), a.Name, len(report.BTail), b.Name)
+}
+
+func journaledHistory(ctx context.Context, storage viracochan.Storage, w *Worker, id string) ([]*viracochan.Config, error) {
+	entries, err := viracochan.NewJournal(storage, fmt.Sprintf("worker-%d.journal", w.ID)).FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var history []*viracochan.Config
+	for _, entry := range entries {
+		if entry.Config != nil {
+			history = append(history, entry.Config)
+		}
+	}
+	return history, nil
+}
@@ -5,6 +5,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -185,14 +186,22 @@ func main() {
 	// Phase 2: Simulate disasters
 	fmt.Println("\n--- Phase 2: Simulating Disasters ---")
 
+	// Locate the files to damage through the manager rather than assuming
+	// the default layout
+	configFiles, journalPath, err := manager.StoragePaths(ctx, configID)
+	if err != nil {
+		log.Fatal("Failed to locate storage paths:", err)
+	}
+	fmt.Printf("✓ %d version files, journal at %s\n", len(configFiles), journalPath)
+
 	if *chaos {
 		// Disaster 1: Corrupt journal entries
 		fmt.Println("\n[Disaster 1] Corrupting journal entries...")
-		corruptJournal(filepath.Join(*dataDir, "primary.journal"))
+		corruptJournal(filepath.Join(*dataDir, journalPath))
 
 		// Disaster 2: Delete random config files
 		fmt.Println("[Disaster 2] Deleting random configuration files...")
-		deleteRandomConfigs(*dataDir, configID)
+		deleteRandomConfigs(*dataDir, configFiles)
 
 		// Disaster 3: Create duplicate/conflicting entries
 		fmt.Println("[Disaster 3] Creating duplicate journal entries...")
@@ -222,11 +231,34 @@ func main() {
 		} else {
 			fmt.Printf("⚠ Partial read successful: v%d\n", latest.Meta.Version)
 		}
+
+		// Assess the damage before touching anything
+		if report, err := manager2.SelfTest(ctx); err != nil {
+			fmt.Printf("✗ Self-test failed: %v\n", err)
+		} else {
+			counts := make(map[viracochan.Severity]int)
+			for _, f := range report.Findings {
+				counts[f.Severity]++
+			}
+			fmt.Printf("Self-test: worst=%s, %d critical, %d warnings, %d info\n", report.Worst(),
+				counts[viracochan.SeverityCritical], counts[viracochan.SeverityWarning], counts[viracochan.SeverityInfo])
+			for _, f := range report.Findings {
+				if f.Severity == viracochan.SeverityCritical && f.Check != viracochan.CheckAudit {
+					fmt.Printf("  %s %s v%d: %s\n", f.Check, f.ID, f.Version, f.Detail)
+				}
+			}
+		}
 	}
 
 	// Recovery 2: Read journal directly and attempt resequencing
 	fmt.Println("\n[Recovery 2] Direct journal reconstruction...")
 	journal := viracochan.NewJournal(storage, "primary.journal")
+	if dedup, err := journal.Dedup(ctx); err != nil {
+		fmt.Printf("✗ Dedup failed: %v\n", err)
+	} else {
+		fmt.Printf("Dedup: %d duplicates removed, %d forked entries quarantined, %d forks unresolved\n",
+			dedup.Removed, dedup.Quarantined, dedup.Unresolved)
+	}
 	entries, err := journal.ReadAll(ctx)
 	if err != nil {
 		fmt.Printf("✗ Failed to read journal: %v\n", err)
@@ -246,6 +278,13 @@ func main() {
 		ordered, err := journal.Resequence(validEntries)
 		if err != nil {
 			fmt.Printf("⚠ Resequence warning: %v\n", err)
+			var heads *viracochan.ErrMultipleHeads
+			if errors.As(err, &heads) {
+				fmt.Println("Competing chain heads:")
+				for i, cs := range heads.Heads {
+					fmt.Printf("  [%d] v%d %s\n", i, heads.Versions[i], cs[:min(len(cs), 16)])
+				}
+			}
 			// Try to recover what we can
 			if len(validEntries) > 0 {
 				fmt.Println("Attempting partial recovery from available entries...")
@@ -314,15 +353,7 @@ func main() {
 	rebuiltCount := 0
 	for v := uint64(1); v <= uint64(len(versions)); v++ {
 		if cfg, ok := allConfigs[v]; ok {
-			entry := &viracochan.JournalEntry{
-				ID:        configID,
-				Version:   cfg.Meta.Version,
-				CS:        cfg.Meta.CS,
-				PrevCS:    cfg.Meta.PrevCS,
-				Time:      cfg.Meta.Time,
-				Operation: "recovered",
-				Config:    cfg,
-			}
+			entry := viracochan.NewJournalEntry(configID, "recovered", cfg)
 			if err := recoveryJournal.Append(ctx, entry); err != nil {
 				fmt.Printf("  ✗ Failed to rebuild v%d: %v\n", v, err)
 			} else {
@@ -342,14 +373,38 @@ func main() {
 		storage,
 		viracochan.WithSigner(signer),
 		viracochan.WithJournalPath("recovery.journal"),
+		// Recovered histories may mix clocks; allow a little skew, not more
+		viracochan.WithClockSkewTolerance(2*time.Second),
 	)
 	if err != nil {
 		fmt.Printf("✗ Failed to create recovery manager: %v\n", err)
 	} else {
-		// Attempt final reconstruction
-		reconstructed, err := recoveryManager.Reconstruct(ctx, configID)
+		// Attempt final reconstruction, with feedback and a deadline
+		reconstructCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		reconstructed, err := recoveryManager.ReconstructWithProgress(reconstructCtx, configID, func(done, total int) {
+			fmt.Printf("  validated %d/%d journal entries\n", done, total)
+		})
+		cancel()
 		if err != nil {
 			fmt.Printf("✗ Reconstruction failed: %v\n", err)
+
+			// Escape hatch: start a fresh lineage from the newest recovered
+			// content, archiving the broken chain for forensics
+			var newest *viracochan.Config
+			for _, cfg := range allConfigs {
+				if newest == nil || cfg.Meta.Version > newest.Meta.Version {
+					newest = cfg
+				}
+			}
+			if newest != nil {
+				fresh, err := recoveryManager.Reset(ctx, configID, newest.Content, viracochan.ConfirmReset())
+				if err != nil {
+					fmt.Printf("✗ Reset failed: %v\n", err)
+				} else {
+					fmt.Printf("✓ Reset to a fresh v%d from recovered v%d (old chain archived)\n",
+						fresh.Meta.Version, newest.Meta.Version)
+				}
+			}
 		} else {
 			fmt.Printf("✓ Successfully reconstructed to v%d\n", reconstructed.Meta.Version)
 
@@ -435,12 +490,14 @@ func corruptJournal(path string) {
 	}
 }
 
-func deleteRandomConfigs(dataDir, configID string) {
-	configPath := filepath.Join(dataDir, "configs", configID)
-	files, err := filepath.Glob(filepath.Join(configPath, "v*.json"))
-	if err != nil || len(files) == 0 {
+func deleteRandomConfigs(dataDir string, configFiles []string) {
+	if len(configFiles) == 0 {
 		return
 	}
+	files := make([]string, len(configFiles))
+	for i, path := range configFiles {
+		files[i] = filepath.Join(dataDir, path)
+	}
 
 	// Delete 30-50% of files randomly
 	deleteCount := len(files) / 3
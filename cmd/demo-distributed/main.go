@@ -5,6 +5,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -75,7 +76,9 @@ func main() {
 		},
 	}
 
-	cfg, err := nodes[0].Manager.Create(ctx, "cluster-config", masterConfig)
+	// Every node may race to initialize the config at startup; CreateOrGet
+	// creates it once and hands the others the existing version
+	cfg, err := nodes[0].Manager.CreateOrGet(ctx, "cluster-config", masterConfig)
 	if err != nil {
 		log.Fatal("Failed to create config:", err)
 	}
@@ -144,16 +147,12 @@ func main() {
 			log.Fatal("Failed to unmarshal:", err)
 		}
 
-		// Import each version in order
+		// Import the history as one chain; a fresh node has nothing to conflict with
+		report, err := nodes[i].Manager.ImportHistory(ctx, "cluster-config", configs, viracochan.ConflictReject)
+		if err != nil {
+			log.Fatal("Failed to import history:", err)
+		}
 		for _, cfg := range configs {
-			data, _ := json.Marshal(cfg)
-			configID := fmt.Sprintf("cluster-config-v%d", cfg.Meta.Version)
-
-			if err := nodes[i].Manager.Import(ctx, configID, data); err != nil {
-				fmt.Printf("  ✗ Failed to import v%d: %v\n", cfg.Meta.Version, err)
-				continue
-			}
-
 			// Verify signature
 			if err := nodes[i].Manager.Verify(cfg, masterSigner.PublicKey()); err != nil {
 				fmt.Printf("  ✗ v%d signature verification failed: %v\n", cfg.Meta.Version, err)
@@ -162,13 +161,7 @@ func main() {
 					cfg.Meta.Version, cfg.Meta.CS[:8]+"...")
 			}
 		}
-
-		// Reconstruct the main config from imported versions
-		lastVersion := configs[len(configs)-1]
-		data, _ := json.Marshal(lastVersion)
-		if err := nodes[i].Manager.Import(ctx, "cluster-config", data); err != nil {
-			log.Fatal("Failed to import main config:", err)
-		}
+		fmt.Printf("  Imported %d versions\n", len(report.Imported))
 	}
 
 	// Verify chain integrity on all nodes
@@ -176,8 +169,8 @@ func main() {
 	for i, node := range nodes {
 		fmt.Printf("Node %d: ", i)
 
-		// Get the latest config
-		latest, err := node.Manager.GetLatest(ctx, "cluster-config")
+		// Read the latest config from disk rather than the import's cache
+		latest, err := node.Manager.GetLatestFresh(ctx, "cluster-config")
 		if err != nil {
 			fmt.Printf("✗ Failed to get latest: %v\n", err)
 			continue
@@ -222,20 +215,47 @@ func main() {
 		}
 	}
 
+	// Ship one config's change log rather than whole histories
+	fmt.Println("\n--- Per-ID Journal Sync ---")
+	masterJournal := viracochan.NewJournal(nodes[0].Storage, "journal.jsonl")
+	fragment, err := masterJournal.ExportID(ctx, "cluster-config")
+	if err != nil {
+		fmt.Printf("✗ Journal export failed: %v\n", err)
+	} else {
+		standby := viracochan.NewJournal(viracochan.NewMemoryStorage(), "journal.jsonl")
+		imported, err := standby.ImportID(ctx, fragment)
+		if err != nil {
+			fmt.Printf("✗ Journal import failed: %v\n", err)
+		} else {
+			again, _ := standby.ImportID(ctx, fragment)
+			fmt.Printf("✓ Standby imported %d entries (%d bytes); re-import added %d\n", imported, len(fragment), again)
+		}
+	}
+
 	// Demonstrate watch functionality
 	fmt.Println("\n--- Setting up Configuration Watch ---")
 	watchCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	// Start watching on last node
+	// Start watching on last node, waking only when the emergency block changes
 	watchNode := nodes[len(nodes)-1]
-	ch, err := watchNode.Manager.Watch(watchCtx, "cluster-config", 500*time.Millisecond)
+	// WatchErr surfaces a storage outage instead of looking like a quiet config
+	watcher, err := watchNode.Manager.WatchErr(watchCtx, "cluster-config", 500*time.Millisecond,
+		viracochan.WatchFilter(viracochan.FieldChanged("emergency")))
 	if err != nil {
 		log.Printf("Failed to setup watch: %v", err)
 		cancel()
 		return
 	}
 
+	// A subscriber on the master only needs what changed, not the whole config
+	diffs, err := nodes[0].Manager.WatchDiffs(watchCtx, "cluster-config", 500*time.Millisecond)
+	if err != nil {
+		log.Printf("Failed to setup diff watch: %v", err)
+		cancel()
+		return
+	}
+
 	fmt.Printf("%s watching for changes...\n", watchNode.ID)
 
 	// Make an update on master after a delay
@@ -260,9 +280,26 @@ func main() {
 		}
 	}()
 
+	// The master's subscriber sees the change set of its own write
+	select {
+	case d, ok := <-diffs:
+		if ok {
+			fmt.Printf("✓ Master diff subscriber got v%d → v%d:\n", d.FromVersion, d.ToVersion)
+			for _, c := range d.Changes {
+				fmt.Printf("  %s %s\n", c.Kind, c.Path)
+			}
+		}
+	case <-watchCtx.Done():
+		fmt.Println("Diff watch timeout")
+	}
+
 	// Wait for update
 	select {
-	case updated := <-ch:
+	case updated, ok := <-watcher.C:
+		if !ok {
+			fmt.Printf("✗ Watch stopped: %v\n", watcher.Err())
+			break
+		}
 		fmt.Printf("✓ %s detected update to v%d\n", watchNode.ID, updated.Meta.Version)
 
 		var content map[string]interface{}
@@ -276,6 +313,14 @@ func main() {
 		fmt.Println("Watch timeout")
 	}
 
+	// Two replicas update independently, then heal the fork
+	fmt.Println("\n--- Healing a Fork ---")
+	healFork(ctx, nodes[1], nodes[2], "cluster-config")
+
+	// The master's emergency update and node 2's own change diverged
+	fmt.Println("\n--- Policy-Driven Sync ---")
+	syncFromMaster(ctx, nodes[0], nodes[2], "cluster-config")
+
 	// Final statistics
 	fmt.Println("\n=== Final Statistics ===")
 	for i, node := range nodes {
@@ -295,6 +340,86 @@ func main() {
 	fmt.Println("\n✓ Distributed configuration demo completed successfully")
 }
 
+// healFork has a and b each write their own next version of id, as if
+// partitioned, then merges b's chain into a's with Reconcile
+func healFork(ctx context.Context, a, b *Node, id string) {
+	for _, side := range []struct {
+		node   *Node
+		change [2]string
+	}{{a, [2]string{"log_level", "debug"}}, {b, [2]string{"replicas", "5"}}} {
+		node, change := side.node, side.change
+		latest, err := node.Manager.GetLatest(ctx, id)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", node.ID, err)
+			return
+		}
+		var content map[string]interface{}
+		if err := json.Unmarshal(latest.Content, &content); err != nil {
+			log.Printf("Failed to unmarshal content: %v", err)
+		}
+		content[change[0]] = change[1]
+		cfg, err := node.Manager.Update(ctx, id, content)
+		if err != nil {
+			fmt.Printf("✗ %s update failed: %v\n", node.ID, err)
+			return
+		}
+		fmt.Printf("%s wrote v%d (cs: %s) setting %s\n", node.ID, cfg.Meta.Version, cfg.Meta.CS[:8]+"...", change[0])
+	}
+
+	other, err := b.Manager.GetHistory(ctx, id)
+	if err != nil {
+		fmt.Printf("✗ %s history: %v\n", b.ID, err)
+		return
+	}
+	merged, err := a.Manager.Reconcile(ctx, id, other)
+	if err != nil {
+		fmt.Printf("✗ Reconcile failed: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ %s merged %s's chain as v%d, parents %s and %s\n", a.ID, b.ID, merged.Meta.Version,
+		merged.Meta.MergeParents[0][:8]+"...", merged.Meta.MergeParents[1][:8]+"...")
+}
+
+// syncFromMaster pulls the master's history of id into replica, first
+// refusing to resolve a fork and then keeping both sides with ConflictFork
+func syncFromMaster(ctx context.Context, master, replica *Node, id string) {
+	history, err := master.Manager.GetHistory(ctx, id)
+	if err != nil {
+		fmt.Printf("✗ %s history: %v\n", master.ID, err)
+		return
+	}
+
+	report, err := replica.Manager.ImportHistory(ctx, id, history, viracochan.ConflictReject)
+	switch {
+	case errors.Is(err, viracochan.ErrHistoryConflict):
+		fmt.Printf("%s refused the import: histories forked after v%d\n", replica.ID, report.CommonVersion)
+		for _, c := range report.Conflicts {
+			fmt.Printf("  v%d local %s incoming %s\n", c.Version, shortCS(c.LocalCS), shortCS(c.IncomingCS))
+		}
+	case err != nil:
+		fmt.Printf("✗ Import failed: %v\n", err)
+		return
+	default:
+		fmt.Printf("✓ %s imported %d versions, no conflict\n", replica.ID, len(report.Imported))
+		return
+	}
+
+	report, err = replica.Manager.ImportHistory(ctx, id, history, viracochan.ConflictFork)
+	if err != nil {
+		fmt.Printf("✗ Fork import failed: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ %s kept its history and stored the master's as %s (%d conflicting versions, resolved as %s)\n",
+		replica.ID, report.ForkID, len(report.Conflicts), viracochan.ConflictFork)
+}
+
+func shortCS(cs string) string {
+	if len(cs) < 8 {
+		return "-"
+	}
+	return cs[:8] + "..."
+}
+
 func createNode(ctx context.Context, baseDir string, index int, signer *viracochan.Signer) (*Node, error) {
 	nodeID := fmt.Sprintf("node-%d", index)
 	nodeDir := filepath.Join(baseDir, nodeID)
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -284,6 +285,7 @@ func main() {
 		dataDir  = flag.String("dir", "./encryption-demo", "data directory")
 		keyStr   = flag.String("key", "", "32-byte encryption key (hex)")
 		compress = flag.Bool("compress", true, "enable compression")
+		metrics  = flag.String("metrics", "", "serve Prometheus metrics on this address (e.g. :9090) until interrupted")
 	)
 	flag.Parse()
 
@@ -341,9 +343,15 @@ func main() {
 		log.Fatal("Failed to create signer:", err)
 	}
 
+	prom := viracochan.NewPrometheusMetrics("viracochan")
 	manager, err := viracochan.NewManager(
 		encryptedStorage,
 		viracochan.WithSigner(signer),
+		viracochan.WithMetrics(prom),
+		// Exports are for sharing, so they never carry the secrets
+		viracochan.WithRedactor(viracochan.NewPathRedactor([]string{
+			"credentials.*", "payment.*", "personal_data.users.ssn",
+		})),
 	)
 	if err != nil {
 		log.Fatal("Failed to create manager:", err)
@@ -507,20 +515,19 @@ func main() {
 
 	// Verify all versions are encrypted
 	fmt.Println("\nVerifying encryption of all versions:")
-	history, err := manager.GetHistory(ctx, configID)
+	paths, _, err := manager.StoragePaths(ctx, configID)
 	if err != nil {
-		fmt.Printf("✗ Failed to get history: %v\n", err)
+		fmt.Printf("✗ Failed to locate version files: %v\n", err)
 	} else {
-		for _, h := range history {
+		for _, path := range paths {
 			// Try to read raw
-			path := fmt.Sprintf("configs/%s/v%d.json", configID, h.Meta.Version)
 			raw, _ := baseStorage.Read(ctx, path)
 
 			encrypted := !strings.Contains(string(raw), "api_key")
 			if encrypted {
-				fmt.Printf("  v%d: ✓ Encrypted\n", h.Meta.Version)
+				fmt.Printf("  %s: ✓ Encrypted\n", path)
 			} else {
-				fmt.Printf("  v%d: ✗ NOT ENCRYPTED!\n", h.Meta.Version)
+				fmt.Printf("  %s: ✗ NOT ENCRYPTED!\n", path)
 			}
 		}
 	}
@@ -548,6 +555,23 @@ func main() {
 		fmt.Printf("  Encryption overhead: %.1f%%\n", overhead)
 	}
 
+	// A quick snapshot of the manager's own counters, without any exporter
+	mstats := manager.Stats()
+	fmt.Println("\nManager Statistics:")
+	fmt.Printf("  Writes:          %d (last at %s)\n", mstats.Writes, mstats.LastWrite.Format(time.RFC3339))
+	fmt.Printf("  Journal:         %d entries, %d bytes\n", mstats.JournalEntries, mstats.JournalBytes)
+	fmt.Printf("  Cache:           %d ids, %d hits, %d misses\n", mstats.CacheEntries, mstats.CacheHits, mstats.CacheMisses)
+
+	// The manager reports the same kind of numbers itself through WithMetrics
+	fmt.Println("\nManager Operations (Prometheus exposition):")
+	var exposition strings.Builder
+	prom.WriteTo(&exposition)
+	for _, line := range strings.Split(exposition.String(), "\n") {
+		if strings.HasPrefix(line, "viracochan_operations_total") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
 	// Phase 8: Integrity verification
 	fmt.Println("\n--- Phase 8: Full Integrity Scan ---")
 
@@ -594,7 +618,22 @@ func main() {
 		}
 	}
 
+	// Exports are masked, while reads above still see the real values
+	if exported, err := manager.Export(ctx, configID); err != nil {
+		fmt.Printf("✗ Export failed: %v\n", err)
+	} else if strings.Contains(string(exported), "sk-1234567890abcdef") || strings.Contains(string(exported), "123-45-6789") {
+		fmt.Println("⚠ WARNING: Export leaks sensitive data!")
+	} else {
+		fmt.Printf("✓ Export redacted (%d fields masked)\n", strings.Count(string(exported), viracochan.RedactedValue))
+	}
+
 	fmt.Println("\n✓ Encrypted storage demo completed successfully")
+
+	if *metrics != "" {
+		http.Handle("/metrics", prom)
+		fmt.Printf("\nServing metrics on http://%s/metrics (Ctrl-C to exit)\n", *metrics)
+		log.Fatal(http.ListenAndServe(*metrics, nil))
+	}
 }
 
 func mustMarshal(v interface{}) []byte {
@@ -22,12 +22,6 @@ type S3Storage struct {
 	viracochan.Storage
 	latency     time.Duration
 	failureRate float64
-	mu          sync.RWMutex
-	metrics     struct {
-		reads    int
-		writes   int
-		failures int
-	}
 }
 
 func NewS3Storage(base viracochan.Storage, latency time.Duration) *S3Storage {
@@ -39,18 +33,11 @@ func NewS3Storage(base viracochan.Storage, latency time.Duration) *S3Storage {
 }
 
 func (s *S3Storage) Read(ctx context.Context, path string) ([]byte, error) {
-	s.mu.Lock()
-	s.metrics.reads++
-	s.mu.Unlock()
-
 	// Simulate network latency
 	time.Sleep(s.latency)
 
 	// Simulate occasional failures
 	if time.Now().UnixNano()%20 == 0 {
-		s.mu.Lock()
-		s.metrics.failures++
-		s.mu.Unlock()
 		return nil, fmt.Errorf("S3 read timeout")
 	}
 
@@ -58,28 +45,37 @@ func (s *S3Storage) Read(ctx context.Context, path string) ([]byte, error) {
 }
 
 func (s *S3Storage) Write(ctx context.Context, path string, data []byte) error {
-	s.mu.Lock()
-	s.metrics.writes++
-	s.mu.Unlock()
-
 	// Simulate network latency
 	time.Sleep(s.latency * 2) // Writes are slower
 
 	// Simulate occasional failures
 	if time.Now().UnixNano()%25 == 0 {
-		s.mu.Lock()
-		s.metrics.failures++
-		s.mu.Unlock()
 		return fmt.Errorf("S3 write failed: service unavailable")
 	}
 
 	return s.Storage.Write(ctx, path, data)
 }
 
-func (s *S3Storage) GetMetrics() (reads, writes, failures int) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.metrics.reads, s.metrics.writes, s.metrics.failures
+// s3Counters tallies the calls a TracingStorage sees
+type s3Counters struct {
+	mu                      sync.Mutex
+	reads, writes, failures int
+	written                 int
+}
+
+func (c *s3Counters) record(op, path string, bytes int, dur time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch op {
+	case "read":
+		c.reads++
+	case "write":
+		c.writes++
+		c.written += bytes
+	}
+	if err != nil {
+		c.failures++
+	}
 }
 
 // CachedStorage adds a caching layer
@@ -158,6 +154,42 @@ func (c *CachedStorage) GetMetrics() (hits, misses int) {
 	return c.hits, c.misses
 }
 
+// printTracer prints each span when it ends, indented by depth, to show
+// where a manager call spends its time across storage layers
+type printTracer struct{}
+
+type spanDepth struct{}
+
+type printSpan struct {
+	name  string
+	depth int
+	start time.Time
+	attrs []viracochan.Attribute
+}
+
+func (printTracer) Start(ctx context.Context, name string, attrs ...viracochan.Attribute) (context.Context, viracochan.Span) {
+	depth, _ := ctx.Value(spanDepth{}).(int)
+	span := &printSpan{name: name, depth: depth, start: time.Now(), attrs: attrs}
+	return context.WithValue(ctx, spanDepth{}, depth+1), span
+}
+
+func (s *printSpan) SetAttributes(attrs ...viracochan.Attribute) {
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *printSpan) End(err error) {
+	var detail []string
+	for _, a := range s.attrs {
+		if a.Key != viracochan.AttrOp {
+			detail = append(detail, fmt.Sprintf("%s=%v", strings.TrimPrefix(a.Key, "viracochan."), a.Value))
+		}
+	}
+	if err != nil {
+		detail = append(detail, "error="+err.Error())
+	}
+	fmt.Printf("  %s%-28s %8v  %s\n", strings.Repeat("  ", s.depth), s.name, time.Since(s.start).Round(time.Microsecond), strings.Join(detail, " "))
+}
+
 func main() {
 	var (
 		sourceDir = flag.String("source", "./migration-source", "source storage directory")
@@ -308,6 +340,13 @@ func main() {
 	}
 	fmt.Printf("✓ Memory storage verified: v%d available\n", memConfig.Meta.Version)
 
+	// Find the migrated configs by attribute rather than by id
+	production, err := memManager.Query(ctx, viracochan.FieldEquals("/environment", "production"))
+	if err != nil {
+		log.Printf("Query failed: %v", err)
+	}
+	fmt.Printf("✓ Production configs in memory storage: %v\n", production)
+
 	// Phase 3: Migrate to simulated S3 (with latency and failures)
 	fmt.Println("\n--- Phase 3: Migration to Simulated S3 Storage ---")
 
@@ -321,21 +360,21 @@ func main() {
 	fmt.Println("Migrating with simulated network conditions...")
 	startTime := time.Now()
 
-	retryCount := 0
-	for retryCount < 3 {
-		if err := migrateStorage(ctx, memStorage, s3Storage, "S3"); err != nil {
-			retryCount++
-			fmt.Printf("  Retry %d/3 due to: %v\n", retryCount, err)
-			time.Sleep(500 * time.Millisecond)
-		} else {
-			break
-		}
+	// Count every call that reaches S3, retries included
+	var s3Calls s3Counters
+	retryingS3 := viracochan.NewRetryingStorage(viracochan.NewTracingStorage(s3Storage, s3Calls.record), viracochan.RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   100 * time.Millisecond,
+		Jitter:      0.2,
+	})
+	if err := migrateStorage(ctx, memStorage, retryingS3, "S3"); err != nil {
+		fmt.Printf("  Migration failed after retries: %v\n", err)
 	}
 
 	elapsed := time.Since(startTime)
-	reads, writes, failures := s3Storage.GetMetrics()
 	fmt.Printf("S3 migration completed in %v\n", elapsed)
-	fmt.Printf("  Operations: %d reads, %d writes, %d failures\n", reads, writes, failures)
+	fmt.Printf("  Operations: %d reads, %d writes (%d bytes), %d failures, %d retries\n",
+		s3Calls.reads, s3Calls.writes, s3Calls.written, s3Calls.failures, retryingS3.Retries())
 
 	// Phase 4: Add caching layer
 	fmt.Println("\n--- Phase 4: Adding Cache Layer ---")
@@ -349,6 +388,21 @@ func main() {
 		log.Fatal("Failed to create cached manager:", err)
 	}
 
+	// A traced manager shows where a cold read spends its time; spans print
+	// as they end, so storage calls come before the read they belong to
+	fmt.Println("Tracing a cold read through cache and S3:")
+	tracedManager, err := viracochan.NewManager(
+		cachedS3,
+		viracochan.WithSigner(signer),
+		viracochan.WithTracer(printTracer{}),
+	)
+	if err != nil {
+		log.Fatal("Failed to create traced manager:", err)
+	}
+	if _, err := tracedManager.GetLatest(ctx, configID); err != nil {
+		fmt.Printf("  Traced read failed: %v\n", err)
+	}
+
 	// Perform multiple reads to demonstrate caching
 	fmt.Println("Testing cache performance:")
 	for i := 0; i < 5; i++ {
@@ -364,6 +418,31 @@ func main() {
 	hits, misses := cachedS3.GetMetrics()
 	fmt.Printf("Cache statistics: %d hits, %d misses (%.1f%% hit rate)\n",
 		hits, misses, float64(hits)/float64(hits+misses)*100)
+	// The manager's own cache of heads sits in front of the storage cache
+	mstats := cachedManager.Stats()
+	fmt.Printf("Manager cache: %d heads cached, %d hits, %d misses\n",
+		mstats.CacheEntries, mstats.CacheHits, mstats.CacheMisses)
+
+	// The memory copy from Phase 2 doubles as a read replica of S3: reads
+	// are served from it, falling through to S3 when it fails or lags
+	replicaManager, err := viracochan.NewManager(
+		s3Storage,
+		viracochan.WithSigner(signer),
+		viracochan.WithReadReplicas([]viracochan.Storage{memStorage}),
+	)
+	if err != nil {
+		log.Fatal("Failed to create replica manager:", err)
+	}
+	fmt.Println("Reading through a replica:")
+	for _, version := range []uint64{1, memConfig.Meta.Version} {
+		var trace viracochan.ReadTrace
+		start := time.Now()
+		if _, err := replicaManager.Get(viracochan.WithReadTrace(ctx, &trace), configID, version); err != nil {
+			fmt.Printf("  v%d failed: %v\n", version, err)
+			continue
+		}
+		fmt.Printf("  v%d from %s in %v\n", version, trace.Source, time.Since(start))
+	}
 
 	// Phase 5: Migrate to file storage with validation
 	fmt.Println("\n--- Phase 5: Final Migration to File Storage ---")
@@ -442,8 +521,12 @@ func main() {
 	switch {
 	case sourceErr != nil || targetErr != nil:
 		fmt.Printf("⚠ Cannot verify checksums - source error: %v, target error: %v\n", sourceErr, targetErr)
-	case sourceLatest != nil && targetLatest != nil && sourceLatest.Meta.CS == targetLatest.Meta.CS:
+	case sourceLatest.Equal(targetLatest):
 		fmt.Println("✓ Source and target checksums match")
+	case viracochan.ContentEquivalent(sourceLatest, targetLatest):
+		// Checksums cover version and timestamp too; the data is what matters
+		fmt.Printf("✓ Content survived intact (lineage differs: source v%d, target v%d)\n",
+			sourceLatest.Meta.Version, targetLatest.Meta.Version)
 	case sourceLatest != nil && targetLatest != nil:
 		fmt.Println("✗ Checksum mismatch between source and target!")
 	default:
@@ -516,14 +599,15 @@ func validateAndMigrate(ctx context.Context, source, target viracochan.Storage,
 
 	// Separate configs and journal entries
 	var configs []string
-	var journals []string
 	for _, file := range files {
 		if strings.Contains(file, "/configs/") {
 			configs = append(configs, file)
-		} else if strings.HasSuffix(file, ".jsonl") || strings.HasSuffix(file, ".journal") {
-			journals = append(journals, file)
 		}
 	}
+	journals, err := viracochan.DiscoverJournals(ctx, source)
+	if err != nil {
+		return err
+	}
 
 	fmt.Printf("  Found %d config files and %d journal files\n", len(configs), len(journals))
 
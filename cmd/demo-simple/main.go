@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,26 @@ import (
 	"github.com/source-c/viracochan"
 )
 
+// settingsSchema stands in for the schema a registry would serve for the
+// "app-settings-value" subject
+const settingsSchema = `{
+	"type": "object",
+	"required": ["app_name", "version", "settings"],
+	"properties": {
+		"app_name": {"type": "string", "minLength": 1},
+		"version": {"type": "string", "pattern": "^[0-9]+\\.[0-9]+\\.[0-9]+$"},
+		"counter": {"type": "integer", "minimum": 1},
+		"settings": {
+			"type": "object",
+			"properties": {
+				"debug": {"type": "boolean"},
+				"timeout": {"type": "integer", "minimum": 1},
+				"retries": {"type": "integer", "minimum": 0}
+			}
+		}
+	}
+}`
+
 func main() {
 	ctx := context.Background()
 
@@ -34,10 +55,19 @@ func main() {
 	fmt.Printf("Storage directory: %s\n", storageDir)
 	fmt.Printf("Public key: %s\n\n", signer.PublicKey()[:16]+"...")
 
+	// Content is checked against the schema registered for each id
+	registry := viracochan.SchemaRegistryFunc(func(_ context.Context, subject string) ([]byte, error) {
+		if subject != "app-settings-value" {
+			return nil, viracochan.ErrSubjectNotFound
+		}
+		return []byte(settingsSchema), nil
+	})
+
 	// Create manager with signing
 	manager, err := viracochan.NewManager(
 		storage,
 		viracochan.WithSigner(signer),
+		viracochan.WithSchemaRegistry(registry),
 	)
 	if err != nil {
 		log.Fatal("Failed to create manager:", err)
@@ -46,9 +76,24 @@ func main() {
 	// Configuration ID
 	configID := "app-settings"
 
-	// Check if config exists
-	existing, err := manager.GetLatest(ctx, configID)
-	if err == nil {
+	// Initial configuration, used when none is stored yet
+	defaults := map[string]interface{}{
+		"app_name": "Viracochan Example",
+		"version":  "1.0.0",
+		"settings": map[string]interface{}{
+			"debug":   false,
+			"timeout": 30,
+			"retries": 3,
+		},
+		"counter": 1,
+	}
+
+	// Load the stored config, telling a first run from a read failure
+	existing, exists, err := manager.GetLatestOrDefault(ctx, configID, defaults)
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+	if exists {
 		fmt.Printf("Found existing config version %d\n", existing.Meta.Version)
 
 		// Verify signature
@@ -80,19 +125,8 @@ func main() {
 	} else {
 		fmt.Println("Creating new configuration")
 
-		// Create initial configuration
-		config := map[string]interface{}{
-			"app_name": "Viracochan Example",
-			"version":  "1.0.0",
-			"settings": map[string]interface{}{
-				"debug":   false,
-				"timeout": 30,
-				"retries": 3,
-			},
-			"counter": 1,
-		}
-
-		cfg, err := manager.Create(ctx, configID, config)
+		// The default is not stored until it is created
+		cfg, err := manager.Create(ctx, configID, existing.Content)
 		if err != nil {
 			log.Fatal("Failed to create config:", err)
 		}
@@ -102,6 +136,24 @@ func main() {
 		fmt.Printf("Signature: %s\n", cfg.Meta.Signature[:16]+"...")
 	}
 
+	// A write that breaks the registered schema is rejected and stores nothing
+	fmt.Println("\nSchema registry check:")
+	invalid := map[string]interface{}{
+		"app_name": "Viracochan Example",
+		"version":  "1.0",
+		"settings": map[string]interface{}{
+			"debug":   false,
+			"timeout": "30s",
+			"retries": 3,
+		},
+		"counter": 1,
+	}
+	if _, err := manager.Update(ctx, configID, invalid); errors.Is(err, viracochan.ErrSchemaViolation) {
+		fmt.Printf("  Rejected: %v\n", err)
+	} else {
+		log.Fatal("Expected the schema registry to reject the update:", err)
+	}
+
 	// Show history
 	fmt.Println("\nConfiguration history:")
 	history, err := manager.GetHistory(ctx, configID)
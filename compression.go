@@ -0,0 +1,101 @@
+package viracochan
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CompressionGzip is the codec name of gzip-compressed version files
+const CompressionGzip = "gzip"
+
+// ErrUnsupportedCompression is returned when a version file names a codec
+// this build cannot decompress
+var ErrUnsupportedCompression = errors.New("unsupported compression codec")
+
+// compressionCodec compresses and restores version file content
+type compressionCodec struct {
+	compress   func([]byte) ([]byte, error)
+	decompress func([]byte) ([]byte, error)
+}
+
+// compressionCodecs maps each codec name a version file may record to its
+// implementation. Names are never reused, so files stay readable.
+var compressionCodecs = map[string]compressionCodec{
+	CompressionGzip: {compress: gzipCompress, decompress: gzipDecompress},
+}
+
+// WithCompression compresses the content of every version file written in
+// full with codec (CompressionGzip). Each file records its codec next to the
+// compressed bytes, so a history mixes compressed and plain versions freely:
+// files written before compression was enabled, or by a manager without it,
+// load as before, and any manager reads compressed ones. Content that would
+// not shrink is stored plain. Checksums and signatures cover the content as
+// written, never its compressed form, and are checked after decompression.
+// Diffs (WithDeltaStorage) and blob pointers are not compressed; journal
+// entries keep embedding the plain config.
+func WithCompression(codec string) ManagerOption {
+	return func(m *Manager) error {
+		if _, ok := compressionCodecs[codec]; !ok {
+			return fmt.Errorf("%w: %q", ErrUnsupportedCompression, codec)
+		}
+		m.compression = codec
+		return nil
+	}
+}
+
+// encodeFull marshals cfg as a version file holding all of its content,
+// compressed when the store has a codec and it helps
+func (cs *ConfigStorage) encodeFull(cfg *Config) ([]byte, error) {
+	if cs.compression == "" {
+		return cs.encode(cfg)
+	}
+	packed, err := compressionCodecs[cs.compression].compress(cfg.Content)
+	if err != nil {
+		return nil, err
+	}
+	if len(packed) >= len(cfg.Content) {
+		return cs.encode(cfg)
+	}
+	return cs.encode(configFile{Meta: cfg.Meta, Codec: cs.compression, Compressed: packed})
+}
+
+// decompressContent returns the content of a compressed version file
+func decompressContent(file *configFile) (json.RawMessage, error) {
+	codec, ok := compressionCodecs[file.Codec]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCompression, file.Codec)
+	}
+	content, err := codec.decompress(file.Compressed)
+	if err != nil {
+		return nil, fmt.Errorf("%s content: %w", file.Codec, err)
+	}
+	return content, nil
+}
+
+func gzipCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipDecompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
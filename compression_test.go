@@ -0,0 +1,113 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressionMixedHistory(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+
+	content := map[string]interface{}{"servers": strings.Repeat("host.example.com,", 100)}
+	plain, err := NewManager(storage, WithSigner(signer), WithCacheTTL(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if _, err := plain.Create(ctx, "app", content); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	content["n"] = 2
+	if _, err := plain.Update(ctx, "app", content); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	packed, err := NewManager(storage, WithSigner(signer), WithCompression(CompressionGzip), WithCacheTTL(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	for n := 3; n <= 4; n++ {
+		content["n"] = n
+		if _, err := packed.Update(ctx, "app", content); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	// Too small to shrink, so stored plain even with compression on
+	if _, err := packed.Update(ctx, "app", map[string]interface{}{"n": 5}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	plainSize := 0
+	for v, codec := range map[uint64]string{1: "", 2: "", 3: CompressionGzip, 4: CompressionGzip, 5: ""} {
+		data, err := storage.Read(ctx, fmt.Sprintf("configs/app/v%d.json", v))
+		if err != nil {
+			t.Fatalf("read v%d: %v", v, err)
+		}
+		var file configFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			t.Fatalf("decode v%d: %v", v, err)
+		}
+		if file.Codec != codec {
+			t.Errorf("v%d: codec %q, want %q", v, file.Codec, codec)
+		}
+		if v == 2 {
+			plainSize = len(data)
+		}
+		if codec != "" && (len(file.Content) != 0 || len(file.Compressed) == 0) {
+			t.Errorf("v%d: content not compressed", v)
+		}
+	}
+	if data, _ := storage.Read(ctx, "configs/app/v3.json"); len(data) >= plainSize/2 {
+		t.Errorf("compressed v3 is %d bytes, plain v2 %d", len(data), plainSize)
+	}
+
+	// Both managers read the whole history and validate its checksums
+	for name, m := range map[string]*Manager{"plain": plain, "compressing": packed} {
+		for v := uint64(1); v <= 5; v++ {
+			cfg, err := m.Get(ctx, "app", v)
+			if err != nil {
+				t.Fatalf("%s: Get v%d failed: %v", name, v, err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("%s: v%d: %v", name, v, err)
+			}
+		}
+		if err := m.ValidateChain(ctx, "app"); err != nil {
+			t.Errorf("%s: ValidateChain failed: %v", name, err)
+		}
+		got, err := m.Get(ctx, "app", 4)
+		if err != nil {
+			t.Fatalf("%s: Get failed: %v", name, err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(got.Content, &decoded); err != nil || decoded["servers"] != content["servers"] {
+			t.Errorf("%s: v4 content not restored: %v", name, err)
+		}
+	}
+}
+
+func TestCompressionUnsupportedCodec(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewManager(NewMemoryStorage(), WithCompression("lz4")); !errors.Is(err, ErrUnsupportedCompression) {
+		t.Errorf("expected ErrUnsupportedCompression, got %v", err)
+	}
+
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithCompression(CompressionGzip))
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"v": strings.Repeat("a", 500)}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	data, _ := storage.Read(ctx, "configs/app/v1.json")
+	storage.Write(ctx, "configs/app/v1.json", []byte(strings.Replace(string(data), `"codec":"gzip"`, `"codec":"brotli"`, 1)))
+
+	reader, _ := NewManager(storage)
+	if _, err := reader.Get(ctx, "app", 1); !errors.Is(err, ErrUnsupportedCompression) {
+		t.Errorf("expected ErrUnsupportedCompression for an unknown codec, got %v", err)
+	}
+}
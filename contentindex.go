@@ -0,0 +1,138 @@
+package viracochan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ConfigRef names one version of a configuration
+type ConfigRef struct {
+	ID      string `json:"id"`
+	Version uint64 `json:"v"`
+}
+
+// ContentHash returns the hex SHA-256 of content in canonical form, so it does
+// not depend on formatting, key order or the config's checksum algorithm. It
+// is recorded in journal entries and is the key of FindByContentHash.
+func ContentHash(content json.RawMessage) (string, error) {
+	canonical, err := CanonicalizeRaw(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FindByContentHash returns every version, across all ids, whose content has
+// the given ContentHash, ordered by id and version. It is served from an
+// in-memory index built from the journal on first use and kept current by the
+// manager's own writes; journal entries written before content hashes were
+// recorded are indexed by loading their config once. Writes made through
+// other managers sharing the storage are not seen until the index is rebuilt
+// (after Recover or ExpireSweep, or in a new manager).
+func (m *Manager) FindByContentHash(ctx context.Context, hash string) ([]ConfigRef, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	m.indexMu.Lock()
+	defer m.indexMu.Unlock()
+
+	if m.index == nil {
+		if err := m.buildIndex(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return append([]ConfigRef(nil), m.index[hash]...), nil
+}
+
+// buildIndex fills the content index from the journal; indexMu must be held
+func (m *Manager) buildIndex(ctx context.Context) error {
+	entries, err := m.journal.ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string][]ConfigRef)
+	for _, entry := range entries {
+		hash := entry.ContentHash
+		if hash == "" {
+			content, err := m.entryContent(ctx, entry)
+			if err == nil {
+				hash, err = ContentHash(content)
+			}
+			if err != nil {
+				m.logger.Warn("content index: skipping version", "id", entry.ID, "version", entry.Version, "error", err)
+				continue
+			}
+		}
+		index[hash] = insertRef(index[hash], ConfigRef{ID: entry.ID, Version: entry.Version})
+	}
+	m.index = index
+	return nil
+}
+
+func (m *Manager) entryContent(ctx context.Context, entry *JournalEntry) (json.RawMessage, error) {
+	cfg, err := m.entryConfig(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Content, nil
+}
+
+// entryConfig returns the config an entry describes: the embedded one when it
+// matches the entry, otherwise the config file
+func (m *Manager) entryConfig(ctx context.Context, entry *JournalEntry) (*Config, error) {
+	if entry.Config != nil && entry.Config.Meta.CS == entry.CS {
+		return entry.Config, nil
+	}
+	cfg, err := m.configStore.Load(ctx, entry.ID, entry.Version)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Meta.CS != entry.CS {
+		return nil, fmt.Errorf("config file %q v%d does not match its journal entry", entry.ID, entry.Version)
+	}
+	return cfg, nil
+}
+
+// indexPut adds a freshly journaled entry to the content index, if built
+func (m *Manager) indexPut(entry *JournalEntry) {
+	if entry.ContentHash == "" {
+		return
+	}
+
+	m.indexMu.Lock()
+	defer m.indexMu.Unlock()
+	if m.index != nil {
+		m.index[entry.ContentHash] = insertRef(m.index[entry.ContentHash], ConfigRef{ID: entry.ID, Version: entry.Version})
+	}
+}
+
+// indexReset drops the content index so the next lookup rebuilds it
+func (m *Manager) indexReset() {
+	m.indexMu.Lock()
+	defer m.indexMu.Unlock()
+	m.index = nil
+}
+
+// insertRef adds ref to the sorted refs unless already present
+func insertRef(refs []ConfigRef, ref ConfigRef) []ConfigRef {
+	i := sort.Search(len(refs), func(i int) bool {
+		return refs[i].ID > ref.ID || (refs[i].ID == ref.ID && refs[i].Version >= ref.Version)
+	})
+	if i < len(refs) && refs[i] == ref {
+		return refs
+	}
+	refs = append(refs, ConfigRef{})
+	copy(refs[i+1:], refs[i:])
+	refs[i] = ref
+	return refs
+}
@@ -0,0 +1,70 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContentHashCanonical(t *testing.T) {
+	a, _ := ContentHash(json.RawMessage(`{"b":1,"a":[true]}`))
+	b, _ := ContentHash(json.RawMessage("{ \"a\": [true],\n \"b\": 1 }"))
+	if a != b || len(a) != 64 {
+		t.Errorf("expected equal canonical hashes, got %s and %s", a, b)
+	}
+}
+
+func TestManagerFindByContentHash(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	shared := map[string]interface{}{"feature": "on"}
+	manager.Create(ctx, "web", shared)
+	manager.Update(ctx, "web", map[string]interface{}{"feature": "off"})
+	manager.Rollback(ctx, "web", 1)
+	manager.Create(ctx, "api", shared)
+
+	hash, _ := ContentHash(json.RawMessage(`{"feature":"on"}`))
+	refs, err := manager.FindByContentHash(ctx, hash)
+	if err != nil {
+		t.Fatalf("FindByContentHash failed: %v", err)
+	}
+	want := []ConfigRef{{ID: "api", Version: 1}, {ID: "web", Version: 1}, {ID: "web", Version: 3}}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("expected %v, got %v", want, refs)
+	}
+
+	// Writes after the index is built are indexed as they happen
+	manager.Update(ctx, "api", shared)
+	if refs, _ := manager.FindByContentHash(ctx, hash); len(refs) != 4 {
+		t.Errorf("expected new write to be indexed, got %v", refs)
+	}
+
+	if refs, _ := manager.FindByContentHash(ctx, strings.Repeat("0", 64)); len(refs) != 0 {
+		t.Errorf("expected no match, got %v", refs)
+	}
+}
+
+func TestManagerFindByContentHashLegacyJournal(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	writer, _ := NewManager(storage, WithJournalEmbedContent(false))
+	writer.Create(ctx, "app", map[string]interface{}{"n": 1})
+
+	// Strip the recorded hashes, as in journals written by older releases
+	entries, _ := writer.journal.ReadAll(ctx)
+	for _, entry := range entries {
+		entry.ContentHash = ""
+	}
+	writer.journal.Rewrite(ctx, entries)
+
+	reader, _ := NewManager(storage)
+	hash, _ := ContentHash(json.RawMessage(`{"n":1}`))
+	refs, err := reader.FindByContentHash(ctx, hash)
+	if err != nil || len(refs) != 1 || refs[0] != (ConfigRef{ID: "app", Version: 1}) {
+		t.Errorf("expected app v1 from its config file, got %v, %v", refs, err)
+	}
+}
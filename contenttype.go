@@ -0,0 +1,137 @@
+package viracochan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Content types with built-in validators
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeYAML = "application/yaml"
+)
+
+// ErrUnknownContentType is returned when a config names a content type that
+// has no registered validator
+var ErrUnknownContentType = errors.New("unknown content type")
+
+// ErrInvalidContent is returned when content does not parse as its content
+// type, e.g. content JSON that is not well-formed
+var ErrInvalidContent = errors.New("invalid content")
+
+// ErrEmptyContent is returned for a write whose content is missing or JSON
+// null, e.g. Create with nil content. It matches ErrInvalidContent. Use an
+// empty object for a config that has nothing to hold yet.
+var ErrEmptyContent = fmt.Errorf("%w: content is empty or null", ErrInvalidContent)
+
+var (
+	contentMu         sync.RWMutex
+	contentValidators = map[string]func([]byte) error{
+		ContentTypeJSON: validateJSON,
+		ContentTypeYAML: validateYAML,
+	}
+)
+
+// RegisterContentValidator makes contentType usable with WithContentType.
+// validate receives the document (see Config.Document) and rejects it by
+// returning an error; a rejected write stores nothing. Registering a type
+// again replaces its validator. Like hash algorithms, validators are
+// per-process.
+func RegisterContentValidator(contentType string, validate func(doc []byte) error) error {
+	if contentType == "" || validate == nil {
+		return errors.New("content type and validator are required")
+	}
+
+	contentMu.Lock()
+	defer contentMu.Unlock()
+	contentValidators[contentType] = validate
+	return nil
+}
+
+// isJSONContentType reports whether documents of contentType are stored as
+// JSON content directly rather than as a JSON string
+func isJSONContentType(contentType string) bool {
+	return contentType == "" || contentType == ContentTypeJSON || strings.HasSuffix(contentType, "+json")
+}
+
+// Document returns the content as a document of its content type: the raw
+// JSON for JSON types (including "+json" suffixes), otherwise the text held
+// in the content's JSON string, e.g. the YAML source.
+func (c *Config) Document() ([]byte, error) {
+	if isJSONContentType(c.Meta.ContentType) {
+		return c.Content, nil
+	}
+	var doc string
+	if err := json.Unmarshal(c.Content, &doc); err != nil {
+		return nil, fmt.Errorf("%s content must be a JSON string: %w", c.Meta.ContentType, err)
+	}
+	return []byte(doc), nil
+}
+
+// validateContent rejects empty or null content, then runs the validator
+// registered for cfg's content type
+func validateContent(cfg *Config) error {
+	if isEmptyContent(cfg.Content) {
+		return ErrEmptyContent
+	}
+
+	contentType := cfg.Meta.ContentType
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+
+	contentMu.RLock()
+	validate, ok := contentValidators[contentType]
+	contentMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+	}
+
+	doc, err := cfg.Document()
+	if err == nil {
+		err = validate(doc)
+	}
+	if err != nil {
+		return &contentError{contentType: contentType, err: err}
+	}
+	return nil
+}
+
+// contentError is a validator's rejection of content, matching
+// ErrInvalidContent
+type contentError struct {
+	contentType string
+	err         error
+}
+
+func (e *contentError) Error() string {
+	return fmt.Sprintf("invalid %s content: %v", e.contentType, e.err)
+}
+
+func (e *contentError) Unwrap() error { return e.err }
+
+func (e *contentError) Is(target error) bool { return target == ErrInvalidContent }
+
+// isEmptyContent reports whether content is missing or JSON null
+func isEmptyContent(content json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(content)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
+
+func validateJSON(doc []byte) error {
+	if !json.Valid(doc) {
+		return errors.New("not valid JSON")
+	}
+	return nil
+}
+
+func validateYAML(doc []byte) error {
+	var v interface{}
+	return yaml.Unmarshal(doc, &v)
+}
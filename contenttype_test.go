@@ -0,0 +1,124 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContentTypeYAML(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	doc := "server:\n  port: 8080\n  hosts: [a, b]\n"
+	cfg, err := manager.Create(ctx, "app", doc, WithContentType(ContentTypeYAML))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if cfg.Meta.ContentType != ContentTypeYAML {
+		t.Errorf("expected content type recorded, got %q", cfg.Meta.ContentType)
+	}
+	if got, _ := cfg.Document(); string(got) != doc {
+		t.Errorf("expected document text back, got %q", got)
+	}
+
+	// The content type carries over and keeps validating
+	if _, err := manager.Update(ctx, "app", "server: [unclosed\n"); err == nil || !strings.Contains(err.Error(), "invalid application/yaml content") {
+		t.Fatalf("expected malformed YAML to be rejected, got %v", err)
+	}
+	if latest, _ := manager.GetLatest(ctx, "app"); latest.Meta.Version != 1 {
+		t.Errorf("rejected update was stored: v%d", latest.Meta.Version)
+	}
+
+	v2, err := manager.Update(ctx, "app", "server:\n  port: 9090\n")
+	if err != nil || v2.Meta.ContentType != ContentTypeYAML {
+		t.Fatalf("expected YAML v2, got %v, %v", v2, err)
+	}
+	rolled, err := manager.Rollback(ctx, "app", 1)
+	if err != nil || rolled.Meta.ContentType != ContentTypeYAML {
+		t.Errorf("rollback lost the content type: %v, %v", rolled, err)
+	}
+
+	// Switching back to JSON records the default
+	v4, err := manager.Update(ctx, "app", map[string]interface{}{"port": 1}, WithContentType(ContentTypeJSON))
+	if err != nil || v4.Meta.ContentType != "" {
+		t.Errorf("expected JSON recorded as empty, got %v, %v", v4, err)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain invalid: %v", err)
+	}
+}
+
+func TestRegisterContentValidator(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	if _, err := manager.Create(ctx, "app", "x", WithContentType("text/x-test")); !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("expected ErrUnknownContentType, got %v", err)
+	}
+
+	RegisterContentValidator("text/x-test", func(doc []byte) error {
+		if !strings.HasPrefix(string(doc), "#!") {
+			return errors.New("missing header")
+		}
+		return nil
+	})
+	if _, err := manager.Create(ctx, "app", "no header", WithContentType("text/x-test")); err == nil {
+		t.Error("expected custom validator to reject")
+	}
+	if _, err := manager.Create(ctx, "app", "#! ok", WithContentType("text/x-test")); err != nil {
+		t.Errorf("expected custom validator to accept, got %v", err)
+	}
+	if _, err := manager.Create(ctx, "obj", map[string]interface{}{"a": 1}, WithContentType("text/x-test")); err == nil {
+		t.Error("expected non-string content to be rejected for a text type")
+	}
+}
+
+func TestEmptyContent(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	for name, content := range map[string]interface{}{
+		"nil":         nil,
+		"JSON null":   json.RawMessage("null"),
+		"padded null": json.RawMessage(" null\n"),
+		"nil map":     map[string]interface{}(nil),
+	} {
+		if _, err := manager.Create(ctx, "empty", content); !errors.Is(err, ErrEmptyContent) || !errors.Is(err, ErrInvalidContent) {
+			t.Errorf("Create with %s content: got %v, want ErrEmptyContent", name, err)
+		}
+	}
+	if ids, _ := manager.List(ctx); len(ids) != 0 {
+		t.Errorf("rejected creates stored %v", ids)
+	}
+
+	// An empty object is the empty state
+	cfg, err := manager.Create(ctx, "empty", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Create with an empty map failed: %v", err)
+	}
+	if string(cfg.Content) != "{}" {
+		t.Errorf("empty map stored as %s", cfg.Content)
+	}
+	if _, err := manager.Update(ctx, "empty", nil); !errors.Is(err, ErrEmptyContent) {
+		t.Errorf("Update with nil content: got %v, want ErrEmptyContent", err)
+	}
+	if _, err := manager.CreateBatch(ctx, map[string]interface{}{"a": map[string]interface{}{}, "b": nil}); !errors.Is(err, ErrEmptyContent) {
+		t.Errorf("CreateBatch with nil content: got %v, want ErrEmptyContent", err)
+	}
+
+	next, err := manager.Update(ctx, "empty", map[string]interface{}{"port": 8080})
+	if err != nil {
+		t.Fatalf("Update from the empty state failed: %v", err)
+	}
+	changes, err := Diff(cfg.Content, next.Content)
+	if err != nil || len(changes) != 1 || changes[0].Path != "port" || changes[0].Kind != ChangeAdded {
+		t.Errorf("Diff from the empty state: %+v, %v", changes, err)
+	}
+	merged, err := Merge3(cfg.Content, next.Content, cfg.Content, nil)
+	if err != nil || string(merged) != `{"port":8080}` {
+		t.Errorf("Merge3 over the empty state: %s, %v", merged, err)
+	}
+}
@@ -0,0 +1,237 @@
+package viracochan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNoCountersignature is returned by VerifyCountersigned when a version
+	// carries no countersignature from the given authority
+	ErrNoCountersignature = errors.New("no countersignature from timestamp authority")
+	// ErrInvalidCountersignature is returned when a countersignature does not
+	// verify under its authority
+	ErrInvalidCountersignature = errors.New("invalid countersignature")
+)
+
+// Countersignature is a timestamp authority's attestation that a signed
+// version existed at Time. Token is the authority's proof in its own format,
+// such as a signature or an encoded RFC 3161 response.
+type Countersignature struct {
+	Authority string    `json:"tsa"`
+	Time      time.Time `json:"t"`
+	Token     string    `json:"token"`
+}
+
+// TimestampAuthority attests when data existed, independently of the clock
+// of whoever wrote it. Implementations can wrap an RFC 3161 service, a Nostr
+// relay or any other party whose clock verifiers trust;
+// NewSignerTimestampAuthority is one backed by a Schnorr key.
+type TimestampAuthority interface {
+	// ID names the authority, as recorded in Countersignature.Authority
+	ID() string
+	// Timestamp returns the authority's attestation that digest exists now
+	Timestamp(ctx context.Context, digest []byte) (*Countersignature, error)
+	// Verify checks that cs is the authority's attestation of digest at
+	// cs.Time
+	Verify(digest []byte, cs *Countersignature) error
+}
+
+// countersignDigest is what a timestamp authority attests for cfg: its
+// checksum, version and claimed time, and the author's signature
+func countersignDigest(cfg *Config) []byte {
+	payload := fmt.Sprintf("viracochan:tsa:v1:%s:%d:%s:%s",
+		cfg.Meta.CS,
+		cfg.Meta.Version,
+		cfg.Meta.Time.UTC().Format(time.RFC3339Nano),
+		cfg.Meta.Signature)
+	sum := sha256.Sum256([]byte(payload))
+	return sum[:]
+}
+
+// Countersign has tsa countersign version of id and stores the result in
+// the version's Meta.Countersignatures. The version must be signed, since
+// the authority attests the author's signature along with the checksum and
+// claimed time, and its answer is verified before it is stored. A version
+// tsa already countersigned is left alone. Countersignatures are outside the
+// checksum and signature, so the version file, and the copy of the version
+// embedded in the journal that GetLatest and Export serve the head from, are
+// rewritten in place without touching the chain. tsa is called under the
+// write lock.
+func (m *Manager) Countersign(ctx context.Context, id string, version uint64, tsa TimestampAuthority) (err error) {
+	ctx, call := m.begin(ctx, "countersign", id)
+	defer func() { call.end(nil, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+	if tsa == nil {
+		return errors.New("countersign needs a timestamp authority")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	cfg, err := m.configStore.Load(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	if cfg.Meta.Signature == "" {
+		return fmt.Errorf("%w: config %q version %d", ErrUnsignedConfig, id, version)
+	}
+	for _, cs := range cfg.Meta.Countersignatures {
+		if cs.Authority == tsa.ID() {
+			return nil
+		}
+	}
+
+	digest := countersignDigest(cfg)
+	cs, err := tsa.Timestamp(ctx, digest)
+	if err != nil {
+		return fmt.Errorf("timestamp authority %s: %w", tsa.ID(), err)
+	}
+	if err := tsa.Verify(digest, cs); err != nil {
+		return fmt.Errorf("%w from %s: %v", ErrInvalidCountersignature, tsa.ID(), err)
+	}
+
+	cfg.Meta.Countersignatures = append(cfg.Meta.Countersignatures, *cs)
+	if err := m.configStore.Save(ctx, id, cfg); err != nil {
+		return err
+	}
+	m.cacheDelete(id)
+	if err := m.journal.setCountersignatures(ctx, id, cfg); err != nil {
+		return err
+	}
+
+	m.logger.Info("countersigned config", "id", id, "version", version, "tsa", tsa.ID(), "time", cs.Time)
+	return nil
+}
+
+// setCountersignatures copies cfg's countersignatures into the journal
+// entries of id that embed the same version, so that the head reconstructed
+// from the journal carries them. The journal is only rewritten when such an
+// entry exists.
+func (j *Journal) setCountersignatures(ctx context.Context, id string, cfg *Config) error {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	data, err := j.storage.Read(ctx, j.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return nil
+		}
+		return err
+	}
+
+	var buf bytes.Buffer
+	changed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if json.Unmarshal(line, &entry) == nil && entry.ID == id && entry.Config != nil &&
+			entry.Version == cfg.Meta.Version && entry.Config.Meta.CS == cfg.Meta.CS {
+			changed++
+			entry.Config.Meta.Countersignatures = cfg.Meta.Countersignatures
+			if line, err = json.Marshal(&entry); err != nil {
+				return err
+			}
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if changed == 0 {
+		return nil
+	}
+	if err := j.storage.Write(ctx, j.path, buf.Bytes()); err != nil {
+		return err
+	}
+	j.tail = nil
+	j.noteSize(journalLines(buf.Bytes()), buf.Len())
+	return nil
+}
+
+// VerifyCountersigned verifies cfg's signature under publicKey and its
+// countersignature from tsa, and returns the countersignature. Meta.Time is
+// only the writer's claim; the attested Time is the authority's, and the
+// version was signed no later than it.
+func VerifyCountersigned(cfg *Config, publicKey string, tsa TimestampAuthority) (*Countersignature, error) {
+	if err := VerifyConfigSignature(cfg, publicKey); err != nil {
+		return nil, err
+	}
+
+	digest := countersignDigest(cfg)
+	for i := range cfg.Meta.Countersignatures {
+		cs := &cfg.Meta.Countersignatures[i]
+		if cs.Authority != tsa.ID() {
+			continue
+		}
+		if err := tsa.Verify(digest, cs); err != nil {
+			return nil, fmt.Errorf("%w from %s on version %d: %v", ErrInvalidCountersignature, tsa.ID(), cfg.Meta.Version, err)
+		}
+		return cs, nil
+	}
+	return nil, fmt.Errorf("%w %s on version %d", ErrNoCountersignature, tsa.ID(), cfg.Meta.Version)
+}
+
+// signerAuthority is a TimestampAuthority that signs digests and its own
+// clock's time with a Schnorr key
+type signerAuthority struct {
+	signer *Signer
+	now    func() time.Time
+}
+
+// NewSignerTimestampAuthority returns a timestamp authority that attests
+// with signer's key and the local clock, identified by the signer's public
+// key. It suits an authority run as a separate service, or tests; the
+// attestation is worth what the key holder's clock is.
+func NewSignerTimestampAuthority(signer *Signer) TimestampAuthority {
+	return &signerAuthority{signer: signer, now: time.Now}
+}
+
+func (a *signerAuthority) ID() string {
+	return a.signer.PublicKey()
+}
+
+func (a *signerAuthority) Timestamp(_ context.Context, digest []byte) (*Countersignature, error) {
+	t := a.now().UTC().Truncate(time.Microsecond)
+	hash := a.tokenHash(digest, t)
+	sig, err := a.signer.signHash(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return &Countersignature{Authority: a.ID(), Time: t, Token: sig}, nil
+}
+
+func (a *signerAuthority) Verify(digest []byte, cs *Countersignature) error {
+	if cs.Authority != a.ID() {
+		return fmt.Errorf("countersignature is from %s", cs.Authority)
+	}
+	hash := a.tokenHash(digest, cs.Time)
+	return verifyHash(hash[:], cs.Token, a.ID())
+}
+
+func (a *signerAuthority) tokenHash(digest []byte, t time.Time) [32]byte {
+	payload := fmt.Sprintf("viracochan:tsa-token:v1:%s:%s", hex.EncodeToString(digest), t.UTC().Format(time.RFC3339Nano))
+	return sha256.Sum256([]byte(payload))
+}
@@ -0,0 +1,98 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestManagerCountersign(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	tsaKey, _ := NewSigner()
+	tsa := NewSignerTimestampAuthority(tsaKey)
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	v2, _ := manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	if err := manager.Countersign(ctx, "app", 1, tsa); err != nil {
+		t.Fatalf("Countersign failed: %v", err)
+	}
+	if err := manager.Countersign(ctx, "app", 1, tsa); err != nil {
+		t.Fatalf("repeated Countersign failed: %v", err)
+	}
+
+	cfg, err := manager.Get(ctx, "app", 1)
+	if err != nil {
+		t.Fatalf("countersigned version does not load: %v", err)
+	}
+	if len(cfg.Meta.Countersignatures) != 1 {
+		t.Fatalf("expected one countersignature, got %d", len(cfg.Meta.Countersignatures))
+	}
+	cs, err := VerifyCountersigned(cfg, signer.PublicKey(), tsa)
+	if err != nil {
+		t.Fatalf("VerifyCountersigned failed: %v", err)
+	}
+	if cs.Authority != tsaKey.PublicKey() || cs.Time.IsZero() {
+		t.Errorf("unexpected countersignature %+v", cs)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("countersigning broke the chain: %v", err)
+	}
+
+	// The attestation binds the author's signature and claimed time
+	backdated := *cfg
+	backdated.Meta.Time = cfg.Meta.Time.Add(-1)
+	if _, err := VerifyCountersigned(&backdated, signer.PublicKey(), tsa); err == nil {
+		t.Error("expected a changed timestamp to fail")
+	}
+	forged := *cfg
+	forged.Meta.Countersignatures = []Countersignature{{Authority: cs.Authority, Time: cs.Time.Add(-1), Token: cs.Token}}
+	if _, err := VerifyCountersigned(&forged, signer.PublicKey(), tsa); !errors.Is(err, ErrInvalidCountersignature) {
+		t.Errorf("expected ErrInvalidCountersignature for a moved attestation time, got %v", err)
+	}
+
+	if _, err := VerifyCountersigned(v2, signer.PublicKey(), tsa); !errors.Is(err, ErrNoCountersignature) {
+		t.Errorf("expected ErrNoCountersignature, got %v", err)
+	}
+	other, _ := NewSigner()
+	if _, err := VerifyCountersigned(cfg, other.PublicKey(), tsa); err == nil {
+		t.Error("expected a wrong author key to fail")
+	}
+
+	if err := manager.Countersign(ctx, "app", 2, tsa); err != nil {
+		t.Fatalf("Countersign of the head failed: %v", err)
+	}
+	head, err := manager.GetLatest(ctx, "app")
+	if err != nil {
+		t.Fatalf("GetLatest failed: %v", err)
+	}
+	if _, err := VerifyCountersigned(head, signer.PublicKey(), tsa); err != nil {
+		t.Errorf("GetLatest lost the head's countersignature: %v", err)
+	}
+	exported, _ := manager.Export(ctx, "app")
+	exportedHead, err := VerifyConfigBytes(exported, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("exported head does not verify: %v", err)
+	}
+	if _, err := VerifyCountersigned(exportedHead, signer.PublicKey(), tsa); err != nil {
+		t.Errorf("Export lost the head's countersignature: %v", err)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("countersigning the head broke the chain: %v", err)
+	}
+	next, err := manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+	if err != nil {
+		t.Fatalf("Update after countersigning the head failed: %v", err)
+	}
+	if latest, _ := manager.GetLatest(ctx, "app"); len(latest.Meta.Countersignatures) != 0 || latest.Meta.CS != next.Meta.CS {
+		t.Error("countersignatures must not carry over to the next version")
+	}
+
+	unsigned, _ := NewManager(NewMemoryStorage())
+	unsigned.Create(ctx, "app", map[string]interface{}{"n": 1})
+	if err := unsigned.Countersign(ctx, "app", 1, tsa); !errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("expected ErrUnsignedConfig, got %v", err)
+	}
+}
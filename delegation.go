@@ -0,0 +1,116 @@
+package viracochan
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DelegateAllIDs in Delegation.IDs authorizes the node key for every id.
+const DelegateAllIDs = "*"
+
+// Delegation is a root-signed statement authorizing NodeKey to sign the
+// listed config ids. It lets every node hold its own key while trust stays
+// anchored in a small set of root keys. A zero NotAfter never expires;
+// otherwise only configs whose Meta.Time is before NotAfter are accepted.
+type Delegation struct {
+	RootKey   string    `json:"root"`
+	NodeKey   string    `json:"node"`
+	IDs       []string  `json:"ids"`
+	NotAfter  time.Time `json:"not_after,omitempty"`
+	Signature string    `json:"sig"`
+}
+
+// Delegate issues a delegation from s (acting as root) to nodeKey for ids.
+func (s *Signer) Delegate(nodeKey string, ids []string, notAfter time.Time) (*Delegation, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("delegation must name at least one id")
+	}
+
+	d := &Delegation{
+		RootKey:  s.publicKey,
+		NodeKey:  nodeKey,
+		IDs:      append([]string(nil), ids...),
+		NotAfter: notAfter.UTC(),
+	}
+	hash := d.signingHash()
+	sig, err := s.signHash(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	d.Signature = sig
+	return d, nil
+}
+
+// Verify checks that the delegation was signed by its RootKey.
+func (d *Delegation) Verify() error {
+	hash := d.signingHash()
+	if err := verifyHash(hash[:], d.Signature, d.RootKey); err != nil {
+		return fmt.Errorf("delegation to %s: %w", d.NodeKey, err)
+	}
+	return nil
+}
+
+// Covers reports whether the delegation authorizes signing id.
+func (d *Delegation) Covers(id string) bool {
+	for _, allowed := range d.IDs {
+		if allowed == DelegateAllIDs || allowed == id {
+			return true
+		}
+	}
+	return false
+}
+
+// signingHash is the digest the root signs. The sorted ids are encoded as a
+// JSON array, since ids may contain any separator a plain join would use.
+func (d *Delegation) signingHash() [32]byte {
+	ids := append([]string(nil), d.IDs...)
+	sort.Strings(ids)
+	encoded, _ := json.Marshal(ids) // a string slice always marshals
+	notAfter := ""
+	if !d.NotAfter.IsZero() {
+		notAfter = d.NotAfter.UTC().Format(time.RFC3339Nano)
+	}
+	payload := fmt.Sprintf("viracochan:delegation:v2:%s:%s:%s:%s",
+		d.RootKey, d.NodeKey, encoded, notAfter)
+	return sha256.Sum256([]byte(payload))
+}
+
+// VerifyDelegated verifies that cfg, stored under id, is signed either by one
+// of roots directly or by a node key holding a valid delegation from one of
+// roots that covers id and had not expired at cfg.Meta.Time. Delegations
+// issued by keys outside roots are ignored; there is no multi-level chaining.
+func VerifyDelegated(cfg *Config, id string, roots []string, delegations []Delegation) error {
+	if cfg.Meta.Signature == "" {
+		return fmt.Errorf("%w: version %d", ErrUnsignedConfig, cfg.Meta.Version)
+	}
+
+	trusted := make(map[string]bool, len(roots))
+	for _, root := range roots {
+		trusted[root] = true
+		if VerifyConfigSignature(cfg, root) == nil {
+			return nil
+		}
+	}
+
+	for i := range delegations {
+		d := &delegations[i]
+		if !trusted[d.RootKey] || !d.Covers(id) {
+			continue
+		}
+		if !d.NotAfter.IsZero() && !cfg.Meta.Time.Before(d.NotAfter) {
+			continue
+		}
+		if d.Verify() != nil {
+			continue
+		}
+		if VerifyConfigSignature(cfg, d.NodeKey) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: config %q version %d", ErrUntrustedSignature, id, cfg.Meta.Version)
+}
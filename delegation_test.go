@@ -0,0 +1,97 @@
+package viracochan
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func signedTestConfig(t *testing.T, signer *Signer) *Config {
+	t.Helper()
+	cfg := &Config{Content: json.RawMessage(`{"node":"a"}`)}
+	if err := cfg.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	if err := signer.Sign(cfg); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return cfg
+}
+
+func TestVerifyDelegated(t *testing.T) {
+	root, _ := NewSigner()
+	node, _ := NewSigner()
+	rogue, _ := NewSigner()
+	roots := []string{root.PublicKey()}
+
+	delegation, err := root.Delegate(node.PublicKey(), []string{"cluster-config"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Delegate failed: %v", err)
+	}
+	if err := delegation.Verify(); err != nil {
+		t.Fatalf("delegation does not verify: %v", err)
+	}
+
+	cfg := signedTestConfig(t, node)
+	delegations := []Delegation{*delegation}
+
+	if err := VerifyDelegated(cfg, "cluster-config", roots, delegations); err != nil {
+		t.Errorf("delegated signature rejected: %v", err)
+	}
+	if err := VerifyDelegated(signedTestConfig(t, root), "anything", roots, nil); err != nil {
+		t.Errorf("root signature rejected: %v", err)
+	}
+
+	if err := VerifyDelegated(cfg, "other-config", roots, delegations); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected id outside delegation to be rejected, got %v", err)
+	}
+	if err := VerifyDelegated(cfg, "cluster-config", []string{rogue.PublicKey()}, delegations); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected delegation from untrusted root to be ignored, got %v", err)
+	}
+
+	// A node cannot widen its own delegation
+	forged := *delegation
+	forged.IDs = []string{DelegateAllIDs}
+	if err := VerifyDelegated(cfg, "other-config", roots, []Delegation{forged}); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected tampered delegation to be rejected, got %v", err)
+	}
+
+	// Self-issued delegations do not chain trust
+	self, _ := rogue.Delegate(node.PublicKey(), []string{DelegateAllIDs}, time.Time{})
+	if err := VerifyDelegated(cfg, "cluster-config", roots, []Delegation{*self}); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected rogue delegation to be rejected, got %v", err)
+	}
+
+	expired, _ := root.Delegate(node.PublicKey(), []string{DelegateAllIDs}, cfg.Meta.Time.Add(-time.Second))
+	if err := VerifyDelegated(cfg, "cluster-config", roots, []Delegation{*expired}); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected config signed after delegation expiry to be rejected, got %v", err)
+	}
+
+	unsigned := &Config{Content: json.RawMessage(`{}`)}
+	unsigned.UpdateMeta()
+	if err := VerifyDelegated(unsigned, "cluster-config", roots, delegations); !errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("expected ErrUnsignedConfig, got %v", err)
+	}
+}
+
+func TestDelegationIDEncoding(t *testing.T) {
+	root, _ := NewSigner()
+	node, _ := NewSigner()
+
+	joined := Delegation{RootKey: root.PublicKey(), NodeKey: node.PublicKey(), IDs: []string{"a,b"}}
+	split := Delegation{RootKey: root.PublicKey(), NodeKey: node.PublicKey(), IDs: []string{"a", "b"}}
+	if joined.signingHash() == split.signingHash() {
+		t.Fatal(`delegations for "a,b" and for "a" and "b" sign the same hash`)
+	}
+
+	// A delegation for one id with a comma cannot be widened to two ids
+	d, err := root.Delegate(node.PublicKey(), []string{"a,b"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Delegate failed: %v", err)
+	}
+	d.IDs = []string{"a", "b"}
+	if err := d.Verify(); err == nil {
+		t.Error("expected a delegation with split ids to fail verification")
+	}
+}
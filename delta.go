@@ -0,0 +1,221 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ErrDeltaMismatch is returned when a delta-encoded version file cannot be
+// rebuilt: its base is missing or no longer holds the content it was diffed
+// against, or the delta itself is corrupt
+var ErrDeltaMismatch = errors.New("content delta does not match its base")
+
+// contentDelta replaces the content of a version file stored as a diff
+// against an earlier version of the same id (see WithDeltaStorage)
+type contentDelta struct {
+	Base    uint64 `json:"base"`     // version the delta applies to
+	BaseRef string `json:"base_ref"` // blobRef of the base content
+	Ops     []byte `json:"ops"`      // encodeDelta output
+}
+
+// WithDeltaStorage stores each version file as a binary diff against its
+// predecessor's content, with a full keyframe every keyframeInterval
+// versions (v1, v1+keyframeInterval, ...), so reading any version applies at
+// most keyframeInterval-1 diffs. A version is also stored in full when its
+// predecessor cannot be read or the diff would not be smaller. Checksums
+// and signatures still cover the full content, which Load rebuilds; stores
+// may mix full and delta files, and any manager reads both. The journal is
+// unaffected: with embedded content (the default, see
+// WithJournalEmbedContent) it still holds every version in full.
+//
+// Deleting a version file by hand breaks the deltas that follow it until the
+// next keyframe; quota pruning rewrites the oldest retained version in full
+// first.
+func WithDeltaStorage(keyframeInterval int) ManagerOption {
+	return func(m *Manager) error {
+		if keyframeInterval < 1 {
+			return errors.New("keyframe interval must be at least 1")
+		}
+		m.deltaKeyframes = keyframeInterval
+		return nil
+	}
+}
+
+// deltaFor returns cfg's content as a delta against its predecessor, or nil
+// when cfg should be stored in full
+func (cs *ConfigStorage) deltaFor(ctx context.Context, id string, cfg *Config) *contentDelta {
+	v := cfg.Meta.Version
+	if cs.keyframes <= 1 || v <= 1 || (v-1)%uint64(cs.keyframes) == 0 {
+		return nil
+	}
+
+	key := cs.makeKey(id, v-1)
+	data, err := cs.storage.Read(ctx, key)
+	if err != nil {
+		return nil
+	}
+	base, err := decodeConfigFile(ctx, cs.storage, key, data)
+	if err != nil {
+		return nil
+	}
+
+	ops := encodeDelta(base.Content, cfg.Content)
+	if len(ops) >= len(cfg.Content) {
+		return nil
+	}
+	return &contentDelta{Base: v - 1, BaseRef: blobRef(base.Content), Ops: ops}
+}
+
+// resolveDelta rebuilds the content of the version file at path from its
+// delta. Bases are read from the same directory.
+func resolveDelta(ctx context.Context, storage Storage, path string, version uint64, delta *contentDelta) ([]byte, error) {
+	if delta.Base >= version {
+		return nil, fmt.Errorf("%w: v%d is diffed against later v%d", ErrDeltaMismatch, version, delta.Base)
+	}
+
+	basePath := filepath.Join(filepath.Dir(path), fmt.Sprintf("v%d.json", delta.Base))
+	data, err := storage.Read(ctx, basePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: base v%d: %w", ErrDeltaMismatch, delta.Base, err)
+	}
+	base, err := decodeConfigFile(ctx, storage, basePath, data)
+	if err != nil {
+		return nil, fmt.Errorf("base v%d: %w", delta.Base, err)
+	}
+	if blobRef(base.Content) != delta.BaseRef {
+		return nil, fmt.Errorf("%w: base v%d was rewritten", ErrDeltaMismatch, delta.Base)
+	}
+	return applyDelta(base.Content, delta.Ops)
+}
+
+// keyframe rewrites the version file of id at version in full, so that it no
+// longer depends on earlier versions. Callers hold the manager's write lock.
+func (cs *ConfigStorage) keyframe(ctx context.Context, id string, version uint64) error {
+	key := cs.makeKey(id, version)
+	data, err := cs.storage.Read(ctx, key)
+	if err != nil {
+		return err
+	}
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Delta == nil {
+		return err
+	}
+
+	cfg, err := decodeConfigFile(ctx, cs.storage, key, data)
+	if err != nil {
+		return err
+	}
+	full, err := cs.encodeFull(cfg)
+	if err != nil {
+		return err
+	}
+	return cs.storage.Write(ctx, key, full)
+}
+
+// Delta ops, after a varint op code:
+//
+//	deltaInsert n, then n literal bytes
+//	deltaCopy offset n: n bytes of the base starting at offset
+const (
+	deltaInsert = 0
+	deltaCopy   = 1
+
+	// deltaBlock is the match granularity. Shorter runs of unchanged bytes
+	// are sent as literals.
+	deltaBlock = 16
+)
+
+// encodeDelta encodes target as copies from base and literal inserts. Blocks
+// of base are indexed at block boundaries, and each match is extended in
+// both directions, so edits anywhere in a large document cost little more
+// than the edited bytes.
+func encodeDelta(base, target []byte) []byte {
+	index := make(map[string]int, len(base)/deltaBlock)
+	for off := 0; off+deltaBlock <= len(base); off += deltaBlock {
+		if _, ok := index[string(base[off:off+deltaBlock])]; !ok {
+			index[string(base[off:off+deltaBlock])] = off
+		}
+	}
+
+	var out []byte
+	insert := func(lit []byte) {
+		if len(lit) == 0 {
+			return
+		}
+		out = binary.AppendUvarint(out, deltaInsert)
+		out = binary.AppendUvarint(out, uint64(len(lit)))
+		out = append(out, lit...)
+	}
+
+	lit := 0
+	for i := 0; i+deltaBlock <= len(target); {
+		off, ok := index[string(target[i:i+deltaBlock])]
+		if !ok {
+			i++
+			continue
+		}
+
+		start, from := i, off
+		for start > lit && from > 0 && target[start-1] == base[from-1] {
+			start--
+			from--
+		}
+		end, to := i+deltaBlock, off+deltaBlock
+		for end < len(target) && to < len(base) && target[end] == base[to] {
+			end++
+			to++
+		}
+
+		insert(target[lit:start])
+		out = binary.AppendUvarint(out, deltaCopy)
+		out = binary.AppendUvarint(out, uint64(from))
+		out = binary.AppendUvarint(out, uint64(end-start))
+		lit, i = end, end
+	}
+	insert(target[lit:])
+	return out
+}
+
+// applyDelta rebuilds the target that encodeDelta encoded against base
+func applyDelta(base, delta []byte) ([]byte, error) {
+	corrupt := fmt.Errorf("%w: corrupt delta", ErrDeltaMismatch)
+	next := func() (uint64, bool) {
+		v, n := binary.Uvarint(delta)
+		if n <= 0 {
+			return 0, false
+		}
+		delta = delta[n:]
+		return v, true
+	}
+
+	var out []byte
+	for len(delta) > 0 {
+		op, ok := next()
+		if !ok {
+			return nil, corrupt
+		}
+		switch op {
+		case deltaInsert:
+			n, ok := next()
+			if !ok || n > uint64(len(delta)) {
+				return nil, corrupt
+			}
+			out = append(out, delta[:n]...)
+			delta = delta[n:]
+		case deltaCopy:
+			off, ok1 := next()
+			n, ok2 := next()
+			if !ok1 || !ok2 || off > uint64(len(base)) || n > uint64(len(base))-off {
+				return nil, corrupt
+			}
+			out = append(out, base[off:off+n]...)
+		default:
+			return nil, corrupt
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,127 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 4096)
+	rng.Read(base)
+
+	for i := 0; i < 50; i++ {
+		target := bytes.Clone(base)
+		for edits := rng.Intn(5); edits >= 0; edits-- {
+			at := rng.Intn(len(target))
+			switch rng.Intn(3) {
+			case 0:
+				target[at] ^= 0xff
+			case 1:
+				target = append(target[:at], append([]byte("inserted"), target[at:]...)...)
+			default:
+				target = append(target[:at], target[min(at+20, len(target)):]...)
+			}
+		}
+
+		delta := encodeDelta(base, target)
+		got, err := applyDelta(base, delta)
+		if err != nil || !bytes.Equal(got, target) {
+			t.Fatalf("round %d: round trip failed: %v", i, err)
+		}
+		if len(delta) > len(target)/4 {
+			t.Errorf("round %d: delta of %d bytes for a few edits of %d", i, len(delta), len(target))
+		}
+	}
+
+	if _, err := applyDelta(base, []byte{deltaCopy, 0xff, 0x7f}); !errors.Is(err, ErrDeltaMismatch) {
+		t.Errorf("expected ErrDeltaMismatch for an out-of-range copy, got %v", err)
+	}
+}
+
+func TestDeltaStorage(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer), WithDeltaStorage(4))
+
+	content := make(map[string]interface{})
+	for i := 0; i < 200; i++ {
+		content[fmt.Sprintf("key-%03d", i)] = strings.Repeat("x", 20)
+	}
+	manager.Create(ctx, "app", content)
+	for i := 1; i < 10; i++ {
+		content[fmt.Sprintf("key-%03d", i*7)] = fmt.Sprintf("changed-%d", i)
+		if _, err := manager.Update(ctx, "app", content); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	full := len(mustRead(t, storage, "configs/app/v1.json"))
+	for v := 1; v <= 10; v++ {
+		data := mustRead(t, storage, fmt.Sprintf("configs/app/v%d.json", v))
+		keyframe := (v-1)%4 == 0
+		if bytes.Contains(data, []byte(`"delta"`)) == keyframe {
+			t.Errorf("v%d: keyframe=%t but file is %d bytes", v, keyframe, len(data))
+		}
+		if !keyframe && len(data) > full/4 {
+			t.Errorf("v%d: delta file of %d bytes, full is %d", v, len(data), full)
+		}
+	}
+
+	// Any manager reads delta files, and they verify as full content
+	reader, _ := NewManager(storage)
+	history, err := reader.GetHistory(ctx, "app")
+	if err != nil || len(history) != 10 {
+		t.Fatalf("GetHistory failed: %d, %v", len(history), err)
+	}
+	for _, cfg := range history {
+		if err := VerifyConfigSignature(cfg, signer.PublicKey()); err != nil {
+			t.Errorf("v%d: %v", cfg.Meta.Version, err)
+		}
+	}
+	if report, _ := reader.Audit(ctx, signer.PublicKey()); !report.OK() {
+		t.Errorf("audit of a delta store: %+v", report.Issues)
+	}
+
+	// A rewritten base is detected rather than silently misapplied
+	v2 := mustRead(t, storage, "configs/app/v2.json")
+	storage.Write(ctx, "configs/app/v2.json", bytes.Replace(mustRead(t, storage, "configs/app/v1.json"), []byte("x"), []byte("y"), 1))
+	if _, err := manager.configStore.Load(ctx, "app", 3); !errors.Is(err, ErrDeltaMismatch) {
+		t.Errorf("expected ErrDeltaMismatch, got %v", err)
+	}
+	storage.Write(ctx, "configs/app/v2.json", v2)
+}
+
+func TestDeltaStorageQuotaPrune(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithDeltaStorage(8), WithQuota(Quota{MaxVersions: 3, AutoPrune: true}))
+
+	content := map[string]interface{}{"blob": strings.Repeat("abcdefgh", 100), "n": 0}
+	manager.Create(ctx, "app", content)
+	for i := 1; i < 6; i++ {
+		content["n"] = i
+		if _, err := manager.Update(ctx, "app", content); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	versions, _ := manager.configStore.ListVersions(ctx, "app")
+	if len(versions) != 3 || versions[0] != 4 {
+		t.Fatalf("expected v4-v6 retained, got %v", versions)
+	}
+	if bytes.Contains(mustRead(t, storage, "configs/app/v4.json"), []byte(`"delta"`)) {
+		t.Error("oldest retained version should be a keyframe")
+	}
+	for _, v := range versions {
+		if _, err := manager.Get(ctx, "app", v); err != nil {
+			t.Errorf("v%d unreadable after pruning: %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package viracochan
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind classifies a single difference reported by Diff.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// Change is one difference between two contents. Path is the dot separated
+// chain of object keys leading to the value (empty for the content root);
+// arrays and scalars are compared as whole values. Old is unset for added
+// values and New for removed ones.
+type Change struct {
+	Path string          `json:"path"`
+	Kind ChangeKind      `json:"kind"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// Diff reports how content b differs from content a, ordered by path. Values
+// are compared after decoding, so formatting and key order do not matter.
+func Diff(a, b json.RawMessage) ([]Change, error) {
+	var av, bv interface{}
+	if len(a) > 0 {
+		if err := json.Unmarshal(a, &av); err != nil {
+			return nil, err
+		}
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &bv); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []Change
+	if err := diffValue(nil, av, bv, &changes); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func diffValue(path []string, a, b interface{}, changes *[]Change) error {
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	am, aIsMap := a.(map[string]interface{})
+	bm, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		for k, av := range am {
+			bv, ok := bm[k]
+			if !ok {
+				if err := appendChange(changes, append(path, k), ChangeRemoved, av, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := diffValue(append(path, k), av, bv, changes); err != nil {
+				return err
+			}
+		}
+		for k, bv := range bm {
+			if _, ok := am[k]; !ok {
+				if err := appendChange(changes, append(path, k), ChangeAdded, nil, bv); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return appendChange(changes, path, ChangeChanged, a, b)
+}
+
+func appendChange(changes *[]Change, path []string, kind ChangeKind, old, updated interface{}) error {
+	c := Change{Path: strings.Join(path, "."), Kind: kind}
+	if kind != ChangeAdded {
+		data, err := canonicalJSON(old)
+		if err != nil {
+			return err
+		}
+		c.Old = data
+	}
+	if kind != ChangeRemoved {
+		data, err := canonicalJSON(updated)
+		if err != nil {
+			return err
+		}
+		c.New = data
+	}
+	*changes = append(*changes, c)
+	return nil
+}
+
+// FieldChanged returns a WatchWhere predicate reporting whether the value at
+// the dotted path (such as "database.host") differs between the old and new
+// content. A value missing from either side, including when old is nil,
+// counts as absent, so appearing or disappearing is a change.
+func FieldChanged(path string) func(old, new *Config) bool {
+	keys := strings.Split(path, ".")
+	return func(old, new *Config) bool {
+		ov, oldOK := lookupField(old, keys)
+		nv, newOK := lookupField(new, keys)
+		return oldOK != newOK || !reflect.DeepEqual(ov, nv)
+	}
+}
+
+func lookupField(cfg *Config, keys []string) (interface{}, bool) {
+	if cfg == nil {
+		return nil, false
+	}
+	var v interface{}
+	if err := json.Unmarshal(cfg.Content, &v); err != nil {
+		return nil, false
+	}
+	for _, key := range keys {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,57 @@
+package viracochan
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := json.RawMessage(`{"db":{"host":"a","port":5432},"debug":true,"tags":["x"]}`)
+	b := json.RawMessage(`{"db":{"host":"b","port":5432,"pool":10},"tags":["x","y"]}`)
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	want := []Change{
+		{Path: "db.host", Kind: ChangeChanged, Old: json.RawMessage(`"a"`), New: json.RawMessage(`"b"`)},
+		{Path: "db.pool", Kind: ChangeAdded, New: json.RawMessage(`10`)},
+		{Path: "debug", Kind: ChangeRemoved, Old: json.RawMessage(`true`)},
+		{Path: "tags", Kind: ChangeChanged, Old: json.RawMessage(`["x"]`), New: json.RawMessage(`["x","y"]`)},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("unexpected diff:\ngot  %+v\nwant %+v", changes, want)
+	}
+
+	if changes, _ := Diff(json.RawMessage(`{"a": 1, "b": 2}`), json.RawMessage(`{"b":2,"a":1}`)); len(changes) != 0 {
+		t.Errorf("expected formatting-only change to produce no diff, got %+v", changes)
+	}
+
+	root, _ := Diff(json.RawMessage(`1`), json.RawMessage(`"one"`))
+	if len(root) != 1 || root[0].Path != "" || root[0].Kind != ChangeChanged {
+		t.Errorf("expected a single root change, got %+v", root)
+	}
+}
+
+func TestFieldChanged(t *testing.T) {
+	cfg := func(content string) *Config { return &Config{Content: json.RawMessage(content)} }
+	changed := FieldChanged("db.host")
+
+	cases := []struct {
+		old, new *Config
+		want     bool
+	}{
+		{cfg(`{"db":{"host":"a","port":1}}`), cfg(`{"db":{"host":"a","port":2}}`), false},
+		{cfg(`{"db":{"host":"a"}}`), cfg(`{"db":{"host":"b"}}`), true},
+		{cfg(`{"db":{}}`), cfg(`{"db":{"host":"a"}}`), true},
+		{nil, cfg(`{"db":{"host":"a"}}`), true},
+		{nil, cfg(`{"other":1}`), false},
+	}
+	for i, c := range cases {
+		if got := changed(c.old, c.new); got != c.want {
+			t.Errorf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+}
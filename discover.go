@@ -0,0 +1,140 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrAmbiguousJournal is returned by OpenExisting when storage holds more
+// than one candidate journal; the error lists them so the caller can pick
+// one with WithJournalPath
+var ErrAmbiguousJournal = errors.New("more than one journal found")
+
+// ErrNoJournal is returned by OpenExisting when storage holds no candidate
+// journal
+var ErrNoJournal = errors.New("no journal found")
+
+// ErrAmbiguousConfigPrefix is returned by OpenExisting when version files
+// sit under more than one directory; the error lists them so the caller can
+// pick one with WithConfigPrefix
+var ErrAmbiguousConfigPrefix = errors.New("more than one config prefix found")
+
+// journalExtensions are the file extensions DiscoverJournals accepts
+var journalExtensions = []string{".jsonl", ".journal"}
+
+// DiscoverJournals lists, sorted, the files in storage that may be journals:
+// those ending in ".jsonl" or ".journal" outside the directories that
+// DiscoverConfigPrefixes finds. Only names are checked, so a candidate may
+// still turn out not to be a journal; sidecars such as "<journal>.fence" and
+// "<journal>.intents" are not listed.
+func DiscoverJournals(ctx context.Context, storage Storage) ([]string, error) {
+	paths, err := storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return journalCandidates(paths, configPrefixes(paths)), nil
+}
+
+// DiscoverConfigPrefixes lists, sorted, the directories in storage that hold
+// version files laid out as "<prefix>/<id>/v<N>.json", the layout
+// WithConfigPrefix reads. Version-like files directly under the top of the
+// storage or in the manager's own directories, such as snapshots, are not
+// taken for a prefix.
+func DiscoverConfigPrefixes(ctx context.Context, storage Storage) ([]string, error) {
+	paths, err := storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return configPrefixes(paths), nil
+}
+
+// configPrefixes returns the config prefixes among paths; see
+// DiscoverConfigPrefixes
+func configPrefixes(paths []string) []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, path := range paths {
+		clean := filepath.Clean(path)
+		if !isVersionFile(filepath.Base(clean)) {
+			continue
+		}
+		prefix := filepath.Dir(filepath.Dir(clean))
+		if prefix == "." || seen[prefix] || isManagerDir(prefix) {
+			continue
+		}
+		seen[prefix] = true
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// isManagerDir reports whether dir is, or is inside, one of the top-level
+// directories the manager keeps its own files in
+func isManagerDir(dir string) bool {
+	top, _, _ := strings.Cut(dir, string(filepath.Separator))
+	return slices.Contains([]string{channelPrefix, schemaPrefix, snapshotPrefix, basePrefix, blobPrefix}, top)
+}
+
+// journalCandidates returns, sorted, the paths that may be journals, leaving
+// out files under any of prefixes
+func journalCandidates(paths, prefixes []string) []string {
+	var journals []string
+	for _, path := range paths {
+		clean := filepath.Clean(path)
+		if slices.ContainsFunc(prefixes, func(prefix string) bool {
+			return strings.HasPrefix(clean, prefix+string(filepath.Separator))
+		}) {
+			continue
+		}
+		if slices.Contains(journalExtensions, filepath.Ext(clean)) {
+			journals = append(journals, path)
+		}
+	}
+	sort.Strings(journals)
+	return journals
+}
+
+// OpenExisting opens a manager over a store written by another tool or
+// deployment, detecting its layout: the journal, which may not be the default
+// "journal.jsonl", and the config prefix, which may not be "configs". The
+// journal is the one candidate found by DiscoverJournals and the prefix the
+// one found by DiscoverConfigPrefixes. With no journal it fails with
+// ErrNoJournal, with several journals or prefixes with ErrAmbiguousJournal
+// or ErrAmbiguousConfigPrefix, and the caller then names them with
+// WithJournalPath and WithConfigPrefix. A store with no version files, whose
+// journal embeds them, is opened with DefaultConfigPrefix. opts are applied
+// after the discovered layout.
+func OpenExisting(ctx context.Context, storage Storage, opts ...ManagerOption) (*Manager, error) {
+	paths, err := storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := configPrefixes(paths)
+	prefix := DefaultConfigPrefix
+	switch len(prefixes) {
+	case 0:
+	case 1:
+		prefix = prefixes[0]
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrAmbiguousConfigPrefix, strings.Join(prefixes, ", "))
+	}
+
+	journals := journalCandidates(paths, prefixes)
+	switch len(journals) {
+	case 0:
+		return nil, ErrNoJournal
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrAmbiguousJournal, strings.Join(journals, ", "))
+	}
+
+	layout := []ManagerOption{WithJournalPath(journals[0]), WithConfigPrefix(prefix)}
+	return NewManager(storage, append(layout, opts...)...)
+}
@@ -0,0 +1,97 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverJournals(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	if _, err := OpenExisting(ctx, storage); !errors.Is(err, ErrNoJournal) {
+		t.Fatalf("OpenExisting on an empty store: got %v, want ErrNoJournal", err)
+	}
+
+	writer, _ := NewManager(storage, WithJournalPath("ops/primary.journal"), WithFenceToken(1), WithIntentLog())
+	if _, err := writer.Create(ctx, "app", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	writer.Update(ctx, "app", map[string]interface{}{"n": 2})
+	storage.Write(ctx, "configs/app/notes.jsonl", []byte("{}\n"))
+	storage.Write(ctx, "export.json", []byte("{}"))
+
+	journals, err := DiscoverJournals(ctx, storage)
+	if err != nil {
+		t.Fatalf("DiscoverJournals failed: %v", err)
+	}
+	if want := []string{"ops/primary.journal"}; !reflect.DeepEqual(journals, want) {
+		t.Errorf("journals = %v, want %v", journals, want)
+	}
+
+	opened, err := OpenExisting(ctx, storage)
+	if err != nil {
+		t.Fatalf("OpenExisting failed: %v", err)
+	}
+	if cfg, err := opened.GetLatest(ctx, "app"); err != nil || cfg.Meta.Version != 2 {
+		t.Errorf("GetLatest through the discovered journal: %v, %v", cfg, err)
+	}
+	if err := opened.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("ValidateChain through the discovered journal: %v", err)
+	}
+
+	storage.Write(ctx, "journal.jsonl", nil)
+	journals, _ = DiscoverJournals(ctx, storage)
+	if want := []string{"journal.jsonl", "ops/primary.journal"}; !reflect.DeepEqual(journals, want) {
+		t.Errorf("journals = %v, want %v", journals, want)
+	}
+	if _, err := OpenExisting(ctx, storage); !errors.Is(err, ErrAmbiguousJournal) {
+		t.Errorf("OpenExisting with two journals: got %v, want ErrAmbiguousJournal", err)
+	}
+}
+
+func TestOpenExistingConfigPrefix(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	// Without embedded content the head can only come from its version file
+	writer, err := NewManager(storage, WithJournalPath("ops/log.jsonl"), WithConfigPrefix("data/versions"), WithJournalEmbedContent(false))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	writer.Create(ctx, "app", map[string]interface{}{"n": 1})
+	writer.Update(ctx, "app", map[string]interface{}{"n": 2})
+	writer.CreateSnapshot(ctx, "v1")
+
+	prefixes, err := DiscoverConfigPrefixes(ctx, storage)
+	if err != nil {
+		t.Fatalf("DiscoverConfigPrefixes failed: %v", err)
+	}
+	if want := []string{"data/versions"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("prefixes = %v, want %v", prefixes, want)
+	}
+
+	opened, err := OpenExisting(ctx, storage)
+	if err != nil {
+		t.Fatalf("OpenExisting failed: %v", err)
+	}
+	if cfg, err := opened.GetLatest(ctx, "app"); err != nil || cfg.Meta.Version != 2 {
+		t.Errorf("GetLatest through the discovered prefix: %v, %v", cfg, err)
+	}
+	if history, _ := opened.GetHistory(ctx, "app"); len(history) != 2 {
+		t.Errorf("expected 2 versions under the discovered prefix, got %d", len(history))
+	}
+
+	storage.Write(ctx, "configs/other/v1.json", []byte("{}"))
+	if _, err := OpenExisting(ctx, storage); !errors.Is(err, ErrAmbiguousConfigPrefix) {
+		t.Errorf("OpenExisting with two prefixes: got %v, want ErrAmbiguousConfigPrefix", err)
+	}
+
+	for _, prefix := range []string{"", ".", "../up", "/abs"} {
+		if _, err := NewManager(NewMemoryStorage(), WithConfigPrefix(prefix)); err == nil {
+			t.Errorf("expected config prefix %q to be rejected", prefix)
+		}
+	}
+}
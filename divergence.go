@@ -0,0 +1,86 @@
+package viracochan
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DivergenceReport locates where two histories of the same config split.
+// Checksums cover the previous checksum, so versions up to CommonVersion are
+// identical on both sides. ATail and BTail hold each side's versions after
+// it, oldest first. When only one tail is non-empty the other side is simply
+// behind (Forked is false); when both are, the histories forked there.
+type DivergenceReport struct {
+	CommonVersion uint64    `json:"common_version"` // 0 if no version matches
+	CommonCS      string    `json:"common_cs,omitempty"`
+	Forked        bool      `json:"forked"`
+	ATail         []*Config `json:"a_tail,omitempty"`
+	BTail         []*Config `json:"b_tail,omitempty"`
+}
+
+// Lag returns how many versions the side without a tail is behind, or 0 when
+// the histories are equal or forked
+func (r *DivergenceReport) Lag() int {
+	if r.Forked {
+		return 0
+	}
+	return len(r.ATail) + len(r.BTail)
+}
+
+// CompareHistories compares two histories of one config, for example the
+// GetHistory results of two nodes, and reports their last common version
+// and the divergent tails. The histories may be in any order and may start
+// after v1 (as after compaction); only the versions both hold can be matched.
+// A history holding two versions with the same number is an error.
+func CompareHistories(a, b []*Config) (*DivergenceReport, error) {
+	as, err := byVersion(a)
+	if err != nil {
+		return nil, fmt.Errorf("history a: %w", err)
+	}
+	bs, err := byVersion(b)
+	if err != nil {
+		return nil, fmt.Errorf("history b: %w", err)
+	}
+
+	report := &DivergenceReport{}
+	bIndex := make(map[uint64]*Config, len(bs))
+	for _, cfg := range bs {
+		bIndex[cfg.Meta.Version] = cfg
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		if other, ok := bIndex[as[i].Meta.Version]; ok && other.Meta.CS == as[i].Meta.CS {
+			report.CommonVersion = as[i].Meta.Version
+			report.CommonCS = as[i].Meta.CS
+			break
+		}
+	}
+
+	report.ATail = tailAfter(as, report.CommonVersion)
+	report.BTail = tailAfter(bs, report.CommonVersion)
+	report.Forked = len(report.ATail) > 0 && len(report.BTail) > 0
+	return report, nil
+}
+
+// byVersion returns history sorted by version, rejecting repeated versions
+func byVersion(history []*Config) ([]*Config, error) {
+	sorted := append([]*Config(nil), history...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Meta.Version < sorted[j].Meta.Version
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Meta.Version == sorted[i-1].Meta.Version {
+			return nil, fmt.Errorf("%w: version %d appears twice", ErrInvalidChain, sorted[i].Meta.Version)
+		}
+	}
+	return sorted, nil
+}
+
+func tailAfter(sorted []*Config, version uint64) []*Config {
+	i := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i].Meta.Version > version
+	})
+	if i == len(sorted) {
+		return nil
+	}
+	return sorted[i:]
+}
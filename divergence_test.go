@@ -0,0 +1,52 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCompareHistories(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	// A second node copies the store at v2, then both write their own v3
+	other, _ := NewManager(storage.Clone())
+	manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+	other.Update(ctx, "app", map[string]interface{}{"n": 30})
+	other.Update(ctx, "app", map[string]interface{}{"n": 40})
+
+	trunk, _ := manager.GetHistory(ctx, "app")
+	branch, _ := other.GetHistory(ctx, "app")
+
+	// A follower that has seen only v1 and v2 merely lags
+	lag, err := CompareHistories(trunk, trunk[:2])
+	if err != nil {
+		t.Fatalf("CompareHistories failed: %v", err)
+	}
+	if lag.Forked || lag.CommonVersion != 2 || len(lag.ATail) != 1 || len(lag.BTail) != 0 || lag.Lag() != 1 {
+		t.Errorf("expected b one version behind, got %+v", lag)
+	}
+
+	report, err := CompareHistories(trunk, branch)
+	if err != nil {
+		t.Fatalf("CompareHistories failed: %v", err)
+	}
+	if !report.Forked || report.CommonVersion != 2 || report.CommonCS != trunk[1].Meta.CS || report.Lag() != 0 {
+		t.Errorf("expected a fork after v2, got %+v", report)
+	}
+	if len(report.ATail) != 1 || len(report.BTail) != 2 || report.BTail[0].Meta.Version != 3 {
+		t.Errorf("unexpected tails: %d and %d versions", len(report.ATail), len(report.BTail))
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("report does not serialize: %v", err)
+	}
+
+	if _, err := CompareHistories(append(trunk, trunk[0]), trunk); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain for a repeated version, got %v", err)
+	}
+}
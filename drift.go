@@ -0,0 +1,138 @@
+package viracochan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSchemaDrift is returned by writes made WithSchemaDriftCheck when the new
+// content changes the structure of the current version
+var ErrSchemaDrift = errors.New("schema drift")
+
+// Drift is one structural difference reported by SchemaDrift: a value that
+// was removed (Kind ChangeRemoved) or whose JSON type changed (ChangeChanged).
+// Types are "object", "array", "string", "number" or "boolean".
+type Drift struct {
+	Path    string     `json:"path"`
+	Kind    ChangeKind `json:"kind"`
+	OldType string     `json:"old_type"`
+	NewType string     `json:"new_type,omitempty"`
+}
+
+func (d Drift) String() string {
+	path := d.Path
+	if path == "" {
+		path = "content"
+	}
+	if d.Kind == ChangeRemoved {
+		return fmt.Sprintf("%s removed (was %s)", path, d.OldType)
+	}
+	return fmt.Sprintf("%s changed from %s to %s", path, d.OldType, d.NewType)
+}
+
+// SchemaDrift compares the structure of content b against content a and
+// reports, ordered by path, every value of a that is missing from b or has
+// a different JSON type there. Only shape counts, not values: new keys are
+// not drift, nor are changes to or from null, and arrays are compared as a
+// type without looking at their elements.
+func SchemaDrift(a, b json.RawMessage) ([]Drift, error) {
+	changes, err := Diff(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []Drift
+	for _, c := range changes {
+		switch c.Kind {
+		case ChangeRemoved:
+			drift = append(drift, Drift{Path: c.Path, Kind: c.Kind, OldType: jsonType(c.Old)})
+		case ChangeChanged:
+			oldType, newType := jsonType(c.Old), jsonType(c.New)
+			if oldType != newType && oldType != "null" && newType != "null" {
+				drift = append(drift, Drift{Path: c.Path, Kind: c.Kind, OldType: oldType, NewType: newType})
+			}
+		}
+	}
+	return drift, nil
+}
+
+// jsonType names the JSON type of an encoded value
+func jsonType(data json.RawMessage) string {
+	if len(data) == 0 {
+		return "null"
+	}
+	switch data[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
+
+// driftMode selects what a write does about schema drift
+type driftMode int
+
+const (
+	driftOff driftMode = iota
+	driftWarn
+	driftReject
+)
+
+// WithSchemaDriftCheck rejects an update whose content changes the structure
+// of the current version (see SchemaDrift) with ErrSchemaDrift, listing every
+// drifted path. It applies to Update and MergeUpdate of JSON content;
+// rollbacks, which restore an earlier shape on purpose, are not checked.
+func WithSchemaDriftCheck() WriteOption {
+	return func(o *writeOptions) {
+		o.drift = driftReject
+	}
+}
+
+// WithSchemaDriftWarning is like WithSchemaDriftCheck but only logs each
+// drifted path as a warning and lets the update through
+func WithSchemaDriftWarning() WriteOption {
+	return func(o *writeOptions) {
+		o.drift = driftWarn
+	}
+}
+
+// checkDrift applies wo's drift mode to an update of id from current to data
+func (m *Manager) checkDrift(id string, current *Config, data json.RawMessage, wo *writeOptions) error {
+	if wo.drift == driftOff {
+		return nil
+	}
+	newType := current.Meta.ContentType
+	if wo.contentType != nil {
+		newType = *wo.contentType
+	}
+	if !isJSONContentType(current.Meta.ContentType) || !isJSONContentType(newType) {
+		return nil
+	}
+
+	drift, err := SchemaDrift(current.Content, data)
+	if err != nil || len(drift) == 0 {
+		return err
+	}
+
+	if wo.drift == driftWarn {
+		for _, d := range drift {
+			m.logger.Warn("schema drift", "id", id, "version", current.Meta.Version, "drift", d.String())
+		}
+		return nil
+	}
+
+	list := make([]string, len(drift))
+	for i, d := range drift {
+		list[i] = d.String()
+	}
+	return fmt.Errorf("%w in %q since v%d: %s", ErrSchemaDrift, id, current.Meta.Version, strings.Join(list, "; "))
+}
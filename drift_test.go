@@ -0,0 +1,57 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSchemaDrift(t *testing.T) {
+	a := json.RawMessage(`{"database":{"host":"a","port":5432},"cache":{"ttl":1},"tags":["x"],"note":null}`)
+	b := json.RawMessage(`{"database":{"host":"b","port":"5432"},"cache":true,"tags":[],"note":"set","new":1}`)
+
+	drift, err := SchemaDrift(a, b)
+	if err != nil {
+		t.Fatalf("SchemaDrift failed: %v", err)
+	}
+	var got []string
+	for _, d := range drift {
+		got = append(got, d.String())
+	}
+	want := []string{"cache changed from object to boolean", "database.port changed from number to string"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	removed, _ := SchemaDrift(a, json.RawMessage(`{"database":{"host":"a"}}`))
+	if len(removed) != 4 || removed[0].Kind != ChangeRemoved {
+		t.Errorf("expected removed cache, database.port, note and tags, got %v", removed)
+	}
+}
+
+func TestManagerSchemaDriftCheck(t *testing.T) {
+	ctx := context.Background()
+	logger := &recordingLogger{}
+	manager, _ := NewManager(NewMemoryStorage(), WithLogger(logger))
+
+	manager.Create(ctx, "app", map[string]interface{}{"database": map[string]interface{}{"port": 5432}})
+
+	_, err := manager.Update(ctx, "app", map[string]interface{}{"database": map[string]interface{}{"port": "5432"}}, WithSchemaDriftCheck())
+	if !errors.Is(err, ErrSchemaDrift) || !strings.Contains(err.Error(), "database.port changed from number to string") {
+		t.Fatalf("expected ErrSchemaDrift naming database.port, got %v", err)
+	}
+
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"database": map[string]interface{}{"port": 6432}}, WithSchemaDriftCheck()); err != nil {
+		t.Errorf("value-only change should pass, got %v", err)
+	}
+
+	cfg, err := manager.Update(ctx, "app", map[string]interface{}{"database": "db:5432"}, WithSchemaDriftWarning())
+	if err != nil || cfg.Meta.Version != 3 {
+		t.Fatalf("warn mode should let the update through, got %v", err)
+	}
+	if len(logger.warns) != 1 || !strings.Contains(logger.warns[0], "database changed from object to string") {
+		t.Errorf("expected one schema drift warning, got %v", logger.warns)
+	}
+}
@@ -0,0 +1,185 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// encryptionMagic starts every envelope written by EncryptedStorage
+var encryptionMagic = []byte("VCE1")
+
+var (
+	// ErrNotEncrypted is returned when stored data is not an envelope
+	ErrNotEncrypted = errors.New("data is not an encrypted envelope")
+	// ErrUnknownKey is returned when an envelope names a key id that
+	// EncryptedStorage does not hold
+	ErrUnknownKey = errors.New("unknown encryption key id")
+)
+
+// EncryptedStorage wraps a Storage and encrypts everything written through
+// it with AES-256-GCM. Each object is stored as an envelope:
+//
+//	"VCE1" | key id length (1 byte) | key id | nonce (12 bytes) | ciphertext+tag
+//
+// The header and the object path are authenticated as additional data, so an
+// envelope cannot be relabeled with another key id or moved to another path.
+// Writes always use the current key; reads pick the key named in the
+// envelope, which lets older objects stay readable across key rotation.
+type EncryptedStorage struct {
+	backend Storage
+	keyID   string
+	keys    map[string]cipher.AEAD
+	rand    io.Reader
+}
+
+// EncryptionOption configures EncryptedStorage
+type EncryptionOption func(*EncryptedStorage) error
+
+// WithDecryptionKey adds a retired key that is used only to read envelopes
+// written under keyID. A keyID that is already registered, the write key's
+// included, is an error.
+func WithDecryptionKey(keyID string, key []byte) EncryptionOption {
+	return func(es *EncryptedStorage) error {
+		return es.addKey(keyID, key)
+	}
+}
+
+// WithNonceSourceForTesting replaces crypto/rand as the source of GCM nonces.
+//
+// It exists only so tests can produce reproducible ciphertext (for example
+// golden files of the envelope format). Reusing a nonce under the same key
+// breaks GCM completely, so a predictable reader must never be used outside
+// tests. A nil reader keeps crypto/rand.
+func WithNonceSourceForTesting(r io.Reader) EncryptionOption {
+	return func(es *EncryptedStorage) error {
+		if r != nil {
+			es.rand = r
+		}
+		return nil
+	}
+}
+
+// NewEncryptedStorage wraps backend, encrypting writes with the 32-byte key
+// identified by keyID
+func NewEncryptedStorage(backend Storage, keyID string, key []byte, opts ...EncryptionOption) (*EncryptedStorage, error) {
+	es := &EncryptedStorage{
+		backend: backend,
+		keyID:   keyID,
+		keys:    make(map[string]cipher.AEAD),
+		rand:    rand.Reader,
+	}
+	if err := es.addKey(keyID, key); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if err := opt(es); err != nil {
+			return nil, err
+		}
+	}
+	return es, nil
+}
+
+func (es *EncryptedStorage) addKey(keyID string, key []byte) error {
+	if keyID == "" || len(keyID) > 255 {
+		return errors.New("encryption key id must be 1-255 bytes")
+	}
+	if len(key) != 32 {
+		return errors.New("encryption key must be 32 bytes")
+	}
+	if _, ok := es.keys[keyID]; ok {
+		return fmt.Errorf("encryption key id %q is already registered", keyID)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	es.keys[keyID] = aead
+	return nil
+}
+
+func envelopeHeader(keyID string) []byte {
+	header := make([]byte, 0, len(encryptionMagic)+1+len(keyID))
+	header = append(header, encryptionMagic...)
+	header = append(header, byte(len(keyID)))
+	return append(header, keyID...)
+}
+
+func envelopeAAD(header []byte, path string) []byte {
+	return append(append([]byte(nil), header...), path...)
+}
+
+func (es *EncryptedStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	data, err := es.backend.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, encryptionMagic) || len(data) < len(encryptionMagic)+1 {
+		return nil, fmt.Errorf("%w: %s", ErrNotEncrypted, path)
+	}
+	idLen := int(data[len(encryptionMagic)])
+	headerLen := len(encryptionMagic) + 1 + idLen
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("%w: %s: truncated header", ErrNotEncrypted, path)
+	}
+	header, rest := data[:headerLen], data[headerLen:]
+	keyID := string(header[len(encryptionMagic)+1:])
+
+	aead, ok := es.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q: %s", ErrUnknownKey, keyID, path)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: %s: truncated nonce", ErrNotEncrypted, path)
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, envelopeAAD(header, path))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
+func (es *EncryptedStorage) Write(ctx context.Context, path string, data []byte) error {
+	aead := es.keys[es.keyID]
+	header := envelopeHeader(es.keyID)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(es.rand, nonce); err != nil {
+		return fmt.Errorf("nonce generation failed: %w", err)
+	}
+
+	envelope := append(header, nonce...) //nolint:gocritic // header is freshly allocated
+	envelope = aead.Seal(envelope, nonce, data, envelopeAAD(header, path))
+	return es.backend.Write(ctx, path, envelope)
+}
+
+func (es *EncryptedStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	return es.backend.List(ctx, prefix)
+}
+
+func (es *EncryptedStorage) Delete(ctx context.Context, path string) error {
+	return es.backend.Delete(ctx, path)
+}
+
+func (es *EncryptedStorage) Exists(ctx context.Context, path string) (bool, error) {
+	return es.backend.Exists(ctx, path)
+}
+
+// PathLock passes on the backend's lock of path, see PathLocker
+func (es *EncryptedStorage) PathLock(path string) sync.Locker {
+	return pathLock(es.backend, path)
+}
@@ -0,0 +1,115 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestEncryptedStorageGolden(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStorage()
+	es, err := NewEncryptedStorage(backend, "k1", testEncryptionKey,
+		WithNonceSourceForTesting(bytes.NewReader(bytes.Repeat([]byte{0x01}, 12))))
+	if err != nil {
+		t.Fatalf("NewEncryptedStorage failed: %v", err)
+	}
+
+	if err := es.Write(ctx, "a.json", []byte(`{"n":1}`)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// magic "VCE1" | key id length | "k1" | nonce | ciphertext+tag
+	const golden = "56434531" + "02" + "6b31" + "010101010101010101010101" +
+		"568bd825ae65fccc1f65a81d612d98cc231b047a480ed3"
+	raw, _ := backend.Read(ctx, "a.json")
+	if got := hex.EncodeToString(raw); got != golden {
+		t.Errorf("envelope changed:\n got  %s\n want %s", got, golden)
+	}
+
+	// The nonce source is exhausted, so the next write must fail rather than
+	// fall back to anything weaker
+	if err := es.Write(ctx, "b.json", []byte(`{}`)); err == nil {
+		t.Error("expected write to fail once the nonce source is exhausted")
+	}
+}
+
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStorage()
+	es, _ := NewEncryptedStorage(backend, "k1", testEncryptionKey)
+
+	manager, _ := NewManager(es)
+	manager.Create(ctx, "app", map[string]interface{}{"secret": "hunter2"})
+	manager.Update(ctx, "app", map[string]interface{}{"secret": "hunter3"})
+
+	for path, data := range backend.Snapshot() {
+		if bytes.Contains(data, []byte("hunter")) {
+			t.Errorf("%s stored in plaintext", path)
+		}
+	}
+
+	// Rotate: new writes use k2 while k1 envelopes stay readable
+	rotated, _ := NewEncryptedStorage(backend, "k2", bytes.Repeat([]byte{0x07}, 32),
+		WithDecryptionKey("k1", testEncryptionKey))
+	reader, _ := NewManager(rotated)
+	latest, err := reader.GetLatest(ctx, "app")
+	if err != nil || latest.Meta.Version != 2 {
+		t.Fatalf("expected v2 after rotation, got %v, %v", latest, err)
+	}
+	if _, err := reader.Update(ctx, "app", map[string]interface{}{"secret": "hunter4"}); err != nil {
+		t.Fatalf("Update after rotation failed: %v", err)
+	}
+
+	if _, err := es.Read(ctx, "configs/app/v3.json"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey without k2, got %v", err)
+	}
+}
+
+func TestEncryptedStorageDuplicateKeyID(t *testing.T) {
+	backend := NewMemoryStorage()
+	other := bytes.Repeat([]byte{0x07}, 32)
+
+	if _, err := NewEncryptedStorage(backend, "k1", testEncryptionKey, WithDecryptionKey("k1", other)); err == nil {
+		t.Error("expected a decryption key reusing the write key's id to fail")
+	}
+	if _, err := NewEncryptedStorage(backend, "k2", other,
+		WithDecryptionKey("k1", testEncryptionKey),
+		WithDecryptionKey("k1", testEncryptionKey)); err == nil {
+		t.Error("expected a decryption key id registered twice to fail")
+	}
+}
+
+func TestEncryptedStorageTampering(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStorage()
+	es, _ := NewEncryptedStorage(backend, "k1", testEncryptionKey)
+	es.Write(ctx, "a.json", []byte(`{"n":1}`))
+	raw, _ := backend.Read(ctx, "a.json")
+
+	// Moving an envelope to another path breaks authentication
+	backend.Write(ctx, "b.json", raw)
+	if _, err := es.Read(ctx, "b.json"); err == nil {
+		t.Error("expected moved envelope to fail")
+	}
+
+	flipped := append([]byte(nil), raw...)
+	flipped[len(flipped)-1] ^= 1
+	backend.Write(ctx, "a.json", flipped)
+	if _, err := es.Read(ctx, "a.json"); err == nil {
+		t.Error("expected tampered envelope to fail")
+	}
+
+	backend.Write(ctx, "plain.json", []byte(`{"n":1}`))
+	if _, err := es.Read(ctx, "plain.json"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("expected ErrNotEncrypted, got %v", err)
+	}
+
+	if _, err := NewEncryptedStorage(backend, "k1", []byte("short")); err == nil {
+		t.Error("expected error for short key")
+	}
+}
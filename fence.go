@@ -0,0 +1,80 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrFenced is returned by writes of a manager WithFenceToken once storage
+// has seen a higher token: another leader has taken over
+var ErrFenced = errors.New("fenced by a newer leader")
+
+// fenceRecord is the fence sidecar: the highest token that has written
+type fenceRecord struct {
+	Token uint64    `json:"token"`
+	Time  time.Time `json:"time"`
+}
+
+// WithFenceToken fences writes with token, the generation of the leader
+// running this manager, as handed out by the leader election the system
+// already has; each new leader must get a higher token. Before every write
+// that adds versions, and before Reset and ExpireSweep remove any, the
+// manager reads the highest token recorded next to the journal, at
+// "<journal path>.fence": a higher one fails the write with ErrFenced before
+// anything is touched, a lower one is replaced by token. A demoted leader is
+// thus shut out as soon as its successor has written once.
+//
+// The check and the write are separate storage calls, so this is not a
+// distributed lock. A write that passed its check just before the new
+// leader's first write can still land, but no write of the old leader begins
+// after it. Reads, Recover and Compact are not fenced. Token must be
+// positive; without the option nothing is fenced.
+func WithFenceToken(token uint64) ManagerOption {
+	return func(m *Manager) error {
+		if token == 0 {
+			return errors.New("fence token must be positive")
+		}
+		m.fenceToken = token
+		return nil
+	}
+}
+
+func (m *Manager) fencePath() string {
+	return m.journal.path + ".fence"
+}
+
+// checkFence fails with ErrFenced when storage has seen a token above the
+// manager's, raising the stored token to it otherwise. Callers hold mu.
+func (m *Manager) checkFence(ctx context.Context) error {
+	if m.fenceToken == 0 {
+		return nil
+	}
+
+	var seen fenceRecord
+	data, err := m.journal.storage.Read(ctx, m.fencePath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &seen); err != nil {
+			return fmt.Errorf("fence: %w", err)
+		}
+	}
+
+	if seen.Token > m.fenceToken {
+		return fmt.Errorf("%w: token %d, storage has seen %d since %s", ErrFenced, m.fenceToken, seen.Token, seen.Time.Format(time.RFC3339))
+	}
+	if seen.Token == m.fenceToken {
+		return nil
+	}
+	data, err = json.Marshal(fenceRecord{Token: m.fenceToken, Time: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	return m.journal.storage.Write(ctx, m.fencePath(), data)
+}
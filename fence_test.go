@@ -0,0 +1,59 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFenceToken(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	if _, err := NewManager(storage, WithFenceToken(0)); err == nil {
+		t.Error("expected a zero fence token to be rejected")
+	}
+
+	old, _ := NewManager(storage, WithFenceToken(1), WithCacheTTL(time.Nanosecond))
+	if _, err := old.Create(ctx, "app", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := old.Update(ctx, "app", map[string]interface{}{"n": 2}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// Failover: the new leader writes with a higher token
+	leader, _ := NewManager(storage, WithFenceToken(2), WithCacheTTL(time.Nanosecond))
+	if _, err := leader.Update(ctx, "app", map[string]interface{}{"n": 3}); err != nil {
+		t.Fatalf("new leader Update failed: %v", err)
+	}
+
+	before, _ := storage.List(ctx, "")
+	if _, err := old.Update(ctx, "app", map[string]interface{}{"n": 4}); !errors.Is(err, ErrFenced) {
+		t.Fatalf("expected ErrFenced from the demoted leader, got %v", err)
+	}
+	if _, err := old.Create(ctx, "db", map[string]interface{}{"host": "a"}); !errors.Is(err, ErrFenced) {
+		t.Errorf("expected ErrFenced for Create, got %v", err)
+	}
+	if _, err := old.CreateBatch(ctx, map[string]interface{}{"x": 1}); !errors.Is(err, ErrFenced) {
+		t.Errorf("expected ErrFenced for CreateBatch, got %v", err)
+	}
+	if _, err := old.Reset(ctx, "app", map[string]interface{}{}, ConfirmReset()); !errors.Is(err, ErrFenced) {
+		t.Errorf("expected ErrFenced for Reset, got %v", err)
+	}
+	if after, _ := storage.List(ctx, ""); len(after) != len(before) {
+		t.Errorf("fenced writes changed the store: %v -> %v", before, after)
+	}
+
+	// Reads still work, and the new leader keeps writing
+	if cfg, err := old.GetLatest(ctx, "app"); err != nil || cfg.Meta.Version != 3 {
+		t.Errorf("demoted leader read %v, %v", cfg, err)
+	}
+	if _, err := leader.Update(ctx, "app", map[string]interface{}{"n": 4}); err != nil {
+		t.Errorf("new leader Update failed: %v", err)
+	}
+	unfenced, _ := NewManager(storage, WithCacheTTL(time.Nanosecond))
+	if _, err := unfenced.Update(ctx, "app", map[string]interface{}{"n": 5}); err != nil {
+		t.Errorf("a manager without a token is not fenced, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FSStorage exposes an fs.FS, such as an embed.FS or a zip archive, as a
+// read-only Storage. Read, List and Exists work as on FileStorage; Write and
+// Delete always fail with an error matching errors.ErrUnsupported, so a
+// Manager over FSStorage can read, validate and reconstruct configs but not
+// change them.
+type FSStorage struct {
+	fsys fs.FS
+}
+
+// NewFSStorage creates read-only storage over fsys
+func NewFSStorage(fsys fs.FS) *FSStorage {
+	return &FSStorage{fsys: fsys}
+}
+
+// fsPath converts a storage path to an fs.FS path, which is slash-separated
+// and unrooted
+func fsPath(p string) (string, error) {
+	name := path.Clean(strings.TrimPrefix(filepath.ToSlash(p), "/"))
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid path: %s", p)
+	}
+	return name, nil
+}
+
+func (s *FSStorage) Read(ctx context.Context, p string) ([]byte, error) {
+	name, err := fsPath(p)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(s.fsys, name)
+}
+
+func (s *FSStorage) Write(ctx context.Context, p string, data []byte) error {
+	return fmt.Errorf("write %s: fs storage is read-only: %w", p, errors.ErrUnsupported)
+}
+
+func (s *FSStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	stats, err := s.ListStat(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, st := range stats {
+		paths = append(paths, st.Path)
+	}
+	return paths, nil
+}
+
+// ListStat implements StatLister from the same walk as List
+func (s *FSStorage) ListStat(ctx context.Context, prefix string) ([]FileStat, error) {
+	root, err := fsPath(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.Stat(s.fsys, root); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var stats []FileStat
+	err = fs.WalkDir(s.fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stats = append(stats, FileStat{Path: filepath.FromSlash(name), Size: info.Size()})
+		return nil
+	})
+	return stats, err
+}
+
+func (s *FSStorage) Delete(ctx context.Context, p string) error {
+	return fmt.Errorf("delete %s: fs storage is read-only: %w", p, errors.ErrUnsupported)
+}
+
+func (s *FSStorage) Exists(ctx context.Context, p string) (bool, error) {
+	name, err := fsPath(p)
+	if err != nil {
+		return false, err
+	}
+	_, err = fs.Stat(s.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,56 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSStorage(t *testing.T) {
+	ctx := context.Background()
+	source := NewMemoryStorage()
+	writer, _ := NewManager(source)
+	writer.Create(ctx, "app", map[string]interface{}{"n": 1})
+	writer.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	fsys := fstest.MapFS{}
+	for path, data := range source.Snapshot() {
+		fsys[path] = &fstest.MapFile{Data: data}
+	}
+	storage := NewFSStorage(fsys)
+
+	reader, err := NewManager(storage)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	latest, err := reader.GetLatest(ctx, "app")
+	if err != nil || latest.Meta.Version != 2 {
+		t.Fatalf("expected v2, got %v, %v", latest, err)
+	}
+	if _, err := reader.Reconstruct(ctx, "app"); err != nil {
+		t.Errorf("Reconstruct failed: %v", err)
+	}
+	ids, _ := reader.List(ctx)
+	if len(ids) != 1 || ids[0] != "app" {
+		t.Errorf("expected [app], got %v", ids)
+	}
+
+	if _, err := reader.Update(ctx, "app", map[string]interface{}{"n": 3}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported from Update, got %v", err)
+	}
+	if err := storage.Delete(ctx, "journal.jsonl"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported from Delete, got %v", err)
+	}
+
+	if _, err := storage.Read(ctx, "missing.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if paths, err := storage.List(ctx, "configs/ap"); err != nil || len(paths) != 0 {
+		t.Errorf("expected no paths for partial prefix, got %v, %v", paths, err)
+	}
+	if exists, _ := storage.Exists(ctx, "configs/app/v1.json"); !exists {
+		t.Error("expected v1 to exist")
+	}
+}
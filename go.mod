@@ -2,7 +2,10 @@ module github.com/source-c/viracochan
 
 go 1.24
 
-require github.com/btcsuite/btcd/btcec/v2 v2.3.4
+require (
+	github.com/btcsuite/btcd/btcec/v2 v2.3.4
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/btcsuite/btcd/chaincfg/chainhash v1.1.0 // indirect
@@ -0,0 +1,382 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithJournalSyncInterval turns on group commit for the journal: each
+// append is added to the journal file in place and the file is synced at
+// most once per d, so all appends within d share one fsync instead of each
+// rewriting and syncing the whole journal. Config files are still synced on
+// every write. Nor is the journal read before each append: the journal
+// remembers the tail of its last append and rereads the file only when
+// something else changed it, such as another process or a compaction.
+//
+// The price is a durability window of d. A process crash loses nothing the
+// write reached, but on a machine crash or power loss the appends of the
+// last d can be lost, along with at most one append cut short mid-line,
+// which reads ignore and the next append drops. The version files of lost
+// entries survive, ahead of the journal, and the next write of such an id
+// reuses their version numbers. Journal.Sync and Close sync at once; a sync
+// that fails in the background fails the next append.
+//
+// Zero, the default, keeps every append durable when it returns. Group
+// commit needs a FileStorage journal (also under WithTracer); on other
+// storages the option has no effect, and JournalSyncInterval reports the
+// window that applies.
+func WithJournalSyncInterval(d time.Duration) ManagerOption {
+	return func(m *Manager) error {
+		if d < 0 {
+			return errors.New("journal sync interval must not be negative")
+		}
+		m.journalSyncInterval = d
+		return nil
+	}
+}
+
+// JournalSyncInterval returns the journal's durability window: how long an
+// append may stay unsynced, 0 when every append is synced before it returns
+func (m *Manager) JournalSyncInterval() time.Duration {
+	return m.journal.SyncInterval()
+}
+
+// appendSyncer is implemented by storages that can append to a file in
+// place and make it durable separately, as journal group commit needs.
+// statFile identifies the file's current contents cheaply, so that a journal
+// can tell whether anyone else changed it since its last append.
+type appendSyncer interface {
+	appendFile(ctx context.Context, path string, data []byte) error
+	syncFile(ctx context.Context, path string) error
+	statFile(ctx context.Context, path string) (fileStamp, error)
+}
+
+// fileStamp is a file's size and modification time
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// appenderOf returns storage as an appendSyncer, or nil if it cannot append
+// in place. The manager's tracing wrapper passes its backend's ability on.
+func appenderOf(storage Storage) appendSyncer {
+	if ts, ok := storage.(*tracedStorage); ok && appenderOf(ts.storage) == nil {
+		return nil
+	}
+	as, _ := storage.(appendSyncer)
+	return as
+}
+
+// SetSyncInterval sets the group commit interval; see WithJournalSyncInterval
+func (j *Journal) SetSyncInterval(d time.Duration) {
+	j.syncInterval = max(d, 0)
+}
+
+// SyncInterval returns the durability window that applies to the journal's
+// appends: the sync interval, or 0 when it is unset or the storage cannot
+// append in place
+func (j *Journal) SyncInterval() time.Duration {
+	if j.groupCommit() == nil {
+		return 0
+	}
+	return j.syncInterval
+}
+
+// Sync makes every append so far durable now instead of at the end of the
+// sync interval, and reports a background sync that failed since the last
+// call. Appends a background sync is still writing out are synced again
+// rather than assumed durable. Without group commit it does nothing.
+func (j *Journal) Sync(ctx context.Context) error {
+	as := j.groupCommit()
+	if as == nil {
+		return nil
+	}
+
+	j.syncMu.Lock()
+	if j.syncTimer != nil {
+		j.syncTimer.Stop()
+		j.syncTimer = nil
+	}
+	gen := j.appendGen
+	pending := gen != j.syncedGen
+	err := j.takeSyncErr()
+	j.syncMu.Unlock()
+
+	if err != nil || !pending {
+		return err
+	}
+	if err := as.syncFile(ctx, j.path); err != nil {
+		return err
+	}
+	j.synced(gen)
+	return nil
+}
+
+// synced records that a sync covered the first gen appends
+func (j *Journal) synced(gen uint64) {
+	j.syncMu.Lock()
+	defer j.syncMu.Unlock()
+	j.syncedGen = max(j.syncedGen, gen)
+}
+
+func (j *Journal) groupCommit() appendSyncer {
+	if j.syncInterval == 0 {
+		return nil
+	}
+	return appenderOf(j.storage)
+}
+
+// journalTail is what an in-place append needs to know about the journal
+// file without reading it: the file's stamp after the last append through the
+// journal, its entry count, and the last version and checksum of each id
+type journalTail struct {
+	stamp   fileStamp
+	entries int
+	last    map[string]tailEntry
+}
+
+type tailEntry struct {
+	version uint64
+	cs      string
+}
+
+// appendTail appends entries in place using the journal's remembered tail,
+// so that group commit costs each batch a stat and an append rather than a
+// read of the whole journal. It reports false, having done nothing, when no
+// tail is remembered or the file changed since, as when another process
+// appended or the journal was compacted; the caller then reads the journal.
+// Callers hold the journal lock.
+func (j *Journal) appendTail(ctx context.Context, as appendSyncer, entries []*JournalEntry, dedup bool) (bool, error) {
+	t := j.tail
+	if t == nil {
+		return false, nil
+	}
+	stamp, err := as.statFile(ctx, j.path)
+	if err != nil || stamp != t.stamp {
+		j.tail = nil
+		return false, nil
+	}
+
+	if dedup {
+		entry := entries[0]
+		if last, ok := t.last[entry.ID]; ok && last == (tailEntry{entry.Version, entry.CS}) {
+			return true, nil
+		}
+	}
+	lines, err := marshalEntries(entries)
+	if err != nil {
+		return true, err
+	}
+	if err := j.appendInPlace(ctx, as, nil, lines); err != nil {
+		j.tail = nil
+		return true, err
+	}
+
+	for _, entry := range entries {
+		t.last[entry.ID] = tailEntry{entry.Version, entry.CS}
+	}
+	t.entries += len(entries)
+	j.noteSize(t.entries, int(t.stamp.size)+len(lines))
+
+	// Anything but our own append in between means the tail is stale
+	after, err := as.statFile(ctx, j.path)
+	if err != nil || after.size != t.stamp.size+int64(len(lines)) {
+		j.tail = nil
+		return true, nil
+	}
+	t.stamp = after
+	return true, nil
+}
+
+// rememberTail records the tail of the journal file, which now holds data,
+// for the next appendTail. A file that does not end in a complete entry, or
+// that changed since data was read, is not remembered. Callers hold the
+// journal lock.
+func (j *Journal) rememberTail(ctx context.Context, as appendSyncer, data []byte) {
+	j.tail = nil
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return
+	}
+	stamp, err := as.statFile(ctx, j.path)
+	if err != nil || stamp.size != int64(len(data)) {
+		return
+	}
+
+	t := &journalTail{stamp: stamp, last: make(map[string]tailEntry)}
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		line := bytes.TrimSpace(data[:i])
+		data = data[i+1:]
+		if len(line) == 0 {
+			continue
+		}
+		var entry struct {
+			ID      string `json:"id"`
+			Version uint64 `json:"v"`
+			CS      string `json:"cs"`
+		}
+		if json.Unmarshal(line, &entry) != nil {
+			return
+		}
+		t.last[entry.ID] = tailEntry{entry.Version, entry.CS}
+		t.entries++
+	}
+	j.tail = t
+}
+
+// appendInPlace appends lines to the journal file, which holds existing, and
+// schedules the sync that makes them durable. Callers hold mu.
+func (j *Journal) appendInPlace(ctx context.Context, as appendSyncer, existing, lines []byte) error {
+	j.syncMu.Lock()
+	err := j.takeSyncErr()
+	j.syncMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		lines = append([]byte{'\n'}, lines...)
+	}
+	if err := as.appendFile(ctx, j.path, lines); err != nil {
+		return err
+	}
+
+	j.syncMu.Lock()
+	defer j.syncMu.Unlock()
+	j.appendGen++
+	if j.syncTimer == nil {
+		j.syncTimer = time.AfterFunc(j.syncInterval, func() { j.backgroundSync(as) })
+	}
+	return nil
+}
+
+// backgroundSync runs when a sync interval ends. Appends made while it syncs
+// schedule the next one.
+func (j *Journal) backgroundSync(as appendSyncer) {
+	j.syncMu.Lock()
+	j.syncTimer = nil
+	gen := j.appendGen
+	j.syncMu.Unlock()
+
+	if err := as.syncFile(context.Background(), j.path); err != nil {
+		j.logger.Warn("journal sync failed", "path", j.path, "error", err)
+		j.syncMu.Lock()
+		j.syncErr = err
+		j.syncMu.Unlock()
+		return
+	}
+	j.synced(gen)
+}
+
+// takeSyncErr returns and clears the error of a failed background sync.
+// Callers hold syncMu.
+func (j *Journal) takeSyncErr() error {
+	err := j.syncErr
+	j.syncErr = nil
+	if err != nil {
+		return fmt.Errorf("journal sync failed, recent appends may not be durable: %w", err)
+	}
+	return nil
+}
+
+// trimTornTail drops a last line that has no newline and does not decode: an
+// in-place append still being written, or cut short by a crash
+func trimTornTail(data []byte) []byte {
+	i := bytes.LastIndexByte(data, '\n')
+	if i == len(data)-1 {
+		return data
+	}
+	var entry JournalEntry
+	if json.Unmarshal(bytes.TrimSpace(data[i+1:]), &entry) != nil {
+		return data[:i+1]
+	}
+	return data
+}
+
+func (fs *FileStorage) appendFile(ctx context.Context, path string, data []byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600) // #nosec G304 - path is validated above
+	if err != nil {
+		return err
+	}
+	_, writeErr := f.Write(data)
+	if closeErr := f.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	return writeErr
+}
+
+func (fs *FileStorage) statFile(ctx context.Context, path string) (fileStamp, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+func (fs *FileStorage) syncFile(ctx context.Context, path string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fullPath) // #nosec G304 - path is validated above
+	if err != nil {
+		return err
+	}
+	syncErr := f.Sync()
+	if closeErr := f.Close(); syncErr == nil {
+		syncErr = closeErr
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+	return syncDir(filepath.Dir(fullPath))
+}
+
+func (ts *tracedStorage) appendFile(ctx context.Context, path string, data []byte) error {
+	ctx, span := ts.start(ctx, "append", path)
+	span.SetAttributes(Attribute{AttrBytes, int64(len(data))})
+	err := ts.storage.(appendSyncer).appendFile(ctx, path, data)
+	span.End(err)
+	return err
+}
+
+func (ts *tracedStorage) syncFile(ctx context.Context, path string) error {
+	ctx, span := ts.start(ctx, "sync", path)
+	err := ts.storage.(appendSyncer).syncFile(ctx, path)
+	span.End(err)
+	return err
+}
+
+func (ts *tracedStorage) statFile(ctx context.Context, path string) (fileStamp, error) {
+	ctx, span := ts.start(ctx, "stat", path)
+	stamp, err := ts.storage.(appendSyncer).statFile(ctx, path)
+	span.End(err)
+	return stamp, err
+}
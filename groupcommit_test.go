@@ -0,0 +1,265 @@
+package viracochan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// syncCountingStorage is a MemoryStorage that appends in place and counts
+// syncs and reads, like a FileStorage journal under group commit
+type syncCountingStorage struct {
+	*MemoryStorage
+	mu      sync.Mutex
+	appends int
+	syncs   int
+	reads   int
+	writes  map[string]int64
+
+	beforeSync func() // if set, runs at the start of every sync
+}
+
+func (s *syncCountingStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	s.mu.Lock()
+	s.reads++
+	s.mu.Unlock()
+	return s.MemoryStorage.Read(ctx, path)
+}
+
+func (s *syncCountingStorage) Write(ctx context.Context, path string, data []byte) error {
+	s.mu.Lock()
+	if s.writes == nil {
+		s.writes = make(map[string]int64)
+	}
+	s.writes[path]++
+	s.mu.Unlock()
+	return s.MemoryStorage.Write(ctx, path, data)
+}
+
+func (s *syncCountingStorage) appendFile(ctx context.Context, path string, data []byte) error {
+	s.mu.Lock()
+	s.appends++
+	s.mu.Unlock()
+	existing, _ := s.MemoryStorage.Read(ctx, path)
+	return s.Write(ctx, path, append(existing, data...))
+}
+
+// statFile stamps the file with its write count in place of a modification
+// time
+func (s *syncCountingStorage) statFile(ctx context.Context, path string) (fileStamp, error) {
+	data, err := s.MemoryStorage.Read(ctx, path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fileStamp{size: int64(len(data)), modTime: time.Unix(0, s.writes[path])}, nil
+}
+
+func (s *syncCountingStorage) readCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+func (s *syncCountingStorage) syncFile(ctx context.Context, path string) error {
+	if s.beforeSync != nil {
+		s.beforeSync()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncs++
+	return nil
+}
+
+func (s *syncCountingStorage) counts() (appends, syncs int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appends, s.syncs
+}
+
+func TestJournalSyncInterval(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewManager(NewMemoryStorage(), WithJournalSyncInterval(-time.Second)); err == nil {
+		t.Error("expected a negative interval to be rejected")
+	}
+	plain, _ := NewManager(NewMemoryStorage(), WithJournalSyncInterval(time.Second))
+	if d := plain.JournalSyncInterval(); d != 0 {
+		t.Errorf("storage without in-place appends should report no window, got %s", d)
+	}
+
+	storage := &syncCountingStorage{MemoryStorage: NewMemoryStorage()}
+	manager, _ := NewManager(storage, WithJournalSyncInterval(time.Hour))
+	if d := manager.JournalSyncInterval(); d != time.Hour {
+		t.Errorf("expected a one hour window, got %s", d)
+	}
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 0})
+	for i := 1; i < 10; i++ {
+		if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	if appends, syncs := storage.counts(); appends != 10 || syncs != 0 {
+		t.Errorf("expected 10 appends and no sync yet, got %d and %d", appends, syncs)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain of in-place appends is invalid: %v", err)
+	}
+
+	// Close syncs the pending appends once
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, syncs := storage.counts(); syncs != 1 {
+		t.Errorf("expected one sync on Close, got %d", syncs)
+	}
+}
+
+func TestJournalGroupCommit(t *testing.T) {
+	ctx := context.Background()
+	storage := &syncCountingStorage{MemoryStorage: NewMemoryStorage()}
+	journal := NewJournal(storage, "journal.jsonl")
+	journal.SetSyncInterval(20 * time.Millisecond)
+
+	for i := 1; i <= 5; i++ {
+		journal.AppendBatch(ctx, []*JournalEntry{{ID: "app", Version: uint64(i), CS: strings.Repeat("a", i)}})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, syncs := storage.counts(); syncs > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, syncs := storage.counts(); syncs != 1 {
+		t.Errorf("expected the appends to share one sync, got %d", syncs)
+	}
+	if err := journal.Sync(ctx); err != nil {
+		t.Errorf("Sync with nothing pending failed: %v", err)
+	}
+	if _, syncs := storage.counts(); syncs != 1 {
+		t.Errorf("Sync with nothing pending synced again: %d", syncs)
+	}
+}
+
+func TestJournalSyncDuringBackgroundSync(t *testing.T) {
+	ctx := context.Background()
+	storage := &syncCountingStorage{MemoryStorage: NewMemoryStorage()}
+	entered, release := make(chan struct{}), make(chan struct{})
+	var first atomic.Bool
+	storage.beforeSync = func() {
+		if first.CompareAndSwap(false, true) {
+			close(entered)
+			<-release
+		}
+	}
+	journal := NewJournal(storage, "journal.jsonl")
+	journal.SetSyncInterval(time.Millisecond)
+
+	journal.Append(ctx, &JournalEntry{ID: "app", Version: 1, CS: "a1"})
+	select {
+	case <-entered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("background sync did not start")
+	}
+
+	// The background sync has not finished, so Sync must not take the
+	// append as durable
+	if err := journal.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if _, syncs := storage.counts(); syncs != 1 {
+		t.Errorf("expected Sync to sync while the background sync is in flight, got %d syncs", syncs)
+	}
+	close(release)
+}
+
+func TestJournalGroupCommitTail(t *testing.T) {
+	ctx := context.Background()
+	storage := &syncCountingStorage{MemoryStorage: NewMemoryStorage()}
+	journal := NewJournal(storage, "journal.jsonl")
+	journal.SetSyncInterval(time.Hour)
+	defer journal.Sync(ctx)
+
+	journal.Append(ctx, &JournalEntry{ID: "app", Version: 1, CS: "a1"})
+	reads := storage.readCount()
+	for i := 2; i <= 5; i++ {
+		if err := journal.Append(ctx, &JournalEntry{ID: "app", Version: uint64(i), CS: fmt.Sprintf("a%d", i)}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if n := storage.readCount() - reads; n != 0 {
+		t.Errorf("expected appends after the first not to read the journal, read %d times", n)
+	}
+	journal.Append(ctx, &JournalEntry{ID: "app", Version: 5, CS: "a5"})
+	if n := journal.entries.Load(); n != 5 {
+		t.Errorf("expected the duplicate to be skipped from the tail, %d entries", n)
+	}
+
+	// A write behind the journal's back is noticed and the file reread
+	data, _ := storage.MemoryStorage.Read(ctx, "journal.jsonl")
+	storage.Write(ctx, "journal.jsonl", append(data, `{"id":"other","v":1,"cs":"o1"}`+"\n"...))
+	reads = storage.readCount()
+	journal.Append(ctx, &JournalEntry{ID: "other", Version: 1, CS: "o1"})
+	if storage.readCount() == reads {
+		t.Error("expected a changed journal to be reread")
+	}
+	journal.Append(ctx, &JournalEntry{ID: "app", Version: 6, CS: "a6", PrevCS: "a5"})
+
+	entries, err := journal.ReadAll(ctx)
+	if err != nil || len(entries) != 7 {
+		t.Fatalf("expected 7 entries, got %d, %v", len(entries), err)
+	}
+	if last := entries[len(entries)-1]; last.ID != "app" || last.Version != 6 {
+		t.Errorf("unexpected last entry %+v", last)
+	}
+}
+
+func TestJournalTornTail(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	journal := NewJournal(storage, "journal.jsonl")
+	journal.Append(ctx, &JournalEntry{ID: "app", Version: 1, CS: "a1"})
+
+	// An append cut short by a crash leaves a line without its newline
+	data, _ := storage.Read(ctx, "journal.jsonl")
+	storage.Write(ctx, "journal.jsonl", append(data, `{"id":"app","v":2,"cs":"a`...))
+
+	entries, err := journal.ReadAll(ctx)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected the torn line to be ignored, got %d entries, %v", len(entries), err)
+	}
+	if last, err := journal.Last(ctx, "app"); err != nil || last.Version != 1 {
+		t.Errorf("Last read past the torn line: %v, %v", last, err)
+	}
+
+	journal.Append(ctx, &JournalEntry{ID: "app", Version: 2, CS: "a2", PrevCS: "a1"})
+	data, _ = storage.Read(ctx, "journal.jsonl")
+	if lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); len(lines) != 2 {
+		t.Errorf("the next append did not drop the torn line:\n%s", data)
+	}
+}
+
+func TestFileStorageGroupCommit(t *testing.T) {
+	ctx := context.Background()
+	storage, _ := NewFileStorage(t.TempDir())
+	manager, _ := NewManager(storage, WithJournalSyncInterval(time.Minute))
+	if d := manager.JournalSyncInterval(); d != time.Minute {
+		t.Fatalf("FileStorage should support group commit, window %s", d)
+	}
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, _ := NewManager(storage)
+	if cfg, err := reopened.GetLatest(ctx, "app"); err != nil || cfg.Meta.Version != 2 {
+		t.Errorf("expected v2 after reopening, got %v, %v", cfg, err)
+	}
+}
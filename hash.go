@@ -0,0 +1,78 @@
+package viracochan
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// HashAlgorithm names the digest used for a config's checksum and for the
+// content and annotation hashes in its signed message. It is recorded in
+// Meta.HashAlg; an empty value means HashSHA256, the default.
+type HashAlgorithm string
+
+const (
+	HashSHA256 HashAlgorithm = "sha256"
+	HashSHA512 HashAlgorithm = "sha512"
+)
+
+// ErrUnsupportedHashAlgorithm is returned when a config names a hash
+// algorithm that has not been registered.
+var ErrUnsupportedHashAlgorithm = errors.New("unsupported hash algorithm")
+
+var (
+	hashMu         sync.RWMutex
+	hashAlgorithms = map[HashAlgorithm]func() hash.Hash{
+		HashSHA256: sha256.New,
+		HashSHA512: sha512.New,
+	}
+)
+
+// RegisterHashAlgorithm makes an additional algorithm (e.g. BLAKE3 from a
+// third-party package) available to WithHashAlgorithm and to validation of
+// configs that record it. It must be registered in every process that reads
+// such configs. Built-in algorithms cannot be replaced.
+func RegisterHashAlgorithm(alg HashAlgorithm, newHash func() hash.Hash) error {
+	if alg == "" || newHash == nil {
+		return errors.New("hash algorithm name and constructor are required")
+	}
+
+	hashMu.Lock()
+	defer hashMu.Unlock()
+
+	if alg == HashSHA256 || alg == HashSHA512 {
+		return fmt.Errorf("hash algorithm %q is built in", alg)
+	}
+	hashAlgorithms[alg] = newHash
+	return nil
+}
+
+// digestHex hashes data with alg (HashSHA256 when empty) and returns it hex
+// encoded
+func digestHex(alg HashAlgorithm, data []byte) (string, error) {
+	h, err := newDigest(alg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newDigest returns a fresh hash for alg (HashSHA256 when empty)
+func newDigest(alg HashAlgorithm) (hash.Hash, error) {
+	if alg == "" {
+		alg = HashSHA256
+	}
+
+	hashMu.RLock()
+	newHash, ok := hashAlgorithms[alg]
+	hashMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedHashAlgorithm, alg)
+	}
+	return newHash(), nil
+}
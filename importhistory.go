@@ -0,0 +1,290 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+)
+
+// ErrHistoryConflict is returned by ImportHistory under ConflictReject when
+// the incoming history forked from the local one
+var ErrHistoryConflict = errors.New("import history conflicts with local history")
+
+// ConflictPolicy decides how ImportHistory reconciles an incoming history
+// that forked from the local one: both hold versions past their last common
+// version, with different checksums
+type ConflictPolicy int
+
+const (
+	// ConflictReject fails with ErrHistoryConflict and writes nothing
+	ConflictReject ConflictPolicy = iota
+	// ConflictKeepLocal keeps the local history and drops the incoming tail
+	ConflictKeepLocal
+	// ConflictKeepIncoming moves the local history to a branch id and puts
+	// the incoming one in its place
+	ConflictKeepIncoming
+	// ConflictFork keeps the local history and stores the incoming one under
+	// a branch id
+	ConflictFork
+)
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case ConflictReject:
+		return "reject"
+	case ConflictKeepLocal:
+		return "keep_local"
+	case ConflictKeepIncoming:
+		return "keep_incoming"
+	case ConflictFork:
+		return "fork"
+	}
+	return fmt.Sprintf("conflict_policy(%d)", int(p))
+}
+
+// MarshalText encodes the policy by name
+func (p ConflictPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// VersionConflict is a version held by one or both sides of a forked import
+// past their last common version. A side that does not hold it has an empty
+// checksum.
+type VersionConflict struct {
+	Version    uint64         `json:"v"`
+	LocalCS    string         `json:"local_cs,omitempty"`
+	IncomingCS string         `json:"incoming_cs,omitempty"`
+	Resolution ConflictPolicy `json:"resolution"`
+}
+
+// ImportHistoryReport is the result of ImportHistory. Imported lists the
+// incoming versions written under ID, and ForkID names the branch holding the
+// side not kept under ID, after ConflictFork or ConflictKeepIncoming.
+type ImportHistoryReport struct {
+	ID            string            `json:"id"`
+	CommonVersion uint64            `json:"common_version"`
+	Imported      []uint64          `json:"imported,omitempty"`
+	Conflicts     []VersionConflict `json:"conflicts,omitempty"`
+	ForkID        string            `json:"fork_id,omitempty"`
+}
+
+// ImportHistory brings the history of id held elsewhere, for example another
+// node's GetHistory, into local storage, writing only the versions the local
+// history lacks. history may be in any order and may start after v1, as
+// long as it attaches to a local version; each version must validate, link
+// to the one before it and pass signature checks, and nothing is written
+// otherwise.
+//
+// When both histories hold versions past their last common one, they forked
+// and policy decides, per CompareHistories: ConflictReject fails with
+// ErrHistoryConflict, ConflictKeepLocal writes nothing, ConflictFork stores
+// the incoming history under <id>.fork-<UTC timestamp>, and
+// ConflictKeepIncoming moves the local history there, as Reset archives a
+// chain, and writes the incoming one under id, dropping the id's channel
+// pointers. Either way the report lists each conflicting version with its
+// resolution, and it is returned with the ErrHistoryConflict error too. The
+// steps of ConflictKeepIncoming are not atomic; like Reset, it can leave the
+// branch written and id partly rebuilt.
+func (m *Manager) ImportHistory(ctx context.Context, id string, history []*Config, policy ConflictPolicy) (report *ImportHistoryReport, err error) {
+	ctx, call := m.begin(ctx, "import_history", id)
+	defer func() { call.end(nil, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+	switch policy {
+	case ConflictReject, ConflictKeepLocal, ConflictKeepIncoming, ConflictFork:
+	default:
+		return nil, fmt.Errorf("unknown conflict policy %d", policy)
+	}
+
+	incoming, err := m.checkImportHistory(history)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	local, err := m.storedHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	full, err := m.attachHistory(id, local, incoming)
+	if err != nil {
+		return nil, err
+	}
+	d, err := CompareHistories(local, full)
+	if err != nil {
+		return nil, err
+	}
+
+	report = &ImportHistoryReport{ID: id, CommonVersion: d.CommonVersion}
+	if len(d.BTail) == 0 {
+		return report, nil
+	}
+	if !d.Forked {
+		if err := m.writeChain(ctx, id, d.BTail, "import"); err != nil {
+			return nil, err
+		}
+		report.Imported = configVersions(d.BTail)
+		return report, nil
+	}
+
+	report.Conflicts = historyConflicts(d, policy)
+	switch policy {
+	case ConflictReject:
+		return report, fmt.Errorf("%w: %q forked after v%d (%d local, %d incoming versions)",
+			ErrHistoryConflict, id, d.CommonVersion, len(d.ATail), len(d.BTail))
+
+	case ConflictFork:
+		if report.ForkID, err = m.resetArchiveID(ctx, id, "", "fork"); err != nil {
+			return nil, err
+		}
+		if err := m.writeChain(ctx, report.ForkID, full, "import"); err != nil {
+			return nil, err
+		}
+
+	case ConflictKeepIncoming:
+		if report.ForkID, err = m.resetArchiveID(ctx, id, "", "fork"); err != nil {
+			return nil, err
+		}
+		if err := m.checkFence(ctx); err != nil {
+			return nil, err
+		}
+		if _, _, err := m.archiveLineage(ctx, id, report.ForkID); err != nil {
+			return nil, err
+		}
+		if err := m.storage.Delete(ctx, m.channelPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err := m.writeChain(ctx, id, full, "import"); err != nil {
+			return nil, err
+		}
+		report.Imported = configVersions(d.BTail)
+	}
+
+	m.logger.Warn("import history conflict", "id", id, "policy", policy.String(), "common", d.CommonVersion,
+		"local", len(d.ATail), "incoming", len(d.BTail), "fork", report.ForkID)
+	return report, nil
+}
+
+// checkImportHistory returns history sorted by version once every version
+// validates, links to the one before it and passes signature checks
+func (m *Manager) checkImportHistory(history []*Config) ([]*Config, error) {
+	if len(history) == 0 {
+		return nil, errors.New("import history: no versions")
+	}
+	if slices.Contains(history, nil) {
+		return nil, errors.New("import history: nil config")
+	}
+	sorted, err := byVersion(history)
+	if err != nil {
+		return nil, fmt.Errorf("import history: %w", err)
+	}
+
+	for i, cfg := range sorted {
+		v := cfg.Meta.Version
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("import history: version %d: %w", v, err)
+		}
+		if i == 0 && v == 1 && cfg.Meta.PrevCS != "" {
+			return nil, fmt.Errorf("%w: import history: version 1 has a previous checksum", ErrInvalidChain)
+		}
+		if i > 0 {
+			if err := cfg.nextOf(sorted[i-1], m.clockSkew); err != nil {
+				return nil, fmt.Errorf("import history: version %d: %w", v, err)
+			}
+		}
+		if err := m.configStore.verifySignature(cfg); err != nil {
+			return nil, fmt.Errorf("import history: version %d: %w", v, err)
+		}
+		if err := m.checkSigned(cfg); err != nil {
+			return nil, fmt.Errorf("import history: version %d: %w", v, err)
+		}
+	}
+	return sorted, nil
+}
+
+// attachHistory returns incoming preceded by the local versions before its
+// first one, which must link to the local version it follows
+func (m *Manager) attachHistory(id string, local, incoming []*Config) ([]*Config, error) {
+	start := incoming[0].Meta.Version
+	if start == 1 {
+		return incoming, nil
+	}
+
+	var full []*Config
+	for _, cfg := range local {
+		if cfg.Meta.Version < start {
+			full = append(full, cfg)
+		}
+	}
+	if len(full) == 0 || full[len(full)-1].Meta.Version != start-1 {
+		return nil, fmt.Errorf("%w: import history starts at v%d but %q has no v%d", ErrHistoryGap, start, id, start-1)
+	}
+	if err := incoming[0].nextOf(full[len(full)-1], m.clockSkew); err != nil {
+		return nil, fmt.Errorf("import history does not continue %q v%d: %w", id, start-1, err)
+	}
+	return append(full, incoming...), nil
+}
+
+// storedHistory loads every stored version of id, oldest first, failing on
+// any that does not load. Callers hold mu.
+func (m *Manager) storedHistory(ctx context.Context, id string) ([]*Config, error) {
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]*Config, 0, len(versions))
+	for _, v := range versions {
+		cfg, err := m.configStore.Load(ctx, id, v)
+		if err != nil {
+			return nil, fmt.Errorf("config %q version %d: %w", id, v, err)
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// historyConflicts lists the versions of either tail of a forked d, resolved
+// by policy
+func historyConflicts(d *DivergenceReport, policy ConflictPolicy) []VersionConflict {
+	byVersion := make(map[uint64]*VersionConflict)
+	conflict := func(v uint64) *VersionConflict {
+		c, ok := byVersion[v]
+		if !ok {
+			c = &VersionConflict{Version: v, Resolution: policy}
+			byVersion[v] = c
+		}
+		return c
+	}
+	for _, cfg := range d.ATail {
+		conflict(cfg.Meta.Version).LocalCS = cfg.Meta.CS
+	}
+	for _, cfg := range d.BTail {
+		conflict(cfg.Meta.Version).IncomingCS = cfg.Meta.CS
+	}
+
+	conflicts := make([]VersionConflict, 0, len(byVersion))
+	for _, c := range byVersion {
+		conflicts = append(conflicts, *c)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Version < conflicts[j].Version })
+	return conflicts
+}
+
+func configVersions(configs []*Config) []uint64 {
+	versions := make([]uint64, len(configs))
+	for i, cfg := range configs {
+		versions[i] = cfg.Meta.Version
+	}
+	return versions
+}
@@ -0,0 +1,127 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManagerImportHistory(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	source, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+	target, _ := NewManager(NewMemoryStorage())
+
+	source.Create(ctx, "app", map[string]interface{}{"n": 1})
+	source.Update(ctx, "app", map[string]interface{}{"n": 2})
+	source.Update(ctx, "app", map[string]interface{}{"n": 3})
+	history, _ := source.GetHistory(ctx, "app")
+
+	// Out of order on purpose
+	report, err := target.ImportHistory(ctx, "app", []*Config{history[2], history[0], history[1]}, ConflictReject)
+	if err != nil {
+		t.Fatalf("ImportHistory failed: %v", err)
+	}
+	if !reflect.DeepEqual(report.Imported, []uint64{1, 2, 3}) || len(report.Conflicts) != 0 {
+		t.Errorf("unexpected report for a fresh import: %+v", report)
+	}
+	if report, _ := target.ImportHistory(ctx, "app", history, ConflictReject); len(report.Imported) != 0 || report.CommonVersion != 3 {
+		t.Errorf("re-import should write nothing: %+v", report)
+	}
+
+	// A tail alone attaches to the version it follows
+	v4, _ := source.Update(ctx, "app", map[string]interface{}{"n": 4})
+	if report, err := target.ImportHistory(ctx, "app", []*Config{v4}, ConflictReject); err != nil || !reflect.DeepEqual(report.Imported, []uint64{4}) {
+		t.Fatalf("tail import: %+v, %v", report, err)
+	}
+	if err := target.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("imported chain invalid: %v", err)
+	}
+
+	// Both sides write past v4
+	incoming, _ := source.Update(ctx, "app", map[string]interface{}{"n": 5, "from": "source"})
+	local5, _ := target.Update(ctx, "app", map[string]interface{}{"n": 5, "from": "target"})
+	local6, _ := target.Update(ctx, "app", map[string]interface{}{"n": 6, "from": "target"})
+	history, _ = source.GetHistory(ctx, "app")
+
+	wantConflicts := func(policy ConflictPolicy) []VersionConflict {
+		return []VersionConflict{
+			{Version: 5, LocalCS: local5.Meta.CS, IncomingCS: incoming.Meta.CS, Resolution: policy},
+			{Version: 6, LocalCS: local6.Meta.CS, Resolution: policy},
+		}
+	}
+
+	report, err = target.ImportHistory(ctx, "app", history, ConflictReject)
+	if !errors.Is(err, ErrHistoryConflict) {
+		t.Fatalf("expected ErrHistoryConflict, got %v", err)
+	}
+	if report == nil || report.CommonVersion != 4 || !reflect.DeepEqual(report.Conflicts, wantConflicts(ConflictReject)) {
+		t.Fatalf("unexpected conflict report: %+v", report)
+	}
+
+	report, err = target.ImportHistory(ctx, "app", history, ConflictKeepLocal)
+	if err != nil || len(report.Imported) != 0 || !reflect.DeepEqual(report.Conflicts, wantConflicts(ConflictKeepLocal)) {
+		t.Fatalf("keep local: %+v, %v", report, err)
+	}
+	if latest, _ := target.GetLatest(ctx, "app"); latest.Meta.CS != local6.Meta.CS {
+		t.Fatal("rejected and kept-local imports must leave the local head alone")
+	}
+
+	report, err = target.ImportHistory(ctx, "app", history, ConflictFork)
+	if err != nil || !strings.HasPrefix(report.ForkID, "app.fork-") {
+		t.Fatalf("fork: %+v, %v", report, err)
+	}
+	if branch, err := target.GetLatest(ctx, report.ForkID); err != nil || branch.Meta.CS != incoming.Meta.CS {
+		t.Errorf("fork branch should end at the incoming head, got %v, %v", branch, err)
+	}
+	if err := target.ValidateChain(ctx, report.ForkID); err != nil {
+		t.Errorf("fork branch chain invalid: %v", err)
+	}
+	if latest, _ := target.GetLatest(ctx, "app"); latest.Meta.CS != local6.Meta.CS {
+		t.Fatal("fork must leave the local head alone")
+	}
+
+	report, err = target.ImportHistory(ctx, "app", history, ConflictKeepIncoming)
+	if err != nil || !reflect.DeepEqual(report.Imported, []uint64{5}) {
+		t.Fatalf("keep incoming: %+v, %v", report, err)
+	}
+	if latest, _ := target.GetLatest(ctx, "app"); latest.Meta.CS != incoming.Meta.CS {
+		t.Errorf("keep incoming should make the incoming head current, got v%d", latest.Meta.Version)
+	}
+	if err := target.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain invalid after keep incoming: %v", err)
+	}
+	if branch, err := target.GetLatest(ctx, report.ForkID); err != nil || branch.Meta.CS != local6.Meta.CS {
+		t.Errorf("the local history should move to the branch, got %v, %v", branch, err)
+	}
+}
+
+func TestManagerImportHistoryRejectsBadInput(t *testing.T) {
+	ctx := context.Background()
+	source, _ := NewManager(NewMemoryStorage())
+	target, _ := NewManager(NewMemoryStorage())
+	source.Create(ctx, "app", map[string]interface{}{"n": 1})
+	source.Update(ctx, "app", map[string]interface{}{"n": 2})
+	source.Update(ctx, "app", map[string]interface{}{"n": 3})
+	history, _ := source.GetHistory(ctx, "app")
+
+	if _, err := target.ImportHistory(ctx, "app", []*Config{history[0], history[2]}, ConflictReject); err == nil {
+		t.Error("expected a history with a gap to fail")
+	}
+	if _, err := target.ImportHistory(ctx, "app", history[1:], ConflictReject); !errors.Is(err, ErrHistoryGap) {
+		t.Errorf("expected a tail with nothing to attach to to fail with ErrHistoryGap, got %v", err)
+	}
+	tampered := *history[1]
+	tampered.Content = []byte(`{"n":20}`)
+	if _, err := target.ImportHistory(ctx, "app", []*Config{history[0], &tampered}, ConflictReject); err == nil {
+		t.Error("expected a tampered version to fail")
+	}
+	if _, err := target.ImportHistory(ctx, "app", history, ConflictPolicy(9)); err == nil {
+		t.Error("expected an unknown policy to fail")
+	}
+	if versions, _ := target.configStore.ListVersions(ctx, "app"); len(versions) != 0 {
+		t.Errorf("rejected imports wrote versions %v", versions)
+	}
+}
@@ -176,8 +176,17 @@ func TestIntegrationFullWorkflow(t *testing.T) {
 		t.Error("Import/export checksum mismatch")
 	}
 
+	// Re-homed under a fresh lineage, only the content is the same
+	rehomed, err := manager2.ImportAsNew(ctx, "rehomed-app", exported)
+	if err != nil {
+		t.Fatalf("ImportAsNew failed: %v", err)
+	}
+	if rehomed.Meta.CS == rolled.Meta.CS || !ContentEquivalent(rehomed, rolled) {
+		t.Error("re-homed config should differ in lineage but not in content")
+	}
+
 	// Phase 8: Reconstruction from partial data
-	manager.cache = make(map[string]*Config) // Clear cache
+	manager.cacheReset() // Clear cache
 
 	reconstructed, err := manager.Reconstruct(ctx, "app")
 	if err != nil {
@@ -0,0 +1,321 @@
+package viracochan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Intent log phases
+const (
+	IntentBegin  = "intent"
+	IntentCommit = "commit"
+)
+
+// IntentWrite is one config version an intent promises to save and journal
+type IntentWrite struct {
+	ID        string `json:"id"`
+	Version   uint64 `json:"v"`
+	CS        string `json:"cs"`
+	PrevCS    string `json:"prev_cs,omitempty"`
+	Operation string `json:"op"`
+	Message   string `json:"message,omitempty"`
+}
+
+// IntentRecord is one line of the intent log. An IntentBegin record lists
+// the writes of a multi-step operation; the IntentCommit record with the
+// same Tx marks them durable in both the config store and the journal.
+type IntentRecord struct {
+	Tx     string        `json:"tx"`
+	Phase  string        `json:"phase"`
+	Time   time.Time     `json:"t"`
+	Writes []IntentWrite `json:"writes,omitempty"`
+}
+
+// RecoveryAction is how Recover resolved an uncommitted intent
+type RecoveryAction string
+
+const (
+	// RecoveryCommitted means every write had already reached the journal;
+	// only the commit record was missing
+	RecoveryCommitted RecoveryAction = "committed"
+	// RecoveryCompleted means the config files were saved but not journaled,
+	// and Recover journaled them
+	RecoveryCompleted RecoveryAction = "completed"
+	// RecoveryRolledBack means the operation could not be completed and
+	// Recover removed the config files it had saved
+	RecoveryRolledBack RecoveryAction = "rolled_back"
+)
+
+// RecoveredIntent reports how one uncommitted intent was resolved
+type RecoveredIntent struct {
+	Tx     string
+	Action RecoveryAction
+	Writes []IntentWrite
+}
+
+// WithIntentLog makes every write record an intent before touching storage
+// and a commit once the config files and journal entries are in place, so
+// that Recover can resolve operations interrupted by a crash. The log lives
+// next to the journal, in the journal's storage, at "<journal path>.intents"
+// and is emptied whenever no intent is outstanding.
+func WithIntentLog() ManagerOption {
+	return func(m *Manager) error {
+		m.intentLog = true
+		return nil
+	}
+}
+
+func (m *Manager) intentPath() string {
+	return m.journal.path + ".intents"
+}
+
+// beginIntent records writes as an uncommitted intent and returns its tx id;
+// it is a no-op returning "" when the intent log is disabled
+func (m *Manager) beginIntent(ctx context.Context, writes []IntentWrite) (string, error) {
+	if !m.intentLog {
+		return "", nil
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	tx := hex.EncodeToString(buf)
+
+	if err := m.appendIntent(ctx, IntentRecord{Tx: tx, Phase: IntentBegin, Time: time.Now().UTC(), Writes: writes}); err != nil {
+		return "", err
+	}
+	return tx, nil
+}
+
+// commitIntent marks tx committed. When that leaves no intent outstanding the
+// log is removed instead, keeping it empty in steady state.
+func (m *Manager) commitIntent(ctx context.Context, tx string) error {
+	if tx == "" {
+		return nil
+	}
+
+	records, err := m.readIntents(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pendingIntents(records, tx)) == 0 {
+		return m.journal.storage.Delete(ctx, m.intentPath())
+	}
+	return m.appendIntent(ctx, IntentRecord{Tx: tx, Phase: IntentCommit, Time: time.Now().UTC()})
+}
+
+func (m *Manager) appendIntent(ctx context.Context, rec IntentRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	existing, _ := m.journal.storage.Read(ctx, m.intentPath())
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		existing = append(existing, '\n')
+	}
+	existing = append(existing, data...)
+	existing = append(existing, '\n')
+
+	return m.journal.storage.Write(ctx, m.intentPath(), existing)
+}
+
+// readIntents parses the intent log. A line that fails to parse is a record
+// torn by a crash mid-write; it is skipped, which leaves its intent (if it
+// was the begin record, nothing was written yet) or its commit outstanding.
+func (m *Manager) readIntents(ctx context.Context) ([]IntentRecord, error) {
+	data, err := m.journal.storage.Read(ctx, m.intentPath())
+	if err != nil {
+		if isMissingJournalError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []IntentRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var rec IntentRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			m.logger.Warn("intent log: skipping unreadable record", "error", err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// pendingIntents returns the begin records in log order that have no commit,
+// treating the transactions in also as committed
+func pendingIntents(records []IntentRecord, also ...string) []IntentRecord {
+	committed := make(map[string]bool, len(also))
+	for _, tx := range also {
+		committed[tx] = true
+	}
+	for _, rec := range records {
+		if rec.Phase == IntentCommit {
+			committed[rec.Tx] = true
+		}
+	}
+
+	var pending []IntentRecord
+	for _, rec := range records {
+		if rec.Phase == IntentBegin && !committed[rec.Tx] {
+			pending = append(pending, rec)
+		}
+	}
+	return pending
+}
+
+// Recover resolves every intent left uncommitted by an interrupted write and
+// then clears the intent log. Call it on startup, before serving traffic,
+// with no other manager writing to the same storage. Each intent is resolved
+// as a unit:
+//
+//  1. If every write's checksum is already in the journal, the operation
+//     finished and only its commit record was lost: RecoveryCommitted.
+//  2. Otherwise, if every write not yet journaled has its config file saved
+//     with the promised checksum (and a valid signature, when verification
+//     is configured) and continues the journal's current head for its id,
+//     the missing entries are journaled in one append: RecoveryCompleted.
+//  3. Otherwise the operation is abandoned: each unjournaled write's config
+//     file is deleted if it holds the promised checksum, and left alone if it
+//     holds anything else (a later write reused the version): the
+//     RecoveryRolledBack action.
+//
+// Writes are never applied to the journal out of chain order, so a rolled
+// back intent can only lose the writes it described. Intents are processed in
+// log order and the result lists them in that order.
+func (m *Manager) Recover(ctx context.Context) (recovered []RecoveredIntent, err error) {
+	ctx, call := m.begin(ctx, "recover", "")
+	defer func() { call.end(nil, err) }()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	records, err := m.readIntents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	var results []RecoveredIntent
+	for _, rec := range pendingIntents(records) {
+		action, err := m.recoverIntent(ctx, rec)
+		if err != nil {
+			return results, err
+		}
+		for _, w := range rec.Writes {
+			m.cacheDelete(w.ID)
+		}
+		m.indexReset()
+		m.logger.Info("recover: resolved intent", "tx", rec.Tx, "action", string(action), "writes", len(rec.Writes))
+		results = append(results, RecoveredIntent{Tx: rec.Tx, Action: action, Writes: rec.Writes})
+	}
+
+	if err := m.journal.storage.Delete(ctx, m.intentPath()); err != nil && !isMissingJournalError(err) {
+		return results, err
+	}
+	return results, nil
+}
+
+func (m *Manager) recoverIntent(ctx context.Context, rec IntentRecord) (RecoveryAction, error) {
+	var pending []IntentWrite
+	for _, w := range rec.Writes {
+		entries, err := m.journal.FindByID(ctx, w.ID)
+		if err != nil {
+			return "", err
+		}
+		journaled := false
+		for _, entry := range entries {
+			if entry.CS == w.CS {
+				journaled = true
+				break
+			}
+		}
+		if !journaled {
+			pending = append(pending, w)
+		}
+	}
+	if len(pending) == 0 {
+		return RecoveryCommitted, nil
+	}
+
+	entries := make([]*JournalEntry, 0, len(pending))
+	for _, w := range pending {
+		cfg, ok := m.completable(ctx, w)
+		if !ok {
+			entries = nil
+			break
+		}
+		entry := m.journalEntry(w.ID, cfg, w.Operation)
+		entry.Message = w.Message
+		entries = append(entries, entry)
+	}
+	if entries != nil {
+		if err := m.journal.AppendBatch(ctx, entries); err != nil {
+			return "", err
+		}
+		return RecoveryCompleted, nil
+	}
+
+	for _, w := range pending {
+		cfg, err := m.configStore.Load(ctx, w.ID, w.Version)
+		if err != nil || cfg.Meta.CS != w.CS {
+			continue
+		}
+		if err := m.configStore.Delete(ctx, w.ID, w.Version); err != nil {
+			return "", err
+		}
+	}
+	return RecoveryRolledBack, nil
+}
+
+// completable loads the config file promised by w and reports whether it can
+// be journaled as the next entry of its chain
+func (m *Manager) completable(ctx context.Context, w IntentWrite) (*Config, bool) {
+	cfg, err := m.configStore.Load(ctx, w.ID, w.Version)
+	if err != nil || cfg.Meta.CS != w.CS {
+		return nil, false
+	}
+
+	last, err := m.journal.Last(ctx, w.ID)
+	switch {
+	case err == nil:
+		return cfg, last.CS == cfg.Meta.PrevCS && last.Version+1 == cfg.Meta.Version
+	case errors.Is(err, ErrNoJournalEntries):
+		return cfg, cfg.Meta.Version == 1 && cfg.Meta.PrevCS == ""
+	default:
+		return nil, false
+	}
+}
+
+// intentWrite describes cfg as a write of id journaled under op
+func intentWrite(id string, cfg *Config, op, message string) IntentWrite {
+	return IntentWrite{
+		ID:        id,
+		Version:   cfg.Meta.Version,
+		CS:        cfg.Meta.CS,
+		PrevCS:    cfg.Meta.PrevCS,
+		Operation: op,
+		Message:   message,
+	}
+}
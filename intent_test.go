@@ -0,0 +1,145 @@
+package viracochan
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIntentLogSteadyState(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithIntentLog())
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.CreateBatch(ctx, map[string]interface{}{"a": 1, "b": 2})
+
+	if exists, _ := storage.Exists(ctx, "journal.jsonl.intents"); exists {
+		t.Fatal("intent log should be removed once every intent is committed")
+	}
+
+	results, err := manager.Recover(ctx)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("expected nothing to recover, got %v, %v", results, err)
+	}
+}
+
+func TestRecoverCompletesUnjournaledWrite(t *testing.T) {
+	ctx := context.Background()
+	storage := &journalWriteCounter{MemoryStorage: NewMemoryStorage(), path: "journal.jsonl"}
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer), WithIntentLog())
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+
+	storage.fail = true
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": 2}); err == nil {
+		t.Fatal("expected update to fail on journal write")
+	}
+	storage.fail = false
+
+	restarted, _ := NewManager(storage.MemoryStorage, WithIntentLog())
+	results, err := restarted.Recover(ctx)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != RecoveryCompleted {
+		t.Fatalf("expected one completed intent, got %+v", results)
+	}
+	if w := results[0].Writes[0]; w.ID != "app" || w.Version != 2 || w.Operation != "update" {
+		t.Errorf("unexpected recovered write %+v", w)
+	}
+
+	latest, err := restarted.GetLatest(ctx, "app")
+	if err != nil || latest.Meta.Version != 2 {
+		t.Fatalf("expected v2 after recovery, got %v, %v", latest, err)
+	}
+	if err := restarted.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain invalid after recovery: %v", err)
+	}
+	if err := signer.Verify(latest, signer.PublicKey()); err != nil {
+		t.Errorf("recovered version lost its signature: %v", err)
+	}
+	if exists, _ := storage.Exists(ctx, "journal.jsonl.intents"); exists {
+		t.Error("intent log should be cleared by Recover")
+	}
+}
+
+func TestRecoverRollsBackBrokenWrite(t *testing.T) {
+	ctx := context.Background()
+	storage := &journalWriteCounter{MemoryStorage: NewMemoryStorage(), path: "journal.jsonl"}
+	manager, _ := NewManager(storage, WithIntentLog())
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+
+	storage.fail = true
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	storage.fail = false
+
+	// A torn config write: the file exists but no longer matches the intent
+	storage.Write(ctx, "configs/app/v2.json", []byte(`{"_meta":{"v":2},"content":{}}`))
+
+	results, err := manager.Recover(ctx)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != RecoveryRolledBack {
+		t.Fatalf("expected one rolled back intent, got %+v", results)
+	}
+
+	latest, err := manager.GetLatest(ctx, "app")
+	if err != nil || latest.Meta.Version != 1 {
+		t.Fatalf("expected v1 after rollback, got %v, %v", latest, err)
+	}
+
+	// A file that matches the intent but no longer continues the chain is
+	// deleted
+	storage.fail = true
+	manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+	storage.fail = false
+	manager.journal.Append(ctx, &JournalEntry{ID: "app", Version: 2, CS: "other", PrevCS: latest.Meta.CS, Time: time.Now()})
+
+	results, err = manager.Recover(ctx)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != RecoveryRolledBack {
+		t.Fatalf("expected one rolled back intent, got %+v", results)
+	}
+	if exists, _ := storage.Exists(ctx, "configs/app/v2.json"); exists {
+		t.Error("rolled back config file should be deleted")
+	}
+}
+
+func TestRecoverLostCommit(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithIntentLog())
+
+	cfg, _ := manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.appendIntent(ctx, IntentRecord{Tx: "t1", Phase: IntentBegin, Writes: []IntentWrite{intentWrite("app", cfg, "create", "")}})
+	storage.Write(ctx, "journal.jsonl.intents", append(mustRead(t, storage, "journal.jsonl.intents"), `{"tx":"t1","pha`...))
+
+	results, err := manager.Recover(ctx)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != RecoveryCommitted || results[0].Tx != "t1" {
+		t.Fatalf("expected t1 committed, got %+v", results)
+	}
+
+	history, _ := manager.GetHistory(ctx, "app")
+	if len(history) != 1 {
+		t.Errorf("expected history untouched, got %d versions", len(history))
+	}
+}
+
+func mustRead(t *testing.T, storage Storage, path string) []byte {
+	t.Helper()
+	data, err := storage.Read(context.Background(), path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return data
+}
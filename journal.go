@@ -2,6 +2,7 @@ package viracochan
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -10,10 +11,13 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// JournalEntry represents a single change in the journal
+// JournalEntry represents a single change in the journal. Build entries for
+// real configs with NewJournalEntry rather than by copying fields off
+// Config.Meta, so they stay complete when the mapping changes.
 type JournalEntry struct {
 	ID        string    `json:"id"`
 	Version   uint64    `json:"v"`
@@ -22,52 +26,270 @@ type JournalEntry struct {
 	Time      time.Time `json:"t"`
 	Operation string    `json:"op"`
 	Config    *Config   `json:"config,omitempty"`
+
+	// Message is the advisory description given with WithMessage
+	Message string `json:"message,omitempty"`
+
+	// ContentHash is the ContentHash of the version's content, recorded at
+	// write time so content lookups need not load every config
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// NewJournalEntry returns the journal entry recording cfg as a version of id
+// written by operation: version, checksums and time from cfg.Meta, the
+// content hash, and cfg itself embedded. Clear Config to journal the version
+// without its content, as WithJournalEmbedContent(false) does.
+func NewJournalEntry(id, operation string, cfg *Config) *JournalEntry {
+	entry := &JournalEntry{
+		ID:        id,
+		Version:   cfg.Meta.Version,
+		CS:        cfg.Meta.CS,
+		PrevCS:    cfg.Meta.PrevCS,
+		Time:      cfg.Meta.Time,
+		Operation: operation,
+		Config:    cfg,
+	}
+	if hash, err := ContentHash(cfg.Content); err == nil {
+		entry.ContentHash = hash
+	}
+	return entry
+}
+
+// ErrMultipleHeads is returned by Resequence when more than one entry could
+// start the chain. Heads, Versions and Dangling are parallel slices describing
+// each competing head: its checksum, its version and whether its prev_cs
+// points at an entry missing from the set (as opposed to being empty).
+type ErrMultipleHeads struct {
+	Heads    []string
+	Versions []uint64
+	Dangling []bool
+}
+
+func (e *ErrMultipleHeads) Error() string {
+	parts := make([]string, len(e.Heads))
+	for i, cs := range e.Heads {
+		kind := "root"
+		if e.Dangling[i] {
+			kind = "dangling prev_cs"
+		}
+		parts[i] = fmt.Sprintf("v%d cs=%s (%s)", e.Versions[i], cs, kind)
+	}
+	return fmt.Sprintf("multiple chain heads found: %s", strings.Join(parts, ", "))
+}
+
+// ChainRoot records the first retained entry of a chain whose earlier
+// entries were pruned from the journal. Its PrevCS legitimately points at a
+// version that no longer exists.
+type ChainRoot struct {
+	Version uint64 `json:"v"`
+	CS      string `json:"cs"`
+	PrevCS  string `json:"prev_cs,omitempty"`
 }
 
 // Journal manages change log for configurations
 type Journal struct {
 	storage Storage
 	path    string
-	mu      sync.Mutex
+	logger  Logger
+	mu      sync.Mutex // guards the journal when storage is not a PathLocker
+
+	clockSkew    time.Duration // see SetClockSkewTolerance
+	configPrefix string        // see SetConfigPrefix
+
+	syncInterval time.Duration // see SetSyncInterval
+	syncMu       sync.Mutex    // guards syncTimer, syncErr and the generations
+	syncTimer    *time.Timer   // pending group commit sync
+	syncErr      error         // failed background sync, for the next append
+	appendGen    uint64        // in-place appends so far
+	syncedGen    uint64        // appends covered by the last completed sync
+	tail         *journalTail  // see appendTail; guarded by the journal lock
+
+	// entries and bytes are the journal's size as of its last full write
+	// through this handle; see ManagerStats
+	entries atomic.Int64
+	bytes   atomic.Int64
 }
 
-// NewJournal creates new journal instance
+// NewJournal creates new journal instance. If storage is a PathLocker, as
+// MemoryStorage and FileStorage are, every journal over it at path shares its
+// lock, so compaction and other rewrites by one never lose entries appended
+// through another, such as those of two managers over one store.
 func NewJournal(storage Storage, path string) *Journal {
 	return &Journal{
-		storage: storage,
-		path:    path,
+		storage:      storage,
+		path:         path,
+		logger:       NopLogger{},
+		configPrefix: DefaultConfigPrefix,
 	}
 }
 
+// lock returns the lock guarding the journal: the storage's lock of its path,
+// or the journal's own
+func (j *Journal) lock() sync.Locker {
+	if l := pathLock(j.storage, j.path); l != nil {
+		return l
+	}
+	return &j.mu
+}
+
+// SetLogger routes the journal's warnings to logger; nil restores the no-op
+// default
+func (j *Journal) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = NopLogger{}
+	}
+	j.logger = logger
+}
+
+// SetConfigPrefix sets the directory Reconstruct reads version files from,
+// DefaultConfigPrefix unless changed; see WithConfigPrefix
+func (j *Journal) SetConfigPrefix(prefix string) {
+	j.configPrefix = prefix
+}
+
 func isMissingJournalError(err error) bool {
 	return errors.Is(err, io.EOF) || errors.Is(err, os.ErrNotExist) || os.IsNotExist(err)
 }
 
-// Append adds entry to journal
+// Append adds entry to journal. Appending an entry with the id, version and
+// checksum of the id's last entry is a no-op, so retrying an append whose
+// write succeeded despite an error does not duplicate it. A different
+// checksum at the same version is a fork and is appended as before.
 func (j *Journal) Append(ctx context.Context, entry *JournalEntry) error {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+	return j.appendEntries(ctx, []*JournalEntry{entry}, true)
+}
+
+// AppendBatch adds entries to the journal with a single storage write, so
+// either all of them land or none do. Unlike Append it writes every entry
+// as given.
+func (j *Journal) AppendBatch(ctx context.Context, entries []*JournalEntry) error {
+	return j.appendEntries(ctx, entries, false)
+}
+
+// appendEntries appends entries in one write; with dedup, the single entry
+// is skipped when it repeats the last entry of its id
+func (j *Journal) appendEntries(ctx context.Context, entries []*JournalEntry, dedup bool) error {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	as := j.groupCommit()
+	if as != nil {
+		if done, err := j.appendTail(ctx, as, entries, dedup); done || err != nil {
+			return err
+		}
+	}
+
+	raw, _ := j.storage.Read(ctx, j.path)
+	existing := trimTornTail(raw)
+	if dedup {
+		// A tail that does not decode cannot be a duplicate; append anyway
+		entry := entries[0]
+		last, _ := lastEntry(existing, entry.ID)
+		if last != nil && last.Version == entry.Version && last.CS == entry.CS {
+			return nil
+		}
+	}
 
-	data, err := json.Marshal(entry)
+	lines, err := marshalEntries(entries)
 	if err != nil {
 		return err
 	}
 
-	existing, _ := j.storage.Read(ctx, j.path)
+	// A torn tail is dropped by rewriting the journal without it
+	if as != nil && len(existing) == len(raw) {
+		if err := j.appendInPlace(ctx, as, existing, lines); err != nil {
+			return err
+		}
+		j.noteSize(journalLines(existing)+len(entries), len(existing)+len(lines))
+		if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+			existing = append(existing, '\n')
+		}
+		j.rememberTail(ctx, as, append(existing, lines...))
+		return nil
+	}
+
 	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
 		existing = append(existing, '\n')
 	}
 
-	newData := append(existing, data...) //nolint:gocritic // appendAssign is intended here
-	newData = append(newData, '\n')
+	newData := append(existing, lines...) //nolint:gocritic // appendAssign is intended here
+
+	if err := j.storage.Write(ctx, j.path, newData); err != nil {
+		return err
+	}
+	j.noteSize(journalLines(newData), len(newData))
+	if as != nil {
+		j.rememberTail(ctx, as, newData)
+	}
+	return nil
+}
+
+// marshalEntries encodes entries as journal lines
+func marshalEntries(entries []*JournalEntry) ([]byte, error) {
+	var lines []byte
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, data...)
+		lines = append(lines, '\n')
+	}
+	return lines, nil
+}
+
+// noteSize records the journal's size after a write through j
+func (j *Journal) noteSize(entries, size int) {
+	j.entries.Store(int64(entries))
+	j.bytes.Store(int64(size))
+}
 
-	return j.storage.Write(ctx, j.path, newData)
+// journalLines counts the non-blank lines of data without splitting it
+func journalLines(data []byte) int {
+	n := 0
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			n++
+		}
+	}
+	return n
 }
 
 // ReadAll reads all journal entries
 func (j *Journal) ReadAll(ctx context.Context) ([]*JournalEntry, error) {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	data, err := j.storage.Read(ctx, j.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return parseJournalEntries(data)
+}
+
+// readPrefix reads the entries that end within the first offset bytes of the
+// journal: those a JournalReader has returned once its Offset reaches offset.
+// A line cut by offset is left out.
+func (j *Journal) readPrefix(ctx context.Context, offset int64) ([]*JournalEntry, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("negative journal offset %d", offset)
+	}
+
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
 
 	data, err := j.storage.Read(ctx, j.path)
 	if err != nil {
@@ -77,8 +299,16 @@ func (j *Journal) ReadAll(ctx context.Context) ([]*JournalEntry, error) {
 		return nil, err
 	}
 
+	if offset < int64(len(data)) {
+		data = data[:offset]
+		data = data[:bytes.LastIndexByte(data, '\n')+1]
+	}
+	return parseJournalEntries(data)
+}
+
+func parseJournalEntries(data []byte) ([]*JournalEntry, error) {
 	var entries []*JournalEntry
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner := bufio.NewScanner(strings.NewReader(string(trimTornTail(data))))
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" {
@@ -114,20 +344,29 @@ func (j *Journal) Resequence(entries []*JournalEntry) ([]*JournalEntry, error) {
 		csSet[entry.CS] = struct{}{}
 	}
 
-	var head *JournalEntry
+	var heads []*JournalEntry
 	for _, entry := range entries {
 		if entry.PrevCS == "" || (entry.PrevCS != "" && csToEntry[entry.PrevCS] == nil) {
-			if head != nil {
-				return nil, fmt.Errorf("multiple chain heads found")
-			}
-			head = entry
+			heads = append(heads, entry)
 		}
 	}
 
-	if head == nil {
-		return nil, fmt.Errorf("no chain head found")
+	if len(heads) > 1 {
+		herr := &ErrMultipleHeads{}
+		for _, h := range heads {
+			herr.Heads = append(herr.Heads, h.CS)
+			herr.Versions = append(herr.Versions, h.Version)
+			herr.Dangling = append(herr.Dangling, h.PrevCS != "")
+		}
+		return nil, herr
 	}
 
+	if len(heads) == 0 {
+		return nil, fmt.Errorf("no chain head found: none of %d entries has an empty or dangling prev_cs, "+
+			"every entry references another entry in the set (cyclic chain)", len(entries))
+	}
+	head := heads[0]
+
 	ordered := make([]*JournalEntry, 0, len(entries))
 	current := head
 
@@ -154,11 +393,21 @@ func (j *Journal) Resequence(entries []*JournalEntry) ([]*JournalEntry, error) {
 
 // ValidateChain verifies integrity of entry sequence
 func (j *Journal) ValidateChain(entries []*JournalEntry) error {
+	return j.validateChain(context.Background(), entries, nil)
+}
+
+// validateChain is ValidateChain reporting the number of entries validated
+// so far to step, if not nil, after each one. It stops with ctx's error once
+// ctx is done.
+func (j *Journal) validateChain(ctx context.Context, entries []*JournalEntry, step func(done int)) error {
 	if len(entries) == 0 {
 		return nil
 	}
 
 	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if entry.Config != nil {
 			if err := entry.Config.Validate(); err != nil {
 				return fmt.Errorf("entry %d invalid: %w", i, err)
@@ -177,15 +426,82 @@ func (j *Journal) ValidateChain(entries []*JournalEntry) error {
 			if entry.Version != prev.Version+1 {
 				return fmt.Errorf("version break at %d: %d -> %d", i, prev.Version, entry.Version)
 			}
-			if entry.Time.Before(prev.Time) {
+			if timeRegressed(entry.Time, prev.Time, j.clockSkew) {
 				return fmt.Errorf("timestamp regression at %d", i)
 			}
 		}
+		if step != nil {
+			step(i + 1)
+		}
 	}
 
 	return nil
 }
 
+// ErrJournalChanged is returned when the journal was rewritten by another
+// process while it was being compacted
+var ErrJournalChanged = errors.New("journal changed")
+
+// ErrNoJournalEntries is returned by Last when the journal holds no entry for
+// the requested id (including when the journal does not exist yet).
+var ErrNoJournalEntries = errors.New("no journal entries")
+
+// Last returns the most recently appended entry for id. It scans the journal
+// backwards and decodes only lines that can belong to id, so it does not
+// materialize the whole journal.
+func (j *Journal) Last(ctx context.Context, id string) (*JournalEntry, error) {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	data, err := j.storage.Read(ctx, j.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return nil, fmt.Errorf("%w for %q", ErrNoJournalEntries, id)
+		}
+		return nil, err
+	}
+
+	entry, err := lastEntry(data, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w for %q", ErrNoJournalEntries, id)
+	}
+	return entry, nil
+}
+
+// lastEntry returns the last entry of id in the journal data, or nil if it
+// has none. Lines are scanned from the end, and only those that can belong
+// to id are decoded.
+func lastEntry(data []byte, id string) (*JournalEntry, error) {
+	quotedID, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	needle := append([]byte(`"id":`), quotedID...)
+
+	data = trimTornTail(data)
+	for end := len(data); end > 0; {
+		start := bytes.LastIndexByte(data[:end], '\n') + 1
+		line := bytes.TrimSpace(data[start:end])
+		end = start - 1
+
+		if len(line) == 0 || !bytes.Contains(line, needle) {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("invalid journal entry: %w", err)
+		}
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
 // FindByID returns all entries for specific configuration ID
 func (j *Journal) FindByID(ctx context.Context, id string) ([]*JournalEntry, error) {
 	all, err := j.ReadAll(ctx)
@@ -203,19 +519,47 @@ func (j *Journal) FindByID(ctx context.Context, id string) ([]*JournalEntry, err
 	return filtered, nil
 }
 
-// Compact removes redundant entries while preserving chain integrity
-func (j *Journal) Compact(ctx context.Context) error {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+// CompactResult reports what a compaction reclaimed. PerID holds the number
+// of entries removed for every id that was compacted (zero when its chain was
+// already short enough); ids whose entries could not be resequenced are kept
+// unchanged and listed in Skipped with their entry count instead.
+type CompactResult struct {
+	EntriesBefore int
+	EntriesAfter  int
+	BytesBefore   int
+	BytesAfter    int
+	PerID         map[string]int
+	Skipped       map[string]int
+}
+
+// Compact removes redundant entries while preserving chain integrity. It
+// holds the journal lock throughout, so appends through any journal of the
+// process over the same storage wait for it rather than being lost, and
+// replaces the journal with a single Write, which FileStorage performs as a
+// write to a new file renamed into place: readers see the old journal or the
+// compacted one, never a mix. Entries appended meanwhile by another process
+// are carried over when the journal still starts with what was compacted;
+// when it was rewritten instead, Compact fails with ErrJournalChanged and
+// leaves it alone.
+func (j *Journal) Compact(ctx context.Context) (*CompactResult, error) {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	result := &CompactResult{
+		PerID:   make(map[string]int),
+		Skipped: make(map[string]int),
+	}
 
 	// Read without locking since we already have the lock
 	data, err := j.storage.Read(ctx, j.path)
 	if err != nil {
 		if isMissingJournalError(err) {
-			return nil
+			return result, nil
 		}
-		return err
+		return nil, err
 	}
+	result.BytesBefore = len(data)
 
 	var entries []*JournalEntry
 	scanner := bufio.NewScanner(strings.NewReader(string(data)))
@@ -227,53 +571,157 @@ func (j *Journal) Compact(ctx context.Context) error {
 
 		var entry JournalEntry
 		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			return fmt.Errorf("invalid journal entry: %w", err)
+			return nil, fmt.Errorf("invalid journal entry: %w", err)
 		}
 		entries = append(entries, &entry)
 	}
 
 	if err := scanner.Err(); err != nil {
-		return err
+		return nil, err
 	}
+	result.EntriesBefore = len(entries)
 
 	byID := make(map[string][]*JournalEntry)
 	for _, entry := range entries {
 		byID[entry.ID] = append(byID[entry.ID], entry)
 	}
 
+	roots, err := j.readRoots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var compacted []*JournalEntry
 	for id, idEntries := range byID {
 		ordered, err := j.Resequence(idEntries)
 		if err != nil {
-			fmt.Printf("Warning: skipping %s due to resequence error: %v\n", id, err)
+			j.logger.Warn("compact: keeping entries unchanged after resequence error", "id", id, "error", err)
 			compacted = append(compacted, idEntries...)
+			result.Skipped[id] = len(idEntries)
 			continue
 		}
 
 		if len(ordered) > 10 {
-			compacted = append(compacted, ordered[len(ordered)-10:]...)
-		} else {
-			compacted = append(compacted, ordered...)
+			ordered = ordered[len(ordered)-10:]
+			roots[id] = ChainRoot{Version: ordered[0].Version, CS: ordered[0].CS, PrevCS: ordered[0].PrevCS}
 		}
+		compacted = append(compacted, ordered...)
+		result.PerID[id] = len(idEntries) - len(ordered)
+	}
+
+	if err := j.writeRoots(ctx, roots); err != nil {
+		return nil, err
 	}
 
 	var buf strings.Builder
 	for _, entry := range compacted {
 		data, err := json.Marshal(entry)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		buf.Write(data)
 		buf.WriteByte('\n')
 	}
 
-	return j.storage.Write(ctx, j.path, []byte(buf.String()))
+	latest, err := j.storage.Read(ctx, j.path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(latest, data) {
+		return nil, fmt.Errorf("%w during compaction", ErrJournalChanged)
+	}
+	tail := bytes.TrimLeft(latest[len(data):], "\n")
+	buf.Write(tail)
+
+	if err := j.storage.Write(ctx, j.path, []byte(buf.String())); err != nil {
+		return nil, err
+	}
+	result.EntriesAfter = len(compacted) + bytes.Count(tail, []byte("\n"))
+	result.BytesAfter = buf.Len()
+	j.tail = nil
+	j.noteSize(result.EntriesAfter, result.BytesAfter)
+	return result, nil
+}
+
+func (j *Journal) rootsPath() string {
+	return j.path + ".roots"
+}
+
+func (j *Journal) readRoots(ctx context.Context) (map[string]ChainRoot, error) {
+	roots := make(map[string]ChainRoot)
+	data, err := j.storage.Read(ctx, j.rootsPath())
+	if err != nil {
+		if isMissingJournalError(err) {
+			return roots, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &roots); err != nil {
+		return nil, fmt.Errorf("invalid chain roots: %w", err)
+	}
+	return roots, nil
+}
+
+func (j *Journal) writeRoots(ctx context.Context, roots map[string]ChainRoot) error {
+	if len(roots) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(roots)
+	if err != nil {
+		return err
+	}
+	return j.storage.Write(ctx, j.rootsPath(), data)
+}
+
+// Root returns the recorded pruned boundary for id, if compaction has
+// dropped the beginning of its chain.
+func (j *Journal) Root(ctx context.Context, id string) (ChainRoot, bool, error) {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	roots, err := j.readRoots(ctx)
+	if err != nil {
+		return ChainRoot{}, false, err
+	}
+	root, ok := roots[id]
+	return root, ok, nil
+}
+
+// ResequenceFrom orders entries treating the entry at startVersion as the
+// chain root. Entries before startVersion are ignored and the root's PrevCS
+// may reference a version that is not present.
+func (j *Journal) ResequenceFrom(entries []*JournalEntry, startVersion uint64) ([]*JournalEntry, error) {
+	var tail []*JournalEntry
+	found := false
+	for _, entry := range entries {
+		if entry.Version < startVersion {
+			continue
+		}
+		if entry.Version == startVersion {
+			found = true
+		}
+		tail = append(tail, entry)
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no entry at start version %d", ErrInvalidChain, startVersion)
+	}
+
+	ordered, err := j.Resequence(tail)
+	if err != nil {
+		return nil, err
+	}
+	if ordered[0].Version != startVersion {
+		return nil, fmt.Errorf("%w: chain starts at version %d, expected %d", ErrInvalidChain, ordered[0].Version, startVersion)
+	}
+	return ordered, nil
 }
 
 // Rewrite replaces the journal contents with the provided entries.
 func (j *Journal) Rewrite(ctx context.Context, entries []*JournalEntry) error {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
 
 	var buf strings.Builder
 	for _, entry := range entries {
@@ -285,27 +733,294 @@ func (j *Journal) Rewrite(ctx context.Context, entries []*JournalEntry) error {
 		buf.WriteByte('\n')
 	}
 
-	return j.storage.Write(ctx, j.path, []byte(buf.String()))
+	if err := j.storage.Write(ctx, j.path, []byte(buf.String())); err != nil {
+		return err
+	}
+	j.tail = nil
+	j.noteSize(len(entries), buf.Len())
+	return nil
+}
+
+// relabel moves every entry of id to newID, or drops them when newID is
+// empty, along with id's recorded chain root. Lines that do not parse are
+// kept as they are, so it works on a damaged journal. It returns the number
+// of entries moved or dropped.
+func (j *Journal) relabel(ctx context.Context, id, newID string) (int, error) {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	data, err := j.storage.Read(ctx, j.path)
+	if err != nil && !isMissingJournalError(err) {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	moved := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if json.Unmarshal(line, &entry) == nil && entry.ID == id {
+			moved++
+			if newID == "" {
+				continue
+			}
+			entry.ID = newID
+			if line, err = json.Marshal(&entry); err != nil {
+				return 0, err
+			}
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	roots, err := j.readRoots(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if root, ok := roots[id]; ok {
+		delete(roots, id)
+		if newID != "" {
+			roots[newID] = root
+		}
+		if len(roots) == 0 {
+			err = j.storage.Delete(ctx, j.rootsPath())
+		} else {
+			err = j.writeRoots(ctx, roots)
+		}
+		if err != nil && !isMissingJournalError(err) {
+			return 0, err
+		}
+	}
+
+	if moved == 0 {
+		return 0, nil
+	}
+	if err := j.storage.Write(ctx, j.path, buf.Bytes()); err != nil {
+		return 0, err
+	}
+	j.tail = nil
+	j.noteSize(journalLines(buf.Bytes()), buf.Len())
+	return moved, nil
+}
+
+// DedupResult reports what Dedup changed. Unresolved counts forked versions
+// left in place because no entry was clearly the one the chain continues.
+type DedupResult struct {
+	Removed     int
+	Quarantined int
+	Unresolved  int
 }
 
-// Reconstruct rebuilds latest state from journal and scattered files
+func (j *Journal) quarantinePath() string {
+	return j.path + ".quarantine"
+}
+
+// Dedup heals a journal with repeated entries. Exact duplicates (same id,
+// version and checksum) are removed, keeping the first. For a fork, where one
+// version of an id appears with different checksums, the entry that links to
+// its neighbours (previous version's checksum, or none at v1, and the next
+// version's PrevCS) is kept and the others are moved to <journal>.quarantine,
+// never deleted; if no entry links better than the rest, the fork is left for
+// manual repair and counted as Unresolved. Surviving lines, including any
+// that do not parse, keep their order and exact bytes.
+func (j *Journal) Dedup(ctx context.Context) (*DedupResult, error) {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	result := &DedupResult{}
+	data, err := j.storage.Read(ctx, j.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	type line struct {
+		raw   string
+		entry *JournalEntry // nil for lines that do not parse
+	}
+	type versionKey struct {
+		id      string
+		version uint64
+	}
+	type entryKey struct {
+		id      string
+		version uint64
+		cs      string
+	}
+
+	var lines []line
+	byVersion := make(map[versionKey][]*JournalEntry)
+	seen := make(map[entryKey]bool)
+
+	for _, raw := range strings.Split(string(data), "\n") {
+		if raw == "" {
+			continue
+		}
+		var entry JournalEntry
+		if json.Unmarshal([]byte(raw), &entry) != nil {
+			lines = append(lines, line{raw: raw})
+			continue
+		}
+		key := entryKey{entry.ID, entry.Version, entry.CS}
+		if seen[key] {
+			result.Removed++
+			continue
+		}
+		seen[key] = true
+		lines = append(lines, line{raw: raw, entry: &entry})
+		vk := versionKey{entry.ID, entry.Version}
+		byVersion[vk] = append(byVersion[vk], &entry)
+	}
+
+	links := func(e *JournalEntry) int {
+		score := 0
+		if e.Version == 1 && e.PrevCS == "" {
+			score++
+		}
+		for _, prev := range byVersion[versionKey{e.ID, e.Version - 1}] {
+			if e.Version > 1 && prev.CS == e.PrevCS {
+				score++
+				break
+			}
+		}
+		for _, next := range byVersion[versionKey{e.ID, e.Version + 1}] {
+			if next.PrevCS == e.CS {
+				score++
+				break
+			}
+		}
+		return score
+	}
+
+	quarantined := make(map[*JournalEntry]bool)
+	for _, candidates := range byVersion {
+		if len(candidates) < 2 {
+			continue
+		}
+		best, bestScore, tie := candidates[0], links(candidates[0]), false
+		for _, c := range candidates[1:] {
+			switch score := links(c); {
+			case score > bestScore:
+				best, bestScore, tie = c, score, false
+			case score == bestScore:
+				tie = true
+			}
+		}
+		if tie {
+			result.Unresolved++
+			continue
+		}
+		for _, c := range candidates {
+			if c != best {
+				quarantined[c] = true
+			}
+		}
+	}
+
+	if result.Removed == 0 && len(quarantined) == 0 {
+		return result, nil
+	}
+
+	var kept, moved strings.Builder
+	for _, l := range lines {
+		if l.entry != nil && quarantined[l.entry] {
+			moved.WriteString(l.raw)
+			moved.WriteByte('\n')
+			result.Quarantined++
+			continue
+		}
+		kept.WriteString(l.raw)
+		kept.WriteByte('\n')
+	}
+
+	if moved.Len() > 0 {
+		existing, err := j.storage.Read(ctx, j.quarantinePath())
+		if err != nil && !isMissingJournalError(err) {
+			return nil, err
+		}
+		if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+			existing = append(existing, '\n')
+		}
+		if err := j.storage.Write(ctx, j.quarantinePath(), append(existing, moved.String()...)); err != nil {
+			return nil, err
+		}
+	}
+	if err := j.storage.Write(ctx, j.path, []byte(kept.String())); err != nil {
+		return nil, err
+	}
+	j.tail = nil
+	j.noteSize(journalLines([]byte(kept.String())), kept.Len())
+	return result, nil
+}
+
+// progressSteps is about how many times a reconstruction reports progress
+const progressSteps = 100
+
+// Reconstruct rebuilds latest state from journal and scattered files, the
+// version files being read from storage under the journal's config prefix
+// (see SetConfigPrefix).
+//
+// When id has journal entries, the journal decides what is latest: its head
+// entry's embedded config is returned as is, and otherwise the version file
+// is loaded and must carry the head's checksum. A version file saved ahead
+// of its journal entry, as every write does, is therefore not served until
+// the entry lands, and a file rewritten by another writer is an error rather
+// than stale data. Only when the journal has no entries for id is the
+// latest version file taken on its own, to recover a store whose journal
+// was lost.
 func (j *Journal) Reconstruct(ctx context.Context, id string, storage Storage) (*Config, error) {
+	return j.reconstruct(ctx, id, storage, nil)
+}
+
+// reconstruct is Reconstruct reporting progress, if not nil, as described
+// at Manager.ReconstructWithProgress
+func (j *Journal) reconstruct(ctx context.Context, id string, storage Storage, progress func(done, total int)) (*Config, error) {
 	entries, err := j.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(entries) == 0 {
-		cs := NewConfigStorage(storage, "configs")
+		cs := NewConfigStorage(storage, j.configPrefix)
 		return cs.LoadLatest(ctx, id)
 	}
 
+	total := len(entries)
+	var step func(done int)
+	if progress != nil {
+		every := (total + progressSteps - 1) / progressSteps
+		step = func(done int) {
+			if done%every == 0 || done == total {
+				progress(done, total)
+			}
+		}
+		progress(0, total)
+	}
+
 	ordered, err := j.Resequence(entries)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resequence: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	if err := j.ValidateChain(ordered); err != nil {
+	if err := j.validateChain(ctx, ordered, step); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil, fmt.Errorf("invalid chain: %w", err)
 	}
 
@@ -314,8 +1029,15 @@ func (j *Journal) Reconstruct(ctx context.Context, id string, storage Storage) (
 		return latest.Config, nil
 	}
 
-	cs := NewConfigStorage(storage, "configs")
-	return cs.Load(ctx, id, latest.Version)
+	cs := NewConfigStorage(storage, j.configPrefix)
+	cfg, err := cs.Load(ctx, id, latest.Version)
+	if err != nil {
+		return nil, err
+	}
+	if latest.CS != "" && cfg.Meta.CS != latest.CS {
+		return nil, fmt.Errorf("%w: %q v%d file has checksum %s, journal has %s", ErrInvalidChain, id, latest.Version, cfg.Meta.CS, latest.CS)
+	}
+	return cfg, nil
 }
 
 // JournalReader provides streaming read of journal entries
@@ -339,6 +1061,7 @@ func (jr *JournalReader) Next(ctx context.Context) (*JournalEntry, error) {
 	if err != nil {
 		return nil, err
 	}
+	data = trimTornTail(data)
 
 	if jr.offset >= int64(len(data)) {
 		return nil, io.EOF
@@ -360,6 +1083,12 @@ func (jr *JournalReader) Next(ctx context.Context) (*JournalEntry, error) {
 	return &entry, nil
 }
 
+// Offset returns the byte offset of the next entry, just after the last one
+// returned. See Manager.ReconstructAtOffset.
+func (jr *JournalReader) Offset() int64 {
+	return jr.offset
+}
+
 // Reset resets reader to beginning
 func (jr *JournalReader) Reset() {
 	jr.offset = 0
@@ -3,8 +3,13 @@ package viracochan
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -191,6 +196,32 @@ func TestJournalReconstruct(t *testing.T) {
 	}
 }
 
+func TestJournalReconstructPublishOrder(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithJournalEmbedContent(false))
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	v2, _ := manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	// A v3 file saved ahead of its journal entry is not yet published
+	v3 := &Config{Meta: v2.Meta, Content: json.RawMessage(`{"n":3}`)}
+	v3.UpdateMeta()
+	manager.configStore.Save(ctx, "app", v3)
+	latest, err := manager.journal.Reconstruct(ctx, "app", storage)
+	if err != nil || latest.Meta.CS != v2.Meta.CS {
+		t.Fatalf("expected the journal head v2, got %v, %v", latest, err)
+	}
+
+	// Another writer rewriting the head's file is not served as the head
+	other := &Config{Meta: v2.Meta, Content: json.RawMessage(`{"n":"other"}`)}
+	other.Meta.Version, other.Meta.CS = 1, v2.Meta.PrevCS
+	other.UpdateMeta()
+	manager.configStore.Save(ctx, "app", other)
+	if _, err := manager.journal.Reconstruct(ctx, "app", storage); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain for a rewritten head file, got %v", err)
+	}
+}
+
 func TestJournalForkDetection(t *testing.T) {
 	journal := &Journal{}
 
@@ -241,9 +272,19 @@ func TestJournalCompact(t *testing.T) {
 		journal.Append(ctx, entry)
 	}
 
-	if err := journal.Compact(ctx); err != nil {
+	result, err := journal.Compact(ctx)
+	if err != nil {
 		t.Fatalf("Compact failed: %v", err)
 	}
+	if result.EntriesBefore != 25 || result.EntriesAfter != 15 {
+		t.Errorf("expected 25 -> 15 entries, got %d -> %d", result.EntriesBefore, result.EntriesAfter)
+	}
+	if result.PerID["test1"] != 10 || result.PerID["test2"] != 0 || len(result.Skipped) != 0 {
+		t.Errorf("unexpected per-id result: %v skipped=%v", result.PerID, result.Skipped)
+	}
+	if raw, _ := storage.Read(ctx, "test.journal"); result.BytesAfter != len(raw) || result.BytesAfter >= result.BytesBefore {
+		t.Errorf("unexpected byte counts: before=%d after=%d on disk=%d", result.BytesBefore, result.BytesAfter, len(raw))
+	}
 
 	entries, err := journal.ReadAll(ctx)
 	if err != nil {
@@ -254,3 +295,386 @@ func TestJournalCompact(t *testing.T) {
 		t.Errorf("Expected compacted journal to have <= 15 entries, got %d", len(entries))
 	}
 }
+
+// yieldingStorage yields the processor around every read, widening the
+// window between a read and the write that follows it
+type yieldingStorage struct {
+	*MemoryStorage
+}
+
+func (s *yieldingStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	runtime.Gosched()
+	defer runtime.Gosched()
+	return s.MemoryStorage.Read(ctx, path)
+}
+
+func TestJournalCompactConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	storage := &yieldingStorage{NewMemoryStorage()}
+	writer := NewJournal(storage, "journal.jsonl")
+	compactor := NewJournal(storage, "journal.jsonl")
+
+	const n = 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			entry := &JournalEntry{ID: fmt.Sprintf("cfg-%d", i), Version: 1, CS: fmt.Sprintf("cs%d", i), Time: time.Now(), Operation: "create"}
+			if err := writer.Append(ctx, entry); err != nil {
+				t.Errorf("Append failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	compactions := 0
+	for running := true; running; compactions++ {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if _, err := compactor.Compact(ctx); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+	}
+
+	entries, err := writer.ReadAll(ctx)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(entries) != n {
+		t.Fatalf("expected %d entries after %d concurrent compactions, got %d", n, compactions, len(entries))
+	}
+}
+
+func TestJournalPathLock(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryStorage()
+
+	// Wrappers pass the backend's lock on, so journals of managers with
+	// differently wrapped storage still share it
+	wrapped := NewRetryingStorage(NewTracingStorage(mem, nil), RetryPolicy{})
+	if NewJournal(wrapped, "./journal.jsonl").lock() != NewJournal(mem, "journal.jsonl").lock() {
+		t.Error("expected journals of one storage and path to share a lock")
+	}
+	if NewJournal(mem, "journal.jsonl").lock() == NewJournal(mem, "other.jsonl").lock() {
+		t.Error("expected journals of different paths not to share a lock")
+	}
+	if NewJournal(mem, "journal.jsonl").lock() == NewJournal(NewMemoryStorage(), "journal.jsonl").lock() {
+		t.Error("expected journals of different storages not to share a lock")
+	}
+
+	// A storage without locks of its own, and a journal built by hand
+	plain := &Journal{storage: &appendingStorage{Storage: mem}, path: "plain.jsonl", logger: NopLogger{}}
+	if err := plain.Append(ctx, &JournalEntry{ID: "app", Version: 1, CS: "cs1", Time: time.Now(), Operation: "create"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if entries, err := plain.ReadAll(ctx); err != nil || len(entries) != 1 {
+		t.Errorf("expected one entry, got %d, %v", len(entries), err)
+	}
+}
+
+// appendingStorage runs onRead once, after the first read of path
+type appendingStorage struct {
+	Storage
+	path   string
+	onRead func()
+}
+
+func (s *appendingStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	data, err := s.Storage.Read(ctx, path)
+	if path == s.path && s.onRead != nil {
+		onRead := s.onRead
+		s.onRead = nil
+		onRead()
+	}
+	return data, err
+}
+
+func TestJournalCompactForeignWrites(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryStorage()
+	storage := &appendingStorage{Storage: mem, path: "journal.jsonl"}
+	journal := NewJournal(storage, "journal.jsonl")
+
+	prev := ""
+	for v := uint64(1); v <= 12; v++ {
+		cs := fmt.Sprintf("cs%d", v)
+		entry := &JournalEntry{ID: "app", Version: v, CS: cs, PrevCS: prev, Time: time.Now(), Operation: "update"}
+		if err := journal.Append(ctx, entry); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		prev = cs
+	}
+
+	// Another process appends while the journal is being compacted
+	foreign, _ := json.Marshal(&JournalEntry{ID: "other", Version: 1, CS: "o1", Time: time.Now(), Operation: "create"})
+	storage.onRead = func() {
+		data, _ := mem.Read(ctx, "journal.jsonl")
+		mem.Write(ctx, "journal.jsonl", append(data, append(foreign, '\n')...))
+	}
+	result, err := journal.Compact(ctx)
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if result.EntriesAfter != 11 {
+		t.Errorf("expected 10 compacted entries plus the foreign one, got %d", result.EntriesAfter)
+	}
+	if others, _ := journal.FindByID(ctx, "other"); len(others) != 1 {
+		t.Errorf("foreign append was lost during compaction: %d entries", len(others))
+	}
+
+	// A journal rewritten meanwhile is left alone
+	storage.onRead = func() {
+		mem.Write(ctx, "journal.jsonl", append(foreign, '\n'))
+	}
+	if _, err := journal.Compact(ctx); !errors.Is(err, ErrJournalChanged) {
+		t.Fatalf("expected ErrJournalChanged, got %v", err)
+	}
+	if data, _ := mem.Read(ctx, "journal.jsonl"); string(data) != string(foreign)+"\n" {
+		t.Errorf("rewritten journal was overwritten: %q", data)
+	}
+}
+
+func TestJournalResequenceMultipleHeads(t *testing.T) {
+	journal := &Journal{}
+
+	entries := []*JournalEntry{
+		{ID: "test", Version: 1, CS: "cs1", PrevCS: "", Time: time.Now()},
+		{ID: "test", Version: 2, CS: "cs2", PrevCS: "cs1", Time: time.Now()},
+		{ID: "test", Version: 1, CS: "cs1b", PrevCS: "", Time: time.Now()},
+		{ID: "test", Version: 7, CS: "cs7", PrevCS: "missing", Time: time.Now()},
+	}
+
+	_, err := journal.Resequence(entries)
+	var herr *ErrMultipleHeads
+	if !errors.As(err, &herr) {
+		t.Fatalf("expected ErrMultipleHeads, got %v", err)
+	}
+
+	want := []string{"cs1", "cs1b", "cs7"}
+	if !reflect.DeepEqual(herr.Heads, want) {
+		t.Errorf("expected heads %v, got %v", want, herr.Heads)
+	}
+	if !reflect.DeepEqual(herr.Versions, []uint64{1, 1, 7}) {
+		t.Errorf("unexpected head versions %v", herr.Versions)
+	}
+	if !reflect.DeepEqual(herr.Dangling, []bool{false, false, true}) {
+		t.Errorf("unexpected dangling flags %v", herr.Dangling)
+	}
+	if !strings.Contains(err.Error(), "cs1b") {
+		t.Errorf("error message should list competing heads: %v", err)
+	}
+}
+
+func TestJournalResequenceNoHead(t *testing.T) {
+	journal := &Journal{}
+
+	cyclic := []*JournalEntry{
+		{ID: "test", Version: 1, CS: "cs1", PrevCS: "cs2", Time: time.Now()},
+		{ID: "test", Version: 2, CS: "cs2", PrevCS: "cs1", Time: time.Now()},
+	}
+
+	_, err := journal.Resequence(cyclic)
+	if err == nil {
+		t.Fatal("expected no-head error for cyclic chain")
+	}
+	if !strings.Contains(err.Error(), "cyclic") {
+		t.Errorf("expected cyclic hint in error, got %v", err)
+	}
+}
+
+type recordingLogger struct {
+	NopLogger
+	mu    sync.Mutex
+	warns []string
+}
+
+func (l *recordingLogger) Warn(msg string, kv ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, fmt.Sprint(append([]any{msg}, kv...)...))
+}
+
+func TestJournalCompactLogsSkippedIDs(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	logger := &recordingLogger{}
+	manager, err := NewManager(storage, WithJournalPath("forked.journal"), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	// Two competing roots for the same id cannot be resequenced
+	for _, cs := range []string{"a", "b"} {
+		manager.journal.Append(ctx, &JournalEntry{ID: "forked", Version: 1, CS: cs, Operation: "create"})
+	}
+
+	result, err := manager.Compact(ctx)
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if result.Skipped["forked"] != 2 || len(result.PerID) != 0 || result.EntriesAfter != 2 {
+		t.Errorf("expected forked id counted as skipped, got %+v", result)
+	}
+
+	if len(logger.warns) != 1 || !strings.Contains(logger.warns[0], "forked") {
+		t.Errorf("expected one warning naming the skipped id, got %v", logger.warns)
+	}
+
+	entries, _ := manager.journal.ReadAll(ctx)
+	if len(entries) != 2 {
+		t.Errorf("expected unresequenceable entries to be kept, got %d", len(entries))
+	}
+}
+
+func TestJournalLast(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	journal := NewJournal(storage, "test.journal")
+
+	if _, err := journal.Last(ctx, "a"); !errors.Is(err, ErrNoJournalEntries) {
+		t.Fatalf("expected ErrNoJournalEntries on missing journal, got %v", err)
+	}
+
+	for i, id := range []string{"a", "b", "a", "ab", "b"} {
+		journal.Append(ctx, &JournalEntry{ID: id, Version: uint64(i + 1), CS: fmt.Sprintf("cs%d", i), Operation: "update"})
+	}
+
+	for id, want := range map[string]uint64{"a": 3, "b": 5, "ab": 4} {
+		entry, err := journal.Last(ctx, id)
+		if err != nil {
+			t.Fatalf("Last(%q) failed: %v", id, err)
+		}
+		if entry.ID != id || entry.Version != want {
+			t.Errorf("Last(%q): expected v%d, got %s v%d", id, want, entry.ID, entry.Version)
+		}
+	}
+
+	if _, err := journal.Last(ctx, "c"); !errors.Is(err, ErrNoJournalEntries) {
+		t.Errorf("expected ErrNoJournalEntries for unknown id, got %v", err)
+	}
+
+	storage.Write(ctx, "test.journal", []byte("{\"id\":\"a\",broken\n"))
+	if _, err := journal.Last(ctx, "a"); err == nil || errors.Is(err, ErrNoJournalEntries) {
+		t.Errorf("expected decode error distinct from not-found, got %v", err)
+	}
+}
+
+func TestJournalAppendIdempotent(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	journal := NewJournal(storage, "journal.jsonl")
+
+	e1 := &JournalEntry{ID: "app", Version: 1, CS: "a1", Operation: "create", Time: time.Now()}
+	e2 := &JournalEntry{ID: "app", Version: 2, CS: "a2", PrevCS: "a1", Operation: "update", Time: time.Now()}
+	other := &JournalEntry{ID: "other", Version: 1, CS: "o1", Operation: "create", Time: time.Now()}
+	for _, entry := range []*JournalEntry{e1, e2, e2, other, e2} {
+		if err := journal.Append(ctx, entry); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	// e2 repeated right away is dropped; after other it is still the tail of app
+	entries, _ := journal.ReadAll(ctx)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if _, err := journal.Resequence(entries[:2]); err != nil {
+		t.Errorf("retried appends broke resequencing: %v", err)
+	}
+
+	// An older version and a same-version fork are not the tail, so both land
+	fork := &JournalEntry{ID: "app", Version: 2, CS: "b2", PrevCS: "a1", Operation: "update", Time: time.Now()}
+	journal.Append(ctx, e1)
+	journal.Append(ctx, fork)
+	if entries, _ := journal.ReadAll(ctx); len(entries) != 5 {
+		t.Errorf("expected the older entry and the fork appended, got %d entries", len(entries))
+	}
+}
+
+func TestJournalDedup(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	journal := NewJournal(storage, "journal.jsonl")
+
+	e1 := &JournalEntry{ID: "app", Version: 1, CS: "a1", Time: time.Now()}
+	e2 := &JournalEntry{ID: "app", Version: 2, CS: "a2", PrevCS: "a1", Time: time.Now()}
+	e3 := &JournalEntry{ID: "app", Version: 3, CS: "a3", PrevCS: "a2", Time: time.Now()}
+	fork := &JournalEntry{ID: "app", Version: 2, CS: "bogus", PrevCS: "elsewhere", Time: time.Now()}
+	other1 := &JournalEntry{ID: "other", Version: 1, CS: "o1", Time: time.Now()}
+	// Two equally plausible heads for "tied": neither can be preferred
+	tiedA := &JournalEntry{ID: "tied", Version: 1, CS: "t1"}
+	tiedB := &JournalEntry{ID: "tied", Version: 1, CS: "t2"}
+
+	journal.AppendBatch(ctx, []*JournalEntry{e1, e2, other1, e2, fork, e3, e1, tiedA, tiedB})
+	raw := mustRead(t, storage, "journal.jsonl")
+	storage.Write(ctx, "journal.jsonl", append(raw, "{not json\n"...))
+
+	result, err := journal.Dedup(ctx)
+	if err != nil {
+		t.Fatalf("Dedup failed: %v", err)
+	}
+	if result.Removed != 2 || result.Quarantined != 1 || result.Unresolved != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	data := string(mustRead(t, storage, "journal.jsonl"))
+	if !strings.HasSuffix(data, "{not json\n") {
+		t.Error("unparseable line was not preserved")
+	}
+	lines := strings.Split(strings.TrimSuffix(data, "{not json\n"), "\n")
+	var got []string
+	var appEntries []*JournalEntry
+	for _, line := range lines {
+		var entry JournalEntry
+		if json.Unmarshal([]byte(line), &entry) == nil {
+			got = append(got, entry.ID+"/"+entry.CS)
+			if entry.ID == "app" {
+				appEntries = append(appEntries, &entry)
+			}
+		}
+	}
+	if want := []string{"app/a1", "app/a2", "other/o1", "app/a3", "tied/t1", "tied/t2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected surviving order %v, got %v", want, got)
+	}
+
+	if q := string(mustRead(t, storage, "journal.jsonl.quarantine")); !strings.Contains(q, `"bogus"`) {
+		t.Errorf("fork not quarantined: %s", q)
+	}
+
+	if _, err := journal.Resequence(appEntries); err != nil {
+		t.Errorf("Resequence still fails after Dedup: %v", err)
+	}
+
+	again, _ := journal.Dedup(ctx)
+	if again.Removed != 0 || again.Quarantined != 0 {
+		t.Errorf("second Dedup changed the journal: %+v", again)
+	}
+}
+
+func TestNewJournalEntry(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	cfg, _ := manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	entry := NewJournalEntry("app", "recovered", cfg)
+	hash, _ := ContentHash(cfg.Content)
+	if entry.ID != "app" || entry.Version != 2 || entry.CS != cfg.Meta.CS || entry.PrevCS != cfg.Meta.PrevCS ||
+		!entry.Time.Equal(cfg.Meta.Time) || entry.Operation != "recovered" || entry.Config != cfg || entry.ContentHash != hash {
+		t.Errorf("entry does not mirror the config: %+v", entry)
+	}
+
+	// Entries built by the helper match the ones the manager writes
+	written, _ := manager.journal.FindByID(ctx, "app")
+	last := written[len(written)-1]
+	last.Operation = "recovered"
+	got, _ := json.Marshal(entry)
+	want, _ := json.Marshal(last)
+	if string(got) != string(want) {
+		t.Errorf("helper entry differs from the journaled one:\n%s\n%s", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrJournalFull is returned by writes under WithMaxJournalEntries and
+// JournalCapReject when compaction cannot bring the journal back under its
+// cap
+var ErrJournalFull = errors.New("journal entry cap reached")
+
+// JournalCapPolicy decides what a write does when the journal stays over
+// the cap of WithMaxJournalEntries after compaction
+type JournalCapPolicy int
+
+const (
+	// JournalCapWarn lets the write through and logs a warning (the default)
+	JournalCapWarn JournalCapPolicy = iota
+	// JournalCapReject fails the write with ErrJournalFull
+	JournalCapReject
+)
+
+// WithMaxJournalEntries caps the journal at max entries, as a ceiling that
+// complements WithAutoCompact. A write that would take the journal past
+// max first compacts it, synchronously and under the write lock. When
+// compaction cannot get under the cap, since it keeps the recent entries of
+// every id, policy decides: JournalCapWarn writes anyway with a warning, and
+// JournalCapReject fails with ErrJournalFull before anything is touched.
+// After one such compaction the next runs no sooner than another tenth of
+// max entries later, so a journal that stays over the cap does not cost
+// every write a compaction.
+//
+// The manager counts the entries when it first needs to and then adds its
+// own writes, recounting after every compaction; entries appended by other
+// processes are seen at the next recount. Status reports the count.
+func WithMaxJournalEntries(max int, policy JournalCapPolicy) ManagerOption {
+	return func(m *Manager) error {
+		if max <= 0 {
+			return errors.New("max journal entries must be positive")
+		}
+		switch policy {
+		case JournalCapWarn, JournalCapReject:
+		default:
+			return fmt.Errorf("unknown journal cap policy %d", policy)
+		}
+		m.maxJournalEntries = max
+		m.journalCapPolicy = policy
+		return nil
+	}
+}
+
+// ManagerStatus is a point-in-time view of a manager, returned by Status
+type ManagerStatus struct {
+	JournalPath string `json:"journal_path"`
+	// JournalEntries is the number of entries in the journal, unreadable
+	// lines included
+	JournalEntries int `json:"journal_entries"`
+	// MaxJournalEntries is the cap set by WithMaxJournalEntries, 0 if none
+	MaxJournalEntries int `json:"max_journal_entries,omitempty"`
+	// WritesSinceCompact counts the writes toward WithAutoCompact
+	WritesSinceCompact int `json:"writes_since_compact,omitempty"`
+}
+
+// Status counts the journal's entries and reports them with the manager's
+// limits
+func (m *Manager) Status(ctx context.Context) (*ManagerStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	n, err := m.countJournal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ManagerStatus{
+		JournalPath:        m.journal.path,
+		JournalEntries:     n,
+		MaxJournalEntries:  m.maxJournalEntries,
+		WritesSinceCompact: m.writesSinceCompact,
+	}, nil
+}
+
+// countJournal counts the journal's entries and keeps the count for
+// checkJournalCap. Callers hold mu.
+func (m *Manager) countJournal(ctx context.Context) (int, error) {
+	data, err := m.journal.storage.Read(ctx, m.journal.path)
+	if err != nil && !isMissingJournalError(err) {
+		return 0, err
+	}
+
+	data = trimTornTail(data)
+	n := journalLines(data)
+	m.journal.noteSize(n, len(data))
+	m.journalCount, m.journalCounted = n, true
+	return n, nil
+}
+
+// checkJournalCap makes room for n more entries under WithMaxJournalEntries,
+// compacting the journal when they would take it past the cap. Callers hold
+// mu.
+func (m *Manager) checkJournalCap(ctx context.Context, n int) error {
+	if m.maxJournalEntries == 0 {
+		return nil
+	}
+	if !m.journalCounted {
+		if _, err := m.countJournal(ctx); err != nil {
+			return err
+		}
+	}
+	if m.journalCount+n <= m.maxJournalEntries {
+		return nil
+	}
+
+	if m.journalCount >= m.capRetryAt {
+		result, err := m.journal.Compact(ctx)
+		if err != nil {
+			return fmt.Errorf("compaction for the journal entry cap: %w", err)
+		}
+		m.lastCompaction.Store(time.Now().UnixNano())
+		m.logger.Info("journal cap compaction", "entries_before", result.EntriesBefore, "entries_after", result.EntriesAfter, "max", m.maxJournalEntries)
+		if _, err := m.countJournal(ctx); err != nil {
+			return err
+		}
+		m.capRetryAt = m.journalCount + max(1, m.maxJournalEntries/10)
+		if m.journalCount+n <= m.maxJournalEntries {
+			return nil
+		}
+	}
+
+	if m.journalCapPolicy == JournalCapReject {
+		return fmt.Errorf("%w: %d entries after compaction, cap %d", ErrJournalFull, m.journalCount, m.maxJournalEntries)
+	}
+	m.logger.Warn("journal over its entry cap after compaction", "entries", m.journalCount, "max", m.maxJournalEntries)
+	return nil
+}
@@ -0,0 +1,91 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMaxJournalEntries(t *testing.T) {
+	ctx := context.Background()
+	logger := &recordingLogger{}
+	manager, err := NewManager(NewMemoryStorage(), WithMaxJournalEntries(30, JournalCapWarn), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	// One id: compaction keeps its last 10 entries, well under the cap
+	manager.Create(ctx, "app", map[string]interface{}{"n": 0})
+	for i := 1; i < 50; i++ {
+		if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("Update %d failed: %v", i, err)
+		}
+		status, err := manager.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if status.JournalEntries > 30 {
+			t.Fatalf("journal grew to %d entries past its cap", status.JournalEntries)
+		}
+	}
+	if err := manager.ValidateChainFrom(ctx, "app", 41); err != nil {
+		t.Errorf("compacted chain invalid: %v", err)
+	}
+
+	// Four ids keep 40 entries after compaction: the writes go on, warned
+	for _, id := range []string{"a", "b", "c"} {
+		manager.Create(ctx, id, map[string]interface{}{"n": 0})
+		for i := 1; i < 10; i++ {
+			if _, err := manager.Update(ctx, id, map[string]interface{}{"n": i}); err != nil {
+				t.Fatalf("Update of %s failed: %v", id, err)
+			}
+		}
+	}
+	status, _ := manager.Status(ctx)
+	if status.JournalEntries != 40 || status.MaxJournalEntries != 30 {
+		t.Errorf("status = %+v, want 40 entries under a cap of 30", status)
+	}
+	warned := false
+	for _, w := range logger.warns {
+		warned = warned || strings.HasPrefix(w, "journal over its entry cap")
+	}
+	if !warned {
+		t.Errorf("no cap warning logged: %v", logger.warns)
+	}
+}
+
+func TestMaxJournalEntriesReject(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage(), WithMaxJournalEntries(25, JournalCapReject))
+
+	for _, id := range []string{"a", "b"} {
+		manager.Create(ctx, id, map[string]interface{}{"n": 0})
+		for i := 1; i < 12; i++ {
+			manager.Update(ctx, id, map[string]interface{}{"n": i})
+		}
+	}
+	manager.Create(ctx, "c", map[string]interface{}{"n": 0})
+	var err error
+	for i := 1; i < 10 && err == nil; i++ {
+		_, err = manager.Update(ctx, "c", map[string]interface{}{"n": i})
+	}
+	if !errors.Is(err, ErrJournalFull) {
+		t.Fatalf("expected ErrJournalFull once compaction cannot help, got %v", err)
+	}
+	latest, _ := manager.GetLatest(ctx, "c")
+	history, _ := manager.configStore.ListVersions(ctx, "c")
+	if uint64(len(history)) != latest.Meta.Version {
+		t.Errorf("rejected write left a version file: %d files, latest v%d", len(history), latest.Meta.Version)
+	}
+	if status, _ := manager.Status(ctx); status.JournalEntries > 25 {
+		t.Errorf("journal at %d entries over a rejecting cap of 25", status.JournalEntries)
+	}
+
+	if _, err := NewManager(NewMemoryStorage(), WithMaxJournalEntries(0, JournalCapWarn)); err == nil {
+		t.Error("expected a zero cap to be rejected")
+	}
+	if _, err := NewManager(NewMemoryStorage(), WithMaxJournalEntries(10, JournalCapPolicy(9))); err == nil {
+		t.Error("expected an unknown policy to be rejected")
+	}
+}
@@ -0,0 +1,152 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ExportID returns the journal lines of id, in journal order and byte for
+// byte as stored, each ending in a newline: the change log of one config,
+// for ImportID on another journal. Only lines that can belong to id are
+// decoded. An id without entries exports as empty.
+func (j *Journal) ExportID(ctx context.Context, id string) ([]byte, error) {
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	data, err := j.storage.Read(ctx, j.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	quotedID, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	needle := append([]byte(`"id":`), quotedID...)
+
+	var out []byte
+	for _, line := range bytes.Split(trimTornTail(data), []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || !bytes.Contains(line, needle) {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("invalid journal entry: %w", err)
+		}
+		if entry.ID == id {
+			out = append(out, line...)
+			out = append(out, '\n')
+		}
+	}
+	return out, nil
+}
+
+// ImportID appends the entries of one id exported by ExportID and returns
+// how many it added. Entries the journal already holds are skipped, so
+// importing a longer export of the same history adds only its new tail and
+// importing one twice adds nothing. The rest must form a valid chain (see
+// ValidateChain) continuing the id's existing entries from their head, or
+// starting at v1 when there are none; otherwise nothing is written and the
+// error wraps ErrInvalidChain. All entries land in a single write, under the
+// journal lock.
+//
+// Only the journal is written: entries exported without embedded configs
+// need the config files copied as well before Get can load them.
+func (j *Journal) ImportID(ctx context.Context, data []byte) (int, error) {
+	incoming, err := parseJournalEntries(data)
+	if err != nil {
+		return 0, err
+	}
+	if len(incoming) == 0 {
+		return 0, nil
+	}
+	id := incoming[0].ID
+	for _, entry := range incoming {
+		if entry.ID != id {
+			return 0, fmt.Errorf("import holds entries of both %q and %q", id, entry.ID)
+		}
+		if entry.CS == "" {
+			return 0, fmt.Errorf("%w: %q v%d entry has no checksum", ErrInvalidChain, id, entry.Version)
+		}
+	}
+
+	l := j.lock()
+	l.Lock()
+	defer l.Unlock()
+
+	existing, err := j.storage.Read(ctx, j.path)
+	if err != nil && !isMissingJournalError(err) {
+		return 0, err
+	}
+	all, err := parseJournalEntries(existing)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool)
+	var current []*JournalEntry
+	for _, entry := range all {
+		if entry.ID == id {
+			current = append(current, entry)
+			known[entry.CS] = true
+		}
+	}
+
+	var fresh []*JournalEntry
+	for _, entry := range incoming {
+		if !known[entry.CS] {
+			fresh = append(fresh, entry)
+			known[entry.CS] = true
+		}
+	}
+	if len(fresh) == 0 {
+		return 0, nil
+	}
+
+	ordered, err := j.Resequence(fresh)
+	if err != nil {
+		return 0, fmt.Errorf("%w: import of %q: %v", ErrInvalidChain, id, err)
+	}
+	chain := ordered
+	if len(current) > 0 {
+		existingChain, err := j.Resequence(current)
+		if err != nil {
+			return 0, fmt.Errorf("existing entries of %q: %w", id, err)
+		}
+		head := existingChain[len(existingChain)-1]
+		if ordered[0].PrevCS != head.CS {
+			return 0, fmt.Errorf("%w: import of %q from v%d does not continue its head v%d", ErrInvalidChain, id, ordered[0].Version, head.Version)
+		}
+		chain = append([]*JournalEntry{head}, ordered...)
+	} else if first := ordered[0]; first.Version != 1 || first.PrevCS != "" {
+		return 0, fmt.Errorf("%w: import of %q starts at v%d but the journal has no entries for it", ErrInvalidChain, id, first.Version)
+	}
+	if err := j.ValidateChain(chain); err != nil {
+		return 0, fmt.Errorf("%w: import of %q: %v", ErrInvalidChain, id, err)
+	}
+
+	var lines strings.Builder
+	lines.Write(existing)
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		lines.WriteByte('\n')
+	}
+	for _, entry := range ordered {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return 0, err
+		}
+		lines.Write(line)
+		lines.WriteByte('\n')
+	}
+	if err := j.storage.Write(ctx, j.path, []byte(lines.String())); err != nil {
+		return 0, err
+	}
+	return len(ordered), nil
+}
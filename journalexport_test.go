@@ -0,0 +1,112 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJournalExportImportID(t *testing.T) {
+	ctx := context.Background()
+	source, _ := NewManager(NewMemoryStorage())
+	source.Create(ctx, "app", map[string]interface{}{"n": 1})
+	source.Create(ctx, "db", map[string]interface{}{"host": "a"})
+	source.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	fragment, err := source.journal.ExportID(ctx, "app")
+	if err != nil {
+		t.Fatalf("ExportID failed: %v", err)
+	}
+	if n := bytes.Count(fragment, []byte("\n")); n != 2 || bytes.Contains(fragment, []byte(`"id":"db"`)) {
+		t.Fatalf("expected the two app lines only, got %s", fragment)
+	}
+	if empty, err := source.journal.ExportID(ctx, "missing"); err != nil || len(empty) != 0 {
+		t.Errorf("expected an empty export, got %q, %v", empty, err)
+	}
+
+	destStorage := NewMemoryStorage()
+	dest := NewJournal(destStorage, "journal.jsonl")
+	if n, err := dest.ImportID(ctx, fragment); err != nil || n != 2 {
+		t.Fatalf("ImportID returned %d, %v", n, err)
+	}
+	if n, err := dest.ImportID(ctx, fragment); err != nil || n != 0 {
+		t.Errorf("re-import returned %d, %v; expected nothing added", n, err)
+	}
+
+	// A longer export adds only its tail, and the copy reconstructs
+	source.Update(ctx, "app", map[string]interface{}{"n": 3})
+	fragment, _ = source.journal.ExportID(ctx, "app")
+	if n, err := dest.ImportID(ctx, fragment); err != nil || n != 1 {
+		t.Fatalf("ImportID of the tail returned %d, %v", n, err)
+	}
+	cfg, err := dest.Reconstruct(ctx, "app", destStorage)
+	if err != nil || cfg.Meta.Version != 3 {
+		t.Fatalf("Reconstruct returned %v, %v", cfg, err)
+	}
+	dbFragment, _ := source.journal.ExportID(ctx, "db")
+	if n, err := dest.ImportID(ctx, dbFragment); err != nil || n != 1 {
+		t.Errorf("ImportID of db returned %d, %v", n, err)
+	}
+}
+
+func TestJournalImportIDRejects(t *testing.T) {
+	ctx := context.Background()
+	source, _ := NewManager(NewMemoryStorage())
+	source.Create(ctx, "app", map[string]interface{}{"n": 1})
+	source.Update(ctx, "app", map[string]interface{}{"n": 2})
+	source.Update(ctx, "app", map[string]interface{}{"n": 3})
+	source.Create(ctx, "db", map[string]interface{}{"host": "a"})
+	entries, _ := source.journal.FindByID(ctx, "app")
+	dbFragment, _ := source.journal.ExportID(ctx, "db")
+
+	// Another history of app on the destination
+	destStorage := NewMemoryStorage()
+	other, _ := NewManager(destStorage)
+	other.Create(ctx, "app", map[string]interface{}{"n": 10})
+
+	lines := func(entries ...*JournalEntry) []byte {
+		var buf bytes.Buffer
+		for _, e := range entries {
+			data, _ := json.Marshal(e)
+			buf.Write(append(data, '\n'))
+		}
+		return buf.Bytes()
+	}
+	mixed := append(lines(entries[0]), dbFragment...)
+
+	for name, tc := range map[string]struct {
+		journal *Journal
+		data    []byte
+	}{
+		"fork":    {other.journal, lines(entries...)},
+		"no root": {NewJournal(NewMemoryStorage(), "journal.jsonl"), lines(entries[1:]...)},
+		"gap":     {NewJournal(NewMemoryStorage(), "journal.jsonl"), lines(entries[0], entries[2])},
+		"two ids": {NewJournal(NewMemoryStorage(), "journal.jsonl"), mixed},
+	} {
+		before, _ := tc.journal.storage.Read(ctx, tc.journal.path)
+		n, err := tc.journal.ImportID(ctx, tc.data)
+		if err == nil || n != 0 {
+			t.Errorf("%s: expected a refused import, got %d, %v", name, n, err)
+		}
+		if name != "two ids" && !errors.Is(err, ErrInvalidChain) {
+			t.Errorf("%s: expected ErrInvalidChain, got %v", name, err)
+		}
+		if after, _ := tc.journal.storage.Read(ctx, tc.journal.path); !bytes.Equal(before, after) {
+			t.Errorf("%s: refused import changed the journal", name)
+		}
+	}
+
+	if n, err := other.journal.ImportID(ctx, nil); err != nil || n != 0 {
+		t.Errorf("empty import returned %d, %v", n, err)
+	}
+
+	// A time regression within the fragment is caught by ValidateChain
+	regressed := *entries[1]
+	regressed.Time = entries[0].Time.Add(-time.Hour)
+	if _, err := NewJournal(NewMemoryStorage(), "journal.jsonl").ImportID(ctx, lines(entries[0], &regressed)); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain for a timestamp regression, got %v", err)
+	}
+}
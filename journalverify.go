@@ -0,0 +1,144 @@
+package viracochan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// JournalVerifyReport is the result of VerifyJournalFile. Issues use the
+// AuditIssue kinds and are listed in the order they were found: unreadable
+// lines first, then per-entry problems in journal order, then broken chains
+// by id.
+type JournalVerifyReport struct {
+	Entries    int      // readable entries
+	IDs        []string // ids covered, sorted
+	Signed     int      // embedded configs whose signature verified
+	Unsigned   int      // embedded configs without a signature
+	Unverified int      // signatures not checked: no key given, or no embedded config
+	Issues     []AuditIssue
+}
+
+// OK reports whether the journal verified without issues
+func (r *JournalVerifyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// First returns the first anomaly found, or nil
+func (r *JournalVerifyReport) First() *AuditIssue {
+	if len(r.Issues) == 0 {
+		return nil
+	}
+	return &r.Issues[0]
+}
+
+// VerifyJournalFile verifies a raw journal file offline, without a Manager or
+// storage: every line must parse, every embedded config must match its
+// checksum and its entry, every embedded signature must verify under
+// publicKey, and the entries of each id must form one hash chain (prev_cs
+// linkage, consecutive versions, non-decreasing timestamps). Missing or forked
+// entries show up as broken chains. A compacted journal starts each chain at
+// its pruned boundary; without the roots sidecar that boundary is taken on
+// trust. An empty publicKey skips signature checks; an invalid one is an
+// error. Anomalies are reported, not returned as errors.
+func VerifyJournalFile(data []byte, publicKey string) (*JournalVerifyReport, error) {
+	if publicKey != "" {
+		key, err := hex.DecodeString(publicKey)
+		if err == nil {
+			_, err = schnorr.ParsePubKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+	}
+
+	report := &JournalVerifyReport{}
+	byID := make(map[string][]*JournalEntry)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			report.Issues = append(report.Issues, AuditIssue{
+				Kind:   AuditJournalUnreadable,
+				Detail: fmt.Sprintf("line %d: %v", line, err),
+			})
+			continue
+		}
+		report.Entries++
+		verifyJournalEntry(report, &entry, line, publicKey)
+
+		// Chain checks below only look at linkage; embedded configs were
+		// checked above
+		link := entry
+		link.Config = nil
+		byID[entry.ID] = append(byID[entry.ID], &link)
+	}
+	if err := scanner.Err(); err != nil {
+		report.Issues = append(report.Issues, AuditIssue{Kind: AuditJournalUnreadable, Detail: err.Error()})
+	}
+
+	for id := range byID {
+		report.IDs = append(report.IDs, id)
+	}
+	sort.Strings(report.IDs)
+
+	var j Journal
+	for _, id := range report.IDs {
+		ordered, err := j.Resequence(byID[id])
+		if err == nil {
+			err = j.ValidateChain(ordered)
+		}
+		if err != nil {
+			report.Issues = append(report.Issues, AuditIssue{ID: id, Kind: AuditBrokenChain, Detail: err.Error()})
+		}
+	}
+	return report, nil
+}
+
+func verifyJournalEntry(report *JournalVerifyReport, entry *JournalEntry, line int, publicKey string) {
+	issue := func(kind AuditIssueKind, format string, args ...any) {
+		report.Issues = append(report.Issues, AuditIssue{
+			ID:      entry.ID,
+			Version: entry.Version,
+			Kind:    kind,
+			Detail:  fmt.Sprintf("line %d: ", line) + fmt.Sprintf(format, args...),
+		})
+	}
+
+	cfg := entry.Config
+	if cfg == nil {
+		report.Unverified++
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		issue(AuditInvalidConfig, "%v", err)
+		return
+	}
+	if cfg.Meta.CS != entry.CS || cfg.Meta.Version != entry.Version {
+		issue(AuditChecksumMismatch, "embedded config v%d cs=%s, entry cs=%s", cfg.Meta.Version, cfg.Meta.CS, entry.CS)
+		return
+	}
+
+	switch {
+	case cfg.Meta.Signature == "":
+		report.Unsigned++
+	case publicKey == "":
+		report.Unverified++
+	default:
+		if err := VerifyConfigSignature(cfg, publicKey); err != nil {
+			issue(AuditBadSignature, "%v", err)
+			return
+		}
+		report.Signed++
+	}
+}
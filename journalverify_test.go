@@ -0,0 +1,57 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestVerifyJournalFile(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+	manager.Create(ctx, "db", map[string]interface{}{"host": "a"})
+
+	data := mustRead(t, storage, "journal.jsonl")
+	report, err := VerifyJournalFile(data, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("VerifyJournalFile failed: %v", err)
+	}
+	if !report.OK() || report.Entries != 4 || report.Signed != 4 || len(report.IDs) != 2 {
+		t.Fatalf("unexpected report for a clean journal: %+v", report)
+	}
+
+	other, _ := NewSigner()
+	if report, _ := VerifyJournalFile(data, other.PublicKey()); report.OK() || report.First().Kind != AuditBadSignature {
+		t.Errorf("expected bad signatures under another key, got %+v", report)
+	}
+	if report, _ := VerifyJournalFile(data, ""); !report.OK() || report.Unverified != 4 {
+		t.Errorf("expected unverified signatures without a key, got %+v", report)
+	}
+	if _, err := VerifyJournalFile(data, "not-a-key"); err == nil {
+		t.Error("expected error for an invalid key")
+	}
+
+	// Drop app v2: the chain has a gap
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	gapped := bytes.Join([][]byte{lines[0], lines[2], lines[3], []byte("{torn")}, []byte("\n"))
+	report, _ = VerifyJournalFile(gapped, signer.PublicKey())
+	if len(report.Issues) != 2 || report.First().Kind != AuditJournalUnreadable {
+		t.Fatalf("expected an unreadable line and a broken chain, got %+v", report.Issues)
+	}
+	if issue := report.Issues[1]; issue.Kind != AuditBrokenChain || issue.ID != "app" {
+		t.Errorf("expected app's chain broken, got %+v", issue)
+	}
+
+	// Tampered content no longer matches its checksum
+	tampered := bytes.Replace(data, []byte(`"n":2`), []byte(`"n":9`), 1)
+	report, _ = VerifyJournalFile(tampered, signer.PublicKey())
+	if first := report.First(); first == nil || first.Kind != AuditInvalidConfig || first.Version != 2 {
+		t.Errorf("expected tampered v2 reported, got %+v", report.Issues)
+	}
+}
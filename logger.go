@@ -0,0 +1,19 @@
+package viracochan
+
+// Logger receives diagnostic messages from the library, which never writes
+// to stdout or stderr itself. kv holds alternating key/value pairs in the
+// style of log/slog. Implementations must be safe for concurrent use.
+type Logger interface {
+	Debug(msg string, kv ...any)
+	Info(msg string, kv ...any)
+	Warn(msg string, kv ...any)
+	Error(msg string, kv ...any)
+}
+
+// NopLogger discards all messages
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...any) {}
+func (NopLogger) Info(string, ...any)  {}
+func (NopLogger) Warn(string, ...any)  {}
+func (NopLogger) Error(string, ...any) {}
@@ -1,33 +1,144 @@
 package viracochan
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+)
+
+var (
+	// ErrClosed is returned by every Manager method called after Close
+	ErrClosed = errors.New("manager closed")
+	// ErrInvalidID is returned, before any storage access, for config ids
+	// rejected by the manager's id validator
+	ErrInvalidID = errors.New("invalid config id")
+
+	// ErrCrossReference is returned when a write is rejected by the
+	// validator installed with WithCrossRefValidator
+	ErrCrossReference = errors.New("cross-reference validation failed")
+
+	// ErrAlreadyExists is returned by Create for an id that already has
+	// versions. It wraps ErrVersionConflict.
+	ErrAlreadyExists = fmt.Errorf("%w: config already exists", ErrVersionConflict)
 )
 
 // Manager provides high-level configuration management
 type Manager struct {
-	storage     Storage
+	storage     Storage // holds config files; the journal has its own
 	journal     *Journal
 	configStore *ConfigStorage
 	signer      *Signer
+	validateID  func(id string) error
+	logger      Logger
+	metrics     Metrics
+	tracer      Tracer
 	mu          sync.RWMutex
-	cache       map[string]*Config
+	cache       map[string]cachedConfig
+	cacheMu     sync.Mutex
+	index       map[string][]ConfigRef
+	indexMu     sync.Mutex
+	cacheTTL    time.Duration
+	hashAlg     HashAlgorithm
+	mergeFields map[string]MergeType
+	crossRef    CrossRefValidator
+	quota       Quota
+	clockSkew   time.Duration
+	now         func() time.Time // clock expiry is checked against
+	pretty      bool
+	policies    []Policy
+
+	deltaKeyframes int
+	compression    string
+	schemaLock     driftMode // see WithAutoSchemaLock
+
+	registry        SchemaRegistry // see WithSchemaRegistry
+	registrySubject func(id string) string
+	registryTTL     time.Duration
+	registryCache   map[string]cachedSchema // by subject; guarded by registryMu
+	registryMu      sync.Mutex
+
+	replicaStorages []Storage // see WithReadReplicas
+	replicas        []*readSource
+	replicaPolicy   ReplicaPolicy
+	replicaNext     atomic.Uint64
+	highWater       map[string]uint64 // newest version seen per id; guarded by cacheMu
+
+	closed  bool
+	done    chan struct{}
+	workers sync.WaitGroup
+
+	strictHistory       bool
+	embedContent        bool
+	journalSyncInterval time.Duration
+	intentLog           bool
+	requireSignature    bool     // see WithRequireSignature
+	fenceToken          uint64   // see WithFenceToken
+	redactor            Redactor // see WithRedactor
+
+	autoCompactEvery   int
+	writesSinceCompact int // guarded by mu
+	compacting         atomic.Bool
+
+	maxJournalEntries int              // see WithMaxJournalEntries
+	journalCapPolicy  JournalCapPolicy // see WithMaxJournalEntries
+	journalCount      int              // entries counted or written; guarded by mu
+	journalCounted    bool             // journalCount is known; guarded by mu
+	capRetryAt        int              // count at which checkJournalCap compacts again; guarded by mu
+
+	// Counters for Stats
+	cacheHits      atomic.Uint64
+	cacheMisses    atomic.Uint64
+	writes         atomic.Uint64
+	lastWrite      atomic.Int64 // Unix nanoseconds, 0 if none
+	lastCompaction atomic.Int64 // Unix nanoseconds, 0 if none
 }
 
 // NewManager creates new configuration manager
 func NewManager(storage Storage, opts ...ManagerOption) (*Manager, error) {
+	return NewManagerSplit(storage, storage, opts...)
+}
+
+// NewManagerSplit creates a manager whose journal (and intent log) lives in
+// journalStorage while config files live in configStorage, e.g. a hot local
+// journal with versioned configs in object storage. Reconstruction, recovery
+// and audits read each kind of data from its own storage.
+//
+// The two storages are written one after the other with no shared
+// transaction: a config file is always saved before its journal entry, so a
+// failure in between leaves an unjournaled file in configStorage (see
+// WithIntentLog and Recover). Back up and restore both together; a journal
+// restored without its configs relies on embedded content
+// (WithJournalEmbedContent, the default) and MaterializeConfigs.
+func NewManagerSplit(journalStorage, configStorage Storage, opts ...ManagerOption) (*Manager, error) {
 	m := &Manager{
-		storage:     storage,
-		journal:     NewJournal(storage, "journal.jsonl"),
-		configStore: NewConfigStorage(storage, "configs"),
-		cache:       make(map[string]*Config),
+		storage:     configStorage,
+		journal:     NewJournal(journalStorage, "journal.jsonl"),
+		configStore: NewConfigStorage(configStorage, DefaultConfigPrefix),
+		validateID:  DefaultIDValidator,
+		logger:      NopLogger{},
+		metrics:     NopMetrics{},
+		cache:       make(map[string]cachedConfig),
+		highWater:   make(map[string]uint64),
+		done:        make(chan struct{}),
+		now:         time.Now,
+
+		registrySubject: DefaultSchemaSubject,
+		registryTTL:     DefaultSchemaCacheTTL,
+		registryCache:   make(map[string]cachedSchema),
+
+		embedContent: true,
 	}
 
 	for _, opt := range opts {
@@ -35,10 +146,102 @@ func NewManager(storage Storage, opts ...ManagerOption) (*Manager, error) {
 			return nil, err
 		}
 	}
+	m.journal.SetLogger(m.logger)
+	m.journal.SetConfigPrefix(m.configStore.prefix)
+	m.journal.SetClockSkewTolerance(m.clockSkew)
+	m.journal.SetSyncInterval(m.journalSyncInterval)
+	m.configStore.pretty = m.pretty
+	m.configStore.keyframes = m.deltaKeyframes
+	m.configStore.compression = m.compression
+	m.setupTracing()
+	m.setupReplicas()
+	if m.requireSignature && m.signer == nil && len(m.configStore.verifyKeys) == 0 {
+		return nil, errors.New("signatures are required but neither a signer nor trusted keys are configured")
+	}
 
 	return m, nil
 }
 
+// Close stops the manager's background goroutines (watchers) and waits for
+// them to exit, then releases its in-memory state. Writes are synchronous;
+// only journal appends awaiting a group commit sync
+// (WithJournalSyncInterval) are flushed, and a failed sync is returned. Any
+// later call returns ErrClosed; calling Close again is a no-op.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.done)
+	m.cacheReset()
+	m.mu.Unlock()
+
+	m.workers.Wait()
+	return m.journal.Sync(context.Background())
+}
+
+func (m *Manager) isClosed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.closed
+}
+
+// cachedConfig is a cached latest version and when it was cached
+type cachedConfig struct {
+	cfg *Config
+	at  time.Time
+}
+
+// The cache has its own lock because reads holding only m.mu.RLock still
+// populate it.
+func (m *Manager) cacheGet(id string) (*Config, bool) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
+	entry, ok := m.cache[id]
+	if !ok {
+		m.cacheMisses.Add(1)
+		return nil, false
+	}
+	if m.cacheTTL > 0 && time.Since(entry.at) >= m.cacheTTL {
+		delete(m.cache, id)
+		m.cacheMisses.Add(1)
+		return nil, false
+	}
+	m.cacheHits.Add(1)
+	return entry.cfg, true
+}
+
+func (m *Manager) cachePut(id string, cfg *Config) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	m.cache[id] = cachedConfig{cfg: cfg, at: time.Now()}
+	m.highWater[id] = max(m.highWater[id], cfg.Meta.Version)
+}
+
+// seenVersion returns the newest version of id the cache has held
+func (m *Manager) seenVersion(id string) uint64 {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	return m.highWater[id]
+}
+
+func (m *Manager) cacheDelete(id string) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	delete(m.cache, id)
+	delete(m.highWater, id)
+}
+
+func (m *Manager) cacheReset() {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	m.cache = make(map[string]cachedConfig)
+	m.highWater = make(map[string]uint64)
+}
+
 // ManagerOption configures Manager
 type ManagerOption func(*Manager) error
 
@@ -50,19 +253,547 @@ func WithSigner(signer *Signer) ManagerOption {
 	}
 }
 
+// WithIDValidator replaces DefaultIDValidator. Errors returned by validate
+// are wrapped with ErrInvalidID; validate may wrap DefaultIDValidator to add
+// rules rather than relax them.
+func WithIDValidator(validate func(id string) error) ManagerOption {
+	return func(m *Manager) error {
+		if validate == nil {
+			return errors.New("id validator must not be nil")
+		}
+		m.validateID = func(id string) error {
+			if err := validate(id); err != nil {
+				if errors.Is(err, ErrInvalidID) {
+					return err
+				}
+				return fmt.Errorf("%w %q: %v", ErrInvalidID, id, err)
+			}
+			return nil
+		}
+		return nil
+	}
+}
+
+// DefaultIDValidator rejects ids that are empty, contain path separators or
+// non-printable characters, or are "." or "..", since ids become directory
+// names in the config store.
+func DefaultIDValidator(id string) error {
+	switch {
+	case id == "":
+		return fmt.Errorf("%w: empty", ErrInvalidID)
+	case id == "." || id == "..":
+		return fmt.Errorf("%w %q: reserved name", ErrInvalidID, id)
+	case strings.ContainsAny(id, `/\`):
+		return fmt.Errorf("%w %q: contains a path separator", ErrInvalidID, id)
+	}
+	for _, r := range id {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("%w %q: contains non-printable character %U", ErrInvalidID, id, r)
+		}
+	}
+	return nil
+}
+
+// WithLogger routes library warnings to logger instead of discarding them
+func WithLogger(logger Logger) ManagerOption {
+	return func(m *Manager) error {
+		if logger == nil {
+			return errors.New("logger must not be nil")
+		}
+		m.logger = logger
+		return nil
+	}
+}
+
+// WithHashAlgorithm selects the checksum algorithm for versions this manager
+// writes. Each version records its algorithm, so chains may mix algorithms
+// and existing SHA-256 versions stay valid.
+func WithHashAlgorithm(alg HashAlgorithm) ManagerOption {
+	return func(m *Manager) error {
+		if _, err := digestHex(alg, nil); err != nil {
+			return err
+		}
+		// SHA-256 is recorded as the empty default to keep checksums
+		// identical to configs written without the option
+		if alg == HashSHA256 {
+			alg = ""
+		}
+		m.hashAlg = alg
+		return nil
+	}
+}
+
+// WithMergeFields declares conflict-free merge semantics for content fields
+// used by MergeUpdate, e.g. {"counters.*": CounterSum}. See Merge3 for the
+// path syntax.
+func WithMergeFields(fields map[string]MergeType) ManagerOption {
+	return func(m *Manager) error {
+		m.mergeFields = make(map[string]MergeType, len(fields))
+		for path, typ := range fields {
+			if typ != CounterSum && typ != SetUnion {
+				return fmt.Errorf("unknown merge type %d for field %q", typ, path)
+			}
+			m.mergeFields[path] = typ
+		}
+		return nil
+	}
+}
+
+// WithCacheTTL makes cached latest versions expire after ttl, after which
+// the next read rebuilds them from the journal. Managers sharing storage then
+// observe each other's writes (including rollbacks) within ttl. Zero, the
+// default, caches until this manager writes the id again.
+func WithCacheTTL(ttl time.Duration) ManagerOption {
+	return func(m *Manager) error {
+		if ttl < 0 {
+			return errors.New("cache ttl must not be negative")
+		}
+		m.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithRequireSignature keeps unsigned configs out of the store. Every write
+// (Create, Update, Rollback, Reset, Submit, batch and snapshot writes) and
+// every Import must end up with a signature that verifies under a trusted
+// key: the config store's WithVerifyKeys, or the signer's own key. Writes
+// fail with ErrUnsignedConfig when no signer is configured, and imports of
+// unsigned or foreign-signed configs with ErrUnsignedConfig or
+// ErrUntrustedSignature. NewManager fails if there is neither a signer nor a
+// trusted key.
+func WithRequireSignature() ManagerOption {
+	return func(m *Manager) error {
+		m.requireSignature = true
+		return nil
+	}
+}
+
+// WithStrictHistory makes GetHistory fail with ErrHistoryGap instead of
+// skipping versions that are missing or fail to load
+func WithStrictHistory() ManagerOption {
+	return func(m *Manager) error {
+		m.strictHistory = true
+		return nil
+	}
+}
+
+// WithJournalEmbedContent controls whether journal entries embed the full
+// config (the default). With embedding disabled the journal is only an index
+// and config files are the durable source: reconstruction loads content from
+// the config store. Journals written with embedding remain readable.
+func WithJournalEmbedContent(embed bool) ManagerOption {
+	return func(m *Manager) error {
+		m.embedContent = embed
+		return nil
+	}
+}
+
 // WithJournalPath sets custom journal path
 func WithJournalPath(path string) ManagerOption {
 	return func(m *Manager) error {
-		m.journal = NewJournal(m.storage, path)
+		m.journal = NewJournal(m.journal.storage, path)
+		return nil
+	}
+}
+
+// WithMetrics reports manager measurements, such as MetricOperations and
+// MetricAutoCompactions, to metrics; see PrometheusMetrics for a ready-made
+// /metrics endpoint
+func WithMetrics(metrics Metrics) ManagerOption {
+	return func(m *Manager) error {
+		if metrics == nil {
+			return errors.New("metrics must not be nil")
+		}
+		m.metrics = metrics
+		return nil
+	}
+}
+
+// WithAutoCompact compacts the journal in the background after every
+// everyNWrites journaled writes (creates, updates, rollbacks, imports; a
+// batch counts once per config). The triggering write does not wait for it,
+// runs never overlap (a trigger while one is running is dropped) and Close
+// waits for a running one, skipping any not yet started. Each run is logged and counted as
+// MetricAutoCompactions with a "result" label of "ok" or "error".
+func WithAutoCompact(everyNWrites int) ManagerOption {
+	return func(m *Manager) error {
+		if everyNWrites <= 0 {
+			return errors.New("auto compact threshold must be positive")
+		}
+		m.autoCompactEvery = everyNWrites
+		return nil
+	}
+}
+
+// CrossRefValidator checks a new version of id against other configs before
+// it is written. view reads the store as it is at that moment; it must only
+// be used for reads, and only during the call.
+type CrossRefValidator func(ctx context.Context, view *Manager, id string, cfg *Config) error
+
+// WithCrossRefValidator runs validate before every create, update, merge and
+// rollback (and for each config of CreateBatch), after the new version is
+// built but before it is signed or stored. A non-nil error rejects the write,
+// wrapped in ErrCrossReference. Imports of existing versions are not
+// validated.
+func WithCrossRefValidator(validate CrossRefValidator) ManagerOption {
+	return func(m *Manager) error {
+		m.crossRef = validate
+		return nil
+	}
+}
+
+// readView returns a manager over the same storage and journal with its own
+// lock and cache, so a validator can read while m holds its write lock
+func (m *Manager) readView() *Manager {
+	return &Manager{
+		storage:       m.storage,
+		journal:       m.journal,
+		configStore:   m.configStore,
+		validateID:    m.validateID,
+		logger:        m.logger,
+		metrics:       m.metrics,
+		now:           m.now,
+		cache:         make(map[string]cachedConfig),
+		highWater:     make(map[string]uint64),
+		hashAlg:       m.hashAlg,
+		done:          m.done,
+		strictHistory: m.strictHistory,
+		embedContent:  m.embedContent,
+	}
+}
+
+// checkCrossRef runs the cross-reference validator, if any, on cfg
+func (m *Manager) checkCrossRef(ctx context.Context, id string, cfg *Config) error {
+	if m.crossRef == nil {
+		return nil
+	}
+	if err := m.crossRef(ctx, m.readView(), id, cfg); err != nil {
+		return fmt.Errorf("%w: config %q: %v", ErrCrossReference, id, err)
+	}
+	return nil
+}
+
+// WriteOption configures a single write operation
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	annotations map[string]string
+	ttl         time.Duration
+	message     string
+	contentType *string
+	drift       driftMode
+
+	schemaOverride bool // see WithSchemaOverride
+}
+
+// WithAnnotations attaches free-form annotations to the version being
+// written. See Meta.Annotations for integrity semantics.
+func WithAnnotations(annotations map[string]string) WriteOption {
+	return func(o *writeOptions) {
+		if len(annotations) == 0 {
+			return
+		}
+		o.annotations = make(map[string]string, len(annotations))
+		for k, v := range annotations {
+			o.annotations[k] = v
+		}
+	}
+}
+
+// WithTTL makes the version being written expire ttl after its timestamp.
+// Expiry is per version: a later Update without WithTTL does not expire.
+func WithTTL(ttl time.Duration) WriteOption {
+	return func(o *writeOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithContentType sets the content type of the version written, and of
+// later versions until changed again. For types other than JSON the content
+// passed to Create or Update is the document text as a string. The content is
+// checked by the validator registered for the type (RegisterContentValidator)
+// and nothing is stored if it fails. ContentTypeJSON is recorded as empty.
+func WithContentType(contentType string) WriteOption {
+	return func(o *writeOptions) {
+		if contentType == ContentTypeJSON {
+			contentType = ""
+		}
+		o.contentType = &contentType
+	}
+}
+
+// WithMessage records a short human-readable description of the change,
+// like a commit message, in the version's journal entry. The message is not
+// part of the config, its checksum or its signature, so it is advisory: it
+// can be rewritten by anyone with write access to the journal.
+func WithMessage(message string) WriteOption {
+	return func(o *writeOptions) {
+		o.message = message
+	}
+}
+
+func newWriteOptions(opts []WriteOption) *writeOptions {
+	wo := &writeOptions{}
+	for _, opt := range opts {
+		opt(wo)
+	}
+	return wo
+}
+
+// DefaultConfigPrefix is the directory version files are kept in, as
+// "<prefix>/<id>/v<N>.json", unless WithConfigPrefix names another
+const DefaultConfigPrefix = "configs"
+
+// WithConfigPrefix keeps version files under prefix instead of
+// DefaultConfigPrefix, for stores laid out by another tool or deployment
+// (see OpenExisting). The prefix is a directory inside the storage, such as
+// "data/versions"; it cannot be empty, since the manager keeps other files
+// at the top of the storage.
+func WithConfigPrefix(prefix string) ManagerOption {
+	return func(m *Manager) error {
+		clean := filepath.Clean(prefix)
+		if prefix == "" || clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("config prefix %q must be a directory inside the storage", prefix)
+		}
+		m.configStore.prefix = clean
+		return nil
+	}
+}
+
+// WithConfigStorageOptions configures the manager's config store, e.g. with
+// WithVerifyKeys for signature verification on every load
+func WithConfigStorageOptions(opts ...ConfigStorageOption) ManagerOption {
+	return func(m *Manager) error {
+		m.configStore = NewConfigStorage(m.storage, m.configStore.prefix, opts...)
+		return nil
+	}
+}
+
+// WithPrettyStorage writes version files indented, so stores kept in version
+// control diff cleanly. Checksums and signatures cover the content, not the
+// file layout, and Load reads compact and indented files alike, so a store
+// may mix both. The journal stays one compact entry per line.
+func WithPrettyStorage() ManagerOption {
+	return func(m *Manager) error {
+		m.pretty = true
 		return nil
 	}
 }
 
-// Create creates new configuration
-func (m *Manager) Create(ctx context.Context, id string, content interface{}) (*Config, error) {
+// Create creates new configuration. It returns ErrAlreadyExists if id
+// already has versions; use Update to change an existing config.
+func (m *Manager) Create(ctx context.Context, id string, content interface{}, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "create", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	if err := m.checkNew(ctx, id); err != nil {
+		return nil, err
+	}
+	return m.create(ctx, id, content, "create", newWriteOptions(opts))
+}
+
+// CreateOrGet returns the latest version of id, or creates id with content
+// if it has none. The check and the create happen under one lock, so when
+// several callers race to initialize the same id exactly one creates it and
+// the others get its result. Like GetLatest, it returns ErrExpired when the
+// existing latest version has expired.
+func (m *Manager) CreateOrGet(ctx context.Context, id string, content interface{}, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "create_or_get", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	cfg, err = m.getLatest(ctx, id)
+	switch {
+	case err == nil:
+		if cfg.Expired(m.now()) {
+			return nil, fmt.Errorf("%w: %q at %s", ErrExpired, id, cfg.Meta.ExpiresAt.Format(time.RFC3339))
+		}
+		return cfg, nil
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	if err := m.checkNew(ctx, id); err != nil {
+		return nil, err
+	}
+	return m.create(ctx, id, content, "create", newWriteOptions(opts))
+}
+
+// checkNew returns ErrAlreadyExists when id has a journaled chain or any
+// version file. Callers hold mu.
+func (m *Manager) checkNew(ctx context.Context, id string) error {
+	if _, err := m.getLatest(ctx, id); err == nil {
+		return fmt.Errorf("%w: %q", ErrAlreadyExists, id)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		return fmt.Errorf("%w: %q has version files but no journaled chain", ErrAlreadyExists, id)
+	}
+	return nil
+}
+
+// CreateWithTTL creates a configuration that GetLatest stops serving once
+// ttl has elapsed; ExpireSweep removes it afterwards
+func (m *Manager) CreateWithTTL(ctx context.Context, id string, content interface{}, ttl time.Duration, opts ...WriteOption) (*Config, error) {
+	return m.Create(ctx, id, content, append(opts, WithTTL(ttl))...)
+}
+
+// CreateBatch creates every item as a fresh configuration, signing each, and
+// journals them with a single append. It is all-or-nothing: when any id
+// already exists or cannot be encoded, nothing is written and the combined
+// per-id errors are returned; when a write fails, config files written so far
+// are removed and no entry reaches the journal.
+func (m *Manager) CreateBatch(ctx context.Context, items map[string]interface{}, opts ...WriteOption) (configs map[string]*Config, err error) {
+	ctx, call := m.begin(ctx, "create_batch", "")
+	defer func() { call.end(nil, err) }()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	wo := newWriteOptions(opts)
+	configs = make(map[string]*Config, len(items))
+	var errs []error
+	for _, id := range ids {
+		if err := m.validateID(id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := m.checkNew(ctx, id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		cfg, err := newConfig(items[id], m.hashAlg)
+		if err == nil {
+			if err := m.checkCrossRef(ctx, id, cfg); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			err = m.seal(cfg, wo)
+		}
+		if err == nil {
+			err = m.checkRegistry(ctx, id, cfg)
+		}
+		if err == nil {
+			err = m.checkPolicies(id, cfg)
+		}
+		if err == nil {
+			_, err = m.checkQuota(ctx, id, cfg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config %q: %w", id, err))
+			continue
+		}
+		configs[id] = cfg
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	writes := make([]IntentWrite, 0, len(ids))
+	for _, id := range ids {
+		writes = append(writes, intentWrite(id, configs[id], "create", wo.message))
+	}
+	if err := m.checkFence(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.checkJournalCap(ctx, len(writes)); err != nil {
+		return nil, err
+	}
+	tx, err := m.beginIntent(ctx, writes)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*JournalEntry, 0, len(ids))
+	for i, id := range ids {
+		if err := m.configStore.Save(ctx, id, configs[id]); err != nil {
+			m.removeConfigs(ctx, ids[:i])
+			return nil, fmt.Errorf("config %q: %w", id, err)
+		}
+		entry := m.journalEntry(id, configs[id], "create")
+		entry.Message = wo.message
+		entries = append(entries, entry)
+	}
+
+	if err := m.journal.AppendBatch(ctx, entries); err != nil {
+		m.removeConfigs(ctx, ids)
+		return nil, err
+	}
+	if err := m.commitIntent(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		m.cachePut(id, configs[id])
+		m.indexPut(entries[i])
+	}
+	m.noteWrites(len(entries))
+	return configs, nil
+}
+
+// removeConfigs deletes the first version of each id, undoing a partially
+// written batch
+func (m *Manager) removeConfigs(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		if err := m.configStore.Delete(ctx, id, 1); err != nil {
+			m.logger.Warn("create batch: failed to remove partially written config", "id", id, "error", err)
+		}
+	}
+}
+
+// create writes content as a fresh v1 of id journaled under op
+func (m *Manager) create(ctx context.Context, id string, content interface{}, op string, wo *writeOptions) (*Config, error) {
+	cfg, err := newConfig(content, m.hashAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.commit(ctx, id, cfg, op, wo); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newConfig encodes content as an unsealed v1 checksummed with alg
+func newConfig(content interface{}, alg HashAlgorithm) (*Config, error) {
 	data, err := json.Marshal(content)
 	if err != nil {
 		return nil, err
@@ -71,6 +802,7 @@ func (m *Manager) Create(ctx context.Context, id string, content interface{}) (*
 	cfg := &Config{
 		Meta: Meta{
 			Version: 0,
+			HashAlg: alg,
 		},
 		Content: json.RawMessage(data),
 	}
@@ -78,335 +810,1563 @@ func (m *Manager) Create(ctx context.Context, id string, content interface{}) (*
 	if err := cfg.UpdateMeta(); err != nil {
 		return nil, err
 	}
+	return cfg, nil
+}
 
-	if m.signer != nil {
-		if err := m.signer.Sign(cfg); err != nil {
-			return nil, err
+// Update updates existing configuration
+func (m *Manager) Update(ctx context.Context, id string, content interface{}, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "update", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	current, err := m.getLatest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.update(ctx, id, current, data, "update", newWriteOptions(opts))
+}
+
+// MergeUpdate updates id with content that was derived from version base.
+// When base is still the latest version this is a plain Update. Otherwise
+// content is three-way merged (see Merge3) with the changes written since
+// base, using the manager's merge fields, and the result is written as a new
+// version; ErrMergeConflict is returned if the edits collide.
+func (m *Manager) MergeUpdate(ctx context.Context, id string, base uint64, content interface{}, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "merge_update", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	current, err := m.getLatest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Meta.Version == base {
+		return m.update(ctx, id, current, data, "update", newWriteOptions(opts))
+	}
+	if base > current.Meta.Version {
+		return nil, fmt.Errorf("%w: base version %d is ahead of latest %d", ErrVersionConflict, base, current.Meta.Version)
+	}
+
+	baseCfg, err := m.configStore.Load(ctx, id, base)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := Merge3(baseCfg.Content, current.Content, data, m.mergeFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.update(ctx, id, current, merged, fmt.Sprintf("merge_from_v%d", base), newWriteOptions(opts))
+}
+
+// update writes data as the successor of current
+func (m *Manager) update(ctx context.Context, id string, current *Config, data json.RawMessage, op string, wo *writeOptions) (*Config, error) {
+	if err := m.checkDrift(id, current, data, wo); err != nil {
+		return nil, err
+	}
+	if err := m.checkSchemaLock(ctx, id, current, data, wo); err != nil {
+		return nil, err
+	}
+	if err := m.checkRegistryCompat(ctx, id, current, data, wo); err != nil {
+		return nil, err
+	}
+
+	newCfg := &Config{
+		Meta:    current.Meta,
+		Content: data,
+	}
+	newCfg.Meta.HashAlg = m.hashAlg
+
+	if err := newCfg.UpdateMeta(); err != nil {
+		return nil, err
+	}
+
+	if err := m.commit(ctx, id, newCfg, op, wo); err != nil {
+		return nil, err
+	}
+	m.relockSchema(ctx, id, newCfg, wo)
+	return newCfg, nil
+}
+
+// commit seals cfg and persists it as the new head of id
+func (m *Manager) commit(ctx context.Context, id string, cfg *Config, op string, wo *writeOptions) error {
+	if err := m.checkCrossRef(ctx, id, cfg); err != nil {
+		return err
+	}
+	if err := m.seal(cfg, wo); err != nil {
+		return err
+	}
+	if err := m.checkRegistry(ctx, id, cfg); err != nil {
+		return err
+	}
+	if err := m.checkPolicies(id, cfg); err != nil {
+		return err
+	}
+	prune, err := m.checkQuota(ctx, id, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := m.persist(ctx, id, cfg, op, wo.message); err != nil {
+		return err
+	}
+	m.pruneVersions(ctx, id, prune)
+	return nil
+}
+
+// seal applies write options and signs cfg when a signer is configured
+func (m *Manager) seal(cfg *Config, wo *writeOptions) error {
+	cfg.Meta.Annotations = wo.annotations
+
+	rehash := false
+	if wo.ttl > 0 {
+		expiresAt := cfg.Meta.Time.Add(wo.ttl)
+		cfg.Meta.ExpiresAt = &expiresAt
+		rehash = true
+	}
+	if wo.contentType != nil && *wo.contentType != cfg.Meta.ContentType {
+		cfg.Meta.ContentType = *wo.contentType
+		rehash = true
+	}
+	if rehash {
+		if err := cfg.rehash(); err != nil {
+			return err
+		}
+	}
+
+	if err := validateContent(cfg); err != nil {
+		return err
+	}
+
+	if m.signer != nil {
+		contentHash, err := digestHex(cfg.Meta.HashAlg, cfg.Content)
+		if err != nil {
+			return err
+		}
+		if err := m.signer.sign(cfg, contentHash); err != nil {
+			return err
+		}
+	} else if m.requireSignature {
+		return fmt.Errorf("%w: signatures are required and no signer is configured", ErrUnsignedConfig)
+	}
+	return m.checkSigned(cfg)
+}
+
+// persist saves cfg to the config store, journals it and caches it. With
+// the intent log enabled the two steps are bracketed by an intent and a
+// commit record, and a failure between them is left for Recover.
+//
+// The order is the publish contract every write path keeps: the config file
+// is written first and the journal append publishes the version, so a
+// reader that finds the entry also finds the file. Until then readers get
+// the previous head, the journal being what Reconstruct trusts.
+func (m *Manager) persist(ctx context.Context, id string, cfg *Config, op, message string) error {
+	if err := m.checkFence(ctx); err != nil {
+		return err
+	}
+	if err := m.checkJournalCap(ctx, 1); err != nil {
+		return err
+	}
+	tx, err := m.beginIntent(ctx, []IntentWrite{intentWrite(id, cfg, op, message)})
+	if err != nil {
+		return err
+	}
+
+	if err := m.configStore.Save(ctx, id, cfg); err != nil {
+		return err
+	}
+
+	entry := m.journalEntry(id, cfg, op)
+	entry.Message = message
+	if err := m.journal.Append(ctx, entry); err != nil {
+		return err
+	}
+
+	if err := m.commitIntent(ctx, tx); err != nil {
+		return err
+	}
+
+	m.cachePut(id, cfg)
+	m.indexPut(entry)
+	m.noteWrites(1)
+	return nil
+}
+
+// checkSigned enforces WithRequireSignature on a config about to be written
+func (m *Manager) checkSigned(cfg *Config) error {
+	if !m.requireSignature {
+		return nil
+	}
+	if cfg.Meta.Signature == "" {
+		return fmt.Errorf("%w: version %d", ErrUnsignedConfig, cfg.Meta.Version)
+	}
+
+	keys := slices.Clone(m.configStore.verifyKeys)
+	if m.signer != nil {
+		keys = append(keys, m.signer.PublicKey())
+	}
+	if !verifiesUnderAny(cfg, keys) {
+		return fmt.Errorf("%w: version %d", ErrUntrustedSignature, cfg.Meta.Version)
+	}
+	return nil
+}
+
+// journalEntry describes cfg as a journal entry, embedding it when enabled
+func (m *Manager) journalEntry(id string, cfg *Config, op string) *JournalEntry {
+	entry := NewJournalEntry(id, op, cfg)
+	if !m.embedContent {
+		entry.Config = nil
+	}
+	return entry
+}
+
+// Get retrieves specific version of configuration
+func (m *Manager) Get(ctx context.Context, id string, version uint64) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "get", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	err = m.readThrough(ctx, func(src *readSource) (err error) {
+		cfg, err = src.configStore.Load(ctx, id, version)
+		return err
+	})
+	return cfg, err
+}
+
+// GetLatest retrieves latest version of configuration. It returns
+// ErrExpired when the latest version carries an expiry that has passed.
+func (m *Manager) GetLatest(ctx context.Context, id string) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "get_latest", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	cfg, err = m.readLatest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Expired(m.now()) {
+		return nil, fmt.Errorf("%w: %q at %s", ErrExpired, id, cfg.Meta.ExpiresAt.Format(time.RFC3339))
+	}
+	return cfg, nil
+}
+
+// GetLatestFresh is GetLatest without the cache, for reads that must see
+// what storage holds now, such as writes by other managers sharing it within
+// WithCacheTTL. The latest version is reconstructed from the primary's
+// journal and config files, never from a read replica, which may lag, and
+// then cached, so later GetLatest calls are served from memory again.
+func (m *Manager) GetLatestFresh(ctx context.Context, id string) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "get_latest_fresh", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	cfg, err = m.loadLatest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if trace, _ := ctx.Value(readTraceKey{}).(*ReadTrace); trace != nil {
+		trace.Source = SourcePrimary
+	}
+	if cfg.Expired(m.now()) {
+		return nil, fmt.Errorf("%w: %q at %s", ErrExpired, id, cfg.Meta.ExpiresAt.Format(time.RFC3339))
+	}
+	return cfg, nil
+}
+
+// GetLatestOrDefault is GetLatest for configs that may not exist yet. When
+// id has no history at all it returns a v1 built from def, with exists
+// false; otherwise it returns what GetLatest does, with exists true. The
+// default is checksummed but not signed, and is neither written nor cached:
+// pass its content to Create to persist it. A config whose journal or
+// version files exist but cannot be read is an error, not a default.
+func (m *Manager) GetLatestOrDefault(ctx context.Context, id string, def interface{}) (cfg *Config, exists bool, err error) {
+	if err := m.validateID(id); err != nil {
+		return nil, false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, false, ErrClosed
+	}
+
+	cfg, err = m.readLatest(ctx, id)
+	if err == nil {
+		if cfg.Expired(m.now()) {
+			return nil, true, fmt.Errorf("%w: %q at %s", ErrExpired, id, cfg.Meta.ExpiresAt.Format(time.RFC3339))
+		}
+		return cfg, true, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+
+	// A missing file is only a missing config if nothing else records it
+	versions, listErr := m.configStore.ListVersions(ctx, id)
+	if listErr != nil {
+		return nil, false, listErr
+	}
+	entries, findErr := m.journal.FindByID(ctx, id)
+	if findErr != nil {
+		return nil, false, findErr
+	}
+	if len(versions) > 0 || len(entries) > 0 {
+		return nil, true, fmt.Errorf("config %q has history but its latest version cannot be read: %w", id, err)
+	}
+
+	data, err := json.Marshal(def)
+	if err != nil {
+		return nil, false, err
+	}
+	cfg = &Config{Content: data}
+	cfg.Meta.HashAlg = m.hashAlg
+	if err := cfg.UpdateMeta(); err != nil {
+		return nil, false, err
+	}
+	return cfg, false, nil
+}
+
+// HeadVersion returns the version and checksum of the latest journal entry
+// for id, without loading or validating any config. It reads the journal
+// rather than the cache, so it sees writes by other managers sharing the
+// storage, and is meant as a cheap probe for comparing heads across nodes:
+// a lower version is lag, a different checksum at the same version is a fork.
+// An id without entries yields ErrNoJournalEntries.
+func (m *Manager) HeadVersion(ctx context.Context, id string) (uint64, string, error) {
+	if err := m.validateID(id); err != nil {
+		return 0, "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return 0, "", ErrClosed
+	}
+
+	entry, err := m.journal.Last(ctx, id)
+	if err != nil {
+		return 0, "", err
+	}
+	return entry.Version, entry.CS, nil
+}
+
+// StoragePaths returns where id is stored: the keys of its version files in
+// version order, in the manager's storage, and the journal's path in the
+// journal's storage (the same storage unless created with NewManagerSplit).
+// Paths are relative to the storage root, as passed to Storage methods, and
+// follow WithJournalPath. The journal is shared by every id, and sidecars
+// such as content blobs are not listed.
+func (m *Manager) StoragePaths(ctx context.Context, id string) (configFiles []string, journalPath string, err error) {
+	if err := m.validateID(id); err != nil {
+		return nil, "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, "", ErrClosed
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, v := range versions {
+		configFiles = append(configFiles, m.configStore.makeKey(id, v))
+	}
+	return configFiles, m.journal.path, nil
+}
+
+func (m *Manager) getLatest(ctx context.Context, id string) (*Config, error) {
+	if cfg, ok := m.cacheGet(id); ok {
+		return cfg, nil
+	}
+	return m.loadLatest(ctx, id)
+}
+
+// loadLatest reconstructs the latest version of id from the primary,
+// bypassing the cache, and caches it
+func (m *Manager) loadLatest(ctx context.Context, id string) (*Config, error) {
+	cfg, err := m.journal.Reconstruct(ctx, id, m.storage)
+	if err != nil {
+		return nil, err
+	}
+	return m.cacheReconstructed(id, cfg)
+}
+
+// cacheReconstructed checks the signature of id's reconstructed head cfg
+// against the manager's trusted keys and caches it, so that nothing served
+// from the cache skipped the check a load makes
+func (m *Manager) cacheReconstructed(id string, cfg *Config) (*Config, error) {
+	if err := m.configStore.verifySignature(cfg); err != nil {
+		return nil, err
+	}
+
+	m.cachePut(id, cfg)
+	return cfg, nil
+}
+
+// GetHistory retrieves configuration history.
+//
+// The result is in strictly ascending version order on every backend.
+// Versions that fail to load are skipped, so the slice may contain holes;
+// with WithStrictHistory any hole between the first and last stored version
+// (or any load failure) makes GetHistory return ErrHistoryGap instead.
+func (m *Manager) GetHistory(ctx context.Context, id string) ([]*Config, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]*Config, 0, len(versions))
+	for i, v := range versions {
+		if m.strictHistory && i > 0 && v != versions[i-1]+1 {
+			return nil, fmt.Errorf("%w: config %q missing versions %d..%d", ErrHistoryGap, id, versions[i-1]+1, v-1)
+		}
+
+		cfg, err := m.configStore.Load(ctx, id, v)
+		if err != nil {
+			if m.strictHistory {
+				return nil, fmt.Errorf("%w: config %q version %d: %v", ErrHistoryGap, id, v, err)
+			}
+			continue
+		}
+		configs = append(configs, cfg)
+	}
+
+	return configs, nil
+}
+
+// GetLatestN returns the n most recent stored versions of id, newest first.
+// Only those n version files are read, after one listing of the id's
+// versions; fewer are returned when the history is shorter. Versions that
+// fail to load are skipped, or yield ErrHistoryGap with WithStrictHistory, as
+// in GetHistory.
+func (m *Manager) GetLatestN(ctx context.Context, id string, n int) ([]*Config, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, errors.New("n must be positive")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > n {
+		versions = versions[len(versions)-n:]
+	}
+
+	configs := make([]*Config, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if m.strictHistory && i > 0 && v != versions[i-1]+1 {
+			return nil, fmt.Errorf("%w: config %q missing versions %d..%d", ErrHistoryGap, id, versions[i-1]+1, v-1)
+		}
+
+		cfg, err := m.configStore.Load(ctx, id, v)
+		if err != nil {
+			if m.strictHistory {
+				return nil, fmt.Errorf("%w: config %q version %d: %v", ErrHistoryGap, id, v, err)
+			}
+			continue
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// ValidateChain validates configuration chain integrity
+func (m *Manager) ValidateChain(ctx context.Context, id string) error {
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ordered, err := m.journal.Resequence(entries)
+	if err != nil {
+		return m.chainFailure("validate_chain", err)
+	}
+
+	return m.chainFailure("validate_chain", m.validateOrdered(ctx, id, ordered))
+}
+
+// ValidateChainFrom validates chain continuity starting at startVersion,
+// which is treated as the chain root. Entries before it are ignored, so a
+// chain whose beginning was pruned can still be validated. The start version
+// must not precede the pruned boundary recorded by compaction.
+func (m *Manager) ValidateChainFrom(ctx context.Context, id string, startVersion uint64) error {
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	ordered, err := m.journal.ResequenceFrom(entries, startVersion)
+	if err != nil {
+		return m.chainFailure("validate_chain", err)
+	}
+
+	return m.chainFailure("validate_chain", m.validateOrdered(ctx, id, ordered))
+}
+
+// validateOrdered validates the resequenced entries of id from their root
+func (m *Manager) validateOrdered(ctx context.Context, id string, ordered []*JournalEntry) error {
+	if err := m.checkChainRoot(ctx, id, ordered[0]); err != nil {
+		return err
+	}
+	return m.journal.ValidateChain(ordered)
+}
+
+// chainFailure counts a non-nil err as MetricChainValidationFailures and
+// returns it
+func (m *Manager) chainFailure(op string, err error) error {
+	if err != nil {
+		m.metrics.IncCounter(MetricChainValidationFailures, 1, "op", op)
+	}
+	return err
+}
+
+// checkChainRoot verifies that a head with a dangling prev_cs sits at or
+// after the pruned boundary recorded for id.
+func (m *Manager) checkChainRoot(ctx context.Context, id string, head *JournalEntry) error {
+	if head.PrevCS == "" {
+		return nil
+	}
+
+	root, ok, err := m.journal.Root(ctx, id)
+	if err != nil || !ok {
+		return err
+	}
+
+	switch {
+	case head.Version < root.Version:
+		return fmt.Errorf("%w: start version %d precedes pruned boundary v%d", ErrInvalidChain, head.Version, root.Version)
+	case head.Version == root.Version && head.CS != root.CS:
+		return fmt.Errorf("%w: root v%d checksum %s does not match recorded boundary %s",
+			ErrInvalidChain, head.Version, head.CS, root.CS)
+	}
+	return nil
+}
+
+// FirstInvalid walks the stored versions of id in order and returns the
+// earliest one that fails checksum, linkage or (when a signer is configured)
+// signature verification, with a human-readable reason. It returns version 0
+// when the whole chain is valid; err is reserved for storage failures.
+func (m *Manager) FirstInvalid(ctx context.Context, id string) (version uint64, reason string, err error) {
+	if err := m.validateID(id); err != nil {
+		return 0, "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return 0, "", ErrClosed
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var prev *Config
+	for _, v := range versions {
+		if prev != nil && v != prev.Meta.Version+1 {
+			return prev.Meta.Version + 1, "version missing", nil
+		}
+
+		cfg, err := loadConfigAtPath(ctx, m.storage, m.configStore.makeKey(id, v))
+		if err != nil {
+			return v, fmt.Sprintf("unreadable: %v", err), nil
+		}
+		if cfg.Meta.Version != v {
+			return v, fmt.Sprintf("metadata reports version %d", cfg.Meta.Version), nil
+		}
+		if err := cfg.Validate(); err != nil {
+			return v, err.Error(), nil
+		}
+
+		switch {
+		case prev == nil && v == 1 && cfg.Meta.PrevCS != "":
+			return v, "first version has non-empty prev_cs", nil
+		case prev != nil && cfg.Meta.PrevCS != prev.Meta.CS:
+			return v, fmt.Sprintf("chain break: prev_cs=%s != cs=%s", cfg.Meta.PrevCS, prev.Meta.CS), nil
+		case prev != nil && timeRegressed(cfg.Meta.Time, prev.Meta.Time, m.clockSkew):
+			return v, fmt.Sprintf("timestamp regression: %s < %s", cfg.Meta.Time, prev.Meta.Time), nil
+		}
+
+		if m.signer != nil && cfg.Meta.Signature != "" {
+			if err := m.signer.Verify(cfg, m.signer.PublicKey()); err != nil {
+				return v, fmt.Sprintf("signature: %v", err), nil
+			}
+		}
+
+		prev = cfg
+	}
+
+	return 0, "", nil
+}
+
+// Reconstruct rebuilds state from journal and scattered files
+func (m *Manager) Reconstruct(ctx context.Context, id string) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "reconstruct", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	cfg, err = m.journal.Reconstruct(ctx, id, m.storage)
+	if err != nil {
+		return nil, err
+	}
+	return m.cacheReconstructed(id, cfg)
+}
+
+// ReconstructWithProgress is Reconstruct for long histories: progress, if
+// not nil, is called with the number of journal entries of id validated so
+// far and their total, first with 0 once the entries are read, then about
+// every hundredth of the chain, and last with total once the chain is
+// valid. It is called on the calling goroutine, with no entries when id only
+// has version files. Once ctx is done the reconstruction stops before the
+// next entry and returns ctx's error, and nothing is cached. Reads run
+// alongside it; writes wait for it.
+func (m *Manager) ReconstructWithProgress(ctx context.Context, id string, progress func(done, total int)) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "reconstruct", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	cfg, err = m.journal.reconstruct(ctx, id, m.storage, progress)
+	if err != nil {
+		return nil, err
+	}
+	return m.cacheReconstructed(id, cfg)
+}
+
+// ReconstructAtOffset rebuilds id as the store saw it when the journal was
+// offset bytes long, ignoring every later entry: what the system believed
+// before a given entry was appended. Offsets are those of JournalReader.Offset;
+// a line cut by offset is ignored, and an offset past the end reads the whole
+// journal. The entries of id in that prefix must form a valid chain. The head
+// comes from its embedded config, or else its config file, which must match
+// the entry. The result is not cached.
+func (m *Manager) ReconstructAtOffset(ctx context.Context, id string, offset int64) (*Config, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	prefix, err := m.journal.readPrefix(ctx, offset)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*JournalEntry
+	for _, entry := range prefix {
+		if entry.ID == id {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w for %q before offset %d", ErrNoJournalEntries, id, offset)
+	}
+
+	ordered, err := m.journal.Resequence(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resequence: %w", err)
+	}
+	if err := m.journal.ValidateChain(ordered); err != nil {
+		return nil, fmt.Errorf("invalid chain: %w", err)
+	}
+
+	cfg, err := m.entryConfig(ctx, ordered[len(ordered)-1])
+	if err != nil {
+		return nil, err
+	}
+	if err := m.configStore.verifySignature(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// MaterializeConfigs writes back the config file of every journaled version
+// of id whose file is missing, using the config embedded in the journal
+// entry, and returns how many were written. Entries without an embedded
+// config, or whose embedded config fails validation, are skipped and reported
+// through the logger. Existing files are never overwritten.
+func (m *Manager) MaterializeConfigs(ctx context.Context, id string) (n int, err error) {
+	ctx, call := m.begin(ctx, "materialize", id)
+	defer func() { call.end(nil, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, ErrClosed
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for _, entry := range entries {
+		exists, err := m.storage.Exists(ctx, m.configStore.makeKey(id, entry.Version))
+		if err != nil {
+			return written, err
+		}
+		if exists {
+			continue
+		}
+
+		if entry.Config == nil {
+			m.logger.Warn("materialize: journal entry has no embedded config", "id", id, "version", entry.Version)
+			continue
+		}
+		if err := entry.Config.Validate(); err != nil || entry.Config.Meta.CS != entry.CS {
+			m.logger.Warn("materialize: embedded config does not match its entry", "id", id, "version", entry.Version, "error", err)
+			continue
+		}
+
+		if err := m.configStore.Save(ctx, id, entry.Config); err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	return written, nil
+}
+
+// Export exports configuration to writer, masked by the manager's
+// redactor if any (see WithRedactor)
+func (m *Manager) Export(ctx context.Context, id string) ([]byte, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	cfg, err := m.getLatest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.redactor != nil {
+		cfg = &Config{Meta: cfg.Meta, Content: m.redact(cfg.Content)}
+	}
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// Import imports a config exported by Export as the next version of id. Its
+// content is checked against its content type before anything else, so an
+// export whose content JSON was damaged fails with ErrInvalidContent rather
+// than a bare JSON syntax error.
+func (m *Manager) Import(ctx context.Context, id string, data []byte) (err error) {
+	ctx, call := m.begin(ctx, "import", id)
+	defer func() { call.end(nil, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		if contentSyntaxError(data) {
+			return fmt.Errorf("%w JSON: %v", ErrInvalidContent, err)
+		}
+		return fmt.Errorf("invalid import data: %w", err)
+	}
+
+	if err := validateContent(&cfg); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := m.checkSigned(&cfg); err != nil {
+		return err
+	}
+
+	key := m.configStore.makeKey(id, cfg.Meta.Version)
+	exists, err := m.storage.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: config %q version %d already exists", ErrVersionConflict, id, cfg.Meta.Version)
+	}
+
+	latest, err := m.getLatest(ctx, id)
+	if err == nil {
+		if err := cfg.nextOf(latest, m.clockSkew); err != nil {
+			return fmt.Errorf("import does not continue existing chain: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return m.persist(ctx, id, &cfg, "import", "")
+}
+
+// contentSyntaxError reports whether data, an exported config that does not
+// decode, fails inside the value of its "content" key
+func contentSyntaxError(data []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return tok == "content"
+		}
+	}
+	return false
+}
+
+// ImportAsNew re-homes content under newID as a fresh, locally signed v1.
+// data may be bare content or an exported config (as produced by Export), in
+// which case its foreign metadata (version, checksums, signature) is
+// discarded and only the content is kept. It returns ErrAlreadyExists if
+// newID already exists.
+func (m *Manager) ImportAsNew(ctx context.Context, newID string, data json.RawMessage, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "import_as_new", newID)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(newID); err != nil {
+		return nil, err
+	}
+
+	var envelope map[string]json.RawMessage
+	content := data
+	if json.Unmarshal(data, &envelope) == nil {
+		if _, ok := envelope["_meta"]; ok {
+			content = envelope["content"]
 		}
 	}
+	if !json.Valid(content) {
+		return nil, errors.New("import content is not valid JSON")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	if err := m.checkNew(ctx, newID); err != nil {
+		return nil, err
+	}
+
+	return m.create(ctx, newID, content, "imported-as-new", newWriteOptions(opts))
+}
+
+// ImportLegacy brings bare content from an older or external system into the
+// versioned store as a properly checksummed v1 of id, signed when a signer is
+// configured. It is journaled with the "imported-legacy" operation tag and
+// fails if id already has versions.
+func (m *Manager) ImportLegacy(ctx context.Context, id string, content interface{}, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "import_legacy", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	if err := m.checkNew(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return m.create(ctx, id, content, "imported-legacy", newWriteOptions(opts))
+}
+
+// Compact compacts journal to reduce size and reports what was reclaimed
+func (m *Manager) Compact(ctx context.Context) (result *CompactResult, err error) {
+	ctx, call := m.begin(ctx, "compact", "")
+	defer func() { call.end(nil, err) }()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
 
-	if err := m.configStore.Save(ctx, id, cfg); err != nil {
+	m.journalCounted = false
+	result, err = m.journal.Compact(ctx)
+	if err != nil {
 		return nil, err
 	}
+	m.lastCompaction.Store(time.Now().UnixNano())
+	return result, nil
+}
 
-	entry := &JournalEntry{
-		ID:        id,
-		Version:   cfg.Meta.Version,
-		CS:        cfg.Meta.CS,
-		PrevCS:    cfg.Meta.PrevCS,
-		Time:      cfg.Meta.Time,
-		Operation: "create",
-		Config:    cfg,
+// noteWrites counts n journaled writes toward WithMaxJournalEntries and
+// WithAutoCompact, and starts a background compaction once the threshold of
+// the latter is reached. Callers hold mu.
+func (m *Manager) noteWrites(n int) {
+	m.journalCount += n
+	m.writes.Add(uint64(n))
+	m.lastWrite.Store(time.Now().UnixNano())
+	if m.autoCompactEvery == 0 {
+		return
 	}
-
-	if err := m.journal.Append(ctx, entry); err != nil {
-		return nil, err
+	m.writesSinceCompact += n
+	if m.writesSinceCompact < m.autoCompactEvery {
+		return
+	}
+	m.writesSinceCompact = 0
+	if !m.compacting.CompareAndSwap(false, true) {
+		return
 	}
 
-	m.cache[id] = cfg
-	return cfg, nil
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		defer m.compacting.Store(false)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.closed {
+			return
+		}
+
+		result, err := m.journal.Compact(context.Background())
+		m.journalCounted = false
+		if err != nil {
+			m.logger.Error("auto-compaction failed", "error", err)
+			m.metrics.IncCounter(MetricAutoCompactions, 1, "result", "error")
+			return
+		}
+		m.lastCompaction.Store(time.Now().UnixNano())
+		m.logger.Info("auto-compaction", "entries_before", result.EntriesBefore, "entries_after", result.EntriesAfter)
+		m.metrics.IncCounter(MetricAutoCompactions, 1, "result", "ok")
+	}()
 }
 
-// Update updates existing configuration
-func (m *Manager) Update(ctx context.Context, id string, content interface{}) (*Config, error) {
+// ExpireSweep deletes every configuration whose latest version has expired:
+// its version files, channels, base link, journal entries and cache entry. It
+// returns the swept ids.
+func (m *Manager) ExpireSweep(ctx context.Context) (swept []string, err error) {
+	ctx, call := m.begin(ctx, "expire_sweep", "")
+	defer func() { call.end(nil, err) }()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	current, err := m.getLatest(ctx, id)
-	if err != nil {
-		return nil, err
+	if m.closed {
+		return nil, ErrClosed
 	}
 
-	data, err := json.Marshal(content)
+	entries, err := m.journal.ReadAll(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	newCfg := &Config{
-		Meta:    current.Meta,
-		Content: json.RawMessage(data),
+	now := m.now()
+	expired := make(map[string]bool)
+	for _, entry := range entries {
+		if _, seen := expired[entry.ID]; seen {
+			continue
+		}
+		cfg, err := m.getLatest(ctx, entry.ID)
+		expired[entry.ID] = err == nil && cfg.Expired(now)
+		if expired[entry.ID] {
+			swept = append(swept, entry.ID)
+		}
 	}
-
-	if err := newCfg.UpdateMeta(); err != nil {
+	if len(swept) == 0 {
+		return nil, nil
+	}
+	if err := m.checkFence(ctx); err != nil {
 		return nil, err
 	}
 
-	if m.signer != nil {
-		if err := m.signer.Sign(newCfg); err != nil {
+	for _, id := range swept {
+		if err := m.configStore.DeleteAll(ctx, id); err != nil {
 			return nil, err
 		}
+		for _, sidecar := range []string{m.channelPath(id), m.basePath(id), m.schemaPath(id)} {
+			if err := m.storage.Delete(ctx, sidecar); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+		m.cacheDelete(id)
 	}
+	m.indexReset()
 
-	if err := m.configStore.Save(ctx, id, newCfg); err != nil {
-		return nil, err
-	}
-
-	entry := &JournalEntry{
-		ID:        id,
-		Version:   newCfg.Meta.Version,
-		CS:        newCfg.Meta.CS,
-		PrevCS:    newCfg.Meta.PrevCS,
-		Time:      newCfg.Meta.Time,
-		Operation: "update",
-		Config:    newCfg,
+	kept := make([]*JournalEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !expired[entry.ID] {
+			kept = append(kept, entry)
+		}
 	}
-
-	if err := m.journal.Append(ctx, entry); err != nil {
+	m.journalCounted = false
+	if err := m.journal.Rewrite(ctx, kept); err != nil {
 		return nil, err
 	}
 
-	m.cache[id] = newCfg
-	return newCfg, nil
-}
-
-// Get retrieves specific version of configuration
-func (m *Manager) Get(ctx context.Context, id string, version uint64) (*Config, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	return m.configStore.Load(ctx, id, version)
+	return swept, nil
 }
 
-// GetLatest retrieves latest version of configuration
-func (m *Manager) GetLatest(ctx context.Context, id string) (*Config, error) {
+// List lists all configuration IDs
+func (m *Manager) List(ctx context.Context) ([]string, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	return m.getLatest(ctx, id)
-}
-
-func (m *Manager) getLatest(ctx context.Context, id string) (*Config, error) {
-	if cfg, ok := m.cache[id]; ok {
-		return cfg, nil
+	if m.closed {
+		return nil, ErrClosed
 	}
 
-	cfg, err := m.journal.Reconstruct(ctx, id, m.storage)
+	var entries []*JournalEntry
+	err := m.readThrough(ctx, func(src *readSource) (err error) {
+		entries, err = src.journal.ReadAll(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	m.cache[id] = cfg
-	return cfg, nil
-}
-
-// GetHistory retrieves configuration history
-func (m *Manager) GetHistory(ctx context.Context, id string) ([]*Config, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	seen := make(map[string]bool)
+	var ids []string
 
-	versions, err := m.configStore.ListVersions(ctx, id)
-	if err != nil {
-		return nil, err
+	for _, entry := range entries {
+		if !seen[entry.ID] {
+			seen[entry.ID] = true
+			ids = append(ids, entry.ID)
+		}
 	}
 
-	// Sort versions to ensure correct order
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i] < versions[j]
-	})
+	return ids, nil
+}
 
-	configs := make([]*Config, 0, len(versions))
-	for _, v := range versions {
-		cfg, err := m.configStore.Load(ctx, id, v)
+// ListPage returns up to limit config ids in ascending order, starting after
+// cursor (empty for the first page), and the cursor for the next page, which
+// is empty once the listing is exhausted. Ids come from the config store's
+// directory listing rather than the journal. A cursor encodes the last id
+// returned, so continuing with it stays correct when ids are added or removed
+// between calls.
+func (m *Manager) ListPage(ctx context.Context, cursor string, limit int) (ids []string, nextCursor string, err error) {
+	if limit <= 0 {
+		return nil, "", errors.New("limit must be positive")
+	}
+
+	after := ""
+	if cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
 		if err != nil {
-			continue
+			return nil, "", fmt.Errorf("invalid cursor: %w", err)
 		}
-		configs = append(configs, cfg)
+		after = string(raw)
 	}
 
-	return configs, nil
-}
-
-// ValidateChain validates configuration chain integrity
-func (m *Manager) ValidateChain(ctx context.Context, id string) error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	entries, err := m.journal.FindByID(ctx, id)
+	if m.closed {
+		return nil, "", ErrClosed
+	}
+
+	all, err := m.configIDs(ctx)
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+	all = all[sort.SearchStrings(all, after):]
+	if len(all) > 0 && all[0] == after {
+		all = all[1:]
 	}
 
-	if len(entries) == 0 {
-		return nil
+	if len(all) > limit {
+		all = all[:limit]
+		nextCursor = base64.RawURLEncoding.EncodeToString([]byte(all[limit-1]))
 	}
+	return all, nextCursor, nil
+}
 
-	ordered, err := m.journal.Resequence(entries)
+// WalkAll calls fn for every stored version of every config, ordered by id
+// and then version, loading one version at a time so the store never has to
+// fit in memory. The manager lock is not held while fn runs, so fn may call
+// the manager. A version that fails to load is skipped and the walk goes on;
+// such failures are returned together once it completes. An error from fn,
+// or ctx being done, stops the walk and is returned as is.
+func (m *Manager) WalkAll(ctx context.Context, fn func(id string, cfg *Config) error) error {
+	m.mu.RLock()
+	if m.closed {
+		m.mu.RUnlock()
+		return ErrClosed
+	}
+	ids, err := m.configIDs(ctx)
+	m.mu.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	return m.journal.ValidateChain(ordered)
-}
+	load := func(id string, version uint64) (*Config, error) {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		if m.closed {
+			return nil, ErrClosed
+		}
+		return m.configStore.Load(ctx, id, version)
+	}
 
-// Reconstruct rebuilds state from journal and scattered files
-func (m *Manager) Reconstruct(ctx context.Context, id string) (*Config, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	var loadErrs []error
+	for _, id := range ids {
+		m.mu.RLock()
+		versions, err := m.configStore.ListVersions(ctx, id)
+		m.mu.RUnlock()
+		if err != nil {
+			loadErrs = append(loadErrs, fmt.Errorf("config %q: %w", id, err))
+			continue
+		}
 
-	cfg, err := m.journal.Reconstruct(ctx, id, m.storage)
+		for _, v := range versions {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			cfg, err := load(id, v)
+			if errors.Is(err, ErrClosed) {
+				return err
+			}
+			if err != nil {
+				loadErrs = append(loadErrs, fmt.Errorf("config %q version %d: %w", id, v, err))
+				continue
+			}
+			if err := fn(id, cfg); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(loadErrs...)
+}
+
+// configIDs returns, in ascending order, every id with at least one file in
+// the config store
+func (m *Manager) configIDs(ctx context.Context) ([]string, error) {
+	paths, err := m.storage.List(ctx, m.configStore.prefix)
 	if err != nil {
 		return nil, err
 	}
 
-	m.cache[id] = cfg
-	return cfg, nil
+	prefix := filepath.Clean(m.configStore.prefix) + string(filepath.Separator)
+	seen := make(map[string]bool)
+	var ids []string
+	for _, path := range paths {
+		rest := strings.TrimPrefix(filepath.Clean(path), prefix)
+		id, _, nested := strings.Cut(rest, string(filepath.Separator))
+		if !nested || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
 }
 
-// Export exports configuration to writer
-func (m *Manager) Export(ctx context.Context, id string) ([]byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	cfg, err := m.getLatest(ctx, id)
-	if err != nil {
-		return nil, err
+// Verify verifies configuration signature
+func (m *Manager) Verify(cfg *Config, publicKey string) error {
+	if m.signer == nil {
+		return errors.New("no signer configured")
 	}
 
-	return json.MarshalIndent(cfg, "", "  ")
+	return m.signer.Verify(cfg, publicKey)
 }
 
-// Import imports configuration from reader
-func (m *Manager) Import(ctx context.Context, id string, data []byte) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// VerificationStatus is the outcome of verifying one version's signature
+type VerificationStatus string
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return err
-	}
+const (
+	VerificationVerified VerificationStatus = "verified"
+	VerificationUnsigned VerificationStatus = "unsigned"
+	VerificationFailed   VerificationStatus = "failed"
+)
 
-	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("invalid configuration: %w", err)
-	}
+// VersionVerification reports the signature check of one stored version; Err
+// is set when Status is VerificationFailed
+type VersionVerification struct {
+	Version uint64
+	Status  VerificationStatus
+	Err     error
+}
 
-	key := m.configStore.makeKey(id, cfg.Meta.Version)
-	exists, err := m.storage.Exists(ctx, key)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return fmt.Errorf("%w: config %q version %d already exists", ErrVersionConflict, id, cfg.Meta.Version)
-	}
+// VerifyHistoryOption configures VerifyHistory
+type VerifyHistoryOption func(*verifyHistoryOptions)
 
-	latest, err := m.getLatest(ctx, id)
-	if err == nil {
-		if err := cfg.NextOf(latest); err != nil {
-			return fmt.Errorf("import does not continue existing chain: %w", err)
-		}
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return err
+type verifyHistoryOptions struct {
+	stopAtFirst bool
+}
+
+// StopAtFirstFailure makes VerifyHistory end its result at the first version
+// that fails verification
+func StopAtFirstFailure() VerifyHistoryOption {
+	return func(o *verifyHistoryOptions) {
+		o.stopAtFirst = true
 	}
+}
 
-	if err := m.configStore.Save(ctx, id, &cfg); err != nil {
-		return err
+// VerifyHistory loads the history of id once (see GetHistory) and verifies
+// every version's signature against publicKey concurrently, returning one
+// result per version in version order. A failed version does not stop the
+// others unless StopAtFirstFailure is given; err is reserved for loading
+// failures.
+func (m *Manager) VerifyHistory(ctx context.Context, id, publicKey string, opts ...VerifyHistoryOption) ([]VersionVerification, error) {
+	o := &verifyHistoryOptions{}
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	entry := &JournalEntry{
-		ID:        id,
-		Version:   cfg.Meta.Version,
-		CS:        cfg.Meta.CS,
-		PrevCS:    cfg.Meta.PrevCS,
-		Time:      cfg.Meta.Time,
-		Operation: "import",
-		Config:    &cfg,
+	history, err := m.GetHistory(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := m.journal.Append(ctx, entry); err != nil {
-		return err
+	errs := verifySignaturesConcurrent(history, publicKey, 0, o.stopAtFirst)
+	results := make([]VersionVerification, 0, len(history))
+	for i, cfg := range history {
+		result := VersionVerification{Version: cfg.Meta.Version, Status: VerificationVerified}
+		switch {
+		case errs[i] != nil:
+			result.Status, result.Err = VerificationFailed, errs[i]
+		case cfg.Meta.Signature == "":
+			result.Status = VerificationUnsigned
+		}
+		results = append(results, result)
+		if o.stopAtFirst && errs[i] != nil {
+			break
+		}
 	}
+	return results, nil
+}
 
-	m.cache[id] = &cfg
-	return nil
+// Watch watches for configuration changes. The current version is not
+// delivered; only versions created after the call are emitted.
+func (m *Manager) Watch(ctx context.Context, id string, interval time.Duration) (<-chan *Config, error) {
+	ch, _, err := m.watch(ctx, id, interval, watchOptions{})
+	return ch, err
 }
 
-// Compact compacts journal to reduce size
-func (m *Manager) Compact(ctx context.Context) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// WatchWithReplay is like Watch but first delivers the current config (if
+// any) as the initial channel value, then continues with updates. Subscribers
+// thus initialize and subscribe in one step without missing an update.
+func (m *Manager) WatchWithReplay(ctx context.Context, id string, interval time.Duration) (<-chan *Config, error) {
+	ch, _, err := m.watch(ctx, id, interval, watchOptions{replay: true})
+	return ch, err
+}
 
-	return m.journal.Compact(ctx)
+// WatchWithDone is like Watch but also returns a channel that is closed once
+// the watcher goroutine has fully exited, after ctx is cancelled or the
+// manager is closed. Waiting on it replaces sleeping after cancellation in
+// teardown code.
+func (m *Manager) WatchWithDone(ctx context.Context, id string, interval time.Duration) (<-chan *Config, <-chan struct{}, error) {
+	return m.watch(ctx, id, interval, watchOptions{})
 }
 
-// List lists all configuration IDs
-func (m *Manager) List(ctx context.Context) ([]string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// WatchWhere is like Watch but only emits a new version when predicate
+// returns true for the transition from the previously observed version (nil
+// if id did not exist when watching started) to the new one. Versions that
+// are skipped still become the previous version for the next transition. See
+// FieldChanged for the common case of reacting to one field.
+func (m *Manager) WatchWhere(ctx context.Context, id string, interval time.Duration, predicate func(old, new *Config) bool) (<-chan *Config, error) {
+	if predicate == nil {
+		return nil, errors.New("watch predicate must not be nil")
+	}
+	ch, _, err := m.watch(ctx, id, interval, watchOptions{predicate: predicate})
+	return ch, err
+}
 
-	entries, err := m.journal.ReadAll(ctx)
+func (m *Manager) watch(ctx context.Context, id string, interval time.Duration, wo watchOptions) (<-chan *Config, <-chan struct{}, error) {
+	last, err := m.startWatch(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	seen := make(map[string]bool)
-	var ids []string
-
-	for _, entry := range entries {
-		if !seen[entry.ID] {
-			seen[entry.ID] = true
-			ids = append(ids, entry.ID)
-		}
+	ch := make(chan *Config, 1)
+	done := make(chan struct{})
+	if last != nil && wo.replay {
+		// Channel is fresh and buffered, so this never blocks
+		ch <- last
 	}
 
-	return ids, nil
-}
+	go func() {
+		defer m.workers.Done()
+		defer close(done)
+		defer close(ch)
 
-// Verify verifies configuration signature
-func (m *Manager) Verify(cfg *Config, publicKey string) error {
-	if m.signer == nil {
-		return errors.New("no signer configured")
-	}
+		m.pollWatch(ctx, id, interval, wo, last, func(_, cfg *Config) bool {
+			select {
+			case ch <- cfg:
+				return true
+			case <-ctx.Done():
+				wo.end(ctx.Err())
+			case <-m.done:
+				wo.end(ErrClosed)
+			}
+			return false
+		})
+	}()
 
-	return m.signer.Verify(cfg, publicKey)
+	return ch, done, nil
 }
 
-// Watch watches for configuration changes
-func (m *Manager) Watch(ctx context.Context, id string, interval time.Duration) (<-chan *Config, error) {
-	ch := make(chan *Config, 1)
+// startWatch registers a watch of id as a worker, which the caller's
+// goroutine must mark done, and returns the current version, nil if there
+// is none
+func (m *Manager) startWatch(ctx context.Context, id string) (*Config, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	m.workers.Add(1)
+	m.mu.Unlock()
 
 	// Get initial version to avoid sending current state
-	initialCfg, err := m.GetLatest(ctx, id)
+	last, err := m.GetLatest(ctx, id)
 	if err != nil {
 		// If config doesn't exist yet, start from 0
-		initialCfg = &Config{Meta: Meta{Version: 0}}
+		return nil, nil
 	}
+	return last, nil
+}
 
-	go func() {
-		defer close(ch)
-
-		lastVersion := initialCfg.Meta.Version
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				cfg, err := m.GetLatest(ctx, id)
-				if err != nil {
+// pollWatch polls id every interval until ctx is done, the manager is
+// closed or storage keeps failing, and passes each newer version the
+// predicate accepts to deliver along with the version observed before it.
+// deliver returns false to end the watch, having recorded why.
+func (m *Manager) pollWatch(ctx context.Context, id string, interval time.Duration, wo watchOptions, last *Config, deliver func(old, cfg *Config) bool) {
+	var lastVersion uint64
+	if last != nil {
+		lastVersion = last.Meta.Version
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			wo.end(ctx.Err())
+			return
+		case <-m.done:
+			wo.end(ErrClosed)
+			return
+		case <-ticker.C:
+			cfg, err := m.GetLatest(ctx, id)
+			if err != nil {
+				if !watchFailure(err) {
+					failures = 0
+					continue
+				}
+				if ctx.Err() != nil || errors.Is(err, ErrClosed) {
 					continue
 				}
+				failures++
+				if wo.maxErrors > 0 && failures >= wo.maxErrors {
+					m.logger.Warn("watch: giving up", "id", id, "failures", failures, "error", err)
+					wo.end(fmt.Errorf("%w: %d consecutive errors watching %q: %w", ErrWatchFailed, failures, id, err))
+					return
+				}
+				continue
+			}
+			failures = 0
 
-				if cfg.Meta.Version > lastVersion {
-					lastVersion = cfg.Meta.Version
-					select {
-					case ch <- cfg:
-					case <-ctx.Done():
-						return
-					}
+			if cfg.Meta.Version > lastVersion {
+				old := last
+				last, lastVersion = cfg, cfg.Meta.Version
+				if wo.predicate != nil && !wo.predicate(old, cfg) {
+					continue
+				}
+				if !deliver(old, cfg) {
+					return
 				}
 			}
 		}
-	}()
-
-	return ch, nil
+	}
 }
 
 // Rollback rolls back to specific version
-func (m *Manager) Rollback(ctx context.Context, id string, version uint64) (*Config, error) {
+func (m *Manager) Rollback(ctx context.Context, id string, version uint64, opts ...WriteOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "rollback", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.closed {
+		return nil, ErrClosed
+	}
+
 	// Get the content from the target version
 	targetCfg, err := m.configStore.Load(ctx, id, version)
 	if err != nil {
@@ -424,35 +2384,15 @@ func (m *Manager) Rollback(ctx context.Context, id string, version uint64) (*Con
 		Meta:    latestCfg.Meta,
 		Content: targetCfg.Content,
 	}
+	newCfg.Meta.HashAlg = m.hashAlg
+	newCfg.Meta.ContentType = targetCfg.Meta.ContentType
 
 	if err := newCfg.UpdateMeta(); err != nil {
 		return nil, err
 	}
 
-	if m.signer != nil {
-		if err := m.signer.Sign(newCfg); err != nil {
-			return nil, err
-		}
-	}
-
-	if err := m.configStore.Save(ctx, id, newCfg); err != nil {
-		return nil, err
-	}
-
-	entry := &JournalEntry{
-		ID:        id,
-		Version:   newCfg.Meta.Version,
-		CS:        newCfg.Meta.CS,
-		PrevCS:    newCfg.Meta.PrevCS,
-		Time:      newCfg.Meta.Time,
-		Operation: fmt.Sprintf("rollback_to_v%d", version),
-		Config:    newCfg,
-	}
-
-	if err := m.journal.Append(ctx, entry); err != nil {
+	if err := m.commit(ctx, id, newCfg, fmt.Sprintf("rollback_to_v%d", version), newWriteOptions(opts)); err != nil {
 		return nil, err
 	}
-
-	m.cache[id] = newCfg
 	return newCfg, nil
 }
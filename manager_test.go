@@ -1,9 +1,15 @@
 package viracochan
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -193,7 +199,7 @@ func TestManagerReconstruct(t *testing.T) {
 	}
 
 	// Clear cache to force reconstruction
-	manager.cache = make(map[string]*Config)
+	manager.cacheReset()
 
 	// Reconstruct
 	reconstructed, err := manager.Reconstruct(ctx, "test")
@@ -206,6 +212,121 @@ func TestManagerReconstruct(t *testing.T) {
 	}
 }
 
+func TestManagerReconstructWithProgress(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	const versions = 250
+	manager.Create(ctx, "big", map[string]interface{}{"n": 0})
+	for i := 1; i < versions; i++ {
+		if _, err := manager.Update(ctx, "big", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("Update %d failed: %v", i, err)
+		}
+	}
+	manager.cacheReset()
+
+	var calls [][2]int
+	cfg, err := manager.ReconstructWithProgress(ctx, "big", func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	})
+	if err != nil {
+		t.Fatalf("ReconstructWithProgress failed: %v", err)
+	}
+	if cfg.Meta.Version != versions {
+		t.Errorf("reconstructed v%d, want v%d", cfg.Meta.Version, versions)
+	}
+	if len(calls) < 3 || len(calls) > progressSteps+2 {
+		t.Errorf("progress called %d times for %d entries", len(calls), versions)
+	}
+	if first, last := calls[0], calls[len(calls)-1]; first != [2]int{0, versions} || last != [2]int{versions, versions} {
+		t.Errorf("progress ran from %v to %v", first, last)
+	}
+	for i := 1; i < len(calls); i++ {
+		if calls[i][0] <= calls[i-1][0] {
+			t.Fatalf("progress went from %v to %v", calls[i-1], calls[i])
+		}
+	}
+
+	manager.cacheReset()
+	cancelCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lastDone int
+	_, err = manager.ReconstructWithProgress(cancelCtx, "big", func(done, total int) {
+		lastDone = done
+		if done >= versions/2 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled reconstruction: got %v, want context.Canceled", err)
+	}
+	if lastDone >= versions {
+		t.Errorf("canceled reconstruction ran to the end")
+	}
+	manager.cacheMu.Lock()
+	_, cached := manager.cache["big"]
+	manager.cacheMu.Unlock()
+	if cached {
+		t.Error("canceled reconstruction left a cached config")
+	}
+
+	if _, err := manager.ReconstructWithProgress(ctx, "big", nil); err != nil {
+		t.Errorf("ReconstructWithProgress without a callback: %v", err)
+	}
+}
+
+func TestManagerReconstructAtOffset(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithJournalEmbedContent(false))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Create(ctx, "db", map[string]interface{}{"host": "a"})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+
+	reader := NewJournalReader(storage, "journal.jsonl")
+	var offsets []int64
+	for {
+		if _, err := reader.Next(ctx); err != nil {
+			break
+		}
+		offsets = append(offsets, reader.Offset())
+	}
+	if len(offsets) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(offsets))
+	}
+
+	for _, tc := range []struct {
+		offset  int64
+		version uint64
+	}{
+		{offsets[0], 1},
+		{offsets[1], 2},
+		{offsets[3] - 1, 2}, // the last line is cut
+		{offsets[3], 3},
+		{offsets[3] + 100, 3},
+	} {
+		cfg, err := manager.ReconstructAtOffset(ctx, "app", tc.offset)
+		if err != nil {
+			t.Fatalf("ReconstructAtOffset(%d) failed: %v", tc.offset, err)
+		}
+		if cfg.Meta.Version != tc.version {
+			t.Errorf("offset %d: expected v%d, got v%d", tc.offset, tc.version, cfg.Meta.Version)
+		}
+	}
+
+	if _, err := manager.ReconstructAtOffset(ctx, "db", offsets[1]); !errors.Is(err, ErrNoJournalEntries) {
+		t.Errorf("expected ErrNoJournalEntries before db existed, got %v", err)
+	}
+	if _, err := manager.ReconstructAtOffset(ctx, "app", -1); err == nil {
+		t.Error("expected error for a negative offset")
+	}
+	if latest, _ := manager.GetLatest(ctx, "app"); latest.Meta.Version != 3 {
+		t.Errorf("point-in-time read changed the cached head: v%d", latest.Meta.Version)
+	}
+}
+
 func TestManagerImportExport(t *testing.T) {
 	ctx := context.Background()
 	storage1 := NewMemoryStorage()
@@ -252,6 +373,52 @@ func TestManagerImportExport(t *testing.T) {
 	}
 }
 
+func TestManagerImportRejectsInvalidContent(t *testing.T) {
+	ctx := context.Background()
+	source, _ := NewManager(NewMemoryStorage())
+	if _, err := source.Create(ctx, "cfg", map[string]interface{}{"port": 8080}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	exported, err := source.Export(ctx, "cfg")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	broken := bytes.Replace(exported, []byte(`"port": 8080`), []byte(`"port": 8080,`), 1)
+	if bytes.Equal(broken, exported) {
+		t.Fatalf("export has no port to break: %s", exported)
+	}
+	err = manager.Import(ctx, "cfg", broken)
+	if !errors.Is(err, ErrInvalidContent) {
+		t.Fatalf("Import of broken content JSON: got %v, want ErrInvalidContent", err)
+	}
+	if !strings.Contains(err.Error(), "content JSON") {
+		t.Errorf("error does not name the content JSON: %v", err)
+	}
+
+	if err := manager.Import(ctx, "cfg", []byte(`{"_meta": {"v": 1,}}`)); err == nil || errors.Is(err, ErrInvalidContent) {
+		t.Errorf("broken metadata: got %v, want a non-content error", err)
+	}
+
+	// well-formed JSON that is not a document of the declared content type
+	var cfg Config
+	if err := json.Unmarshal(exported, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Meta.ContentType = ContentTypeYAML
+	mistyped, _ := json.Marshal(&cfg)
+	if err := manager.Import(ctx, "cfg", mistyped); !errors.Is(err, ErrInvalidContent) {
+		t.Errorf("Import of YAML config with object content: got %v, want ErrInvalidContent", err)
+	}
+
+	if keys, _ := storage.List(ctx, ""); len(keys) != 0 {
+		t.Errorf("rejected imports wrote %v", keys)
+	}
+}
+
 func TestManagerImportRejectsDivergingChain(t *testing.T) {
 	ctx := context.Background()
 	storage := NewMemoryStorage()
@@ -445,7 +612,7 @@ func TestManagerCompact(t *testing.T) {
 	}
 
 	// Compact journal
-	if err := manager.Compact(ctx); err != nil {
+	if _, err := manager.Compact(ctx); err != nil {
 		t.Fatalf("Compact failed: %v", err)
 	}
 
@@ -459,3 +626,1575 @@ func TestManagerCompact(t *testing.T) {
 		t.Errorf("Expected version 20, got %d", latest.Meta.Version)
 	}
 }
+
+func TestManagerWatchWithReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	if _, err := manager.Create(ctx, "replay-test", map[string]interface{}{"v": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	ch, err := manager.WatchWithReplay(ctx, "replay-test", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchWithReplay failed: %v", err)
+	}
+
+	select {
+	case cfg := <-ch:
+		if cfg == nil || cfg.Meta.Version != 1 {
+			t.Fatalf("expected current version 1 first, got %+v", cfg)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timeout waiting for replayed config")
+	}
+
+	if _, err := manager.Update(ctx, "replay-test", map[string]interface{}{"v": 2}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	select {
+	case cfg := <-ch:
+		if cfg == nil || cfg.Meta.Version != 2 {
+			t.Errorf("expected version 2, got %+v", cfg)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("timeout waiting for config update")
+	}
+}
+
+func TestManagerWatchWithReplayMissingConfig(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	manager, _ := NewManager(NewMemoryStorage())
+
+	ch, err := manager.WatchWithReplay(ctx, "later", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchWithReplay failed: %v", err)
+	}
+
+	if _, err := manager.Create(ctx, "later", map[string]interface{}{"v": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	select {
+	case cfg := <-ch:
+		if cfg == nil || cfg.Meta.Version != 1 {
+			t.Errorf("expected version 1 as first value, got %+v", cfg)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("timeout waiting for created config")
+	}
+}
+
+func TestManagerValidateChainFrom(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	for i := 0; i < 15; i++ {
+		content := map[string]interface{}{"iteration": i}
+		var err error
+		if i == 0 {
+			_, err = manager.Create(ctx, "pruned", content)
+		} else {
+			_, err = manager.Update(ctx, "pruned", content)
+		}
+		if err != nil {
+			t.Fatalf("write %d failed: %v", i, err)
+		}
+	}
+
+	if err := manager.ValidateChainFrom(ctx, "pruned", 4); err != nil {
+		t.Errorf("ValidateChainFrom on full journal failed: %v", err)
+	}
+
+	if _, err := manager.Compact(ctx); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	root, ok, err := manager.journal.Root(ctx, "pruned")
+	if err != nil || !ok {
+		t.Fatalf("expected recorded root after compaction, ok=%v err=%v", ok, err)
+	}
+	if root.Version != 6 {
+		t.Errorf("expected pruned boundary at v6, got v%d", root.Version)
+	}
+
+	if err := manager.ValidateChain(ctx, "pruned"); err != nil {
+		t.Errorf("ValidateChain after compaction failed: %v", err)
+	}
+	if err := manager.ValidateChainFrom(ctx, "pruned", 6); err != nil {
+		t.Errorf("ValidateChainFrom boundary failed: %v", err)
+	}
+	if err := manager.ValidateChainFrom(ctx, "pruned", 10); err != nil {
+		t.Errorf("ValidateChainFrom after boundary failed: %v", err)
+	}
+	if err := manager.ValidateChainFrom(ctx, "pruned", 2); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain for pruned start version, got %v", err)
+	}
+}
+
+func TestManagerAnnotations(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+
+	annotations := map[string]string{"ticket": "OPS-42", "reason": "initial deploy"}
+	cfg, err := manager.Create(ctx, "annotated", map[string]interface{}{"v": 1}, WithAnnotations(annotations))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	annotations["ticket"] = "mutated"
+	if cfg.Meta.Annotations["ticket"] != "OPS-42" {
+		t.Error("annotations must be copied from the caller's map")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("annotated config should validate: %v", err)
+	}
+	if err := manager.Verify(cfg, signer.PublicKey()); err != nil {
+		t.Errorf("annotated config should verify: %v", err)
+	}
+
+	loaded, err := manager.Get(ctx, "annotated", 1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Meta.Annotations, cfg.Meta.Annotations) {
+		t.Errorf("annotations not persisted: %v", loaded.Meta.Annotations)
+	}
+
+	updated, err := manager.Update(ctx, "annotated", map[string]interface{}{"v": 2})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(updated.Meta.Annotations) != 0 {
+		t.Errorf("annotations must not carry over to new versions: %v", updated.Meta.Annotations)
+	}
+}
+
+func TestManagerVerifyOnLoad(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	trusted, _ := NewSigner()
+	other, _ := NewSigner()
+
+	writer, _ := NewManager(storage, WithSigner(other))
+	if _, err := writer.Create(ctx, "cfg", map[string]interface{}{"v": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	reader, err := NewManager(storage, WithConfigStorageOptions(WithVerifyKeys([]string{trusted.PublicKey()})))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	if _, err := reader.Get(ctx, "cfg", 1); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("Get: expected ErrUntrustedSignature, got %v", err)
+	}
+	if _, err := reader.GetLatest(ctx, "cfg"); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("GetLatest: expected ErrUntrustedSignature, got %v", err)
+	}
+	history, err := reader.GetHistory(ctx, "cfg")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("GetHistory must not return untrusted versions, got %d", len(history))
+	}
+
+	// Reconstructing must not slip the untrusted head into the cache
+	if _, err := reader.Reconstruct(ctx, "cfg"); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("Reconstruct: expected ErrUntrustedSignature, got %v", err)
+	}
+	if _, err := reader.ReconstructWithProgress(ctx, "cfg", nil); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("ReconstructWithProgress: expected ErrUntrustedSignature, got %v", err)
+	}
+	if _, err := reader.GetLatest(ctx, "cfg"); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("GetLatest after Reconstruct: expected ErrUntrustedSignature, got %v", err)
+	}
+}
+
+func TestManagerImportLegacy(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+
+	legacy := json.RawMessage(`{"host": "db.internal", "port": 5432}`)
+	cfg, err := manager.ImportLegacy(ctx, "legacy", legacy)
+	if err != nil {
+		t.Fatalf("ImportLegacy failed: %v", err)
+	}
+
+	if cfg.Meta.Version != 1 || cfg.Meta.PrevCS != "" {
+		t.Errorf("expected fresh v1, got v%d prev=%q", cfg.Meta.Version, cfg.Meta.PrevCS)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("imported config should validate: %v", err)
+	}
+	if err := manager.Verify(cfg, signer.PublicKey()); err != nil {
+		t.Errorf("imported config should be signed: %v", err)
+	}
+
+	entries, _ := manager.journal.FindByID(ctx, "legacy")
+	if len(entries) != 1 || entries[0].Operation != "imported-legacy" {
+		t.Errorf("expected single imported-legacy journal entry, got %+v", entries)
+	}
+
+	if _, err := manager.Update(ctx, "legacy", map[string]interface{}{"port": 6432}); err != nil {
+		t.Errorf("imported config should accept updates: %v", err)
+	}
+
+	if _, err := manager.ImportLegacy(ctx, "legacy", legacy); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict for existing id, got %v", err)
+	}
+}
+
+func TestManagerGetHistoryOrderingAcrossBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"file": func(t *testing.T) Storage {
+			fs, err := NewFileStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFileStorage failed: %v", err)
+			}
+			return fs
+		},
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := newStorage(t)
+			manager, _ := NewManager(storage)
+			strict, _ := NewManager(storage, WithStrictHistory())
+
+			// More than 9 versions so lexical and numeric order differ
+			for i := 1; i <= 12; i++ {
+				var err error
+				if i == 1 {
+					_, err = manager.Create(ctx, "hist", map[string]interface{}{"i": i})
+				} else {
+					_, err = manager.Update(ctx, "hist", map[string]interface{}{"i": i})
+				}
+				if err != nil {
+					t.Fatalf("write %d failed: %v", i, err)
+				}
+			}
+
+			history, err := strict.GetHistory(ctx, "hist")
+			if err != nil {
+				t.Fatalf("strict GetHistory on complete chain failed: %v", err)
+			}
+			for i, cfg := range history {
+				if cfg.Meta.Version != uint64(i+1) {
+					t.Fatalf("position %d holds version %d", i, cfg.Meta.Version)
+				}
+			}
+
+			if err := storage.Delete(ctx, manager.configStore.makeKey("hist", 7)); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+
+			history, err = manager.GetHistory(ctx, "hist")
+			if err != nil {
+				t.Fatalf("GetHistory failed: %v", err)
+			}
+			if len(history) != 11 {
+				t.Fatalf("expected 11 versions with a hole, got %d", len(history))
+			}
+			for i := 1; i < len(history); i++ {
+				if history[i].Meta.Version <= history[i-1].Meta.Version {
+					t.Errorf("history not strictly ascending at %d", i)
+				}
+			}
+
+			if _, err := strict.GetHistory(ctx, "hist"); !errors.Is(err, ErrHistoryGap) {
+				t.Errorf("expected ErrHistoryGap in strict mode, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManagerJournalEmbedContent(t *testing.T) {
+	ctx := context.Background()
+
+	for _, embed := range []bool{true, false} {
+		storage := NewMemoryStorage()
+		manager, _ := NewManager(storage, WithJournalEmbedContent(embed))
+
+		manager.Create(ctx, "cfg", map[string]interface{}{"v": 1})
+		manager.Update(ctx, "cfg", map[string]interface{}{"v": 2})
+
+		entries, _ := manager.journal.FindByID(ctx, "cfg")
+		for _, entry := range entries {
+			if (entry.Config != nil) != embed {
+				t.Errorf("embed=%v: entry v%d has embedded config=%v", embed, entry.Version, entry.Config != nil)
+			}
+		}
+
+		fresh, _ := NewManager(storage, WithJournalEmbedContent(embed))
+		latest, err := fresh.GetLatest(ctx, "cfg")
+		if err != nil {
+			t.Fatalf("embed=%v: GetLatest failed: %v", embed, err)
+		}
+		if latest.Meta.Version != 2 {
+			t.Errorf("embed=%v: expected v2, got v%d", embed, latest.Meta.Version)
+		}
+		if err := fresh.ValidateChain(ctx, "cfg"); err != nil {
+			t.Errorf("embed=%v: ValidateChain failed: %v", embed, err)
+		}
+	}
+}
+
+func TestManagerJournalEmbedContentMixedJournal(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	embedded, _ := NewManager(storage)
+	embedded.Create(ctx, "cfg", map[string]interface{}{"v": 1})
+	embedded.Update(ctx, "cfg", map[string]interface{}{"v": 2})
+
+	slim, _ := NewManager(storage, WithJournalEmbedContent(false))
+	if _, err := slim.Update(ctx, "cfg", map[string]interface{}{"v": 3}); err != nil {
+		t.Fatalf("Update with embedding disabled failed: %v", err)
+	}
+
+	fresh, _ := NewManager(storage, WithJournalEmbedContent(false))
+	latest, err := fresh.Reconstruct(ctx, "cfg")
+	if err != nil {
+		t.Fatalf("Reconstruct of mixed journal failed: %v", err)
+	}
+	var content map[string]interface{}
+	json.Unmarshal(latest.Content, &content)
+	if latest.Meta.Version != 3 || content["v"] != float64(3) {
+		t.Errorf("unexpected reconstructed head v%d %v", latest.Meta.Version, content)
+	}
+	if err := fresh.ValidateChain(ctx, "cfg"); err != nil {
+		t.Errorf("ValidateChain on mixed journal failed: %v", err)
+	}
+}
+
+func TestManagerFirstInvalid(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	for i := 1; i <= 6; i++ {
+		var err error
+		if i == 1 {
+			_, err = manager.Create(ctx, "cfg", map[string]interface{}{"i": i})
+		} else {
+			_, err = manager.Update(ctx, "cfg", map[string]interface{}{"i": i})
+		}
+		if err != nil {
+			t.Fatalf("write %d failed: %v", i, err)
+		}
+	}
+
+	if v, reason, err := manager.FirstInvalid(ctx, "cfg"); err != nil || v != 0 {
+		t.Fatalf("valid chain reported v%d (%s), err=%v", v, reason, err)
+	}
+
+	// Tamper with v5 content and resign nothing: checksum breaks at v5
+	v5, _ := manager.Get(ctx, "cfg", 5)
+	v5.Content = json.RawMessage(`{"i":"tampered"}`)
+	writeConfigAtPath(ctx, storage, manager.configStore.makeKey("cfg", 5), v5)
+
+	// Forge v3 with a valid checksum but a foreign signature
+	other, _ := NewSigner()
+	v3, _ := manager.Get(ctx, "cfg", 3)
+	other.Sign(v3)
+	writeConfigAtPath(ctx, storage, manager.configStore.makeKey("cfg", 3), v3)
+
+	v, reason, err := manager.FirstInvalid(ctx, "cfg")
+	if err != nil {
+		t.Fatalf("FirstInvalid failed: %v", err)
+	}
+	if v != 3 {
+		t.Errorf("expected first invalid version 3, got v%d (%s)", v, reason)
+	}
+	if reason == "" {
+		t.Error("expected a failure reason")
+	}
+
+	// Remove v2: the gap is now the earliest failure
+	storage.Delete(ctx, manager.configStore.makeKey("cfg", 2))
+	if v, reason, _ := manager.FirstInvalid(ctx, "cfg"); v != 2 || reason != "version missing" {
+		t.Errorf("expected missing v2, got v%d (%s)", v, reason)
+	}
+}
+
+func TestManagerCreateWithTTL(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	tmp, err := manager.CreateWithTTL(ctx, "experiment", map[string]interface{}{"flag": true}, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateWithTTL failed: %v", err)
+	}
+	if tmp.Meta.ExpiresAt == nil || !tmp.Meta.ExpiresAt.Equal(tmp.Meta.Time.Add(time.Hour)) {
+		t.Fatalf("unexpected expiry: %v", tmp.Meta.ExpiresAt)
+	}
+	if err := tmp.Validate(); err != nil {
+		t.Fatalf("expiring config does not validate: %v", err)
+	}
+	if err := signer.Verify(tmp, signer.PublicKey()); err != nil {
+		t.Fatalf("expiring config signature invalid: %v", err)
+	}
+
+	// Expiry is bound by the checksum and therefore by the signature
+	forged := *tmp
+	later := tmp.Meta.ExpiresAt.Add(time.Hour)
+	forged.Meta.ExpiresAt = &later
+	if err := forged.Validate(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected checksum mismatch for extended expiry, got %v", err)
+	}
+
+	if _, err := manager.Create(ctx, "permanent", map[string]interface{}{"flag": false}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := manager.GetLatest(ctx, "experiment"); err != nil {
+		t.Fatalf("GetLatest before expiry failed: %v", err)
+	}
+
+	// Move the manager's clock past the expiry instead of sleeping
+	if tmp.Expired(tmp.Meta.ExpiresAt.Add(-time.Second)) || !tmp.Expired(*tmp.Meta.ExpiresAt) {
+		t.Error("expected the config to expire exactly at ExpiresAt")
+	}
+	expiredAt := tmp.Meta.ExpiresAt.Add(time.Second)
+	manager.now = func() time.Time { return expiredAt }
+
+	if _, err := manager.GetLatest(ctx, "experiment"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	if _, err := manager.Get(ctx, "experiment", 1); err != nil {
+		t.Errorf("explicit version read should ignore expiry: %v", err)
+	}
+
+	swept, err := manager.ExpireSweep(ctx)
+	if err != nil {
+		t.Fatalf("ExpireSweep failed: %v", err)
+	}
+	if len(swept) != 1 || swept[0] != "experiment" {
+		t.Fatalf("expected [experiment] swept, got %v", swept)
+	}
+
+	ids, _ := manager.List(ctx)
+	if len(ids) != 1 || ids[0] != "permanent" {
+		t.Errorf("expected only permanent to remain, got %v", ids)
+	}
+	if _, err := manager.Get(ctx, "experiment", 1); err == nil {
+		t.Error("expected swept config files to be deleted")
+	}
+	if _, err := manager.GetLatest(ctx, "permanent"); err != nil {
+		t.Errorf("non-expiring config affected by sweep: %v", err)
+	}
+
+	// Expiry is per version: updating without WithTTL clears it
+	manager.now = time.Now
+	manager.CreateWithTTL(ctx, "override", map[string]interface{}{"v": 1}, time.Hour)
+	updated, err := manager.Update(ctx, "override", map[string]interface{}{"v": 2})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Meta.ExpiresAt != nil {
+		t.Errorf("expected update to clear expiry, got %v", updated.Meta.ExpiresAt)
+	}
+}
+
+// journalWriteCounter counts writes to one path and can be told to fail them
+type journalWriteCounter struct {
+	*MemoryStorage
+	path   string
+	writes int
+	fail   bool
+}
+
+func (s *journalWriteCounter) Write(ctx context.Context, path string, data []byte) error {
+	if path == s.path {
+		s.writes++
+		if s.fail {
+			return errors.New("journal write failed")
+		}
+	}
+	return s.MemoryStorage.Write(ctx, path, data)
+}
+
+func TestManagerCreateBatch(t *testing.T) {
+	ctx := context.Background()
+	storage := &journalWriteCounter{MemoryStorage: NewMemoryStorage(), path: "journal.jsonl"}
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	items := map[string]interface{}{
+		"alpha": map[string]interface{}{"n": 1},
+		"beta":  map[string]interface{}{"n": 2},
+		"gamma": map[string]interface{}{"n": 3},
+	}
+	created, err := manager.CreateBatch(ctx, items)
+	if err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if len(created) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(created))
+	}
+	if storage.writes != 1 {
+		t.Errorf("expected a single journal write, got %d", storage.writes)
+	}
+	for id, cfg := range created {
+		if err := signer.Verify(cfg, signer.PublicKey()); err != nil {
+			t.Errorf("%s: signature invalid: %v", id, err)
+		}
+		latest, err := manager.Reconstruct(ctx, id)
+		if err != nil || latest.Meta.CS != cfg.Meta.CS {
+			t.Errorf("%s: not reconstructable from journal: %v", id, err)
+		}
+	}
+
+	// One existing id rejects the whole batch
+	_, err = manager.CreateBatch(ctx, map[string]interface{}{
+		"alpha": 1,
+		"delta": 4,
+		"omega": make(chan int),
+	})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "omega") {
+		t.Errorf("expected combined error to mention every failing id, got %v", err)
+	}
+	if exists, _ := storage.Exists(ctx, "configs/delta/v1.json"); exists {
+		t.Error("rejected batch must not write any config")
+	}
+
+	// A failed journal append removes the config files already written
+	storage.fail = true
+	if _, err := manager.CreateBatch(ctx, map[string]interface{}{"delta": 4, "epsilon": 5}); err == nil {
+		t.Fatal("expected journal failure")
+	}
+	storage.fail = false
+	for _, id := range []string{"delta", "epsilon"} {
+		if exists, _ := storage.Exists(ctx, "configs/"+id+"/v1.json"); exists {
+			t.Errorf("%s: config file left behind after failed batch", id)
+		}
+		if _, err := manager.GetLatest(ctx, id); err == nil {
+			t.Errorf("%s: visible after failed batch", id)
+		}
+	}
+}
+
+func TestManagerMaterializeConfigs(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	logger := &recordingLogger{}
+	manager, _ := NewManager(storage, WithLogger(logger))
+
+	manager.Create(ctx, "app", map[string]interface{}{"v": 1})
+	for i := 2; i <= 4; i++ {
+		manager.Update(ctx, "app", map[string]interface{}{"v": i})
+	}
+
+	// Lose config files 2 and 3, and strip the embedded config from v3's entry
+	storage.Delete(ctx, "configs/app/v2.json")
+	storage.Delete(ctx, "configs/app/v3.json")
+	entries, _ := manager.journal.ReadAll(ctx)
+	for _, entry := range entries {
+		if entry.Version == 3 {
+			entry.Config = nil
+		}
+	}
+	manager.journal.Rewrite(ctx, entries)
+
+	n, err := manager.MaterializeConfigs(ctx, "app")
+	if err != nil {
+		t.Fatalf("MaterializeConfigs failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 config materialized, got %d", n)
+	}
+	if _, err := manager.Get(ctx, "app", 2); err != nil {
+		t.Errorf("v2 not restored: %v", err)
+	}
+	if exists, _ := storage.Exists(ctx, "configs/app/v3.json"); exists {
+		t.Error("v3 has no embedded config and must stay missing")
+	}
+	if len(logger.warns) != 1 || !strings.Contains(logger.warns[0], "no embedded config") {
+		t.Errorf("expected skipped entry to be reported, got %v", logger.warns)
+	}
+
+	if n, _ := manager.MaterializeConfigs(ctx, "app"); n != 0 {
+		t.Errorf("expected second run to write nothing, got %d", n)
+	}
+}
+
+func TestManagerClose(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"v": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	ch, err := manager.Watch(ctx, "app", 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Close waits for watchers, so the channel is already closed
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("expected watch channel to be closed")
+		}
+	default:
+		t.Error("watch goroutine still running after Close")
+	}
+
+	if _, err := manager.GetLatest(ctx, "app"); !errors.Is(err, ErrClosed) {
+		t.Errorf("GetLatest: expected ErrClosed, got %v", err)
+	}
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"v": 2}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Update: expected ErrClosed, got %v", err)
+	}
+	if _, _, err := manager.FirstInvalid(ctx, "app"); !errors.Is(err, ErrClosed) {
+		t.Errorf("FirstInvalid: expected ErrClosed, got %v", err)
+	}
+	if _, err := manager.Watch(ctx, "app", time.Millisecond); !errors.Is(err, ErrClosed) {
+		t.Errorf("Watch: expected ErrClosed, got %v", err)
+	}
+	if err := manager.Close(); err != nil {
+		t.Errorf("second Close should be a no-op, got %v", err)
+	}
+}
+
+func TestManagerWatchWithDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	manager, _ := NewManager(NewMemoryStorage())
+	manager.Create(context.Background(), "app", map[string]interface{}{"v": 1})
+
+	ch, done, err := manager.WatchWithDone(ctx, "app", 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchWithDone failed: %v", err)
+	}
+
+	select {
+	case <-done:
+		t.Fatal("done closed before cancellation")
+	default:
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not exit after cancellation")
+	}
+
+	// The goroutine has returned, so the config channel is closed too
+	if _, ok := <-ch; ok {
+		t.Error("expected config channel to be closed")
+	}
+}
+
+func TestManagerWatchWhere(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager, _ := NewManager(NewMemoryStorage())
+	defer manager.Close()
+
+	db := func(host string, port int) map[string]interface{} {
+		return map[string]interface{}{"database": map[string]interface{}{"host": host, "port": port}}
+	}
+	manager.Create(ctx, "app", db("a", 1))
+
+	var transitions []uint64
+	hostChanged := FieldChanged("database.host")
+	ch, err := manager.WatchWhere(ctx, "app", 5*time.Millisecond, func(old, new *Config) bool {
+		transitions = append(transitions, old.Meta.Version, new.Meta.Version)
+		return hostChanged(old, new)
+	})
+	if err != nil {
+		t.Fatalf("WatchWhere failed: %v", err)
+	}
+
+	manager.Update(ctx, "app", db("a", 2))
+	time.Sleep(30 * time.Millisecond)
+	manager.Update(ctx, "app", db("b", 2))
+
+	select {
+	case cfg := <-ch:
+		if cfg.Meta.Version != 3 {
+			t.Errorf("expected only the host change (v3), got v%d", cfg.Meta.Version)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no emission for host change")
+	}
+
+	if want := []uint64{1, 2, 2, 3}; !reflect.DeepEqual(transitions, want) {
+		t.Errorf("expected transitions %v, got %v", want, transitions)
+	}
+
+	if _, err := manager.WatchWhere(ctx, "app", time.Millisecond, nil); err == nil {
+		t.Error("expected error for nil predicate")
+	}
+}
+
+func TestManagerCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	writer, _ := NewManager(storage)
+	reader, _ := NewManager(storage, WithCacheTTL(20*time.Millisecond))
+	sticky, _ := NewManager(storage)
+
+	writer.Create(ctx, "app", map[string]interface{}{"v": 1})
+	writer.Update(ctx, "app", map[string]interface{}{"v": 2})
+
+	for _, m := range []*Manager{reader, sticky} {
+		if cfg, err := m.GetLatest(ctx, "app"); err != nil || cfg.Meta.Version != 2 {
+			t.Fatalf("initial read failed: %v", err)
+		}
+	}
+
+	if _, err := writer.Rollback(ctx, "app", 1); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if cfg, _ := reader.GetLatest(ctx, "app"); cfg.Meta.Version != 2 {
+		t.Errorf("expected cached v2 within ttl, got v%d", cfg.Meta.Version)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if cfg, _ := reader.GetLatest(ctx, "app"); cfg.Meta.Version != 3 {
+		t.Errorf("expected reader to converge to rolled back v3, got v%d", cfg.Meta.Version)
+	}
+	if cfg, _ := sticky.GetLatest(ctx, "app"); cfg.Meta.Version != 2 {
+		t.Errorf("expected manager without ttl to keep its cached v2, got v%d", cfg.Meta.Version)
+	}
+
+	if _, err := NewManager(storage, WithCacheTTL(-time.Second)); err == nil {
+		t.Error("expected negative ttl to be rejected")
+	}
+}
+
+func TestManagerGetLatestFresh(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	writer, _ := NewManager(storage)
+	reader, _ := NewManager(storage)
+
+	writer.Create(ctx, "app", map[string]interface{}{"v": 1})
+	reader.GetLatest(ctx, "app")
+	writer.Update(ctx, "app", map[string]interface{}{"v": 2})
+
+	if cfg, _ := reader.GetLatest(ctx, "app"); cfg.Meta.Version != 1 {
+		t.Fatalf("expected the cached v1, got v%d", cfg.Meta.Version)
+	}
+
+	var trace ReadTrace
+	cfg, err := reader.GetLatestFresh(WithReadTrace(ctx, &trace), "app")
+	if err != nil || cfg.Meta.Version != 2 {
+		t.Fatalf("expected a fresh read of v2, got %v, %v", cfg, err)
+	}
+	if trace.Source != SourcePrimary {
+		t.Errorf("fresh read served from %q", trace.Source)
+	}
+
+	trace = ReadTrace{}
+	if cfg, _ := reader.GetLatest(WithReadTrace(ctx, &trace), "app"); cfg.Meta.Version != 2 || trace.Source != SourceCache {
+		t.Errorf("expected the fresh read to refill the cache, got v%d from %q", cfg.Meta.Version, trace.Source)
+	}
+
+	if _, err := reader.GetLatestFresh(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-found error, got %v", err)
+	}
+}
+
+// noStorage fails the test on any storage access
+type noStorage struct {
+	Storage
+	t *testing.T
+}
+
+func (s noStorage) Read(context.Context, string) ([]byte, error) {
+	s.t.Fatal("storage read before id validation")
+	return nil, nil
+}
+
+func (s noStorage) Write(context.Context, string, []byte) error {
+	s.t.Fatal("storage write before id validation")
+	return nil
+}
+
+func (s noStorage) List(context.Context, string) ([]string, error) {
+	s.t.Fatal("storage list before id validation")
+	return nil, nil
+}
+
+func TestManagerIDValidation(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(noStorage{t: t})
+
+	for _, id := range []string{"", ".", "..", "../etc", "/abs", `a\b`, "a/b", "tab\there", "nul\x00"} {
+		if _, err := manager.Create(ctx, id, 1); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Create(%q): expected ErrInvalidID, got %v", id, err)
+		}
+		if _, err := manager.Get(ctx, id, 1); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Get(%q): expected ErrInvalidID, got %v", id, err)
+		}
+		if err := manager.Import(ctx, id, []byte(`[]`)); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Import(%q): expected ErrInvalidID, got %v", id, err)
+		}
+	}
+
+	for _, id := range []string{"app-config", "cluster.v2", "räksmörgås", "a..b"} {
+		if err := DefaultIDValidator(id); err != nil {
+			t.Errorf("DefaultIDValidator(%q) rejected a valid id: %v", id, err)
+		}
+	}
+
+	lower, _ := NewManager(NewMemoryStorage(), WithIDValidator(func(id string) error {
+		if err := DefaultIDValidator(id); err != nil {
+			return err
+		}
+		if strings.ToLower(id) != id {
+			return errors.New("must be lower case")
+		}
+		return nil
+	}))
+	if _, err := lower.Create(ctx, "App", 1); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("expected custom validator error wrapped in ErrInvalidID, got %v", err)
+	}
+	if _, err := lower.CreateBatch(ctx, map[string]interface{}{"ok": 1, "../x": 2}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("expected CreateBatch to validate every id, got %v", err)
+	}
+	if _, err := lower.Create(ctx, "app", 1); err != nil {
+		t.Errorf("valid id rejected: %v", err)
+	}
+}
+
+func TestManagerListPage(t *testing.T) {
+	ctx := context.Background()
+	for name, storage := range map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"file":   mustFileStorage(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			manager, _ := NewManager(storage)
+			for _, id := range []string{"delta", "alpha", "echo", "charlie", "bravo"} {
+				manager.Create(ctx, id, map[string]interface{}{"id": id})
+			}
+			manager.Update(ctx, "alpha", map[string]interface{}{"id": "alpha2"})
+
+			page, cursor, err := manager.ListPage(ctx, "", 2)
+			if err != nil {
+				t.Fatalf("ListPage failed: %v", err)
+			}
+			if !reflect.DeepEqual(page, []string{"alpha", "bravo"}) || cursor == "" {
+				t.Fatalf("unexpected first page %v (cursor %q)", page, cursor)
+			}
+
+			// Ids added before the cursor do not shift later pages
+			manager.Create(ctx, "aardvark", 1)
+			page, cursor, _ = manager.ListPage(ctx, cursor, 2)
+			if !reflect.DeepEqual(page, []string{"charlie", "delta"}) {
+				t.Fatalf("unexpected second page %v", page)
+			}
+
+			page, cursor, _ = manager.ListPage(ctx, cursor, 2)
+			if !reflect.DeepEqual(page, []string{"echo"}) || cursor != "" {
+				t.Errorf("unexpected last page %v (cursor %q)", page, cursor)
+			}
+
+			if _, _, err := manager.ListPage(ctx, "not base64!", 2); err == nil {
+				t.Error("expected invalid cursor to be rejected")
+			}
+		})
+	}
+}
+
+func mustFileStorage(t *testing.T) *FileStorage {
+	t.Helper()
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	return storage
+}
+
+func TestManagerHashAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	legacy, _ := NewManager(storage, WithSigner(signer))
+	modern, err := NewManager(storage, WithSigner(signer), WithHashAlgorithm(HashSHA512))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	v1, _ := legacy.Create(ctx, "app", map[string]interface{}{"v": 1})
+	if v1.Meta.HashAlg != "" || len(v1.Meta.CS) != 64 {
+		t.Fatalf("default manager should write SHA-256 without recording it, got %q/%d", v1.Meta.HashAlg, len(v1.Meta.CS))
+	}
+
+	v2, err := modern.Update(ctx, "app", map[string]interface{}{"v": 2})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if v2.Meta.HashAlg != HashSHA512 || len(v2.Meta.CS) != 128 {
+		t.Fatalf("expected SHA-512 checksum, got %q/%d", v2.Meta.HashAlg, len(v2.Meta.CS))
+	}
+	if err := v2.Validate(); err != nil {
+		t.Errorf("SHA-512 config does not validate: %v", err)
+	}
+	if err := signer.Verify(v2, signer.PublicKey()); err != nil {
+		t.Errorf("SHA-512 config signature invalid: %v", err)
+	}
+	if err := modern.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("mixed-algorithm chain invalid: %v", err)
+	}
+
+	// The algorithm is bound by the checksum
+	relabelled := *v2
+	relabelled.Meta.HashAlg = ""
+	if err := relabelled.Validate(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected checksum mismatch after changing hash_alg, got %v", err)
+	}
+
+	unknown := *v2
+	unknown.Meta.HashAlg = "md4"
+	if err := unknown.Validate(); !errors.Is(err, ErrUnsupportedHashAlgorithm) {
+		t.Errorf("expected ErrUnsupportedHashAlgorithm, got %v", err)
+	}
+	if _, err := NewManager(storage, WithHashAlgorithm("md4")); !errors.Is(err, ErrUnsupportedHashAlgorithm) {
+		t.Errorf("expected unknown algorithm to be rejected, got %v", err)
+	}
+}
+
+func TestManagerMergeUpdate(t *testing.T) {
+	ctx := context.Background()
+	manager, err := NewManager(NewMemoryStorage(), WithMergeFields(map[string]MergeType{"counters.*": CounterSum}))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	base, _ := manager.Create(ctx, "shared", map[string]interface{}{
+		"counters": map[string]interface{}{"w1": 0, "w2": 0},
+		"owner":    "ops",
+	})
+
+	// Two workers increment from the same base version
+	if _, err := manager.MergeUpdate(ctx, "shared", base.Meta.Version, map[string]interface{}{
+		"counters": map[string]interface{}{"w1": 1, "w2": 0}, "owner": "ops",
+	}); err != nil {
+		t.Fatalf("first MergeUpdate failed: %v", err)
+	}
+	merged, err := manager.MergeUpdate(ctx, "shared", base.Meta.Version, map[string]interface{}{
+		"counters": map[string]interface{}{"w1": 1, "w2": 1}, "owner": "ops",
+	})
+	if err != nil {
+		t.Fatalf("concurrent MergeUpdate failed: %v", err)
+	}
+
+	var content struct {
+		Counters map[string]float64 `json:"counters"`
+	}
+	json.Unmarshal(merged.Content, &content)
+	if content.Counters["w1"] != 2 || content.Counters["w2"] != 1 {
+		t.Errorf("expected counters w1=2 w2=1, got %v", content.Counters)
+	}
+	if merged.Meta.Version != 3 {
+		t.Errorf("expected merged v3, got v%d", merged.Meta.Version)
+	}
+
+	// Plain fields changed on both sides still conflict
+	manager.Update(ctx, "shared", map[string]interface{}{"counters": content.Counters, "owner": "sec"})
+	if _, err := manager.MergeUpdate(ctx, "shared", merged.Meta.Version, map[string]interface{}{
+		"counters": content.Counters, "owner": "qa",
+	}); !errors.Is(err, ErrMergeConflict) {
+		t.Errorf("expected conflicting owner edits to fail, got %v", err)
+	}
+}
+
+func TestManagerImportAsNew(t *testing.T) {
+	ctx := context.Background()
+	foreignSigner, _ := NewSigner()
+	foreign, _ := NewManager(NewMemoryStorage(), WithSigner(foreignSigner))
+	foreign.Create(ctx, "remote", map[string]interface{}{"region": "eu"})
+	foreign.Update(ctx, "remote", map[string]interface{}{"region": "us"})
+	exported, _ := foreign.Export(ctx, "remote")
+
+	localSigner, _ := NewSigner()
+	local, _ := NewManager(NewMemoryStorage(), WithSigner(localSigner))
+
+	cfg, err := local.ImportAsNew(ctx, "rehomed", exported)
+	if err != nil {
+		t.Fatalf("ImportAsNew failed: %v", err)
+	}
+	if cfg.Meta.Version != 1 || cfg.Meta.PrevCS != "" {
+		t.Errorf("expected fresh v1 lineage, got v%d prev=%q", cfg.Meta.Version, cfg.Meta.PrevCS)
+	}
+	if err := localSigner.Verify(cfg, localSigner.PublicKey()); err != nil {
+		t.Errorf("expected local signature: %v", err)
+	}
+	var content map[string]string
+	json.Unmarshal(cfg.Content, &content)
+	if content["region"] != "us" {
+		t.Errorf("expected exported content, got %s", cfg.Content)
+	}
+	if err := local.ValidateChain(ctx, "rehomed"); err != nil {
+		t.Errorf("imported chain invalid: %v", err)
+	}
+
+	bare, err := local.ImportAsNew(ctx, "bare", json.RawMessage(`{"a": 1}`))
+	if err != nil || string(bare.Content) != `{"a":1}` {
+		t.Errorf("bare content import failed: %v %s", err, bare.Content)
+	}
+
+	if _, err := local.ImportAsNew(ctx, "rehomed", exported); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict for existing id, got %v", err)
+	}
+	if _, err := local.ImportAsNew(ctx, "broken", json.RawMessage(`{`)); err == nil {
+		t.Error("expected invalid JSON to be rejected")
+	}
+}
+
+func TestManagerVerifyHistory(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	signed, _ := NewManager(storage, WithSigner(signer))
+	unsigned, _ := NewManager(storage)
+
+	signed.Create(ctx, "app", map[string]interface{}{"n": 1})
+	unsigned.Update(ctx, "app", map[string]interface{}{"n": 2})
+	signed.cacheReset()
+	signed.Update(ctx, "app", map[string]interface{}{"n": 3})
+	signed.Update(ctx, "app", map[string]interface{}{"n": 4})
+
+	// Break v3's signature without touching its checksum
+	v3, _ := signed.Get(ctx, "app", 3)
+	v3.Meta.Signature = strings.Repeat("0", len(v3.Meta.Signature))
+	signed.configStore.Save(ctx, "app", v3)
+
+	results, err := signed.VerifyHistory(ctx, "app", signer.PublicKey())
+	if err != nil {
+		t.Fatalf("VerifyHistory failed: %v", err)
+	}
+	var statuses []VerificationStatus
+	for _, r := range results {
+		statuses = append(statuses, r.Status)
+	}
+	want := []VerificationStatus{VerificationVerified, VerificationUnsigned, VerificationFailed, VerificationVerified}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("expected %v, got %v", want, statuses)
+	}
+	if results[2].Version != 3 || results[2].Err == nil {
+		t.Errorf("expected v3 failure with error, got %+v", results[2])
+	}
+
+	results, _ = signed.VerifyHistory(ctx, "app", signer.PublicKey(), StopAtFirstFailure())
+	if len(results) != 3 || results[2].Status != VerificationFailed {
+		t.Errorf("expected results to end at v3, got %+v", results)
+	}
+}
+
+func TestManagerWithMessage(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	cfg, _ := manager.Create(ctx, "app", map[string]interface{}{"timeout": 30}, WithMessage("initial"))
+	manager.Update(ctx, "app", map[string]interface{}{"timeout": 10}, WithMessage("reduce timeout"))
+	manager.CreateBatch(ctx, map[string]interface{}{"db": 1}, WithMessage("bootstrap"))
+
+	entries, _ := manager.journal.ReadAll(ctx)
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	if want := []string{"initial", "reduce timeout", "bootstrap"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("expected messages %v, got %v", want, messages)
+	}
+
+	// Advisory only: the config itself is untouched
+	plain, _ := newConfig(map[string]interface{}{"timeout": 30}, "")
+	if !cfg.ContentEqual(plain) || strings.Contains(string(mustRead(t, manager.storage, "configs/app/v1.json")), "initial") {
+		t.Error("message leaked into the stored config")
+	}
+}
+
+func TestManagerChangelog(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	manager.Create(ctx, "app", map[string]interface{}{"level": "info"})
+	manager.Update(ctx, "app", map[string]interface{}{"level": "debug", "trace": true}, WithMessage("chase timeout bug"))
+	manager.Rollback(ctx, "app", 1)
+
+	changelog, err := manager.Changelog(ctx, "app")
+	if err != nil {
+		t.Fatalf("Changelog failed: %v", err)
+	}
+	if len(changelog) != 2 {
+		t.Fatalf("expected 2 version changes, got %d", len(changelog))
+	}
+	if changelog[0].Version != 2 || changelog[0].Operation != "update" || changelog[0].Message != "chase timeout bug" || len(changelog[0].Changes) != 2 {
+		t.Errorf("unexpected v2 change: %+v", changelog[0])
+	}
+	if changelog[1].Operation != "rollback_to_v1" || changelog[1].Changes[0].Path != "level" {
+		t.Errorf("unexpected v3 change: %+v", changelog[1])
+	}
+
+	var buf strings.Builder
+	if err := RenderChangelog(&buf, changelog, nil); err != nil {
+		t.Fatalf("RenderChangelog failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"v2 (", "update) chase timeout bug", `changed level "info" -> "debug"`, "added trace true", "removed trace true"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered changelog missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestNewManagerSplit(t *testing.T) {
+	ctx := context.Background()
+	journalStore := NewMemoryStorage()
+	configStore := NewMemoryStorage()
+	manager, err := NewManagerSplit(journalStore, configStore, WithIntentLog())
+	if err != nil {
+		t.Fatalf("NewManagerSplit failed: %v", err)
+	}
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	if paths, _ := journalStore.List(ctx, "configs"); len(paths) != 0 {
+		t.Errorf("config files leaked into journal storage: %v", paths)
+	}
+	if exists, _ := configStore.Exists(ctx, "journal.jsonl"); exists {
+		t.Error("journal leaked into config storage")
+	}
+	if exists, _ := journalStore.Exists(ctx, "journal.jsonl"); !exists {
+		t.Error("journal missing from journal storage")
+	}
+
+	// A fresh manager over the same pair reconstructs from both sides
+	reopened, _ := NewManagerSplit(journalStore, configStore, WithJournalEmbedContent(false))
+	latest, err := reopened.GetLatest(ctx, "app")
+	if err != nil || latest.Meta.Version != 2 {
+		t.Fatalf("expected v2, got %v, %v", latest, err)
+	}
+	if report, err := reopened.Audit(ctx); err != nil || !report.OK() {
+		t.Errorf("expected clean audit, got %+v, %v", report, err)
+	}
+
+	// Config files lost from the cold tier come back from the journal
+	configStore.Delete(ctx, "configs/app/v1.json")
+	if n, err := reopened.MaterializeConfigs(ctx, "app"); err != nil || n != 1 {
+		t.Errorf("expected 1 materialized config, got %d, %v", n, err)
+	}
+}
+
+func TestAutoCompact(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	metrics := &countingMetrics{}
+	manager, err := NewManager(storage, WithAutoCompact(3), WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 0})
+	for i := 1; i < 6; i++ {
+		if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	runs := func() float64 {
+		metrics.mu.Lock()
+		defer metrics.mu.Unlock()
+		return metrics.counters[MetricAutoCompactions]
+	}
+	for deadline := time.Now().Add(2 * time.Second); runs() < 1 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	manager.Close()
+
+	if runs := runs(); runs < 1 || runs > 2 {
+		t.Errorf("expected one or two auto-compactions for 6 writes, got %v", runs)
+	}
+
+	reopened, _ := NewManager(storage)
+	latest, err := reopened.GetLatest(ctx, "app")
+	if err != nil || latest.Meta.Version != 6 {
+		t.Fatalf("expected v6 after auto-compaction, got %v, %v", latest, err)
+	}
+	if err := reopened.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain invalid after auto-compaction: %v", err)
+	}
+
+	if _, err := NewManager(storage, WithAutoCompact(0)); err == nil {
+		t.Error("expected error for non-positive threshold")
+	}
+}
+
+func TestWalkAll(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	manager.Create(ctx, "b", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "b", map[string]interface{}{"n": 2})
+	manager.Create(ctx, "a", map[string]interface{}{"n": 1})
+	manager.Create(ctx, "c", map[string]interface{}{"n": 1})
+	storage.Write(ctx, "configs/c/v1.json", []byte(`{"_meta":{"v":1,"cs":"bad"},"content":{}}`))
+
+	var visited []string
+	err := manager.WalkAll(ctx, func(id string, cfg *Config) error {
+		visited = append(visited, fmt.Sprintf("%s@%d", id, cfg.Meta.Version))
+		// The lock is released while fn runs
+		_, err := manager.GetLatest(ctx, "a")
+		return err
+	})
+	if want := []string{"a@1", "b@1", "b@2"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("expected %v, got %v", want, visited)
+	}
+	if err == nil || !strings.Contains(err.Error(), `config "c" version 1`) {
+		t.Errorf("expected load error for c v1, got %v", err)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = manager.WalkAll(ctx, func(string, *Config) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected walk to stop at fn error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestCrossRefValidator(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	// A service may only pin a version listed in the registry
+	validate := func(ctx context.Context, view *Manager, id string, cfg *Config) error {
+		if id == "registry" {
+			return nil
+		}
+		var service struct {
+			Version string `json:"version"`
+		}
+		json.Unmarshal(cfg.Content, &service)
+
+		registry, err := view.GetLatest(ctx, "registry")
+		if err != nil {
+			return err
+		}
+		var known struct {
+			Versions []string `json:"versions"`
+		}
+		json.Unmarshal(registry.Content, &known)
+		for _, v := range known.Versions {
+			if v == service.Version {
+				return nil
+			}
+		}
+		return fmt.Errorf("version %q is not in the registry", service.Version)
+	}
+
+	manager, _ := NewManager(storage, WithCrossRefValidator(validate))
+	manager.Create(ctx, "registry", map[string]interface{}{"versions": []string{"1.0", "1.1"}})
+
+	if _, err := manager.Create(ctx, "svc", map[string]interface{}{"version": "1.0"}); err != nil {
+		t.Fatalf("valid create rejected: %v", err)
+	}
+	if _, err := manager.Update(ctx, "svc", map[string]interface{}{"version": "2.0"}); !errors.Is(err, ErrCrossReference) {
+		t.Errorf("expected ErrCrossReference, got %v", err)
+	}
+	if latest, _ := manager.GetLatest(ctx, "svc"); latest.Meta.Version != 1 {
+		t.Errorf("rejected update was written: v%d", latest.Meta.Version)
+	}
+
+	// The validator sees the latest registry
+	manager.Update(ctx, "registry", map[string]interface{}{"versions": []string{"1.0", "1.1", "2.0"}})
+	if _, err := manager.Update(ctx, "svc", map[string]interface{}{"version": "2.0"}); err != nil {
+		t.Errorf("update rejected after registry change: %v", err)
+	}
+
+	_, err := manager.CreateBatch(ctx, map[string]interface{}{
+		"ok":  map[string]interface{}{"version": "1.1"},
+		"bad": map[string]interface{}{"version": "9.9"},
+	})
+	if !errors.Is(err, ErrCrossReference) {
+		t.Errorf("expected CreateBatch to fail with ErrCrossReference, got %v", err)
+	}
+	if _, err := manager.GetLatest(ctx, "ok"); err == nil {
+		t.Error("CreateBatch wrote configs despite a rejected item")
+	}
+}
+
+type readCounter struct {
+	*MemoryStorage
+	reads int
+}
+
+func (s *readCounter) Read(ctx context.Context, path string) ([]byte, error) {
+	if strings.HasPrefix(path, "configs/") {
+		s.reads++
+	}
+	return s.MemoryStorage.Read(ctx, path)
+}
+
+func TestGetLatestN(t *testing.T) {
+	ctx := context.Background()
+	storage := &readCounter{MemoryStorage: NewMemoryStorage()}
+	manager, _ := NewManager(storage)
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	for i := 2; i <= 50; i++ {
+		manager.Update(ctx, "app", map[string]interface{}{"n": i})
+	}
+
+	storage.reads = 0
+	latest, err := manager.GetLatestN(ctx, "app", 5)
+	if err != nil {
+		t.Fatalf("GetLatestN failed: %v", err)
+	}
+	var got []uint64
+	for _, cfg := range latest {
+		got = append(got, cfg.Meta.Version)
+	}
+	if want := []uint64{50, 49, 48, 47, 46}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected newest first %v, got %v", want, got)
+	}
+	if storage.reads != 5 {
+		t.Errorf("expected 5 version reads, got %d", storage.reads)
+	}
+
+	all, _ := manager.GetLatestN(ctx, "app", 100)
+	if len(all) != 50 || all[49].Meta.Version != 1 {
+		t.Errorf("expected all 50 versions for large n, got %d", len(all))
+	}
+	if _, err := manager.GetLatestN(ctx, "app", 0); err == nil {
+		t.Error("expected error for n=0")
+	}
+	if none, err := manager.GetLatestN(ctx, "missing", 3); err != nil || len(none) != 0 {
+		t.Errorf("expected empty result for missing id, got %v, %v", none, err)
+	}
+}
+
+func TestCreateOrGet(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	var wg sync.WaitGroup
+	results := make([]*Config, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg, err := manager.CreateOrGet(ctx, "app", map[string]interface{}{"node": i})
+			if err != nil {
+				t.Errorf("CreateOrGet failed: %v", err)
+				return
+			}
+			results[i] = cfg
+		}(i)
+	}
+	wg.Wait()
+
+	for _, cfg := range results {
+		if cfg == nil || cfg.Meta.CS != results[0].Meta.CS || cfg.Meta.Version != 1 {
+			t.Fatalf("expected every caller to get the same v1, got %+v", cfg)
+		}
+	}
+	if history, _ := manager.GetHistory(ctx, "app"); len(history) != 1 {
+		t.Errorf("expected a single create, got %d versions", len(history))
+	}
+
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{}); !errors.Is(err, ErrAlreadyExists) || !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrAlreadyExists from Create, got %v", err)
+	}
+}
+
+func TestManagerCreateTwice(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	first, _ := manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"n": 3}); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	entries, _ := manager.journal.ReadAll(ctx)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 journal entries, got %d", len(entries))
+	}
+	if _, err := manager.journal.Resequence(entries); err != nil {
+		t.Errorf("journal no longer resequences: %v", err)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain corrupted: %v", err)
+	}
+	if v1, _ := manager.Get(ctx, "app", 1); v1.Meta.CS != first.Meta.CS {
+		t.Error("v1 was overwritten")
+	}
+
+	// Version files without a journal still count as existing
+	orphaned, _ := NewManagerSplit(NewMemoryStorage(), storage)
+	if _, err := orphaned.Create(ctx, "app", map[string]interface{}{"n": 3}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists for unjournaled version files, got %v", err)
+	}
+}
+
+func TestManagerHeadVersion(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	leader, _ := NewManager(storage)
+	follower, _ := NewManager(storage, WithCacheTTL(time.Hour))
+
+	leader.Create(ctx, "app", map[string]interface{}{"n": 1})
+	follower.GetLatest(ctx, "app")
+	latest, _ := leader.Update(ctx, "app", map[string]interface{}{"n": 2})
+
+	v, cs, err := follower.HeadVersion(ctx, "app")
+	if err != nil || v != 2 || cs != latest.Meta.CS {
+		t.Errorf("expected head v2 %s despite the follower's cache, got v%d %s, %v", latest.Meta.CS, v, cs, err)
+	}
+	if _, _, err := leader.HeadVersion(ctx, "missing"); !errors.Is(err, ErrNoJournalEntries) {
+		t.Errorf("expected ErrNoJournalEntries, got %v", err)
+	}
+}
+
+func TestManagerStoragePaths(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithJournalPath("logs/app.journal"))
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Create(ctx, "app2", map[string]interface{}{"n": 1})
+
+	files, journalPath, err := manager.StoragePaths(ctx, "app")
+	if err != nil {
+		t.Fatalf("StoragePaths failed: %v", err)
+	}
+	if journalPath != "logs/app.journal" {
+		t.Errorf("unexpected journal path %q", journalPath)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 version files, got %v", files)
+	}
+	for _, path := range append(files, journalPath) {
+		if ok, _ := storage.Exists(ctx, path); !ok {
+			t.Errorf("%s does not exist in storage", path)
+		}
+	}
+
+	if files, _, err := manager.StoragePaths(ctx, "missing"); err != nil || len(files) != 0 {
+		t.Errorf("expected no files for an unknown id, got %v, %v", files, err)
+	}
+}
+
+func TestManagerRequireSignature(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := NewManager(NewMemoryStorage(), WithRequireSignature()); err == nil {
+		t.Fatal("expected an error without a signer or trusted keys")
+	}
+
+	signer, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer), WithRequireSignature())
+	cfg, err := manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	if err != nil || cfg.Meta.Signature == "" {
+		t.Fatalf("signed Create failed: %v", err)
+	}
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	if _, err := manager.Rollback(ctx, "app", 1); err != nil {
+		t.Errorf("signed Rollback failed: %v", err)
+	}
+
+	// Imports must be signed by a trusted key
+	unsigned, _ := NewManager(NewMemoryStorage())
+	plain, _ := unsigned.Create(ctx, "plain", map[string]interface{}{"n": 1})
+	data, _ := json.Marshal(plain)
+	if err := manager.Import(ctx, "plain", data); !errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("expected ErrUnsignedConfig importing an unsigned config, got %v", err)
+	}
+	stranger, _ := NewSigner()
+	foreign, _ := NewManager(NewMemoryStorage(), WithSigner(stranger))
+	signed, _ := foreign.Create(ctx, "foreign", map[string]interface{}{"n": 1})
+	data, _ = json.Marshal(signed)
+	if err := manager.Import(ctx, "foreign", data); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected ErrUntrustedSignature importing a foreign signature, got %v", err)
+	}
+
+	// A verifier without a signer imports trusted history but cannot write
+	follower, err := NewManager(NewMemoryStorage(), WithRequireSignature(),
+		WithConfigStorageOptions(WithVerifyKeys([]string{signer.PublicKey()})))
+	if err != nil {
+		t.Fatalf("NewManager with trusted keys failed: %v", err)
+	}
+	data, _ = json.Marshal(cfg)
+	if err := follower.Import(ctx, "app", data); err != nil {
+		t.Errorf("trusted Import failed: %v", err)
+	}
+	if _, err := follower.Update(ctx, "app", map[string]interface{}{"n": 3}); !errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("expected ErrUnsignedConfig writing without a signer, got %v", err)
+	}
+	if err := follower.Submit(ctx, "app", externalNext(t, cfg, `{"n":3}`, stranger)); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected ErrUntrustedSignature submitting a foreign signature, got %v", err)
+	}
+}
+
+func TestManagerGetLatestOrDefault(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithJournalEmbedContent(false))
+
+	def, exists, err := manager.GetLatestOrDefault(ctx, "app", map[string]interface{}{"port": 80})
+	if err != nil || exists {
+		t.Fatalf("expected a default, got exists=%t, %v", exists, err)
+	}
+	if def.Meta.Version != 1 || def.Validate() != nil || string(def.Content) != `{"port":80}` {
+		t.Errorf("unexpected default: %+v %s", def.Meta, def.Content)
+	}
+	if paths, _ := storage.List(ctx, ""); len(paths) != 0 {
+		t.Errorf("default was persisted: %v", paths)
+	}
+	if _, err := manager.GetLatest(ctx, "app"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("default was cached: %v", err)
+	}
+
+	manager.Create(ctx, "app", map[string]interface{}{"port": 8080})
+	cfg, exists, err := manager.GetLatestOrDefault(ctx, "app", map[string]interface{}{"port": 80})
+	if err != nil || !exists || string(cfg.Content) != `{"port":8080}` {
+		t.Errorf("expected the stored config, got %v, %t, %v", cfg, exists, err)
+	}
+
+	// A journaled config whose file is lost is not absent
+	reader, _ := NewManager(storage)
+	storage.Delete(ctx, "configs/app/v1.json")
+	if _, exists, err := reader.GetLatestOrDefault(ctx, "app", nil); err == nil || !exists {
+		t.Errorf("expected a read error for a lost version file, got exists=%t, %v", exists, err)
+	}
+}
@@ -0,0 +1,206 @@
+package viracochan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrMergeConflict is returned when both sides of a three-way merge changed
+// the same value differently and no merge type applies to it.
+var ErrMergeConflict = errors.New("merge conflict")
+
+// MergeType selects conflict-free merge semantics for a content field.
+type MergeType int
+
+const (
+	// CounterSum merges numeric fields by applying both sides' increments:
+	// base + (ours - base) + (theirs - base). A missing value counts as 0.
+	CounterSum MergeType = iota + 1
+	// SetUnion merges array fields as the union of both sides, keeping the
+	// order of ours followed by new elements of theirs. Removals made
+	// concurrently with another change are not preserved.
+	SetUnion
+)
+
+// Merge3 merges two concurrent edits of base. Values changed on one side
+// only take that side; objects changed on both sides are merged key by key;
+// fields listed in fields are combined according to their MergeType; any
+// other value changed on both sides yields ErrMergeConflict naming its path.
+//
+// Field paths are dot separated object keys from the content root, e.g.
+// "stats.requests"; a "*" segment matches any key, so "counters.*" covers
+// every entry of the counters object.
+func Merge3(base, ours, theirs json.RawMessage, fields map[string]MergeType) (json.RawMessage, error) {
+	return merge3(base, ours, theirs, fields, failConflict)
+}
+
+// conflictFunc resolves the value at path that both sides of a merge changed
+// differently. reason says why the field's merge type did not apply, and is
+// empty for plain conflicts.
+type conflictFunc func(path []string, reason string, ours, theirs interface{}, oursPresent, theirsPresent bool) (interface{}, bool, error)
+
+// failConflict is the conflictFunc of Merge3: every conflict is an error
+func failConflict(path []string, reason string, _, _ interface{}, _, _ bool) (interface{}, bool, error) {
+	if reason != "" {
+		return nil, false, fmt.Errorf("%w at %q: %s", ErrMergeConflict, strings.Join(path, "."), reason)
+	}
+	return nil, false, fmt.Errorf("%w at %q", ErrMergeConflict, strings.Join(path, "."))
+}
+
+// merge3 is Merge3 with conflicts passed to conflict
+func merge3(base, ours, theirs json.RawMessage, fields map[string]MergeType, conflict conflictFunc) (json.RawMessage, error) {
+	var b, o, t interface{}
+	for _, side := range []struct {
+		raw json.RawMessage
+		dst *interface{}
+	}{{base, &b}, {ours, &o}, {theirs, &t}} {
+		if len(side.raw) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(side.raw, side.dst); err != nil {
+			return nil, err
+		}
+	}
+
+	merged, _, err := mergeValue(nil, b, o, t, true, true, true, fields, conflict)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+// mergeValue merges one value; the *Present flags distinguish an absent key
+// from an explicit null. It returns the merged value and whether it exists.
+func mergeValue(path []string, base, ours, theirs interface{}, basePresent, oursPresent, theirsPresent bool, fields map[string]MergeType, conflict conflictFunc) (interface{}, bool, error) {
+	same := func(aPresent bool, a interface{}, bPresent bool, b interface{}) bool {
+		return aPresent == bPresent && reflect.DeepEqual(a, b)
+	}
+
+	switch {
+	case same(basePresent, base, oursPresent, ours):
+		return theirs, theirsPresent, nil
+	case same(basePresent, base, theirsPresent, theirs):
+		return ours, oursPresent, nil
+	}
+
+	// Merge types apply even when both sides agree: two identical
+	// increments are still two increments
+	if typ, ok := mergeTypeFor(path, fields); ok {
+		switch typ {
+		case CounterSum:
+			b, bOK := counterValue(base, basePresent)
+			o, oOK := counterValue(ours, oursPresent)
+			t, tOK := counterValue(theirs, theirsPresent)
+			if bOK && oOK && tOK {
+				return b + (o - b) + (t - b), true, nil
+			}
+		case SetUnion:
+			o, oOK := setValue(ours, oursPresent)
+			t, tOK := setValue(theirs, theirsPresent)
+			if oOK && tOK {
+				return unionSets(o, t), true, nil
+			}
+		}
+		return conflict(path, "values do not fit the field's merge type", ours, theirs, oursPresent, theirsPresent)
+	}
+
+	if same(oursPresent, ours, theirsPresent, theirs) {
+		return ours, oursPresent, nil
+	}
+
+	oMap, oIsMap := ours.(map[string]interface{})
+	tMap, tIsMap := theirs.(map[string]interface{})
+	bMap, bIsMap := base.(map[string]interface{})
+	if oIsMap && tIsMap && (bIsMap || !basePresent || base == nil) {
+		keys := make(map[string]struct{})
+		for _, m := range []map[string]interface{}{bMap, oMap, tMap} {
+			for k := range m {
+				keys[k] = struct{}{}
+			}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		out := make(map[string]interface{}, len(sorted))
+		for _, k := range sorted {
+			bv, bp := bMap[k]
+			ov, op := oMap[k]
+			tv, tp := tMap[k]
+			v, present, err := mergeValue(append(path, k), bv, ov, tv, bp, op, tp, fields, conflict)
+			if err != nil {
+				return nil, false, err
+			}
+			if present {
+				out[k] = v
+			}
+		}
+		return out, true, nil
+	}
+
+	return conflict(path, "", ours, theirs, oursPresent, theirsPresent)
+}
+
+func mergeTypeFor(path []string, fields map[string]MergeType) (MergeType, bool) {
+	for pattern, typ := range fields {
+		segments := strings.Split(pattern, ".")
+		if len(segments) != len(path) {
+			continue
+		}
+		match := true
+		for i, seg := range segments {
+			if seg != "*" && seg != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return typ, true
+		}
+	}
+	return 0, false
+}
+
+func counterValue(v interface{}, present bool) (float64, bool) {
+	if !present || v == nil {
+		return 0, true
+	}
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func setValue(v interface{}, present bool) ([]interface{}, bool) {
+	if !present || v == nil {
+		return nil, true
+	}
+	s, ok := v.([]interface{})
+	return s, ok
+}
+
+func unionSets(ours, theirs []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(ours)+len(theirs))
+	out = append(out, ours...)
+	for _, t := range theirs {
+		found := false
+		for _, o := range out {
+			if reflect.DeepEqual(o, t) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, t)
+		}
+	}
+	return out
+}
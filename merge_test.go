@@ -0,0 +1,77 @@
+package viracochan
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	fields := map[string]MergeType{
+		"counters.*": CounterSum,
+		"total":      CounterSum,
+		"tags":       SetUnion,
+	}
+
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflict           bool
+	}{
+		{
+			name: "disjoint keys",
+			base: `{"a":1,"b":1}`, ours: `{"a":2,"b":1}`, theirs: `{"a":1,"b":3}`,
+			want: `{"a":2,"b":3}`,
+		},
+		{
+			name: "counter increments sum",
+			base: `{"total":10}`, ours: `{"total":12}`, theirs: `{"total":15}`,
+			want: `{"total":17}`,
+		},
+		{
+			name: "wildcard counters including new keys",
+			base: `{"counters":{"w1":1}}`, ours: `{"counters":{"w1":2}}`, theirs: `{"counters":{"w1":2,"w2":1}}`,
+			want: `{"counters":{"w1":3,"w2":1}}`,
+		},
+		{
+			name: "set union",
+			base: `{"tags":["a"]}`, ours: `{"tags":["a","b"]}`, theirs: `{"tags":["a","c"]}`,
+			want: `{"tags":["a","b","c"]}`,
+		},
+		{
+			name: "deletion on one side",
+			base: `{"a":1,"b":1}`, ours: `{"a":1}`, theirs: `{"a":1,"b":1,"c":1}`,
+			want: `{"a":1,"c":1}`,
+		},
+		{
+			name: "plain field conflict",
+			base: `{"name":"x"}`, ours: `{"name":"y"}`, theirs: `{"name":"z"}`,
+			conflict: true,
+		},
+		{
+			name: "counter with non-numeric value",
+			base: `{"total":1}`, ours: `{"total":2}`, theirs: `{"total":"many"}`,
+			conflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Merge3(json.RawMessage(tt.base), json.RawMessage(tt.ours), json.RawMessage(tt.theirs), fields)
+			if tt.conflict {
+				if !errors.Is(err, ErrMergeConflict) {
+					t.Fatalf("expected ErrMergeConflict, got %s, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge3 failed: %v", err)
+			}
+			want, _ := CanonicalizeRaw(json.RawMessage(tt.want))
+			if have, _ := CanonicalizeRaw(got); string(have) != string(want) {
+				t.Errorf("got %s, want %s", have, want)
+			}
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package viracochan
 
 import (
-	"crypto/sha256"
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -16,6 +16,9 @@ var (
 	ErrChecksumMismatch = errors.New("checksum mismatch")
 	ErrInvalidChain     = errors.New("invalid chain")
 	ErrVersionConflict  = errors.New("version conflict")
+	ErrHistoryGap       = errors.New("history gap")
+	ErrExpired          = errors.New("config expired")
+	ErrSectionNotFound  = errors.New("section not found")
 )
 
 // Meta holds versioning and integrity metadata for configurations
@@ -26,6 +29,39 @@ type Meta struct {
 	CS        string    `json:"cs"`
 	Signature string    `json:"sig,omitempty"`
 	SigAlg    string    `json:"sig_alg,omitempty"`
+
+	// Annotations carry free-form per-version metadata (ticket id, deploy
+	// reason, ...). They are excluded from the checksum but bound by the
+	// signature, so on signed configs they are tamper-evident and must be
+	// treated as immutable once the version is written.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ExpiresAt is the optional instant after which this version is no
+	// longer served as current. Unlike annotations it is covered by the
+	// checksum (and therefore the signature); configs without an expiry keep
+	// their existing checksums because the field is omitted when nil.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// HashAlg names the checksum algorithm; empty means SHA-256. It is part
+	// of the checksum input, so a config cannot be reinterpreted under a
+	// different algorithm, and it carries over to the next version.
+	HashAlg HashAlgorithm `json:"hash_alg,omitempty"`
+
+	// ContentType names the document type of the content; empty means JSON.
+	// Non-JSON documents (e.g. YAML) are held as a JSON string. Like HashAlg
+	// it is checksummed and carries over to the next version.
+	ContentType string `json:"content_type,omitempty"`
+
+	// MergeParents holds the checksums of the two heads a Reconcile merged:
+	// the local head, which is also PrevCS, and the other chain's. It is
+	// checksummed like ExpiresAt and does not carry over to the next version.
+	MergeParents []string `json:"merge_parents,omitempty"`
+
+	// Countersignatures hold timestamp authorities' attestations of when the
+	// version was signed (see Manager.Countersign). They are added after
+	// the version is written, so they are outside both the checksum and the
+	// signature; each one verifies on its own against its authority.
+	Countersignatures []Countersignature `json:"countersigs,omitempty"`
 }
 
 // Config represents a configuration with metadata and arbitrary content
@@ -34,26 +70,40 @@ type Config struct {
 	Content json.RawMessage `json:"content"`
 }
 
-// computeChecksum computes SHA-256 hex checksum over canonical JSON
+// computeChecksum computes the hex checksum over canonical JSON using the
+// config's hash algorithm
 func computeChecksum(c *Config) (string, error) {
 	tmp := *c
 	tmp.Meta.CS = ""
 	tmp.Meta.Signature = ""
 	tmp.Meta.SigAlg = ""
+	tmp.Meta.Annotations = nil
+	tmp.Meta.Countersignatures = nil
 
 	canonical, err := canonicalJSON(&tmp)
 	if err != nil {
 		return "", err
 	}
 
-	// Append timestamp to canonical bytes (following MVPChain pattern)
-	ts := tmp.Meta.Time.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
-	buf := make([]byte, 0, len(canonical)+len(ts))
-	buf = append(buf, canonical...)
-	buf = append(buf, []byte(ts)...)
+	// Hash the timestamp after the canonical bytes (following MVPChain
+	// pattern), streamed rather than copied into one buffer
+	h, err := newDigest(tmp.Meta.HashAlg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(canonical)
+	h.Write([]byte(tmp.Meta.Time.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	sum := sha256.Sum256(buf)
-	return hex.EncodeToString(sum[:]), nil
+// rehash sets c's checksum
+func (c *Config) rehash() error {
+	cs, err := computeChecksum(c)
+	if err != nil {
+		return err
+	}
+	c.Meta.CS = cs
+	return nil
 }
 
 // Validate recomputes checksum and verifies integrity
@@ -70,6 +120,11 @@ func (c *Config) Validate() error {
 
 // NextOf checks that c is immediate successor of prev
 func (c *Config) NextOf(prev *Config) error {
+	return c.nextOf(prev, 0)
+}
+
+// nextOf is NextOf allowing c's timestamp to be up to skew before prev's
+func (c *Config) nextOf(prev *Config, skew time.Duration) error {
 	if prev == nil {
 		return errors.New("previous config is nil")
 	}
@@ -79,7 +134,7 @@ func (c *Config) NextOf(prev *Config) error {
 	if c.Meta.PrevCS != prev.Meta.CS {
 		return fmt.Errorf("chain break: prev_cs=%s != cs=%s", c.Meta.PrevCS, prev.Meta.CS)
 	}
-	if c.Meta.Time.Before(prev.Meta.Time) {
+	if timeRegressed(c.Meta.Time, prev.Meta.Time, skew) {
 		return fmt.Errorf("timestamp regression: %s < %s", c.Meta.Time, prev.Meta.Time)
 	}
 	if err := prev.Validate(); err != nil {
@@ -91,21 +146,140 @@ func (c *Config) NextOf(prev *Config) error {
 	return nil
 }
 
-// UpdateMeta updates metadata for new version
+// Equal reports whether c and other have the same canonical content and the
+// same version, checksum, previous checksum and signature. Timestamps are not
+// compared directly, as the checksum binds them; the signature is outside the
+// checksum, so a version signed twice, or once and not at all, is not Equal.
+func (c *Config) Equal(other *Config) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if c.Meta.Version != other.Meta.Version ||
+		c.Meta.CS != other.Meta.CS ||
+		c.Meta.PrevCS != other.Meta.PrevCS ||
+		c.Meta.Signature != other.Meta.Signature ||
+		c.Meta.SigAlg != other.Meta.SigAlg {
+		return false
+	}
+	return c.ContentEqual(other)
+}
+
+// ContentEqual reports whether c and other carry the same canonical content,
+// ignoring all metadata. Key ordering and numeric representation differences
+// do not affect the result.
+func (c *Config) ContentEqual(other *Config) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	a, err := canonicalContent(c.Content)
+	if err != nil {
+		return false
+	}
+	b, err := canonicalContent(other.Content)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
+
+// ContentEquivalent reports whether a and b hold the same data regardless
+// of lineage: their canonical content is compared and all metadata,
+// versions, timestamps and checksums included, is ignored. It is
+// Config.ContentEqual as a function, e.g. for slices.EqualFunc over two
+// histories, and is the check for content that went through a migration or
+// ImportAsNew, whose checksums differ by design.
+func ContentEquivalent(a, b *Config) bool {
+	return a.ContentEqual(b)
+}
+
+// Expired reports whether c carries an expiry that is not after now
+func (c *Config) Expired(now time.Time) bool {
+	return c.Meta.ExpiresAt != nil && !now.Before(*c.Meta.ExpiresAt)
+}
+
+// Section returns the raw JSON of the top-level key of c's content without
+// decoding the rest: other values are scanned over, not unmarshaled. Content
+// must be a JSON object; an absent key returns ErrSectionNotFound. As with
+// json.Unmarshal, the last occurrence of a duplicated key wins.
+func (c *Config) Section(key string) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(c.Content))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("content is not a JSON object: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("content is not a JSON object")
+	}
+
+	var section json.RawMessage
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if tok.(string) == key {
+			section = value
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	if section == nil {
+		return nil, fmt.Errorf("%w: %q", ErrSectionNotFound, key)
+	}
+	return section, nil
+}
+
+// canonicalContent produces canonical JSON for raw content; empty content is
+// treated as JSON null
+func canonicalContent(content json.RawMessage) ([]byte, error) {
+	if len(content) == 0 {
+		return []byte("null"), nil
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		return nil, err
+	}
+	return canonicalJSON(parsed)
+}
+
+// UpdateMeta updates metadata for new version. The new timestamp is the
+// wall clock, but never earlier than the previous version's or than any
+// timestamp this process issued before, so a clock stepped backwards cannot
+// break the chain's timestamp order.
 func (c *Config) UpdateMeta() error {
-	c.Meta.Time = time.Now().UTC().Truncate(time.Microsecond)
+	return c.updateMeta(versionClock.stamp(c.Meta.Time))
+}
+
+// UpdateMetaAt is UpdateMeta stamping the new version with t instead of the
+// clock, for fixtures and imports that need chosen timestamps. t is kept to
+// the microsecond and must not precede the previous version's timestamp.
+func (c *Config) UpdateMetaAt(t time.Time) error {
+	t = t.UTC().Truncate(time.Microsecond)
+	if t.Before(c.Meta.Time) {
+		return fmt.Errorf("timestamp %s precedes the previous version's %s", t.Format(time.RFC3339Nano), c.Meta.Time.Format(time.RFC3339Nano))
+	}
+	return c.updateMeta(t)
+}
+
+func (c *Config) updateMeta(t time.Time) error {
+	c.Meta.Time = t
 	c.Meta.Version++
 	c.Meta.PrevCS = c.Meta.CS
 	c.Meta.CS = ""
 	c.Meta.Signature = ""
 	c.Meta.SigAlg = ""
+	c.Meta.Annotations = nil
+	c.Meta.ExpiresAt = nil
+	c.Meta.MergeParents = nil
+	c.Meta.Countersignatures = nil
 
-	cs, err := computeChecksum(c)
-	if err != nil {
-		return err
-	}
-	c.Meta.CS = cs
-	return nil
+	return c.rehash()
 }
 
 // MarshalJSON implements custom JSON marshaling with automatic metadata update
@@ -126,6 +300,22 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// CanonicalJSON returns the canonical JSON encoding viracochan uses when
+// computing checksums: object keys sorted, no insignificant whitespace and
+// timestamps in RFC3339 with microsecond precision. The output format is part
+// of the checksum contract and is kept stable across releases, so it can be
+// used to build external content-address indexes or to pre-normalize content.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	return canonicalJSON(v)
+}
+
+// CanonicalizeRaw parses raw JSON and re-encodes it in canonical form. Empty
+// input is treated as JSON null. Numbers are decoded as float64, so integers
+// beyond 2^53 lose precision exactly as they do in ContentEqual.
+func CanonicalizeRaw(raw json.RawMessage) ([]byte, error) {
+	return canonicalContent(raw)
+}
+
 // canonicalJSON produces deterministic JSON with sorted keys
 func canonicalJSON(v interface{}) ([]byte, error) {
 	normalized, err := normalizeValue(reflect.ValueOf(v))
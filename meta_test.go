@@ -1,8 +1,13 @@
 package viracochan
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"slices"
 	"testing"
 	"time"
 )
@@ -117,6 +122,28 @@ func TestTimestampRegression(t *testing.T) {
 	}
 }
 
+func TestUpdateMetaAt(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 123456789, time.FixedZone("", 3600))
+	cfg := &Config{Content: json.RawMessage(`{"n":1}`)}
+	if err := cfg.UpdateMetaAt(at); err != nil {
+		t.Fatalf("UpdateMetaAt failed: %v", err)
+	}
+	if want := at.UTC().Truncate(time.Microsecond); !cfg.Meta.Time.Equal(want) || cfg.Meta.Version != 1 {
+		t.Errorf("got v%d at %s, want v1 at %s", cfg.Meta.Version, cfg.Meta.Time, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("stamped config invalid: %v", err)
+	}
+
+	next := &Config{Meta: cfg.Meta, Content: json.RawMessage(`{"n":2}`)}
+	if err := next.UpdateMetaAt(at.Add(-time.Second)); err == nil {
+		t.Error("expected an earlier timestamp to be rejected")
+	}
+	if err := next.UpdateMetaAt(at.Add(time.Second)); err != nil || next.NextOf(cfg) != nil {
+		t.Errorf("v2 does not follow v1: %v", err)
+	}
+}
+
 func TestCanonicalJSON(t *testing.T) {
 	data := map[string]interface{}{
 		"z": "last",
@@ -148,6 +175,32 @@ func TestCanonicalJSON(t *testing.T) {
 	}
 }
 
+func TestCanonicalizeRaw(t *testing.T) {
+	got, err := CanonicalizeRaw(json.RawMessage(`{ "b": [1, 2], "a": {"d": true, "c": null} }`))
+	if err != nil {
+		t.Fatalf("CanonicalizeRaw failed: %v", err)
+	}
+	expected := `{"a":{"c":null,"d":true},"b":[1,2]}`
+	if string(got) != expected {
+		t.Errorf("Unexpected canonical JSON:\nGot:      %s\nExpected: %s", got, expected)
+	}
+
+	exported, err := CanonicalJSON(map[string]interface{}{"b": []int{1, 2}, "a": map[string]interface{}{"d": true, "c": nil}})
+	if err != nil {
+		t.Fatalf("CanonicalJSON failed: %v", err)
+	}
+	if string(exported) != expected {
+		t.Errorf("CanonicalJSON and CanonicalizeRaw disagree: %s vs %s", exported, got)
+	}
+
+	if empty, _ := CanonicalizeRaw(nil); string(empty) != "null" {
+		t.Errorf("expected empty input to canonicalize to null, got %s", empty)
+	}
+	if _, err := CanonicalizeRaw(json.RawMessage(`{bad`)); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+}
+
 func TestVersionChain(t *testing.T) {
 	configs := make([]*Config, 10)
 
@@ -183,3 +236,146 @@ func TestVersionChain(t *testing.T) {
 		}
 	}
 }
+
+func TestContentEquivalent(t *testing.T) {
+	a, _ := newConfig(map[string]interface{}{"port": 8080, "tags": []string{"x"}}, "")
+	b := &Config{Content: json.RawMessage(`{"tags":["x"],"port":8080.0}`)}
+	for i := 0; i < 3; i++ {
+		if err := b.UpdateMeta(); err != nil {
+			t.Fatalf("UpdateMeta failed: %v", err)
+		}
+	}
+
+	if a.Meta.CS == b.Meta.CS || !ContentEquivalent(a, b) {
+		t.Error("configs of different lineages with the same content should be equivalent")
+	}
+	if ContentEquivalent(a, &Config{Content: json.RawMessage(`{"port":8081,"tags":["x"]}`)}) {
+		t.Error("different content should not be equivalent")
+	}
+	if !ContentEquivalent(nil, nil) || ContentEquivalent(a, nil) || ContentEquivalent(nil, a) {
+		t.Error("nil configs are equivalent only to each other")
+	}
+
+	if !slices.EqualFunc([]*Config{a, b}, []*Config{b, a}, ContentEquivalent) {
+		t.Error("ContentEquivalent should work as a slices.EqualFunc comparator")
+	}
+}
+
+func TestConfigEqual(t *testing.T) {
+	cfg1 := &Config{
+		Content: json.RawMessage(`{"b": 1, "a": {"y": 2.0, "x": "s"}}`),
+	}
+	if err := cfg1.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+
+	reordered := &Config{
+		Meta:    cfg1.Meta,
+		Content: json.RawMessage(`{"a":{"x":"s","y":2},"b":1.0}`),
+	}
+	if !cfg1.ContentEqual(reordered) {
+		t.Error("ContentEqual should ignore key order and numeric representation")
+	}
+	if !cfg1.Equal(reordered) {
+		t.Error("Equal should hold for identical meta and canonical content")
+	}
+
+	next := &Config{
+		Meta:    cfg1.Meta,
+		Content: cfg1.Content,
+	}
+	if err := next.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	if !cfg1.ContentEqual(next) {
+		t.Error("ContentEqual should ignore metadata")
+	}
+	if cfg1.Equal(next) {
+		t.Error("Equal should fail for different versions")
+	}
+
+	changed := &Config{
+		Meta:    cfg1.Meta,
+		Content: json.RawMessage(`{"a":{"x":"s","y":3},"b":1}`),
+	}
+	if cfg1.ContentEqual(changed) || cfg1.Equal(changed) {
+		t.Error("different content must not compare equal")
+	}
+
+	signer, _ := NewSigner()
+	signed := &Config{Meta: cfg1.Meta, Content: cfg1.Content}
+	if err := signer.Sign(signed); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if signed.Meta.CS != cfg1.Meta.CS || cfg1.Equal(signed) {
+		t.Error("a signed copy keeps the checksum but must not compare equal")
+	}
+	other, _ := NewSigner()
+	resigned := &Config{Meta: cfg1.Meta, Content: cfg1.Content}
+	other.Sign(resigned)
+	if signed.Equal(resigned) {
+		t.Error("versions signed by different keys must not compare equal")
+	}
+
+	broken := &Config{Meta: cfg1.Meta, Content: json.RawMessage(`{broken`)}
+	if cfg1.ContentEqual(broken) {
+		t.Error("malformed content must not compare equal")
+	}
+
+	var nilCfg *Config
+	if nilCfg.Equal(cfg1) || cfg1.Equal(nil) {
+		t.Error("nil config must not equal a non-nil config")
+	}
+	if !nilCfg.Equal(nil) {
+		t.Error("two nil configs should compare equal")
+	}
+}
+
+func TestRegisterHashAlgorithm(t *testing.T) {
+	const fnvAlg HashAlgorithm = "test-fnv128a"
+	if err := RegisterHashAlgorithm(fnvAlg, func() hash.Hash { return fnv.New128a() }); err != nil {
+		t.Fatalf("RegisterHashAlgorithm failed: %v", err)
+	}
+	defer func() {
+		hashMu.Lock()
+		delete(hashAlgorithms, fnvAlg)
+		hashMu.Unlock()
+	}()
+
+	cfg := &Config{Meta: Meta{HashAlg: fnvAlg}, Content: json.RawMessage(`{"a":1}`)}
+	if err := cfg.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	if len(cfg.Meta.CS) != 32 {
+		t.Errorf("expected 128-bit checksum, got %q", cfg.Meta.CS)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+
+	if err := RegisterHashAlgorithm(HashSHA256, sha256.New); err == nil {
+		t.Error("expected built-in algorithm to be protected")
+	}
+}
+
+func TestConfigSection(t *testing.T) {
+	cfg := &Config{Content: json.RawMessage(`{"db":{"host":"x","pool":[1,2]},"cache":{"ttl":5},"flag":true}`)}
+
+	section, err := cfg.Section("cache")
+	if err != nil || string(section) != `{"ttl":5}` {
+		t.Errorf("expected cache section, got %s, %v", section, err)
+	}
+	if section, _ := cfg.Section("flag"); string(section) != "true" {
+		t.Errorf("expected scalar section, got %s", section)
+	}
+	if _, err := cfg.Section("missing"); !errors.Is(err, ErrSectionNotFound) {
+		t.Errorf("expected ErrSectionNotFound, got %v", err)
+	}
+
+	for _, content := range []string{`[1,2]`, `"text"`, ``, `{"a":1`} {
+		cfg := &Config{Content: json.RawMessage(content)}
+		if _, err := cfg.Section("a"); err == nil || errors.Is(err, ErrSectionNotFound) {
+			t.Errorf("expected invalid content error for %q, got %v", content, err)
+		}
+	}
+}
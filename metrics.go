@@ -0,0 +1,44 @@
+package viracochan
+
+// Metric names reported by the library. Labels are passed as alternating
+// key/value pairs and never include config ids, keeping cardinality bounded.
+const (
+	MetricStorageRetries  = "storage_retries_total"
+	MetricAutoCompactions = "auto_compactions_total"
+
+	// MetricOperations counts manager calls with an "op" label and a
+	// "result" label of "ok" or "error". Every write is counted (create,
+	// create_or_get, create_batch, create_from_base, update, merge_update,
+	// rollback, rollback_to_snapshot, reset, squash, promote, countersign,
+	// submit, reconcile, import, import_as_new, import_legacy,
+	// import_history, import_objects, set_schema_lock, create_snapshot,
+	// materialize, migrate_signatures, compact, compact_to_blobs,
+	// expire_sweep, recover), as are get, get_latest, get_latest_fresh and
+	// reconstruct
+	MetricOperations = "operations_total"
+	// MetricOperationDuration observes the latency in seconds of the calls
+	// counted by MetricOperations, labelled by "op"
+	MetricOperationDuration = "operation_duration_seconds"
+	// MetricChainValidationFailures counts chains found broken by
+	// ValidateChain, ValidateChainFrom and Submit, labelled by "op"
+	MetricChainValidationFailures = "chain_validation_failures_total"
+	// MetricStoreBytes and MetricConfigs are set by a whole-store Usage call
+	// to the store's total size and its number of config ids
+	MetricStoreBytes = "store_size_bytes"
+	MetricConfigs    = "configs"
+)
+
+// Metrics receives operational measurements from the library. Implementations
+// must be safe for concurrent use.
+type Metrics interface {
+	IncCounter(name string, delta float64, labels ...string)
+	ObserveHistogram(name string, value float64, labels ...string)
+	SetGauge(name string, value float64, labels ...string)
+}
+
+// NopMetrics discards all measurements
+type NopMetrics struct{}
+
+func (NopMetrics) IncCounter(string, float64, ...string)       {}
+func (NopMetrics) ObserveHistogram(string, float64, ...string) {}
+func (NopMetrics) SetGauge(string, float64, ...string)         {}
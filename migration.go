@@ -46,7 +46,7 @@ func MigrateLegacyConfig(cfg *Config, signer *Signer) error {
 	if cfg.Meta.Signature == "" {
 		return nil
 	}
-	if cfg.Meta.SigAlg == SignatureAlgorithmV2 {
+	if isNativeSignatureAlgorithm(cfg.Meta.SigAlg) {
 		return nil
 	}
 	if cfg.Meta.SigAlg != "" {
@@ -62,12 +62,18 @@ func MigrateLegacyConfig(cfg *Config, signer *Signer) error {
 
 // MigrateLegacySignatures upgrades legacy signatures in the manager's config
 // store and journal to the v0.2.0 native signing scheme.
-func (m *Manager) MigrateLegacySignatures(ctx context.Context, opts SignatureMigrationOptions) (*SignatureMigrationReport, error) {
+func (m *Manager) MigrateLegacySignatures(ctx context.Context, opts SignatureMigrationOptions) (report *SignatureMigrationReport, err error) {
+	ctx, call := m.begin(ctx, "migrate_signatures", "")
+	defer func() { call.end(nil, err) }()
+
+	if m.isClosed() {
+		return nil, ErrClosed
+	}
 	if m.signer == nil {
 		return nil, errors.New("no signer configured")
 	}
 
-	report := &SignatureMigrationReport{}
+	report = &SignatureMigrationReport{}
 	migratedByCS := make(map[string]Meta)
 
 	paths, err := m.storage.List(ctx, m.configStore.prefix)
@@ -155,7 +161,7 @@ func (m *Manager) MigrateLegacySignatures(ctx context.Context, opts SignatureMig
 	}
 
 	if !opts.DryRun {
-		m.cache = make(map[string]*Config)
+		m.cacheReset()
 	}
 
 	return report, nil
@@ -182,7 +188,7 @@ func migrateConfigStatus(cfg *Config, signer *Signer) (migrationStatus, error) {
 	if cfg.Meta.Signature == "" {
 		return migrationStatusUnsigned, nil
 	}
-	if cfg.Meta.SigAlg == SignatureAlgorithmV2 {
+	if isNativeSignatureAlgorithm(cfg.Meta.SigAlg) {
 		if err := signer.Verify(cfg, signer.PublicKey()); err != nil {
 			return 0, fmt.Errorf("current signature invalid: %w", err)
 		}
@@ -204,7 +210,7 @@ func migrateJournalEntry(entry *JournalEntry, signer *Signer, migratedByCS map[s
 	if cfg.Meta.Signature == "" {
 		return migrationStatusUnsigned, nil
 	}
-	if cfg.Meta.SigAlg == SignatureAlgorithmV2 {
+	if isNativeSignatureAlgorithm(cfg.Meta.SigAlg) {
 		if err := signer.Verify(cfg, signer.PublicKey()); err != nil {
 			return 0, fmt.Errorf("current signature invalid: %w", err)
 		}
@@ -317,12 +323,7 @@ func loadConfigAtPath(ctx context.Context, storage Storage, path string) (*Confi
 		return nil, err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, err
-	}
-
-	return &cfg, nil
+	return decodeConfigFile(ctx, storage, path, data)
 }
 
 func writeConfigAtPath(ctx context.Context, storage Storage, path string, cfg *Config) error {
@@ -64,15 +64,7 @@ func TestManagerMigrateLegacySignatures(t *testing.T) {
 		t.Fatalf("Save failed: %v", err)
 	}
 
-	entry := &JournalEntry{
-		ID:        "legacy-config",
-		Version:   cfg.Meta.Version,
-		CS:        cfg.Meta.CS,
-		PrevCS:    cfg.Meta.PrevCS,
-		Time:      cfg.Meta.Time,
-		Operation: "create",
-		Config:    cloneConfig(cfg),
-	}
+	entry := NewJournalEntry("legacy-config", "create", cloneConfig(cfg))
 	if err := manager.journal.Append(ctx, entry); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
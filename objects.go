@@ -0,0 +1,185 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ObjectRefs is the key of the refs object in an ExportObjects map. It can
+// never collide with a checksum, which is lowercase hex.
+const ObjectRefs = "refs"
+
+// ExportObjects returns the full history of id as content-addressed objects:
+// each version's JSON keyed by its checksum, plus an ObjectRefs object that
+// maps version numbers to checksums ({"1":"<cs>","2":"<cs>",...}). The
+// objects can be kept in any content-addressable store, since each one
+// verifies against its own key, and rebuilt with ImportObjects.
+func (m *Manager) ExportObjects(ctx context.Context, id string) (map[string][]byte, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("config %q: %w", id, os.ErrNotExist)
+	}
+
+	objects := make(map[string][]byte, len(versions)+1)
+	refs := make(map[uint64]string, len(versions))
+	for _, v := range versions {
+		cfg, err := m.configStore.Load(ctx, id, v)
+		if err != nil {
+			return nil, fmt.Errorf("config %q version %d: %w", id, v, err)
+		}
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		objects[cfg.Meta.CS] = data
+		refs[v] = cfg.Meta.CS
+	}
+
+	data, err := json.Marshal(refs)
+	if err != nil {
+		return nil, err
+	}
+	objects[ObjectRefs] = data
+	return objects, nil
+}
+
+// ImportObjects rebuilds the history of id from objects produced by
+// ExportObjects. Every object named by the refs must be present, validate,
+// carry the checksum it is keyed by and the version it is listed under, and
+// the versions must run contiguously from 1 with each one linking to the
+// previous. Nothing is written unless the whole history checks out; id must
+// not exist yet. Objects not named by the refs are ignored.
+func (m *Manager) ImportObjects(ctx context.Context, id string, objects map[string][]byte) (err error) {
+	ctx, call := m.begin(ctx, "import_objects", id)
+	defer func() { call.end(nil, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+
+	var refs map[uint64]string
+	data, ok := objects[ObjectRefs]
+	if !ok {
+		return errors.New("import objects: missing refs object")
+	}
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return fmt.Errorf("import objects: refs: %w", err)
+	}
+	if len(refs) == 0 {
+		return errors.New("import objects: refs list no versions")
+	}
+
+	versions := make([]uint64, 0, len(refs))
+	for v := range refs {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	configs := make([]*Config, 0, len(versions))
+	for i, v := range versions {
+		if v != uint64(i)+1 {
+			return fmt.Errorf("%w: import objects: expected version %d, refs list %d", ErrHistoryGap, i+1, v)
+		}
+		cs := refs[v]
+		data, ok := objects[cs]
+		if !ok {
+			return fmt.Errorf("import objects: version %d: object %s missing", v, cs)
+		}
+
+		var cfg Config
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("import objects: version %d: %w", v, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("import objects: version %d: %w", v, err)
+		}
+		if cfg.Meta.CS != cs || cfg.Meta.Version != v {
+			return fmt.Errorf("%w: import objects: object %s is not version %d", ErrChecksumMismatch, cs, v)
+		}
+		if i == 0 && cfg.Meta.PrevCS != "" {
+			return fmt.Errorf("%w: import objects: version 1 has a previous checksum", ErrInvalidChain)
+		}
+		if i > 0 {
+			if err := cfg.nextOf(configs[i-1], m.clockSkew); err != nil {
+				return fmt.Errorf("import objects: version %d: %w", v, err)
+			}
+		}
+		if err := m.configStore.verifySignature(&cfg); err != nil {
+			return fmt.Errorf("import objects: version %d: %w", v, err)
+		}
+		if err := m.checkSigned(&cfg); err != nil {
+			return fmt.Errorf("import objects: version %d: %w", v, err)
+		}
+		configs = append(configs, &cfg)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	if err := m.checkNew(ctx, id); err != nil {
+		return err
+	}
+	return m.writeChain(ctx, id, configs, "import")
+}
+
+// writeChain writes configs, consecutive versions that continue the history
+// of id, as one intent and one journal batch under op. Callers hold mu.
+func (m *Manager) writeChain(ctx context.Context, id string, configs []*Config, op string) error {
+	writes := make([]IntentWrite, 0, len(configs))
+	for _, cfg := range configs {
+		writes = append(writes, intentWrite(id, cfg, op, ""))
+	}
+	if err := m.checkFence(ctx); err != nil {
+		return err
+	}
+	if err := m.checkJournalCap(ctx, len(writes)); err != nil {
+		return err
+	}
+	tx, err := m.beginIntent(ctx, writes)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]*JournalEntry, 0, len(configs))
+	for _, cfg := range configs {
+		if err := m.configStore.Save(ctx, id, cfg); err != nil {
+			return err
+		}
+		entries = append(entries, m.journalEntry(id, cfg, op))
+	}
+	if err := m.journal.AppendBatch(ctx, entries); err != nil {
+		return err
+	}
+	if err := m.commitIntent(ctx, tx); err != nil {
+		return err
+	}
+
+	m.cachePut(id, configs[len(configs)-1])
+	for _, entry := range entries {
+		m.indexPut(entry)
+	}
+	m.noteWrites(len(entries))
+	return nil
+}
@@ -0,0 +1,83 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestExportImportObjects(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	source, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+	source.Create(ctx, "app", map[string]interface{}{"n": 1})
+	source.Update(ctx, "app", map[string]interface{}{"n": 2})
+	latest, _ := source.Update(ctx, "app", map[string]interface{}{"n": 3})
+
+	objects, err := source.ExportObjects(ctx, "app")
+	if err != nil {
+		t.Fatalf("ExportObjects failed: %v", err)
+	}
+	if len(objects) != 4 {
+		t.Fatalf("expected 3 versions plus refs, got %d objects", len(objects))
+	}
+	var refs map[string]string
+	if err := json.Unmarshal(objects[ObjectRefs], &refs); err != nil || refs["3"] != latest.Meta.CS {
+		t.Fatalf("unexpected refs %s, %v", objects[ObjectRefs], err)
+	}
+
+	target, _ := NewManager(NewMemoryStorage())
+	if err := target.ImportObjects(ctx, "app", objects); err != nil {
+		t.Fatalf("ImportObjects failed: %v", err)
+	}
+	got, err := target.Reconstruct(ctx, "app")
+	if err != nil || !got.Equal(latest) {
+		t.Fatalf("expected imported latest to equal source, got %v, %v", got, err)
+	}
+	if err := target.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("imported chain invalid: %v", err)
+	}
+	if err := signer.Verify(got, signer.PublicKey()); err != nil {
+		t.Errorf("imported version lost its signature: %v", err)
+	}
+
+	if err := target.ImportObjects(ctx, "app", objects); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict for existing id, got %v", err)
+	}
+}
+
+func TestImportObjectsRejectsBrokenHistory(t *testing.T) {
+	ctx := context.Background()
+	source, _ := NewManager(NewMemoryStorage())
+	v1, _ := source.Create(ctx, "app", map[string]interface{}{"n": 1})
+	v2, _ := source.Update(ctx, "app", map[string]interface{}{"n": 2})
+	objects, _ := source.ExportObjects(ctx, "app")
+
+	clone := func() map[string][]byte {
+		c := make(map[string][]byte, len(objects))
+		for k, v := range objects {
+			c[k] = v
+		}
+		return c
+	}
+
+	swapped := clone()
+	swapped[v1.Meta.CS], swapped[v2.Meta.CS] = objects[v2.Meta.CS], objects[v1.Meta.CS]
+
+	missing := clone()
+	delete(missing, v1.Meta.CS)
+
+	gap := clone()
+	gap[ObjectRefs] = []byte(`{"2":"` + v2.Meta.CS + `"}`)
+
+	for name, objs := range map[string]map[string][]byte{"swapped": swapped, "missing": missing, "gap": gap} {
+		target, _ := NewManager(NewMemoryStorage())
+		if err := target.ImportObjects(ctx, "app", objs); err == nil {
+			t.Errorf("%s: expected import to fail", name)
+		}
+		if ids, _ := target.List(ctx); len(ids) != 0 {
+			t.Errorf("%s: failed import wrote %v", name, ids)
+		}
+	}
+}
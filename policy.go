@@ -0,0 +1,131 @@
+package viracochan
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrPolicyViolation is returned when a write fails a critical policy
+var ErrPolicyViolation = errors.New("policy violation")
+
+// PolicyFunc checks a config and explains the outcome in detail
+type PolicyFunc func(cfg *Config) (pass bool, detail string)
+
+// Policy is a named content or metadata rule, installed with WithPolicies
+type Policy struct {
+	Name  string
+	Check PolicyFunc
+	// Critical policies reject a write they fail; the others only log a
+	// warning and show up in EvaluatePolicies
+	Critical bool
+}
+
+// PolicyResult is the outcome of one policy for one config
+type PolicyResult struct {
+	Pass     bool   `json:"pass"`
+	Detail   string `json:"detail,omitempty"`
+	Critical bool   `json:"critical,omitempty"`
+}
+
+// WithPolicies evaluates policies on every create, update, merge and rollback
+// (and on each config of CreateBatch, RollbackToSnapshot and Reset), after
+// the new version is sealed and signed but before it is stored. A failing
+// critical policy rejects the write with ErrPolicyViolation; other failures
+// are logged. Imports are not checked. Names must be unique and non-empty.
+func WithPolicies(policies []Policy) ManagerOption {
+	return func(m *Manager) error {
+		seen := make(map[string]bool, len(policies))
+		for _, p := range policies {
+			if p.Name == "" || p.Check == nil {
+				return errors.New("policy needs a name and a check")
+			}
+			if seen[p.Name] {
+				return fmt.Errorf("duplicate policy %q", p.Name)
+			}
+			seen[p.Name] = true
+		}
+		m.policies = append([]Policy(nil), policies...)
+		return nil
+	}
+}
+
+// EvaluatePolicies runs the manager's policies on cfg, keyed by policy name.
+// It does not touch storage, so it also works for reports over history.
+func (m *Manager) EvaluatePolicies(cfg *Config) map[string]PolicyResult {
+	results := make(map[string]PolicyResult, len(m.policies))
+	for _, p := range m.policies {
+		pass, detail := p.Check(cfg)
+		results[p.Name] = PolicyResult{Pass: pass, Detail: detail, Critical: p.Critical}
+	}
+	return results
+}
+
+// checkPolicies evaluates the policies on the sealed cfg, rejecting it if a
+// critical one fails
+func (m *Manager) checkPolicies(id string, cfg *Config) error {
+	if len(m.policies) == 0 {
+		return nil
+	}
+
+	var failed []string
+	for name, result := range m.EvaluatePolicies(cfg) {
+		if result.Pass {
+			continue
+		}
+		if !result.Critical {
+			m.logger.Warn("policy failed", "id", id, "version", cfg.Meta.Version, "policy", name, "detail", result.Detail)
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%s: %s", name, result.Detail))
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%w: config %q: %s", ErrPolicyViolation, id, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// HasSignaturePolicy passes signed configs
+func HasSignaturePolicy() Policy {
+	return Policy{Name: "has_signature", Check: func(cfg *Config) (bool, string) {
+		if cfg.Meta.Signature == "" {
+			return false, "config is unsigned"
+		}
+		return true, ""
+	}}
+}
+
+// ValidChecksumPolicy passes configs whose checksum matches their content
+func ValidChecksumPolicy() Policy {
+	return Policy{Name: "valid_checksum", Check: func(cfg *Config) (bool, string) {
+		if err := cfg.Validate(); err != nil {
+			return false, err.Error()
+		}
+		return true, ""
+	}}
+}
+
+// RecentUpdatePolicy passes configs written within maxAge. Every new write
+// passes it; it is meant for reports over stored versions.
+func RecentUpdatePolicy(maxAge time.Duration) Policy {
+	return Policy{Name: "recent_update", Check: func(cfg *Config) (bool, string) {
+		if age := time.Since(cfg.Meta.Time); age >= maxAge {
+			return false, fmt.Sprintf("last written %s ago", age.Round(time.Second))
+		}
+		return true, ""
+	}}
+}
+
+// VersionContinuityPolicy passes configs with a version in the chain (v1 or
+// later)
+func VersionContinuityPolicy() Policy {
+	return Policy{Name: "version_continuity", Check: func(cfg *Config) (bool, string) {
+		if cfg.Meta.Version == 0 {
+			return false, "config has no version"
+		}
+		return true, ""
+	}}
+}
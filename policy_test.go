@@ -0,0 +1,66 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	logger := &recordingLogger{}
+	noDebug := Policy{Name: "no_debug", Critical: true, Check: func(cfg *Config) (bool, string) {
+		var content map[string]interface{}
+		json.Unmarshal(cfg.Content, &content)
+		if content["debug"] == true {
+			return false, "debug enabled"
+		}
+		return true, ""
+	}}
+	manager, err := NewManager(NewMemoryStorage(), WithLogger(logger), WithPolicies([]Policy{
+		noDebug, HasSignaturePolicy(), ValidChecksumPolicy(), RecentUpdatePolicy(time.Hour), VersionContinuityPolicy(),
+	}))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	cfg, err := manager.Create(ctx, "app", map[string]interface{}{"debug": false})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	results := manager.EvaluatePolicies(cfg)
+	if len(results) != 5 || !results["no_debug"].Pass || !results["recent_update"].Pass {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if r := results["has_signature"]; r.Pass || r.Critical || r.Detail == "" {
+		t.Errorf("expected a non-critical signature failure, got %+v", r)
+	}
+	if len(logger.warns) != 1 || !strings.Contains(logger.warns[0], "policy failed") {
+		t.Errorf("expected one warning for the unsigned write, got %v", logger.warns)
+	}
+
+	_, err = manager.Update(ctx, "app", map[string]interface{}{"debug": true})
+	if !errors.Is(err, ErrPolicyViolation) || !strings.Contains(err.Error(), "no_debug: debug enabled") {
+		t.Fatalf("expected ErrPolicyViolation, got %v", err)
+	}
+	if latest, _ := manager.GetLatest(ctx, "app"); latest.Meta.Version != 1 {
+		t.Errorf("rejected write was stored: v%d", latest.Meta.Version)
+	}
+	if _, err := manager.CreateBatch(ctx, map[string]interface{}{"db": map[string]interface{}{"debug": true}}); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("expected CreateBatch rejected, got %v", err)
+	}
+
+	old := &Config{Meta: Meta{Version: 1, Time: time.Now().Add(-2 * time.Hour)}, Content: json.RawMessage(`{}`)}
+	if r := manager.EvaluatePolicies(old)["recent_update"]; r.Pass {
+		t.Error("expected an old config to fail recent_update")
+	}
+
+	for _, policies := range [][]Policy{{{Name: "x"}}, {noDebug, noDebug}} {
+		if _, err := NewManager(NewMemoryStorage(), WithPolicies(policies)); err == nil {
+			t.Errorf("expected error for policies %v", policies)
+		}
+	}
+}
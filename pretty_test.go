@@ -0,0 +1,52 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestPrettyStorage(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	compact, _ := NewManager(storage, WithSigner(signer))
+	pretty, _ := NewManager(storage, WithSigner(signer), WithPrettyStorage())
+
+	compact.Create(ctx, "app", map[string]interface{}{"db": map[string]interface{}{"host": "a b", "port": 1}})
+	cfg, err := pretty.Update(ctx, "app", map[string]interface{}{"db": map[string]interface{}{"host": "a b", "port": 2}})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if data := mustRead(t, storage, "configs/app/v1.json"); bytes.Contains(data, []byte("\n")) {
+		t.Errorf("expected a compact v1, got %s", data)
+	}
+	if data := mustRead(t, storage, "configs/app/v2.json"); !bytes.Contains(data, []byte("\n      \"port\": 2")) {
+		t.Errorf("expected an indented v2, got %s", data)
+	}
+
+	// Both layouts load to the same bytes, under either manager
+	for _, m := range []*Manager{compact, pretty} {
+		history, err := m.GetHistory(ctx, "app")
+		if err != nil || len(history) != 2 {
+			t.Fatalf("GetHistory failed: %d, %v", len(history), err)
+		}
+		if !bytes.Equal(history[1].Content, cfg.Content) {
+			t.Errorf("expected content %s, got %s", cfg.Content, history[1].Content)
+		}
+		for _, c := range history {
+			if err := VerifyConfigSignature(c, signer.PublicKey()); err != nil {
+				t.Errorf("v%d signature: %v", c.Meta.Version, err)
+			}
+		}
+		if err := m.ValidateChain(ctx, "app"); err != nil {
+			t.Errorf("ValidateChain failed: %v", err)
+		}
+	}
+
+	fresh, _ := NewManager(storage, WithPrettyStorage())
+	if latest, err := fresh.Reconstruct(ctx, "app"); err != nil || latest.Meta.CS != cfg.Meta.CS {
+		t.Errorf("Reconstruct from an indented file: %v", err)
+	}
+}
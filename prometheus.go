@@ -0,0 +1,263 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PrometheusBuckets are the upper bounds, in seconds, of the histograms
+// exported by PrometheusMetrics
+var PrometheusBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// promHelp describes the library's metrics in the exposition
+var promHelp = map[string]string{
+	MetricStorageRetries:          "Storage operations retried after a transient error.",
+	MetricAutoCompactions:         "Background journal compactions by result.",
+	MetricOperations:              "Manager operations by operation and result.",
+	MetricOperationDuration:       "Latency of manager operations in seconds.",
+	MetricChainValidationFailures: "Chains found broken by validation or submission.",
+	MetricStoreBytes:              "Total size of the store in bytes, as of the last whole-store Usage call.",
+	MetricConfigs:                 "Number of config ids in the store, as of the last whole-store Usage call.",
+}
+
+// PrometheusMetrics is a Metrics implementation that serves everything
+// reported to it in the Prometheus text exposition format. It needs no
+// client library: mount it as the /metrics handler,
+//
+//	prom := viracochan.NewPrometheusMetrics("viracochan")
+//	manager, err := viracochan.NewManager(storage, viracochan.WithMetrics(prom))
+//	http.Handle("/metrics", prom)
+//
+// IncCounter, SetGauge and ObserveHistogram define counters, gauges and
+// histograms (with PrometheusBuckets) named namespace_name. A name reported
+// as more than one kind keeps the first. Label values are exported as given,
+// so callers keep them bounded, as the library does.
+type PrometheusMetrics struct {
+	namespace string
+
+	mu       sync.Mutex
+	families map[string]*promFamily
+	onScrape []func(ctx context.Context)
+}
+
+type promFamily struct {
+	kind   string // counter, gauge or histogram
+	series map[string]*promSeries
+}
+
+type promSeries struct {
+	labels string   // rendered label pairs, without braces
+	value  float64  // counter or gauge value
+	counts []uint64 // histogram observations per bucket, not cumulative
+	count  uint64   // histogram observations
+	sum    float64  // histogram sum
+}
+
+// NewPrometheusMetrics returns an empty exporter whose metric names are
+// prefixed with namespace and an underscore; an empty namespace adds no
+// prefix
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		namespace: namespace,
+		families:  make(map[string]*promFamily),
+	}
+}
+
+// OnScrape registers fn to run before each exposition, for values that are
+// cheaper to refresh on demand, such as the store gauges:
+//
+//	prom.OnScrape(func(ctx context.Context) { manager.Usage(ctx, "") })
+func (p *PrometheusMetrics) OnScrape(fn func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onScrape = append(p.onScrape, fn)
+}
+
+// IncCounter adds delta to a counter; negative deltas are ignored
+func (p *PrometheusMetrics) IncCounter(name string, delta float64, labels ...string) {
+	if delta < 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s := p.seriesFor("counter", name, labels); s != nil {
+		s.value += delta
+	}
+}
+
+// SetGauge sets a gauge
+func (p *PrometheusMetrics) SetGauge(name string, value float64, labels ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s := p.seriesFor("gauge", name, labels); s != nil {
+		s.value = value
+	}
+}
+
+// ObserveHistogram records value in a histogram
+func (p *PrometheusMetrics) ObserveHistogram(name string, value float64, labels ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.seriesFor("histogram", name, labels)
+	if s == nil {
+		return
+	}
+	if s.counts == nil {
+		s.counts = make([]uint64, len(PrometheusBuckets))
+	}
+	for i, bound := range PrometheusBuckets {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// seriesFor returns the series of name with labels, creating it, or nil
+// when name is already a metric of another kind. Callers hold p.mu.
+func (p *PrometheusMetrics) seriesFor(kind, name string, labels []string) *promSeries {
+	f, ok := p.families[name]
+	if !ok {
+		f = &promFamily{kind: kind, series: make(map[string]*promSeries)}
+		p.families[name] = f
+	}
+	if f.kind != kind {
+		return nil
+	}
+
+	rendered := renderPromLabels(labels)
+	s, ok := f.series[rendered]
+	if !ok {
+		s = &promSeries{labels: rendered}
+		f.series[rendered] = s
+	}
+	return s
+}
+
+// ServeHTTP writes the exposition, after running the OnScrape hooks
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	hooks := append([]func(context.Context){}, p.onScrape...)
+	p.mu.Unlock()
+	for _, fn := range hooks {
+		fn(r.Context())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := p.WriteTo(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WriteTo writes the current values in the text exposition format, metrics
+// and series in sorted order
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	var buf bytes.Buffer
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p.writeFamily(&buf, name, p.families[name])
+	}
+	p.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+func (p *PrometheusMetrics) writeFamily(buf *bytes.Buffer, name string, f *promFamily) {
+	full := sanitizePromName(name)
+	if p.namespace != "" {
+		full = sanitizePromName(p.namespace) + "_" + full
+	}
+	help, ok := promHelp[name]
+	if !ok {
+		help = "Reported by viracochan as " + name + "."
+	}
+	fmt.Fprintf(buf, "# HELP %s %s\n", full, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", full, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			fmt.Fprintf(buf, "%s%s %s\n", full, braced(s.labels), formatPromValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range PrometheusBuckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", full, braced(joinLabels(s.labels, `le="`+formatPromValue(bound)+`"`)), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", full, braced(joinLabels(s.labels, `le="+Inf"`)), s.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", full, braced(s.labels), formatPromValue(s.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", full, braced(s.labels), s.count)
+	}
+}
+
+// renderPromLabels renders alternating key/value pairs sorted by key; an
+// unpaired trailing key is dropped
+func renderPromLabels(labels []string) string {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, sanitizePromName(labels[i])+`="`+value+`"`)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func braced(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// sanitizePromName replaces the characters Prometheus does not allow in
+// metric and label names with underscores
+func sanitizePromName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		ok := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !ok {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func formatPromValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,104 @@
+package viracochan
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	prom := NewPrometheusMetrics("test")
+	prom.IncCounter(MetricOperations, 1, "op", "get", "result", "ok")
+	prom.IncCounter(MetricOperations, 2, "result", "ok", "op", "get")
+	prom.SetGauge(MetricConfigs, 7)
+	prom.ObserveHistogram(MetricOperationDuration, 0.003, "op", "get")
+	prom.ObserveHistogram(MetricOperationDuration, 10, "op", "get")
+	prom.SetGauge(MetricOperations, 99) // wrong kind, ignored
+	prom.IncCounter("odd name", 1, "path", "a\"b\\c\n")
+
+	var sb strings.Builder
+	if _, err := prom.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	out := sb.String()
+
+	for _, line := range []string{
+		"# TYPE test_operations_total counter",
+		`test_operations_total{op="get",result="ok"} 3`,
+		"# TYPE test_configs gauge",
+		"test_configs 7",
+		"# TYPE test_operation_duration_seconds histogram",
+		`test_operation_duration_seconds_bucket{op="get",le="0.0025"} 0`,
+		`test_operation_duration_seconds_bucket{op="get",le="0.005"} 1`,
+		`test_operation_duration_seconds_bucket{op="get",le="5"} 1`,
+		`test_operation_duration_seconds_bucket{op="get",le="+Inf"} 2`,
+		`test_operation_duration_seconds_sum{op="get"} 10.003`,
+		`test_operation_duration_seconds_count{op="get"} 2`,
+		`test_odd_name{path="a\"b\\c\n"} 1`,
+	} {
+		if !containsLine([]byte(out), line) {
+			t.Errorf("exposition lacks %q:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, " 99") {
+		t.Errorf("gauge of a counter name was exported:\n%s", out)
+	}
+}
+
+func TestPrometheusManagerMetrics(t *testing.T) {
+	ctx := context.Background()
+	prom := NewPrometheusMetrics("viracochan")
+	manager, err := NewManager(NewMemoryStorage(), WithMetrics(prom))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	prom.OnScrape(func(ctx context.Context) { manager.Usage(ctx, "") })
+
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": 2}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	manager.GetLatest(ctx, "app")
+	manager.GetLatest(ctx, "missing")
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Fatalf("ValidateChain failed: %v", err)
+	}
+	head, _ := manager.GetLatest(ctx, "app")
+	if err := manager.Submit(ctx, "fresh", head); err == nil {
+		t.Fatal("expected Submit of a v2 for a new id to fail")
+	}
+
+	rec := httptest.NewRecorder()
+	prom.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	out := rec.Body.String()
+
+	for _, line := range []string{
+		`viracochan_operations_total{op="create",result="ok"} 1`,
+		`viracochan_operations_total{op="update",result="ok"} 1`,
+		`viracochan_operations_total{op="get_latest",result="ok"} 2`,
+		`viracochan_operations_total{op="get_latest",result="error"} 1`,
+		`viracochan_operation_duration_seconds_count{op="update"} 1`,
+		`viracochan_operations_total{op="submit",result="error"} 1`,
+		`viracochan_chain_validation_failures_total{op="submit"} 1`,
+		"viracochan_configs 1",
+	} {
+		if !containsLine([]byte(out), line) {
+			t.Errorf("exposition lacks %q:\n%s", line, out)
+		}
+	}
+	if !strings.Contains(out, "viracochan_store_size_bytes ") {
+		t.Errorf("exposition lacks store size:\n%s", out)
+	}
+	if strings.Contains(out, `op="validate_chain"`) {
+		t.Errorf("valid chain counted as a failure:\n%s", out)
+	}
+	if strings.Contains(out, "app") || strings.Contains(out, "missing") {
+		t.Errorf("config ids leaked into labels:\n%s", out)
+	}
+}
@@ -0,0 +1,126 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrPointerNotFound is returned by Lookup when the JSON Pointer does not
+// resolve in the content
+var ErrPointerNotFound = errors.New("pointer not found")
+
+// Lookup returns the value at the JSON Pointer (RFC 6901) in c's content,
+// decoded as by json.Unmarshal into an interface{}: "" is the whole
+// content, "/database/host" a nested key, "/servers/0" an array element, and
+// "~1" and "~0" escape "/" and "~" in keys. A pointer that does not resolve
+// returns ErrPointerNotFound.
+func (c *Config) Lookup(pointer string) (interface{}, error) {
+	if pointer != "" && !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must be empty or start with /", pointer)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(c.Content, &v); err != nil {
+		return nil, err
+	}
+	if pointer == "" {
+		return v, nil
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPointerNotFound, pointer)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) || (len(token) > 1 && token[0] == '0') {
+				return nil, fmt.Errorf("%w: %q", ErrPointerNotFound, pointer)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPointerNotFound, pointer)
+		}
+	}
+	return v, nil
+}
+
+// FieldEquals returns a Query matcher for configs whose content holds value
+// at the JSON Pointer (see Lookup). value is compared in its JSON form, so
+// FieldEquals("/replicas", 3) matches a stored 3.0.
+func FieldEquals(pointer string, value interface{}) func(id string, cfg *Config) bool {
+	var want interface{}
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(data, &want)
+	}
+	return func(_ string, cfg *Config) bool {
+		if err != nil {
+			return false
+		}
+		got, lookupErr := cfg.Lookup(pointer)
+		return lookupErr == nil && reflect.DeepEqual(got, want)
+	}
+}
+
+// Query returns, in ascending order, the ids whose latest version matcher
+// accepts, for example
+//
+//	prod, err := manager.Query(ctx, viracochan.FieldEquals("/environment", "production"))
+//
+// Ids come from the config store as in ListPage, and their latest versions
+// are loaded one at a time, so the store never has to fit in memory. The
+// manager lock is not held while matcher runs. Configs whose latest version
+// has expired are not matched. A config that fails to load is skipped and
+// the query goes on; such failures are returned together with the matches.
+// ctx being done stops the query and returns its error.
+func (m *Manager) Query(ctx context.Context, matcher func(id string, cfg *Config) bool) ([]string, error) {
+	m.mu.RLock()
+	if m.closed {
+		m.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	ids, err := m.configIDs(ctx)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := func(id string) (*Config, error) {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		if m.closed {
+			return nil, ErrClosed
+		}
+		return m.getLatest(ctx, id)
+	}
+
+	var matches []string
+	var loadErrs []error
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cfg, err := latest(id)
+		if errors.Is(err, ErrClosed) {
+			return nil, err
+		}
+		if err != nil {
+			loadErrs = append(loadErrs, fmt.Errorf("config %q: %w", id, err))
+			continue
+		}
+		if !cfg.Expired(m.now()) && matcher(id, cfg) {
+			matches = append(matches, id)
+		}
+	}
+	return matches, errors.Join(loadErrs...)
+}
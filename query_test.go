@@ -0,0 +1,68 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfigLookup(t *testing.T) {
+	cfg := &Config{Content: json.RawMessage(`{"db":{"host":"a","ports":[5432,5433]},"a/b":1,"m~n":2}`)}
+
+	for pointer, want := range map[string]interface{}{
+		"/db/host":    "a",
+		"/db/ports/1": float64(5433),
+		"/a~1b":       float64(1),
+		"/m~0n":       float64(2),
+	} {
+		got, err := cfg.Lookup(pointer)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %v, got %v, %v", pointer, want, got, err)
+		}
+	}
+	if whole, err := cfg.Lookup(""); err != nil || len(whole.(map[string]interface{})) != 3 {
+		t.Errorf("empty pointer should return the whole content, got %v, %v", whole, err)
+	}
+	for _, pointer := range []string{"/missing", "/db/ports/2", "/db/ports/01", "/db/host/x"} {
+		if _, err := cfg.Lookup(pointer); !errors.Is(err, ErrPointerNotFound) {
+			t.Errorf("%s: expected ErrPointerNotFound, got %v", pointer, err)
+		}
+	}
+	if _, err := cfg.Lookup("db"); err == nil {
+		t.Error("expected an error for a pointer without a leading /")
+	}
+}
+
+func TestManagerQuery(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	manager.Create(ctx, "api", map[string]interface{}{"environment": "production", "replicas": 3})
+	manager.Create(ctx, "web", map[string]interface{}{"environment": "staging"})
+	manager.Update(ctx, "web", map[string]interface{}{"environment": "production"})
+	manager.Create(ctx, "old", map[string]interface{}{"environment": "production"})
+	manager.Update(ctx, "old", map[string]interface{}{"environment": "retired"})
+	manager.Create(ctx, "gone", map[string]interface{}{"environment": "production"}, WithTTL(time.Nanosecond))
+
+	ids, err := manager.Query(ctx, FieldEquals("/environment", "production"))
+	if err != nil || !slices.Equal(ids, []string{"api", "web"}) {
+		t.Errorf("expected [api web], got %v, %v", ids, err)
+	}
+	if ids, _ := manager.Query(ctx, FieldEquals("/replicas", 3)); !slices.Equal(ids, []string{"api"}) {
+		t.Errorf("expected numbers to match in JSON form, got %v", ids)
+	}
+
+	ids, err = manager.Query(ctx, func(id string, cfg *Config) bool { return cfg.Meta.Version > 1 })
+	if err != nil || !slices.Equal(ids, []string{"old", "web"}) {
+		t.Errorf("expected [old web], got %v, %v", ids, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := manager.Query(cancelled, FieldEquals("/environment", "production")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
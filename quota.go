@@ -0,0 +1,147 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// ErrQuotaExceeded is returned when a write would take an id past its quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota caps the version files a single id may keep. Zero limits are
+// unlimited. Bytes are counted as Usage counts ConfigBytes: the size of the
+// version files in config storage, not the journal.
+type Quota struct {
+	MaxVersions int   // version files per id
+	MaxBytes    int64 // bytes of version files per id
+	// AutoPrune deletes the oldest version files of the id, after the new
+	// version is written, instead of rejecting the write. Their journal
+	// entries stay, so the history keeps a gap where they were (GetHistory
+	// skips them, or fails with WithStrictHistory).
+	AutoPrune bool
+}
+
+func (q Quota) enabled() bool {
+	return q.MaxVersions > 0 || q.MaxBytes > 0
+}
+
+func (q Quota) exceeded(versions int, bytes int64) bool {
+	return (q.MaxVersions > 0 && versions > q.MaxVersions) || (q.MaxBytes > 0 && bytes > q.MaxBytes)
+}
+
+// WithQuota enforces q on every create, update, merge and rollback (and on
+// each config of CreateBatch). The check runs under the write lock, so
+// concurrent writes to the same id cannot overshoot it. A write that would
+// cross a limit fails with ErrQuotaExceeded, unless q.AutoPrune is set and
+// pruning older versions makes room; a single version larger than MaxBytes
+// is always rejected. Imports are not checked.
+func WithQuota(q Quota) ManagerOption {
+	return func(m *Manager) error {
+		if q.MaxVersions < 0 || q.MaxBytes < 0 {
+			return errors.New("quota limits must not be negative")
+		}
+		m.quota = q
+		return nil
+	}
+}
+
+// versionFile is one stored version of an id and the size of its file
+type versionFile struct {
+	version uint64
+	size    int64
+}
+
+// versionFiles lists the version files of id in ascending version order
+func (m *Manager) versionFiles(ctx context.Context, id string) ([]versionFile, error) {
+	stats, err := listStat(ctx, m.storage, filepath.Join(m.configStore.prefix, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []versionFile
+	for _, st := range stats {
+		name := filepath.Base(st.Path)
+		if !isVersionFile(name) {
+			continue
+		}
+		var v uint64
+		if _, err := fmt.Sscanf(name, "v%d.json", &v); err == nil {
+			files = append(files, versionFile{version: v, size: st.Size})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].version < files[j].version
+	})
+	return files, nil
+}
+
+// checkQuota checks that the sealed cfg fits the quota of id and returns the
+// versions to prune after it is written. Callers hold mu.
+func (m *Manager) checkQuota(ctx context.Context, id string, cfg *Config) ([]uint64, error) {
+	if !m.quota.enabled() {
+		return nil, nil
+	}
+
+	files, err := m.versionFiles(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return m.quotaPrune(id, cfg, files)
+}
+
+// quotaPrune checks cfg against the quota as the successor of files, the
+// stored versions of id, and returns the versions to prune
+func (m *Manager) quotaPrune(id string, cfg *Config, files []versionFile) ([]uint64, error) {
+	if !m.quota.enabled() {
+		return nil, nil
+	}
+
+	data, err := m.configStore.encode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, bytes := len(files)+1, int64(len(data))
+	for _, f := range files {
+		bytes += f.size
+	}
+
+	var prune []uint64
+	for m.quota.AutoPrune && m.quota.exceeded(versions, bytes) && len(prune) < len(files) {
+		oldest := files[len(prune)]
+		prune = append(prune, oldest.version)
+		versions--
+		bytes -= oldest.size
+	}
+	if m.quota.exceeded(versions, bytes) {
+		return nil, fmt.Errorf("%w: %q would hold %d versions in %d bytes (max %d versions, %d bytes)",
+			ErrQuotaExceeded, id, versions, bytes, m.quota.MaxVersions, m.quota.MaxBytes)
+	}
+	return prune, nil
+}
+
+// pruneVersions deletes the version files chosen by checkQuota. The write
+// that made room has already succeeded, so failures are only logged.
+func (m *Manager) pruneVersions(ctx context.Context, id string, versions []uint64) {
+	if len(versions) == 0 {
+		return
+	}
+	// The oldest retained version may be diffed against a pruned one
+	if m.configStore.keyframes > 1 {
+		retained := versions[len(versions)-1] + 1
+		if err := m.configStore.keyframe(ctx, id, retained); err != nil {
+			m.logger.Warn("quota: failed to rewrite version as keyframe, not pruning", "id", id, "version", retained, "error", err)
+			return
+		}
+	}
+	for _, v := range versions {
+		if err := m.configStore.Delete(ctx, id, v); err != nil {
+			m.logger.Warn("quota: failed to prune version", "id", id, "version", v, "error", err)
+			continue
+		}
+		m.logger.Info("quota: pruned version", "id", id, "version", v)
+	}
+}
@@ -0,0 +1,79 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestQuotaRejects(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage(), WithQuota(Quota{MaxVersions: 3}))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 0})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			_, err := manager.Update(ctx, "app", map[string]interface{}{"n": n})
+			switch {
+			case err == nil:
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			case !errors.Is(err, ErrQuotaExceeded):
+				t.Errorf("unexpected error %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if succeeded != 2 {
+		t.Errorf("expected 2 updates within quota, got %d", succeeded)
+	}
+	if usage, _ := manager.Usage(ctx, "app"); usage.Versions != 3 {
+		t.Errorf("expected 3 versions, got %d", usage.Versions)
+	}
+
+	byteCapped, _ := NewManager(NewMemoryStorage(), WithQuota(Quota{MaxBytes: 64}))
+	if _, err := byteCapped.Create(ctx, "app", map[string]interface{}{"n": 1}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded for an oversized version, got %v", err)
+	}
+	if _, err := byteCapped.CreateBatch(ctx, map[string]interface{}{"a": 1}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded from CreateBatch, got %v", err)
+	}
+
+	if _, err := NewManager(NewMemoryStorage(), WithQuota(Quota{MaxVersions: -1})); err == nil {
+		t.Error("expected error for a negative limit")
+	}
+}
+
+func TestQuotaAutoPrune(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage, WithQuota(Quota{MaxVersions: 2, AutoPrune: true}))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	for n := 2; n <= 4; n++ {
+		if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": n}); err != nil {
+			t.Fatalf("Update %d failed: %v", n, err)
+		}
+	}
+
+	history, _ := manager.GetHistory(ctx, "app")
+	if len(history) != 2 || history[0].Meta.Version != 3 || history[1].Meta.Version != 4 {
+		t.Fatalf("expected v3 and v4 kept, got %d versions", len(history))
+	}
+	latest, err := manager.GetLatest(ctx, "app")
+	if err != nil || latest.Meta.Version != 4 {
+		t.Errorf("expected v4 latest, got %v, %v", latest, err)
+	}
+	if exists, _ := storage.Exists(ctx, "configs/app/v1.json"); exists {
+		t.Error("v1 should have been pruned")
+	}
+}
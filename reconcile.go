@@ -0,0 +1,197 @@
+package viracochan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ErrReconcileConflicts is returned by Reconcile when both chains changed the
+// same values differently and no strategy resolves them. Paths lists every
+// conflicting value, dot separated as in Merge3. It matches ErrMergeConflict.
+type ErrReconcileConflicts struct {
+	Base    uint64 // common ancestor version
+	LocalCS string // local head
+	OtherCS string // other chain's head
+	Paths   []string
+}
+
+func (e *ErrReconcileConflicts) Error() string {
+	return fmt.Sprintf("%v since v%d between %s and %s at %s", ErrMergeConflict, e.Base, e.LocalCS, e.OtherCS, strings.Join(e.Paths, ", "))
+}
+
+func (e *ErrReconcileConflicts) Unwrap() error {
+	return ErrMergeConflict
+}
+
+// ReconcileStrategy selects how Reconcile settles conflicting values
+type ReconcileStrategy int
+
+const (
+	// ReconcileReport fails with ErrReconcileConflicts listing every conflict
+	ReconcileReport ReconcileStrategy = iota
+	// ReconcilePreferLocal keeps the local value of each conflict
+	ReconcilePreferLocal
+	// ReconcilePreferOther takes the other chain's value of each conflict
+	ReconcilePreferOther
+)
+
+// ReconcileOption configures Reconcile
+type ReconcileOption func(*reconcileOptions)
+
+type reconcileOptions struct {
+	strategy ReconcileStrategy
+	write    []WriteOption
+}
+
+// ReconcileWith settles conflicts by strategy instead of reporting them
+func ReconcileWith(strategy ReconcileStrategy) ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.strategy = strategy
+	}
+}
+
+// ReconcileWriteOptions applies opts to the merged version, as for Update
+func ReconcileWriteOptions(opts ...WriteOption) ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.write = append(o.write, opts...)
+	}
+}
+
+// Reconcile heals a fork of id. other is another chain of the same config,
+// such as a peer's GetHistory result; it need only hold the versions from the
+// last one it shares with local history onwards, and is validated from there.
+// The two heads are three-way merged against that common ancestor (see
+// Merge3, using the manager's merge fields) and the result is written as a
+// new version continuing local history, with Meta.MergeParents recording both
+// heads.
+//
+// Conflicting values fail the whole merge with ErrReconcileConflicts unless
+// ReconcileWith supplies a strategy. When other holds nothing local history
+// lacks, the local head is returned and nothing is written; when it is
+// simply ahead, the merge takes its content. ErrInvalidChain is returned if
+// the chains share no version or other does not chain from the ancestor.
+func (m *Manager) Reconcile(ctx context.Context, id string, other []*Config, opts ...ReconcileOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "reconcile", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	ro := reconcileOptions{}
+	for _, opt := range opts {
+		opt(&ro)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	current, err := m.getLatest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	known, err := m.knownHeads(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := byVersion(other)
+	if err != nil {
+		return nil, fmt.Errorf("other chain: %w", err)
+	}
+
+	// The merge base is the newest version of other that local history
+	// holds or has already merged
+	common := -1
+	for i := len(chain) - 1; i >= 0 && common < 0; i-- {
+		if known[chain[i].Meta.CS] {
+			common = i
+		}
+	}
+	switch {
+	case common < 0:
+		return nil, fmt.Errorf("%w: %q shares no version with the other chain", ErrInvalidChain, id)
+	case common == len(chain)-1:
+		return current, nil
+	}
+
+	base := chain[common]
+	if err := base.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: other chain v%d: %v", ErrInvalidChain, base.Meta.Version, err)
+	}
+	theirs := base
+	for _, cfg := range chain[common+1:] {
+		if err := cfg.nextOf(theirs, m.clockSkew); err != nil {
+			return nil, fmt.Errorf("%w: other chain v%d: %v", ErrInvalidChain, cfg.Meta.Version, err)
+		}
+		theirs = cfg
+	}
+
+	var conflicts []string
+	merged, err := merge3(base.Content, current.Content, theirs.Content, m.mergeFields,
+		func(path []string, _ string, ours, other interface{}, oursPresent, otherPresent bool) (interface{}, bool, error) {
+			switch ro.strategy {
+			case ReconcilePreferLocal:
+				return ours, oursPresent, nil
+			case ReconcilePreferOther:
+				return other, otherPresent, nil
+			}
+			conflicts = append(conflicts, strings.Join(path, "."))
+			return ours, oursPresent, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &ErrReconcileConflicts{
+			Base:    base.Meta.Version,
+			LocalCS: current.Meta.CS,
+			OtherCS: theirs.Meta.CS,
+			Paths:   conflicts,
+		}
+	}
+
+	newCfg := &Config{
+		Meta:    current.Meta,
+		Content: merged,
+	}
+	newCfg.Meta.HashAlg = m.hashAlg
+	if err := newCfg.UpdateMeta(); err != nil {
+		return nil, err
+	}
+	newCfg.Meta.MergeParents = []string{current.Meta.CS, theirs.Meta.CS}
+	if newCfg.Meta.CS, err = computeChecksum(newCfg); err != nil {
+		return nil, err
+	}
+
+	op := fmt.Sprintf("reconcile_v%d", theirs.Meta.Version)
+	if err := m.commit(ctx, id, newCfg, op, newWriteOptions(ro.write)); err != nil {
+		return nil, err
+	}
+	return newCfg, nil
+}
+
+// knownHeads returns the checksums of every stored version of id and of every
+// head those versions merged
+func (m *Manager) knownHeads(ctx context.Context, id string) (map[string]bool, error) {
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(versions))
+	for _, v := range versions {
+		cfg, err := m.configStore.Load(ctx, id, v)
+		if err != nil {
+			continue
+		}
+		known[cfg.Meta.CS] = true
+		for _, cs := range cfg.Meta.MergeParents {
+			known[cs] = true
+		}
+	}
+	return known, nil
+}
@@ -0,0 +1,133 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// forkedPair returns two managers that share v1 of "app" and then each
+// wrote their own v2 and v3, v3 applying changes to the common content
+func forkedPair(t *testing.T, local, other map[string]interface{}) (*Manager, *Manager) {
+	t.Helper()
+	ctx := context.Background()
+	a, _ := NewManager(NewMemoryStorage())
+	b, _ := NewManager(NewMemoryStorage())
+	initial := func() map[string]interface{} {
+		return map[string]interface{}{"host": "a", "port": 80, "tags": []interface{}{"x"}}
+	}
+
+	common, err := a.Create(ctx, "app", initial())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	data, _ := json.Marshal(common)
+	if err := b.Import(ctx, "app", data); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for m, changes := range map[*Manager]map[string]interface{}{a: local, b: other} {
+		m.Update(ctx, "app", initial())
+		content := initial()
+		for k, v := range changes {
+			content[k] = v
+		}
+		if _, err := m.Update(ctx, "app", content); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	return a, b
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	a, b := forkedPair(t,
+		map[string]interface{}{"host": "b"},
+		map[string]interface{}{"port": 8080, "tags": []interface{}{"x", "y"}})
+
+	other, _ := b.GetHistory(ctx, "app")
+	local, _ := a.GetLatest(ctx, "app")
+	merged, err := a.Reconcile(ctx, "app", other)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	var content map[string]interface{}
+	json.Unmarshal(merged.Content, &content)
+	if content["host"] != "b" || content["port"] != float64(8080) || len(content["tags"].([]interface{})) != 2 {
+		t.Errorf("unexpected merged content: %s", merged.Content)
+	}
+	want := []string{local.Meta.CS, other[len(other)-1].Meta.CS}
+	if merged.Meta.Version != 4 || merged.Meta.PrevCS != local.Meta.CS || !slices.Equal(merged.Meta.MergeParents, want) {
+		t.Errorf("unexpected merge meta: %+v", merged.Meta)
+	}
+	if err := a.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("chain invalid after reconcile: %v", err)
+	}
+
+	// Merge parents are checksummed and do not carry over
+	tampered := *merged
+	tampered.Meta.MergeParents = []string{"x", "y"}
+	if err := tampered.Validate(); err == nil {
+		t.Error("expected rewritten merge parents to fail validation")
+	}
+	next, _ := a.Update(ctx, "app", content)
+	if next.Meta.MergeParents != nil {
+		t.Errorf("merge parents carried over: %v", next.Meta.MergeParents)
+	}
+
+	// An already merged chain has nothing new to offer
+	head, err := a.Reconcile(ctx, "app", other)
+	if err != nil || head.Meta.CS != next.Meta.CS {
+		t.Errorf("expected the local head back, got %v, %v", head, err)
+	}
+}
+
+func TestReconcileConflicts(t *testing.T) {
+	ctx := context.Background()
+	a, b := forkedPair(t,
+		map[string]interface{}{"host": "b", "port": 81},
+		map[string]interface{}{"host": "c", "port": 82})
+	other, _ := b.GetHistory(ctx, "app")
+
+	_, err := a.Reconcile(ctx, "app", other)
+	var conflicts *ErrReconcileConflicts
+	if !errors.As(err, &conflicts) || !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("expected ErrReconcileConflicts, got %v", err)
+	}
+	if conflicts.Base != 1 || !slices.Equal(conflicts.Paths, []string{"host", "port"}) {
+		t.Errorf("unexpected conflicts: %+v", conflicts)
+	}
+	if latest, _ := a.GetLatest(ctx, "app"); latest.Meta.Version != 3 {
+		t.Errorf("conflicting reconcile wrote v%d", latest.Meta.Version)
+	}
+
+	// The other chain must continue from the common ancestor
+	broken := slices.Clone(other)
+	forged := *broken[2]
+	forged.Content = json.RawMessage(`{"host":"evil"}`)
+	broken[2] = &forged
+	if _, err := a.Reconcile(ctx, "app", broken); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain for a tampered chain, got %v", err)
+	}
+
+	merged, err := a.Reconcile(ctx, "app", other, ReconcileWith(ReconcilePreferOther),
+		ReconcileWriteOptions(WithMessage("heal fork")))
+	if err != nil {
+		t.Fatalf("Reconcile with strategy failed: %v", err)
+	}
+	var content map[string]interface{}
+	json.Unmarshal(merged.Content, &content)
+	if content["host"] != "c" || content["port"] != float64(82) {
+		t.Errorf("expected the other chain's values, got %s", merged.Content)
+	}
+
+	unrelated, _ := NewManager(NewMemoryStorage())
+	unrelated.Create(ctx, "app", map[string]interface{}{"host": "z"})
+	history, _ := unrelated.GetHistory(ctx, "app")
+	if _, err := a.Reconcile(ctx, "app", history); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain without a common ancestor, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotReconstructable is returned by VerifyReconstructable when the journal
+// and the config files disagree on the head of an id
+var ErrNotReconstructable = errors.New("history not reconstructable")
+
+// VerifyReconstructable checks that id rebuilds to the same head from either
+// source: from the journal alone (its chain, and the config embedded in its
+// last entry) and from the config files alone (the highest version file).
+// Both must name the same version and checksum, and an embedded config must
+// also be byte-identical in canonical content to the file. A mismatch, say a
+// file rewritten after it was journaled or a head file lost, returns an error
+// wrapping ErrNotReconstructable; an unreadable or broken source returns its
+// own error. Entries journaled without their config are compared by checksum
+// only. SelfTest runs the same check on every journaled id.
+func (m *Manager) VerifyReconstructable(ctx context.Context, id string) error {
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return m.verifyReconstructable(ctx, id, entries)
+}
+
+// verifyReconstructable compares the head of the journal entries of id with
+// its head config file. Callers hold mu.
+func (m *Manager) verifyReconstructable(ctx context.Context, id string, entries []*JournalEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("%w for %q", ErrNoJournalEntries, id)
+	}
+	ordered, err := m.journal.Resequence(entries)
+	if err != nil {
+		return fmt.Errorf("failed to resequence: %w", err)
+	}
+	if err := m.journal.ValidateChain(ordered); err != nil {
+		return fmt.Errorf("invalid chain: %w", err)
+	}
+	head := ordered[len(ordered)-1]
+
+	files, err := m.versionFiles(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%w: %q journaled up to v%d but has no config files", ErrNotReconstructable, id, head.Version)
+	}
+	if last := files[len(files)-1].version; last != head.Version {
+		return fmt.Errorf("%w: %q journal head is v%d, config files end at v%d", ErrNotReconstructable, id, head.Version, last)
+	}
+	file, err := m.configStore.Load(ctx, id, head.Version)
+	if err != nil {
+		return fmt.Errorf("config file %q v%d: %w", id, head.Version, err)
+	}
+	if file.Meta.CS != head.CS {
+		return fmt.Errorf("%w: %q v%d journaled with cs=%s, config file has cs=%s", ErrNotReconstructable, id, head.Version, head.CS, file.Meta.CS)
+	}
+
+	if head.Config == nil {
+		return nil
+	}
+	embedded := head.Config
+	if embedded.Meta.CS != head.CS || embedded.Meta.Version != head.Version {
+		return fmt.Errorf("%w: %q v%d embeds a config for v%d cs=%s", ErrNotReconstructable, id, head.Version, embedded.Meta.Version, embedded.Meta.CS)
+	}
+	if err := embedded.Validate(); err != nil {
+		return fmt.Errorf("%w: %q v%d embedded config: %v", ErrNotReconstructable, id, head.Version, err)
+	}
+	want, err := canonicalContent(embedded.Content)
+	if err != nil {
+		return fmt.Errorf("%w: %q v%d embedded content: %v", ErrNotReconstructable, id, head.Version, err)
+	}
+	got, err := canonicalContent(file.Content)
+	if err != nil {
+		return fmt.Errorf("%w: %q v%d file content: %v", ErrNotReconstructable, id, head.Version, err)
+	}
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("%w: %q v%d content differs between journal and config file", ErrNotReconstructable, id, head.Version)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVerifyReconstructable(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	for _, id := range []string{"app", "db", "cache"} {
+		manager.Create(ctx, id, map[string]interface{}{"n": 1})
+		manager.Update(ctx, id, map[string]interface{}{"n": 2})
+	}
+	for _, id := range []string{"app", "db", "cache"} {
+		if err := manager.VerifyReconstructable(ctx, id); err != nil {
+			t.Fatalf("%s: expected a reconstructable history, got %v", id, err)
+		}
+	}
+	if err := manager.VerifyReconstructable(ctx, "missing"); !errors.Is(err, ErrNoJournalEntries) {
+		t.Errorf("expected ErrNoJournalEntries, got %v", err)
+	}
+
+	// app: head file rewritten as a valid but different v2
+	first, _ := manager.Get(ctx, "app", 1)
+	rewritten := &Config{Meta: first.Meta, Content: json.RawMessage(`{"n":20}`)}
+	if err := rewritten.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	data, _ := json.Marshal(rewritten)
+	storage.Write(ctx, "configs/app/v2.json", data)
+
+	// db: head file lost
+	storage.Delete(ctx, "configs/db/v2.json")
+
+	for _, id := range []string{"app", "db"} {
+		if err := manager.VerifyReconstructable(ctx, id); !errors.Is(err, ErrNotReconstructable) {
+			t.Errorf("%s: expected ErrNotReconstructable, got %v", id, err)
+		}
+	}
+
+	report, err := manager.SelfTest(ctx)
+	if err != nil {
+		t.Fatalf("SelfTest failed: %v", err)
+	}
+	found := make(map[string]SelfTestFinding)
+	for _, f := range report.Findings {
+		if f.Check == CheckReconstruct {
+			found[f.ID] = f
+		}
+	}
+	if len(found) != 2 || found["app"].Severity != SeverityCritical || !strings.Contains(found["db"].Detail, "v1") {
+		t.Errorf("expected reconstruct findings for app and db, got %+v", found)
+	}
+}
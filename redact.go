@@ -0,0 +1,105 @@
+package viracochan
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// RedactedValue replaces every value a NewPathRedactor masks
+const RedactedValue = "[REDACTED]"
+
+// Redactor masks sensitive parts of content before it leaves the manager.
+// It returns the content to show instead, and must not modify content in
+// place.
+type Redactor func(content json.RawMessage) json.RawMessage
+
+// WithRedactor masks content in the outputs meant for sharing: Export and
+// the changes listed by Changelog (both contents are redacted before they are
+// diffed, so a change confined to masked fields is not listed). Redaction is
+// output-only. Stored versions, checksums and signatures are untouched, and
+// reads such as Get return content as stored. An exported config keeps the
+// checksum of the unredacted content, so a redacted export no longer
+// verifies and cannot be imported again; use ExportObjects, which is never
+// redacted, for backups. Audit records (ExportAuditLog), audit reports and
+// the manager's log lines carry no content, only ids, checksums, paths and
+// types, so there is nothing in them to mask.
+func WithRedactor(r Redactor) ManagerOption {
+	return func(m *Manager) error {
+		m.redactor = r
+		return nil
+	}
+}
+
+// redact returns content masked by the manager's redactor, if any
+func (m *Manager) redact(content json.RawMessage) json.RawMessage {
+	if m.redactor == nil || len(content) == 0 {
+		return content
+	}
+	return m.redactor(content)
+}
+
+// NewPathRedactor returns a Redactor that replaces the value at each of paths
+// with RedactedValue. Paths are dotted as in Diff ("credentials.api_key"); a
+// "*" segment matches every key of an object, and arrays are searched element
+// by element, so "users.ssn" masks the ssn of every user in a list. Absent
+// paths are skipped, and content that is not JSON, or has nothing to mask,
+// is returned as is.
+func NewPathRedactor(paths []string) Redactor {
+	split := make([][]string, 0, len(paths))
+	for _, p := range paths {
+		if p != "" {
+			split = append(split, strings.Split(p, "."))
+		}
+	}
+
+	return func(content json.RawMessage) json.RawMessage {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return content
+		}
+
+		masked := false
+		for _, segs := range split {
+			masked = redactPath(v, segs) || masked
+		}
+		if !masked {
+			return content
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return content
+		}
+		return data
+	}
+}
+
+// redactPath masks the values at segs under v in place and reports whether
+// it masked any
+func redactPath(v interface{}, segs []string) bool {
+	switch node := v.(type) {
+	case []interface{}:
+		masked := false
+		for _, elem := range node {
+			masked = redactPath(elem, segs) || masked
+		}
+		return masked
+	case map[string]interface{}:
+		masked := false
+		for key, child := range node {
+			if segs[0] != "*" && segs[0] != key {
+				continue
+			}
+			if len(segs) == 1 {
+				node[key] = RedactedValue
+				masked = true
+				continue
+			}
+			masked = redactPath(child, segs[1:]) || masked
+		}
+		return masked
+	}
+	return false
+}
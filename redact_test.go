@@ -0,0 +1,95 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPathRedactor(t *testing.T) {
+	redact := NewPathRedactor([]string{"credentials.api_key", "users.ssn", "tokens.*", "missing.path"})
+
+	in := json.RawMessage(`{"credentials":{"api_key":"sk-1","region":"eu"},"users":[{"name":"a","ssn":"123"},{"name":"b"}],"tokens":{"x":"1","y":{"z":2}},"big":12345678901234567890}`)
+	var got map[string]interface{}
+	if err := json.Unmarshal(redact(in), &got); err != nil {
+		t.Fatalf("redacted content is not JSON: %v", err)
+	}
+	creds := got["credentials"].(map[string]interface{})
+	users := got["users"].([]interface{})
+	tokens := got["tokens"].(map[string]interface{})
+	if creds["api_key"] != RedactedValue || creds["region"] != "eu" {
+		t.Errorf("unexpected credentials %v", creds)
+	}
+	if users[0].(map[string]interface{})["ssn"] != RedactedValue || len(users[1].(map[string]interface{})) != 1 {
+		t.Errorf("unexpected users %v", users)
+	}
+	if tokens["x"] != RedactedValue || tokens["y"] != RedactedValue {
+		t.Errorf("unexpected tokens %v", tokens)
+	}
+	if !bytes.Contains(redact(in), []byte("12345678901234567890")) {
+		t.Error("numbers lost precision")
+	}
+	if !bytes.Contains(in, []byte("sk-1")) {
+		t.Error("the input was modified")
+	}
+
+	plain := json.RawMessage(`{ "name": "x" }`)
+	if got := redact(plain); !bytes.Equal(got, plain) {
+		t.Errorf("content with nothing to mask should be returned as is, got %s", got)
+	}
+	if got := redact(json.RawMessage("not json")); string(got) != "not json" {
+		t.Errorf("non-JSON content should be returned as is, got %s", got)
+	}
+}
+
+func TestRedactorOutputs(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer), WithRedactor(NewPathRedactor([]string{"api_key"})))
+
+	manager.Create(ctx, "app", map[string]interface{}{"api_key": "sk-1", "port": 80})
+	manager.Update(ctx, "app", map[string]interface{}{"api_key": "sk-2", "port": 81})
+
+	exported, err := manager.Export(ctx, "app")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if bytes.Contains(exported, []byte("sk-2")) || !bytes.Contains(exported, []byte(RedactedValue)) {
+		t.Errorf("export leaks the secret: %s", exported)
+	}
+
+	changelog, err := manager.Changelog(ctx, "app")
+	if err != nil {
+		t.Fatalf("Changelog failed: %v", err)
+	}
+	var buf bytes.Buffer
+	RenderChangelog(&buf, changelog, nil)
+	if strings.Contains(buf.String(), "sk-") || !strings.Contains(buf.String(), "port") {
+		t.Errorf("changelog leaks the secret or lost other changes: %s", buf.String())
+	}
+
+	var audit bytes.Buffer
+	manager.ExportAuditLog(ctx, &audit, time.Time{})
+	if strings.Contains(audit.String(), "sk-") {
+		t.Errorf("audit log leaks the secret: %s", audit.String())
+	}
+
+	// Stored data and reads are untouched
+	cfg, err := manager.GetLatest(ctx, "app")
+	if err != nil || !bytes.Contains(cfg.Content, []byte("sk-2")) {
+		t.Fatalf("GetLatest returned %s, %v", cfg.Content, err)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("ValidateChain failed: %v", err)
+	}
+
+	// A redacted export does not verify, so it cannot be imported
+	other, _ := NewManager(NewMemoryStorage())
+	if err := other.Import(ctx, "app", exported); err == nil {
+		t.Error("expected the redacted export to be refused")
+	}
+}
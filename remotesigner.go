@@ -0,0 +1,66 @@
+package viracochan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// RemoteSignFunc signs msg, a 32-byte SHA-256 digest, with BIP-340 Schnorr
+// over secp256k1 and returns the 64-byte signature hex-encoded. It is how a
+// Signer reaches a key held outside the process, such as in an HSM or KMS.
+type RemoteSignFunc func(msg []byte) (sig string, err error)
+
+// NewRemoteSigner returns a Signer whose private key never enters the
+// process: every signature is requested from signFunc, and publicKey is the
+// hex x-only public key of the remote key. The message passed to signFunc is
+// SigningMessage of the config being signed, so the result verifies with the
+// standard Verify and VerifyConfigSignature. Delegate signs through signFunc
+// too, with the digest of the delegation.
+//
+// Each returned signature is verified under publicKey before it is used, so a
+// misconfigured service fails the write instead of storing a bad signature.
+func NewRemoteSigner(signFunc RemoteSignFunc, publicKey string) (*Signer, error) {
+	if signFunc == nil {
+		return nil, errors.New("remote sign function must not be nil")
+	}
+	key, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if _, err := schnorr.ParsePubKey(key); err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	return &Signer{publicKey: publicKey, remote: signFunc}, nil
+}
+
+// SigningMessage returns the 32-byte digest that Sign signs for cfg in the
+// current signature format: the SHA-256 of the format's signing payload. It
+// is what a RemoteSignFunc receives, exposed so external signers and
+// verifiers can reproduce it.
+func SigningMessage(cfg *Config) ([]byte, error) {
+	if cfg.Meta.CS == "" {
+		return nil, errors.New("config must have checksum before signing")
+	}
+	payload, err := signingFormats[currentSignatureAlgorithm](cfg, "")
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(payload)
+	return hash[:], nil
+}
+
+func (s *Signer) signRemote(hash []byte) (string, error) {
+	sig, err := s.remote(append([]byte(nil), hash...))
+	if err != nil {
+		return "", fmt.Errorf("remote signer: %w", err)
+	}
+	if err := verifyHash(hash, sig, s.publicKey); err != nil {
+		return "", fmt.Errorf("remote signer returned a bad signature: %w", err)
+	}
+	return sig, nil
+}
@@ -0,0 +1,85 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// fakeKMS holds a key outside any Signer and signs digests on request
+type fakeKMS struct {
+	key   *btcec.PrivateKey
+	calls int
+}
+
+func (k *fakeKMS) sign(msg []byte) (string, error) {
+	k.calls++
+	sig, err := schnorr.Sign(k.key, msg)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig.Serialize()), nil
+}
+
+func (k *fakeKMS) publicKey() string {
+	return hex.EncodeToString(schnorr.SerializePubKey(k.key.PubKey()))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	ctx := context.Background()
+	key, _ := btcec.NewPrivateKey()
+	kms := &fakeKMS{key: key}
+
+	signer, err := NewRemoteSigner(kms.sign, kms.publicKey())
+	if err != nil {
+		t.Fatalf("NewRemoteSigner failed: %v", err)
+	}
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+
+	cfg, err := manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if kms.calls != 1 {
+		t.Errorf("expected one remote signature, got %d", kms.calls)
+	}
+	if err := VerifyConfigSignature(cfg, kms.publicKey()); err != nil {
+		t.Errorf("remote signature does not verify: %v", err)
+	}
+
+	// The exposed message is exactly what was signed
+	msg, _ := SigningMessage(cfg)
+	sigBytes, _ := hex.DecodeString(cfg.Meta.Signature)
+	sig, _ := schnorr.ParseSignature(sigBytes)
+	if !sig.Verify(msg, key.PubKey()) {
+		t.Error("signature is not over SigningMessage")
+	}
+
+	d, err := signer.Delegate(kms.publicKey(), []string{"app"}, time.Time{})
+	if err != nil || d.Verify() != nil {
+		t.Errorf("remote delegation failed: %v", err)
+	}
+
+	// A service answering with another key's signature fails the write
+	other, _ := btcec.NewPrivateKey()
+	wrong, _ := NewRemoteSigner((&fakeKMS{key: other}).sign, kms.publicKey())
+	bad, _ := NewManager(NewMemoryStorage(), WithSigner(wrong))
+	if _, err := bad.Create(ctx, "app", map[string]interface{}{}); err == nil {
+		t.Error("expected a mismatched remote signature to fail")
+	}
+
+	failing, _ := NewRemoteSigner(func([]byte) (string, error) { return "", errors.New("hsm offline") }, kms.publicKey())
+	down, _ := NewManager(NewMemoryStorage(), WithSigner(failing))
+	if _, err := down.Create(ctx, "app", map[string]interface{}{}); err == nil {
+		t.Error("expected a remote failure to fail the write")
+	}
+
+	if _, err := NewRemoteSigner(kms.sign, "zz"); err == nil {
+		t.Error("expected error for an invalid public key")
+	}
+}
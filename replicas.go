@@ -0,0 +1,208 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrStaleReplica is recorded in a ReadTrace when a replica served an older
+// head than the manager has already seen; the read then falls through
+var ErrStaleReplica = errors.New("stale replica")
+
+// ReplicaPolicy selects the order in which reads try their sources
+type ReplicaPolicy int
+
+const (
+	// ReplicaRoundRobin spreads reads over the replicas in turn and falls
+	// through to the other replicas, then the primary
+	ReplicaRoundRobin ReplicaPolicy = iota
+	// ReplicaPrimaryPreferred reads from the primary and only uses the
+	// replicas, in order, when it fails
+	ReplicaPrimaryPreferred
+)
+
+// SourcePrimary and SourceCache name the sources of a ReadTrace besides the
+// replicas, which are named "replica-<index>"
+const (
+	SourcePrimary = "primary"
+	SourceCache   = "cache"
+)
+
+// WithReadReplicas serves Get, GetLatest and List from replicas, copies of
+// the store kept up to date by some external means (object storage
+// replication, rsync, a read-only mount). Each replica holds both the journal
+// and the config files in the primary's layout. Reads try the sources in the
+// order of WithReplicaPolicy and fall through to the next on any error,
+// ending at the primary; everything else, including the reads a write makes,
+// uses the primary only.
+//
+// Replicas are trusted no more than the primary: loaded configs are checksum
+// validated, and signatures checked when trusted keys are configured. A
+// replica that returns an older head than this manager has written or read
+// is skipped with ErrStaleReplica, but staleness within what the manager has
+// never seen, such as another node's latest write, is not detectable; use
+// the primary (GetLatest without replicas) where that matters.
+func WithReadReplicas(replicas []Storage) ManagerOption {
+	return func(m *Manager) error {
+		if len(replicas) == 0 {
+			return errors.New("no read replicas given")
+		}
+		for i, r := range replicas {
+			if r == nil {
+				return fmt.Errorf("read replica %d is nil", i)
+			}
+		}
+		m.replicaStorages = append([]Storage(nil), replicas...)
+		return nil
+	}
+}
+
+// WithReplicaPolicy sets the order in which reads try the read replicas;
+// the default is ReplicaRoundRobin
+func WithReplicaPolicy(policy ReplicaPolicy) ManagerOption {
+	return func(m *Manager) error {
+		if policy != ReplicaRoundRobin && policy != ReplicaPrimaryPreferred {
+			return fmt.Errorf("unknown replica policy %d", policy)
+		}
+		m.replicaPolicy = policy
+		return nil
+	}
+}
+
+// ReadTrace records which source served a read, for debugging replica
+// setups. Skipped holds the sources tried before it and why they failed.
+type ReadTrace struct {
+	Source  string
+	Skipped []SkippedSource
+}
+
+// SkippedSource is a source a read fell through
+type SkippedSource struct {
+	Source string
+	Err    error
+}
+
+type readTraceKey struct{}
+
+// WithReadTrace returns a context that makes Get, GetLatest, GetLatestFresh
+// and List record
+// in trace where they read from:
+//
+//	var trace viracochan.ReadTrace
+//	cfg, err := manager.GetLatest(viracochan.WithReadTrace(ctx, &trace), "app")
+//	log.Printf("v%d from %s", cfg.Meta.Version, trace.Source)
+func WithReadTrace(ctx context.Context, trace *ReadTrace) context.Context {
+	return context.WithValue(ctx, readTraceKey{}, trace)
+}
+
+// readSource is one store reads can be served from
+type readSource struct {
+	name        string
+	primary     bool
+	storage     Storage
+	journal     *Journal
+	configStore *ConfigStorage
+}
+
+// setupReplicas builds the replica sources once the options are applied, so
+// they share the config store's verification settings
+func (m *Manager) setupReplicas() {
+	for i, storage := range m.replicaStorages {
+		cs := *m.configStore
+		cs.storage = storage
+		journal := NewJournal(storage, m.journal.path)
+		journal.SetLogger(m.logger)
+		journal.SetClockSkewTolerance(m.clockSkew)
+		m.replicas = append(m.replicas, &readSource{
+			name:        fmt.Sprintf("replica-%d", i),
+			storage:     storage,
+			journal:     journal,
+			configStore: &cs,
+		})
+	}
+}
+
+// readSources returns the sources to try, in order
+func (m *Manager) readSources() []*readSource {
+	primary := &readSource{
+		name:        SourcePrimary,
+		primary:     true,
+		storage:     m.storage,
+		journal:     m.journal,
+		configStore: m.configStore,
+	}
+	if len(m.replicas) == 0 {
+		return []*readSource{primary}
+	}
+
+	sources := make([]*readSource, 0, len(m.replicas)+1)
+	if m.replicaPolicy == ReplicaPrimaryPreferred {
+		return append(append(sources, primary), m.replicas...)
+	}
+	start := int(m.replicaNext.Add(1)-1) % len(m.replicas)
+	sources = append(sources, m.replicas[start:]...)
+	sources = append(sources, m.replicas[:start]...)
+	return append(sources, primary)
+}
+
+// readThrough calls read with each source in turn until one succeeds, and
+// records the outcome in the context's ReadTrace. The error of the last
+// source is returned when all fail.
+func (m *Manager) readThrough(ctx context.Context, read func(src *readSource) error) error {
+	trace, _ := ctx.Value(readTraceKey{}).(*ReadTrace)
+
+	var err error
+	for _, src := range m.readSources() {
+		if err = read(src); err == nil {
+			if trace != nil {
+				trace.Source = src.name
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if !src.primary {
+			m.logger.Debug("read replica skipped", "replica", src.name, "error", err)
+		}
+		if trace != nil {
+			trace.Skipped = append(trace.Skipped, SkippedSource{Source: src.name, Err: err})
+		}
+	}
+	return err
+}
+
+// readLatest is getLatest served through the read sources
+func (m *Manager) readLatest(ctx context.Context, id string) (*Config, error) {
+	if cfg, ok := m.cacheGet(id); ok {
+		if trace, _ := ctx.Value(readTraceKey{}).(*ReadTrace); trace != nil {
+			trace.Source = SourceCache
+		}
+		return cfg, nil
+	}
+
+	var cfg *Config
+	err := m.readThrough(ctx, func(src *readSource) error {
+		latest, err := src.journal.Reconstruct(ctx, id, src.storage)
+		if err != nil {
+			return err
+		}
+		if err := src.configStore.verifySignature(latest); err != nil {
+			return err
+		}
+		if !src.primary {
+			if seen := m.seenVersion(id); latest.Meta.Version < seen {
+				return fmt.Errorf("%w: %s has %q at v%d, v%d already seen", ErrStaleReplica, src.name, id, latest.Meta.Version, seen)
+			}
+		}
+		cfg = latest
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.cachePut(id, cfg)
+	return cfg, nil
+}
@@ -0,0 +1,115 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// syncReplica copies every file of primary to replica
+func syncReplica(t *testing.T, primary, replica Storage) {
+	t.Helper()
+	ctx := context.Background()
+	paths, err := primary.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	for _, path := range paths {
+		replica.Write(ctx, path, mustRead(t, primary, path))
+	}
+}
+
+func TestReadReplicas(t *testing.T) {
+	ctx := context.Background()
+	primary, r0, r1 := NewMemoryStorage(), NewMemoryStorage(), NewMemoryStorage()
+	manager, _ := NewManager(primary, WithReadReplicas([]Storage{r0, r1}), WithCacheTTL(time.Nanosecond))
+
+	manager.Create(ctx, "app", map[string]interface{}{"v": 1})
+	syncReplica(t, primary, r0)
+	syncReplica(t, primary, r1)
+
+	// Reads rotate over the replicas
+	for _, want := range []string{"replica-0", "replica-1", "replica-0"} {
+		var trace ReadTrace
+		if _, err := manager.Get(WithReadTrace(ctx, &trace), "app", 1); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if trace.Source != want || len(trace.Skipped) != 0 {
+			t.Errorf("expected a read from %s, got %+v", want, trace)
+		}
+	}
+
+	// Writes go to the primary, and replicas behind it are skipped
+	manager.Update(ctx, "app", map[string]interface{}{"v": 2})
+	if ok, _ := r0.Exists(ctx, "configs/app/v2.json"); ok {
+		t.Error("write reached a replica")
+	}
+	var trace ReadTrace
+	cfg, err := manager.GetLatest(WithReadTrace(ctx, &trace), "app")
+	if err != nil || cfg.Meta.Version != 2 {
+		t.Fatalf("expected v2, got %v, %v", cfg, err)
+	}
+	if trace.Source != SourcePrimary || len(trace.Skipped) != 2 || !errors.Is(trace.Skipped[0].Err, ErrStaleReplica) {
+		t.Errorf("expected both stale replicas skipped, got %+v", trace)
+	}
+
+	syncReplica(t, primary, r0)
+	syncReplica(t, primary, r1)
+	trace = ReadTrace{}
+	if cfg, _ := manager.GetLatest(WithReadTrace(ctx, &trace), "app"); cfg.Meta.Version != 2 || trace.Source == SourcePrimary {
+		t.Errorf("expected v2 from a replica, got v%d from %s", cfg.Meta.Version, trace.Source)
+	}
+
+	// A replica serving a corrupt file falls through to the next source
+	r1.Write(ctx, "configs/app/v1.json", []byte(`{"_meta":{"v":1,"cs":"bad"},"content":{}}`))
+	for range 2 {
+		trace = ReadTrace{}
+		if _, err := manager.Get(WithReadTrace(ctx, &trace), "app", 1); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if trace.Source != "replica-0" {
+			t.Errorf("expected replica-0 to serve around replica-1, got %+v", trace)
+		}
+	}
+
+	trace = ReadTrace{}
+	ids, err := manager.List(WithReadTrace(ctx, &trace))
+	if err != nil || len(ids) != 1 || trace.Source == SourcePrimary {
+		t.Errorf("expected List from a replica, got %v from %s, %v", ids, trace.Source, err)
+	}
+}
+
+func TestReadReplicasPrimaryPreferred(t *testing.T) {
+	ctx := context.Background()
+	primary := &outageStorage{MemoryStorage: NewMemoryStorage()}
+	replica := NewMemoryStorage()
+	manager, _ := NewManager(primary, WithReadReplicas([]Storage{replica}),
+		WithReplicaPolicy(ReplicaPrimaryPreferred), WithCacheTTL(time.Nanosecond))
+
+	manager.Create(ctx, "app", map[string]interface{}{"v": 1})
+	syncReplica(t, primary, replica)
+
+	var trace ReadTrace
+	manager.GetLatest(WithReadTrace(ctx, &trace), "app")
+	if trace.Source != SourcePrimary {
+		t.Errorf("expected the primary, got %+v", trace)
+	}
+
+	primary.down.Store(true)
+	trace = ReadTrace{}
+	cfg, err := manager.GetLatest(WithReadTrace(ctx, &trace), "app")
+	if err != nil || cfg.Meta.Version != 1 {
+		t.Fatalf("expected the replica to serve during the outage, got %v", err)
+	}
+	if trace.Source != "replica-0" || len(trace.Skipped) != 1 || !errors.Is(trace.Skipped[0].Err, errTransient) {
+		t.Errorf("unexpected trace: %+v", trace)
+	}
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"v": 2}); err == nil {
+		t.Error("writes must not fall back to a replica")
+	}
+
+	if _, err := NewManager(primary, WithReadReplicas(nil)); err == nil {
+		t.Error("expected an error for no replicas")
+	}
+}
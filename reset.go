@@ -0,0 +1,268 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrResetNotConfirmed is returned by Reset without ConfirmReset
+var ErrResetNotConfirmed = errors.New("reset not confirmed")
+
+// ResetOption configures Reset
+type ResetOption func(*resetOptions)
+
+type resetOptions struct {
+	confirmed bool
+	discard   bool
+	archiveID string
+	write     []WriteOption
+}
+
+// ConfirmReset acknowledges that Reset replaces the history of the id. Reset
+// refuses to run without it.
+func ConfirmReset() ResetOption {
+	return func(o *resetOptions) {
+		o.confirmed = true
+	}
+}
+
+// ResetDiscard deletes the old chain instead of archiving it
+func ResetDiscard() ResetOption {
+	return func(o *resetOptions) {
+		o.discard = true
+	}
+}
+
+// ResetArchiveID archives the old chain under archiveID instead of the
+// default <id>.reset-<UTC timestamp>. The archive id must not have versions.
+func ResetArchiveID(archiveID string) ResetOption {
+	return func(o *resetOptions) {
+		o.archiveID = archiveID
+	}
+}
+
+// ResetWriteOptions applies opts to the fresh v1
+func ResetWriteOptions(opts ...WriteOption) ResetOption {
+	return func(o *resetOptions) {
+		o.write = append(o.write, opts...)
+	}
+}
+
+// Reset discards the history of id and starts a fresh lineage with content as
+// v1, journaled as "reset". It is an escape hatch for chains too damaged to
+// repair and refuses to run without ConfirmReset.
+//
+// By default the old chain is kept for forensics: its version files are
+// copied byte for byte, and its journal entries and chain root relabeled, to
+// an archive id (see ResetArchiveID), where it reads like any other config.
+// ResetDiscard deletes it instead. Journal lines that do not parse are left
+// in place. The id's channel pointers and base link are removed, since they
+// refer to the old chain. The steps are not atomic: a failure part-way can
+// leave the archive written and the old chain partly removed, and Reset can
+// simply be retried.
+func (m *Manager) Reset(ctx context.Context, id string, content interface{}, opts ...ResetOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "reset", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	var ro resetOptions
+	for _, opt := range opts {
+		opt(&ro)
+	}
+	if !ro.confirmed {
+		return nil, fmt.Errorf("%w: pass ConfirmReset to reset %q", ErrResetNotConfirmed, id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	wo := newWriteOptions(ro.write)
+	return m.replaceLineage(ctx, id, content, &ro, wo, "reset", wo.message)
+}
+
+// replaceLineage is Reset without the confirmation and lock, journaling the
+// new v1 as op with message. Callers hold mu.
+func (m *Manager) replaceLineage(ctx context.Context, id string, content interface{}, ro *resetOptions, wo *writeOptions, op, message string) (*Config, error) {
+	// Build the new v1 first so that bad content fails before anything is
+	// removed
+	cfg, err := newConfig(content, m.hashAlg)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkCrossRef(ctx, id, cfg); err != nil {
+		return nil, err
+	}
+	if err := m.seal(cfg, wo); err != nil {
+		return nil, err
+	}
+	if err := m.checkRegistry(ctx, id, cfg); err != nil {
+		return nil, err
+	}
+	if err := m.checkPolicies(id, cfg); err != nil {
+		return nil, err
+	}
+	if _, err := m.quotaPrune(id, cfg, nil); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkFence(ctx); err != nil {
+		return nil, err
+	}
+
+	archiveID := ""
+	if !ro.discard {
+		if archiveID, err = m.resetArchiveID(ctx, id, ro.archiveID, "reset"); err != nil {
+			return nil, err
+		}
+	}
+	versions, moved, err := m.archiveLineage(ctx, id, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sidecar := range []string{m.channelPath(id), m.basePath(id), m.schemaPath(id)} {
+		if err := m.storage.Delete(ctx, sidecar); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	m.logger.Warn("reset config", "id", id, "op", op, "versions", versions, "entries", moved, "archive", archiveID)
+
+	if err := m.persist(ctx, id, cfg, op, message); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// archiveLineage moves the chain of id, version files and journal entries
+// alike, to archiveID, or deletes it when archiveID is empty, and returns the
+// number of versions and entries moved. The id's sidecars are left alone.
+// Callers hold mu.
+func (m *Manager) archiveLineage(ctx context.Context, id, archiveID string) (int, int, error) {
+	versions, err := m.configStore.ListVersions(ctx, id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if archiveID != "" {
+		for _, v := range versions {
+			data, err := m.storage.Read(ctx, m.configStore.makeKey(id, v))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return 0, 0, err
+			}
+			if err := m.storage.Write(ctx, m.configStore.makeKey(archiveID, v), data); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	moved, err := m.journal.relabel(ctx, id, archiveID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := m.configStore.DeleteAll(ctx, id); err != nil {
+		return 0, 0, err
+	}
+	m.cacheDelete(id)
+	m.indexReset()
+	return len(versions), moved, nil
+}
+
+// Squash flattens the history of id into a fresh v1 holding its current
+// content, journaled as "squashed_from_vN" with N the version it replaces,
+// e.g. before exporting a config to a system that has no use for its
+// history. Unlike AutoPrune, which keeps the recent versions, nothing of the old
+// chain stays under id. It is always archived, as by Reset, under
+// <id>.squash-<UTC timestamp> or ResetArchiveID, and the journal message of
+// the new v1 names the archive unless ResetWriteOptions gives one. The chain
+// must validate first, so that tampered content is not made authoritative.
+// Squash needs no ConfirmReset and refuses ResetDiscard.
+func (m *Manager) Squash(ctx context.Context, id string, opts ...ResetOption) (cfg *Config, err error) {
+	ctx, call := m.begin(ctx, "squash", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	var ro resetOptions
+	for _, opt := range opts {
+		opt(&ro)
+	}
+	if ro.discard {
+		return nil, fmt.Errorf("squash of %q always archives the old chain", id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w for %q", ErrNoJournalEntries, id)
+	}
+	ordered, err := m.journal.Resequence(entries)
+	if err != nil {
+		return nil, m.chainFailure("squash", err)
+	}
+	if err := m.validateOrdered(ctx, id, ordered); err != nil {
+		return nil, m.chainFailure("squash", err)
+	}
+	head := ordered[len(ordered)-1]
+	current, err := m.entryConfig(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+
+	if ro.archiveID, err = m.resetArchiveID(ctx, id, ro.archiveID, "squash"); err != nil {
+		return nil, err
+	}
+	wo := newWriteOptions(ro.write)
+	if wo.contentType == nil {
+		wo.contentType = &current.Meta.ContentType
+	}
+	message := wo.message
+	if message == "" {
+		message = fmt.Sprintf("squashed v1-v%d, archived as %s", head.Version, ro.archiveID)
+	}
+	return m.replaceLineage(ctx, id, current.Content, &ro, wo, fmt.Sprintf("squashed_from_v%d", head.Version), message)
+}
+
+// resetArchiveID returns the id to archive id's old chain under: requested if
+// given, otherwise one timestamped and tagged with kind. It must be valid
+// and unused.
+func (m *Manager) resetArchiveID(ctx context.Context, id, requested, kind string) (string, error) {
+	archiveID := requested
+	if archiveID == "" {
+		archiveID = fmt.Sprintf("%s.%s-%s", id, kind, time.Now().UTC().Format("20060102T150405.000000000Z"))
+	}
+	if err := m.validateID(archiveID); err != nil {
+		return "", fmt.Errorf("archive id: %w", err)
+	}
+
+	versions, err := m.configStore.ListVersions(ctx, archiveID)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) > 0 {
+		return "", fmt.Errorf("%w: archive id %q already has versions", ErrVersionConflict, archiveID)
+	}
+	return archiveID, nil
+}
@@ -0,0 +1,149 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReset(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Create(ctx, "db", map[string]interface{}{"host": "a"})
+	manager.Promote(ctx, "app", 2, "stable")
+
+	if _, err := manager.Reset(ctx, "app", map[string]interface{}{"n": 0}); !errors.Is(err, ErrResetNotConfirmed) {
+		t.Fatalf("expected ErrResetNotConfirmed, got %v", err)
+	}
+
+	// A damaged line survives the reset untouched
+	journal := append(mustRead(t, storage, "journal.jsonl"), "{torn\n"...)
+	storage.Write(ctx, "journal.jsonl", journal)
+
+	cfg, err := manager.Reset(ctx, "app", map[string]interface{}{"n": 0}, ConfirmReset(), ResetArchiveID("app.old"))
+	if err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if cfg.Meta.Version != 1 || cfg.Meta.PrevCS != "" {
+		t.Errorf("expected a fresh v1, got %+v", cfg.Meta)
+	}
+
+	history, _ := manager.GetHistory(ctx, "app")
+	if len(history) != 1 {
+		t.Errorf("expected 1 version after reset, got %d", len(history))
+	}
+	if _, err := manager.GetChannel(ctx, "app", "stable"); err == nil {
+		t.Error("channel pointer should be removed")
+	}
+	if data := mustRead(t, storage, "journal.jsonl"); !containsLine(data, "{torn") {
+		t.Error("unparseable journal line should be kept")
+	}
+
+	// Repair the journal to read the archive through the Manager
+	storage.Write(ctx, "journal.jsonl", removeLine(mustRead(t, storage, "journal.jsonl"), "{torn"))
+	archived, err := manager.GetHistory(ctx, "app.old")
+	if err != nil || len(archived) != 2 {
+		t.Fatalf("expected 2 archived versions, got %d, %v", len(archived), err)
+	}
+	if err := manager.ValidateChain(ctx, "app.old"); err != nil {
+		t.Errorf("archived chain invalid: %v", err)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("fresh chain invalid: %v", err)
+	}
+
+	if _, err := manager.Reset(ctx, "db", map[string]interface{}{}, ConfirmReset(), ResetArchiveID("app.old")); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict for a used archive id, got %v", err)
+	}
+	if _, err := manager.Reset(ctx, "db", map[string]interface{}{"host": "b"}, ConfirmReset(), ResetDiscard()); err != nil {
+		t.Fatalf("Reset with discard failed: %v", err)
+	}
+	ids, _ := manager.List(ctx)
+	if len(ids) != 3 {
+		t.Errorf("expected app, app.old and db, got %v", ids)
+	}
+}
+
+func TestSquash(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	if _, err := manager.Squash(ctx, "app"); !errors.Is(err, ErrNoJournalEntries) {
+		t.Fatalf("Squash of a missing id: got %v, want ErrNoJournalEntries", err)
+	}
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	latest, _ := manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+
+	if _, err := manager.Squash(ctx, "app", ResetDiscard()); err == nil {
+		t.Fatal("expected Squash to refuse ResetDiscard")
+	}
+
+	cfg, err := manager.Squash(ctx, "app", ResetArchiveID("app.history"))
+	if err != nil {
+		t.Fatalf("Squash failed: %v", err)
+	}
+	if cfg.Meta.Version != 1 || cfg.Meta.PrevCS != "" || string(cfg.Content) != string(latest.Content) {
+		t.Errorf("expected a v1 with the latest content, got %+v %s", cfg.Meta, cfg.Content)
+	}
+	if err := manager.Verify(cfg, signer.PublicKey()); err != nil {
+		t.Errorf("squashed version not signed: %v", err)
+	}
+
+	entries, _ := manager.journal.FindByID(ctx, "app")
+	if len(entries) != 1 || entries[0].Operation != "squashed_from_v3" {
+		t.Fatalf("expected one squashed_from_v3 entry, got %+v", entries)
+	}
+	if !strings.Contains(entries[0].Message, "app.history") {
+		t.Errorf("journal message does not name the archive: %q", entries[0].Message)
+	}
+
+	archived, err := manager.GetHistory(ctx, "app.history")
+	if err != nil || len(archived) != 3 {
+		t.Fatalf("expected 3 archived versions, got %d, %v", len(archived), err)
+	}
+	if err := manager.ValidateChain(ctx, "app.history"); err != nil {
+		t.Errorf("archived chain invalid: %v", err)
+	}
+	if err := manager.ValidateChain(ctx, "app"); err != nil {
+		t.Errorf("squashed chain invalid: %v", err)
+	}
+
+	// A tampered head is not made authoritative
+	manager.Update(ctx, "app", map[string]interface{}{"n": 4})
+	journal := mustRead(t, storage, "journal.jsonl")
+	tampered := strings.Replace(string(journal), `"content":{"n":4}`, `"content":{"n":5}`, 1)
+	if tampered == string(journal) {
+		t.Fatalf("journal has no embedded v2 content to tamper with:\n%s", journal)
+	}
+	storage.Write(ctx, "journal.jsonl", []byte(tampered))
+	if _, err := manager.Squash(ctx, "app"); err == nil {
+		t.Error("expected Squash of a tampered chain to fail")
+	}
+	if history, _ := manager.GetHistory(ctx, "app"); len(history) != 2 {
+		t.Errorf("failed squash changed the history: %d versions", len(history))
+	}
+}
+
+func containsLine(data []byte, line string) bool {
+	return slices.Contains(strings.Split(string(data), "\n"), line)
+}
+
+func removeLine(data []byte, line string) []byte {
+	var out strings.Builder
+	for _, l := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if l != line {
+			out.WriteString(l + "\n")
+		}
+	}
+	return []byte(out.String())
+}
@@ -0,0 +1,152 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy controls how RetryingStorage retries failed operations
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; defaults to 3.
+	BaseDelay   time.Duration // Delay before the first retry; defaults to 50ms.
+	MaxDelay    time.Duration // Upper bound for a single delay; defaults to 2s.
+	Jitter      float64       // Fraction of each delay randomized, 0..1.
+
+	// Retryable classifies errors; nil uses IsRetryableStorageError.
+	Retryable func(error) bool
+	// Metrics receives a MetricStorageRetries increment per retry.
+	Metrics Metrics
+}
+
+// IsRetryableStorageError reports whether err may be transient. Not-found
+// errors and context cancellation are permanent.
+func IsRetryableStorageError(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, os.ErrNotExist), os.IsNotExist(err):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// RetryingStorage wraps a Storage and retries transient failures with
+// exponential backoff and jitter, honoring the context deadline
+type RetryingStorage struct {
+	backend Storage
+	policy  RetryPolicy
+	retries atomic.Int64
+}
+
+// NewRetryingStorage wraps backend with the given retry policy
+func NewRetryingStorage(backend Storage, policy RetryPolicy) *RetryingStorage {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = 50 * time.Millisecond
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 2 * time.Second
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryableStorageError
+	}
+	if policy.Metrics == nil {
+		policy.Metrics = NopMetrics{}
+	}
+	return &RetryingStorage{backend: backend, policy: policy}
+}
+
+// Retries returns the total number of retries performed so far
+func (rs *RetryingStorage) Retries() int64 {
+	return rs.retries.Load()
+}
+
+func (rs *RetryingStorage) do(ctx context.Context, op string, fn func() error) error {
+	delay := rs.policy.BaseDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || !rs.policy.Retryable(err) || attempt >= rs.policy.MaxAttempts {
+			return err
+		}
+
+		wait := delay
+		if rs.policy.Jitter > 0 {
+			wait -= time.Duration(rand.Float64() * rs.policy.Jitter * float64(delay)) // #nosec G404 - jitter only
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		rs.retries.Add(1)
+		rs.policy.Metrics.IncCounter(MetricStorageRetries, 1, "op", op)
+
+		delay *= 2
+		if delay > rs.policy.MaxDelay {
+			delay = rs.policy.MaxDelay
+		}
+	}
+}
+
+func (rs *RetryingStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	var data []byte
+	err := rs.do(ctx, "read", func() error {
+		var err error
+		data, err = rs.backend.Read(ctx, path)
+		return err
+	})
+	return data, err
+}
+
+func (rs *RetryingStorage) Write(ctx context.Context, path string, data []byte) error {
+	return rs.do(ctx, "write", func() error {
+		return rs.backend.Write(ctx, path, data)
+	})
+}
+
+func (rs *RetryingStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	err := rs.do(ctx, "list", func() error {
+		var err error
+		paths, err = rs.backend.List(ctx, prefix)
+		return err
+	})
+	return paths, err
+}
+
+func (rs *RetryingStorage) Delete(ctx context.Context, path string) error {
+	return rs.do(ctx, "delete", func() error {
+		return rs.backend.Delete(ctx, path)
+	})
+}
+
+func (rs *RetryingStorage) Exists(ctx context.Context, path string) (bool, error) {
+	var exists bool
+	err := rs.do(ctx, "exists", func() error {
+		var err error
+		exists, err = rs.backend.Exists(ctx, path)
+		return err
+	})
+	return exists, err
+}
+
+// PathLock passes on the backend's lock of path, see PathLocker
+func (rs *RetryingStorage) PathLock(path string) sync.Locker {
+	return pathLock(rs.backend, path)
+}
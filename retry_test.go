@@ -0,0 +1,128 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient backend failure")
+
+// flakyStorage fails the first `failures` calls of every operation
+type flakyStorage struct {
+	*MemoryStorage
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (fs *flakyStorage) fail() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.calls++
+	if fs.calls <= fs.failures {
+		return errTransient
+	}
+	return nil
+}
+
+func (fs *flakyStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	if err := fs.fail(); err != nil {
+		return nil, err
+	}
+	return fs.MemoryStorage.Read(ctx, path)
+}
+
+func (fs *flakyStorage) Write(ctx context.Context, path string, data []byte) error {
+	if err := fs.fail(); err != nil {
+		return err
+	}
+	return fs.MemoryStorage.Write(ctx, path, data)
+}
+
+type countingMetrics struct {
+	NopMetrics
+	mu       sync.Mutex
+	counters map[string]float64
+}
+
+func (cm *countingMetrics) IncCounter(name string, delta float64, _ ...string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.counters == nil {
+		cm.counters = make(map[string]float64)
+	}
+	cm.counters[name] += delta
+}
+
+func TestRetryingStorageRetriesTransientErrors(t *testing.T) {
+	ctx := context.Background()
+	backend := &flakyStorage{MemoryStorage: NewMemoryStorage(), failures: 2}
+	metrics := &countingMetrics{}
+
+	storage := NewRetryingStorage(backend, RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Millisecond,
+		Jitter:      0.5,
+		Metrics:     metrics,
+	})
+
+	if err := storage.Write(ctx, "a.txt", []byte("data")); err != nil {
+		t.Fatalf("Write should succeed after retries: %v", err)
+	}
+	if storage.Retries() != 2 {
+		t.Errorf("expected 2 retries, got %d", storage.Retries())
+	}
+	if metrics.counters[MetricStorageRetries] != 2 {
+		t.Errorf("expected retry metric 2, got %v", metrics.counters[MetricStorageRetries])
+	}
+
+	data, err := storage.Read(ctx, "a.txt")
+	if err != nil || string(data) != "data" {
+		t.Errorf("Read failed: %q %v", data, err)
+	}
+}
+
+func TestRetryingStorageGivesUp(t *testing.T) {
+	ctx := context.Background()
+	backend := &flakyStorage{MemoryStorage: NewMemoryStorage(), failures: 10}
+	storage := NewRetryingStorage(backend, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	if err := storage.Write(ctx, "a.txt", []byte("x")); !errors.Is(err, errTransient) {
+		t.Errorf("expected transient error after exhausting attempts, got %v", err)
+	}
+	if backend.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", backend.calls)
+	}
+}
+
+func TestRetryingStorageDoesNotRetryNotFound(t *testing.T) {
+	ctx := context.Background()
+	storage := NewRetryingStorage(NewMemoryStorage(), RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond})
+
+	if _, err := storage.Read(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not-found, got %v", err)
+	}
+	if storage.Retries() != 0 {
+		t.Errorf("not-found must not be retried, got %d retries", storage.Retries())
+	}
+}
+
+func TestRetryingStorageHonorsDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	backend := &flakyStorage{MemoryStorage: NewMemoryStorage(), failures: 100}
+	storage := NewRetryingStorage(backend, RetryPolicy{MaxAttempts: 100, BaseDelay: 50 * time.Millisecond})
+
+	start := time.Now()
+	if err := storage.Write(ctx, "a.txt", []byte("x")); err == nil {
+		t.Fatal("expected failure")
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("retries should stop at the deadline, took %v", elapsed)
+	}
+}
@@ -0,0 +1,315 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// schemaPrefix is where WithAutoSchemaLock keeps the lock of each id, one
+// file per id
+const schemaPrefix = "schemas"
+
+// SchemaLock is the structure WithAutoSchemaLock holds an id to: the JSON
+// type of every object key, nested keys by dotted path as in Diff
+// ("database.ssl"). Keys holding null are left out, and arrays are locked as
+// a type without their elements. Version is the version it was inferred
+// from, 0 if set by hand.
+type SchemaLock struct {
+	Version uint64            `json:"v,omitempty"`
+	Fields  map[string]string `json:"fields"`
+}
+
+// WithAutoSchemaLock locks the structure of each id to its first version.
+// The first update of an id infers a SchemaLock from the version it updates
+// (its first, unless the id predates the option) and keeps it as a sidecar;
+// from then on Update and MergeUpdate reject content that removes a locked
+// key or changes its type with ErrSchemaDrift, listing every violation. New
+// keys are allowed but not locked. A write WithSchemaOverride skips the check
+// and relocks to its own content; SetSchemaLock edits the lock directly.
+// Unlike WithSchemaDriftCheck, which compares each update with the version
+// before it, the lock stays put, so a key dropped over several updates is
+// still caught. Non-JSON content is not checked.
+func WithAutoSchemaLock() ManagerOption {
+	return func(m *Manager) error {
+		m.schemaLock = driftReject
+		return nil
+	}
+}
+
+// WithAutoSchemaLockWarning is like WithAutoSchemaLock but only logs each
+// violation as a warning and lets the update through
+func WithAutoSchemaLockWarning() ManagerOption {
+	return func(m *Manager) error {
+		m.schemaLock = driftWarn
+		return nil
+	}
+}
+
+// WithSchemaOverride lets an update change the structure locked by
+// WithAutoSchemaLock and replaces the lock with one inferred from the new
+// content. It also lets an update drop properties declared by the schema
+// WithSchemaRegistry fetches; the content must still satisfy that schema.
+func WithSchemaOverride() WriteOption {
+	return func(o *writeOptions) {
+		o.schemaOverride = true
+	}
+}
+
+// SchemaLock returns the schema lock of id, or nil if none has been
+// inferred or set yet
+func (m *Manager) SchemaLock(ctx context.Context, id string) (*SchemaLock, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+	return m.readSchemaLock(ctx, id)
+}
+
+// SetSchemaLock replaces the schema lock of id, to add keys to it or
+// deliberately drop some. A nil lock removes it, so that the next update
+// infers a fresh one. Types are "object", "array", "string", "number" or
+// "boolean".
+func (m *Manager) SetSchemaLock(ctx context.Context, id string, lock *SchemaLock) (err error) {
+	ctx, call := m.begin(ctx, "set_schema_lock", id)
+	defer func() { call.end(nil, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	if lock == nil {
+		if err := m.storage.Delete(ctx, m.schemaPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	for path, typ := range lock.Fields {
+		switch typ {
+		case "object", "array", "string", "number", "boolean":
+		default:
+			return fmt.Errorf("schema lock of %q: %s has unknown type %q", id, path, typ)
+		}
+	}
+	return m.writeSchemaLock(ctx, id, lock)
+}
+
+func (m *Manager) schemaPath(id string) string {
+	return filepath.Join(schemaPrefix, id+".json")
+}
+
+func (m *Manager) readSchemaLock(ctx context.Context, id string) (*SchemaLock, error) {
+	data, err := m.storage.Read(ctx, m.schemaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lock SchemaLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("schema lock of %q: %w", id, err)
+	}
+	return &lock, nil
+}
+
+func (m *Manager) writeSchemaLock(ctx context.Context, id string, lock *SchemaLock) error {
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	return m.storage.Write(ctx, m.schemaPath(id), data)
+}
+
+// checkSchemaLock holds an update of id from current to data to its schema
+// lock, inferring the lock from current if there is none yet
+func (m *Manager) checkSchemaLock(ctx context.Context, id string, current *Config, data json.RawMessage, wo *writeOptions) error {
+	if m.schemaLock == driftOff || wo.schemaOverride || !isJSONContentType(current.Meta.ContentType) {
+		return nil
+	}
+	if wo.contentType != nil && !isJSONContentType(*wo.contentType) {
+		return nil
+	}
+
+	lock, err := m.readSchemaLock(ctx, id)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		if lock, err = inferSchemaLock(current.Content, current.Meta.Version); err != nil {
+			return err
+		}
+		if err := m.writeSchemaLock(ctx, id, lock); err != nil {
+			return err
+		}
+	}
+
+	violations, err := lock.check(data)
+	if err != nil || len(violations) == 0 {
+		return err
+	}
+
+	if m.schemaLock == driftWarn {
+		for _, d := range violations {
+			m.logger.Warn("schema lock violated", "id", id, "locked_at", lock.Version, "drift", d.String())
+		}
+		return nil
+	}
+
+	list := make([]string, len(violations))
+	for i, d := range violations {
+		list[i] = d.String()
+	}
+	return fmt.Errorf("%w in %q against the schema locked at v%d: %s", ErrSchemaDrift, id, lock.Version, strings.Join(list, "; "))
+}
+
+// relockSchema replaces the lock of id after a write WithSchemaOverride.
+// The version is already written, so a failure is only logged; the next
+// override or SetSchemaLock fixes it.
+func (m *Manager) relockSchema(ctx context.Context, id string, cfg *Config, wo *writeOptions) {
+	if m.schemaLock == driftOff || !wo.schemaOverride || !isJSONContentType(cfg.Meta.ContentType) {
+		return
+	}
+	lock, err := inferSchemaLock(cfg.Content, cfg.Meta.Version)
+	if err == nil {
+		err = m.writeSchemaLock(ctx, id, lock)
+	}
+	if err != nil {
+		m.logger.Warn("schema lock not updated", "id", id, "version", cfg.Meta.Version, "error", err)
+	}
+}
+
+// inferSchemaLock returns the schema lock of content written as version
+func inferSchemaLock(content json.RawMessage, version uint64) (*SchemaLock, error) {
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return nil, err
+	}
+	return &SchemaLock{Version: version, Fields: schemaFields(v)}, nil
+}
+
+// schemaFields maps the dotted path of every non-null object key in v to
+// its type
+func schemaFields(v interface{}) map[string]string {
+	fields := make(map[string]string)
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, child := range obj {
+			if child == nil {
+				continue
+			}
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			fields[path] = valueType(child)
+			walk(path, child)
+		}
+	}
+	walk("", v)
+	return fields
+}
+
+// check returns, ordered by path, every locked key content lacks or holds
+// with another type. Keys under one already reported, or under one set to
+// null, are skipped.
+func (l *SchemaLock) check(content json.RawMessage) ([]Drift, error) {
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return nil, err
+	}
+	found := schemaFields(v)
+
+	paths := make([]string, 0, len(l.Fields))
+	for path := range l.Fields {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var drift []Drift
+	skip := make(map[string]bool)
+	for _, path := range paths {
+		if underSkipped(path, skip) {
+			continue
+		}
+		want := l.Fields[path]
+		got, ok := found[path]
+		switch {
+		case !ok && isNullAt(v, path):
+		case !ok:
+			drift = append(drift, Drift{Path: path, Kind: ChangeRemoved, OldType: want})
+		case got != want:
+			drift = append(drift, Drift{Path: path, Kind: ChangeChanged, OldType: want, NewType: got})
+		default:
+			continue
+		}
+		skip[path] = true
+	}
+	return drift, nil
+}
+
+// underSkipped reports whether an ancestor of the dotted path is in skip
+func underSkipped(path string, skip map[string]bool) bool {
+	for i := strings.LastIndexByte(path, '.'); i >= 0; i = strings.LastIndexByte(path, '.') {
+		path = path[:i]
+		if skip[path] {
+			return true
+		}
+	}
+	return false
+}
+
+// isNullAt reports whether the dotted path holds an explicit null in v. As
+// in SchemaDrift, changes to null are not violations.
+func isNullAt(v interface{}, path string) bool {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if v, ok = obj[key]; !ok {
+			return false
+		}
+	}
+	return v == nil
+}
+
+// valueType names the JSON type of a decoded value, as jsonType does for an
+// encoded one
+func valueType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return "number"
+}
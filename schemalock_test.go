@@ -0,0 +1,93 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAutoSchemaLock(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage(), WithAutoSchemaLock())
+
+	db := map[string]interface{}{"host": "db1", "ssl": true}
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"database": db, "port": 80, "tags": []string{"a"}}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// New keys and changed values are fine, and the first update locks v1
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"database": db, "port": 81, "tags": []string{}, "extra": "x"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	lock, err := manager.SchemaLock(ctx, "app")
+	if err != nil || lock == nil {
+		t.Fatalf("expected a schema lock, got %v, %v", lock, err)
+	}
+	if lock.Version != 1 || lock.Fields["database.ssl"] != "boolean" || lock.Fields["tags"] != "array" {
+		t.Errorf("unexpected lock %+v", lock)
+	}
+	if _, ok := lock.Fields["extra"]; ok {
+		t.Error("keys added after v1 should not be locked")
+	}
+
+	// Dropping database.ssl, or retyping port, is rejected
+	_, err = manager.Update(ctx, "app", map[string]interface{}{"database": map[string]interface{}{"host": "db2"}, "port": "81", "tags": []string{}})
+	if !errors.Is(err, ErrSchemaDrift) {
+		t.Fatalf("expected ErrSchemaDrift, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "database.ssl removed (was boolean)") || !strings.Contains(msg, "port changed from number to string") {
+		t.Errorf("expected every violation listed, got %v", err)
+	}
+
+	// Removing a locked object reports it once, not its children
+	_, err = manager.Update(ctx, "app", map[string]interface{}{"port": 82, "tags": []string{}})
+	if !errors.Is(err, ErrSchemaDrift) || strings.Contains(err.Error(), "database.host") {
+		t.Errorf("expected only database reported, got %v", err)
+	}
+
+	// An override goes through and relocks
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"port": "82"}, WithSchemaOverride()); err != nil {
+		t.Fatalf("override failed: %v", err)
+	}
+	lock, _ = manager.SchemaLock(ctx, "app")
+	if lock.Version != 3 || len(lock.Fields) != 1 || lock.Fields["port"] != "string" {
+		t.Errorf("expected a lock of v3, got %+v", lock)
+	}
+
+	// A deliberate edit of the lock
+	lock.Fields["region"] = "string"
+	if err := manager.SetSchemaLock(ctx, "app", lock); err != nil {
+		t.Fatalf("SetSchemaLock failed: %v", err)
+	}
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"port": "83"}); !errors.Is(err, ErrSchemaDrift) {
+		t.Errorf("expected the added key to be enforced, got %v", err)
+	}
+	if err := manager.SetSchemaLock(ctx, "app", &SchemaLock{Fields: map[string]string{"port": "int"}}); err == nil {
+		t.Error("expected an unknown type to be rejected")
+	}
+	if err := manager.SetSchemaLock(ctx, "app", nil); err != nil {
+		t.Fatalf("removing the lock failed: %v", err)
+	}
+	if lock, _ := manager.SchemaLock(ctx, "app"); lock != nil {
+		t.Errorf("expected no lock, got %+v", lock)
+	}
+}
+
+func TestAutoSchemaLockWarning(t *testing.T) {
+	ctx := context.Background()
+	logger := &recordingLogger{}
+	manager, _ := NewManager(NewMemoryStorage(), WithAutoSchemaLockWarning(), WithLogger(logger))
+
+	manager.Create(ctx, "app", map[string]interface{}{"a": 1, "b": "x"})
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"a": 2}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	// Setting a locked key to null is not a violation
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"a": 3, "b": nil}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(logger.warns) != 1 || !strings.Contains(logger.warns[0], "b removed") {
+		t.Errorf("expected one warning for b, got %v", logger.warns)
+	}
+}
@@ -0,0 +1,408 @@
+package viracochan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrSchemaViolation is returned when a write's content does not satisfy
+	// the schema registered for its id, or an update is not backward
+	// compatible with it
+	ErrSchemaViolation = errors.New("schema violation")
+	// ErrSubjectNotFound is what a SchemaRegistry returns, possibly wrapped,
+	// when no schema is registered under a subject
+	ErrSubjectNotFound = errors.New("schema subject not found")
+)
+
+// DefaultSchemaCacheTTL is how long a schema fetched from the registry is
+// used before it is fetched again, unless set with WithSchemaCacheTTL
+const DefaultSchemaCacheTTL = 5 * time.Minute
+
+// SchemaRegistry serves JSON Schemas by subject. Fetch returns either the
+// schema document itself or a Confluent style response holding it as a
+// string in "schema", with its "version"; an error wrapping
+// ErrSubjectNotFound means the subject has no schema.
+type SchemaRegistry interface {
+	Fetch(ctx context.Context, subject string) ([]byte, error)
+}
+
+// SchemaRegistryFunc adapts a function to a SchemaRegistry
+type SchemaRegistryFunc func(ctx context.Context, subject string) ([]byte, error)
+
+// Fetch calls f
+func (f SchemaRegistryFunc) Fetch(ctx context.Context, subject string) ([]byte, error) {
+	return f(ctx, subject)
+}
+
+// DefaultSchemaSubject names the registry subject of id as Confluent's
+// topic naming strategy does: "<id>-value"
+func DefaultSchemaSubject(id string) string {
+	return id + "-value"
+}
+
+// WithSchemaRegistry validates the content of every write against the JSON
+// Schema registry holds for its id's subject (see WithSchemaSubject): the
+// same writes WithPolicies checks, after sealing and before storing. Content
+// that does not satisfy the schema fails with ErrSchemaViolation, listing
+// every violation, and nothing is written. Updates must also stay backward
+// compatible: a property the schema declares and the current version sets
+// cannot be dropped, even an optional one, unless the update is made
+// WithSchemaOverride.
+//
+// Ids whose subject has no schema, and non-JSON content, are not checked. A
+// registry that cannot be reached fails the write. Schemas are cached for
+// DefaultSchemaCacheTTL, and the registry is called under the write lock.
+// The keywords checked are type, enum, properties, required,
+// additionalProperties, items, minimum, maximum, minLength, maxLength and
+// pattern; others are ignored.
+func WithSchemaRegistry(registry SchemaRegistry) ManagerOption {
+	return func(m *Manager) error {
+		if registry == nil {
+			return errors.New("schema registry is nil")
+		}
+		m.registry = registry
+		return nil
+	}
+}
+
+// WithSchemaSubject sets how WithSchemaRegistry names the subject of an id,
+// DefaultSchemaSubject by default
+func WithSchemaSubject(subject func(id string) string) ManagerOption {
+	return func(m *Manager) error {
+		if subject == nil {
+			return errors.New("schema subject function is nil")
+		}
+		m.registrySubject = subject
+		return nil
+	}
+}
+
+// WithSchemaCacheTTL sets how long a schema fetched by WithSchemaRegistry is
+// cached, including the absence of one. Zero fetches it on every write.
+func WithSchemaCacheTTL(ttl time.Duration) ManagerOption {
+	return func(m *Manager) error {
+		if ttl < 0 {
+			return fmt.Errorf("schema cache TTL must not be negative, got %v", ttl)
+		}
+		m.registryTTL = ttl
+		return nil
+	}
+}
+
+// registrySchema is a schema fetched for subject; version is the registry's,
+// 0 if it did not say
+type registrySchema struct {
+	subject string
+	version int
+	root    jsonSchema
+}
+
+func (s *registrySchema) String() string {
+	if s.version > 0 {
+		return fmt.Sprintf("subject %q v%d", s.subject, s.version)
+	}
+	return fmt.Sprintf("subject %q", s.subject)
+}
+
+// cachedSchema is a fetch result; schema is nil for a subject with none
+type cachedSchema struct {
+	schema  *registrySchema
+	fetched time.Time
+}
+
+// registrySchemaOf returns the schema registered for id, nil if there is
+// none or no registry is set
+func (m *Manager) registrySchemaOf(ctx context.Context, id string) (*registrySchema, error) {
+	if m.registry == nil {
+		return nil, nil
+	}
+	subject := m.registrySubject(id)
+
+	m.registryMu.Lock()
+	cached, ok := m.registryCache[subject]
+	m.registryMu.Unlock()
+	if ok && time.Since(cached.fetched) < m.registryTTL {
+		return cached.schema, nil
+	}
+
+	data, err := m.registry.Fetch(ctx, subject)
+	var schema *registrySchema
+	switch {
+	case errors.Is(err, ErrSubjectNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("schema registry subject %q: %w", subject, err)
+	default:
+		if schema, err = parseRegistrySchema(subject, data); err != nil {
+			return nil, err
+		}
+	}
+
+	m.registryMu.Lock()
+	m.registryCache[subject] = cachedSchema{schema: schema, fetched: time.Now()}
+	m.registryMu.Unlock()
+	return schema, nil
+}
+
+// parseRegistrySchema decodes a Fetch result, unwrapping a Confluent style
+// response
+func parseRegistrySchema(subject string, data []byte) (*registrySchema, error) {
+	s := &registrySchema{subject: subject}
+
+	var envelope struct {
+		Version    int     `json:"version"`
+		SchemaType string  `json:"schemaType"`
+		Schema     *string `json:"schema"`
+	}
+	if json.Unmarshal(data, &envelope) == nil && envelope.Schema != nil {
+		if envelope.SchemaType != "" && envelope.SchemaType != "JSON" {
+			return nil, fmt.Errorf("schema of subject %q: unsupported schema type %q", subject, envelope.SchemaType)
+		}
+		s.version = envelope.Version
+		data = []byte(*envelope.Schema)
+	}
+
+	if err := json.Unmarshal(data, &s.root); err != nil {
+		return nil, fmt.Errorf("schema of subject %q: %w", subject, err)
+	}
+	return s, nil
+}
+
+// checkRegistry validates the content of cfg, about to be written as id,
+// against the schema registered for id
+func (m *Manager) checkRegistry(ctx context.Context, id string, cfg *Config) error {
+	if m.registry == nil || !isJSONContentType(cfg.Meta.ContentType) {
+		return nil
+	}
+	schema, err := m.registrySchemaOf(ctx, id)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(cfg.Content, &v); err != nil {
+		return err
+	}
+	var violations []string
+	schema.root.validate("", v, &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w in %q against %s: %s", ErrSchemaViolation, id, schema, strings.Join(violations, "; "))
+}
+
+// checkRegistryCompat rejects an update of id from current to data that
+// drops a property the registered schema declares and current sets
+func (m *Manager) checkRegistryCompat(ctx context.Context, id string, current *Config, data json.RawMessage, wo *writeOptions) error {
+	if m.registry == nil || wo.schemaOverride || !isJSONContentType(current.Meta.ContentType) {
+		return nil
+	}
+	if wo.contentType != nil && !isJSONContentType(*wo.contentType) {
+		return nil
+	}
+	schema, err := m.registrySchemaOf(ctx, id)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	var old, updated interface{}
+	if err := json.Unmarshal(current.Content, &old); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &updated); err != nil {
+		return err
+	}
+	var dropped []string
+	schema.root.dropped("", old, updated, &dropped)
+	if len(dropped) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w in %q: not backward compatible with %s: %s", ErrSchemaViolation, id, schema, strings.Join(dropped, "; "))
+}
+
+// jsonSchema is the subset of JSON Schema WithSchemaRegistry checks. The
+// schema true accepts anything; the schema false (reject) nothing.
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+
+	pattern *regexp.Regexp
+	reject  bool
+}
+
+func (s *jsonSchema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = jsonSchema{}
+		return nil
+	case "false":
+		*s = jsonSchema{reject: true}
+		return nil
+	}
+
+	type plain jsonSchema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	return nil
+}
+
+// schemaTypes is the type keyword, a single type or a list of them
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// matches reports whether the decoded value v has one of the types
+func (t schemaTypes) matches(v interface{}) bool {
+	typ := valueType(v)
+	for _, want := range t {
+		if want == typ {
+			return true
+		}
+		if n, ok := v.(float64); ok && want == "integer" && n == math.Trunc(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate appends to out every way the decoded value v at path fails s
+func (s *jsonSchema) validate(path string, v interface{}, out *[]string) {
+	violate := func(format string, args ...interface{}) {
+		at := path
+		if at == "" {
+			at = "content"
+		}
+		*out = append(*out, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if s.reject {
+		violate("not allowed")
+		return
+	}
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		violate("expected %s, got %s", strings.Join(s.Type, " or "), valueType(v))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e interface{}) bool { return reflect.DeepEqual(e, v) }) {
+		violate("not one of the allowed values")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*out = append(*out, keyPath(path, name)+": required")
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p, ok := s.Properties[k]; ok {
+				p.validate(keyPath(path, k), v[k], out)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(keyPath(path, k), v[k], out)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, out)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			violate("shorter than %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			violate("longer than %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			violate("does not match %s", s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			violate("less than %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			violate("greater than %v", *s.Maximum)
+		}
+	}
+}
+
+// dropped appends to out every property s declares that old sets to a
+// non-null value and updated lacks, nested ones included
+func (s *jsonSchema) dropped(path string, old, updated interface{}, out *[]string) {
+	o, ok := old.(map[string]interface{})
+	if !ok {
+		return
+	}
+	n, ok := updated.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ov, had := o[name]
+		if !had || ov == nil {
+			continue
+		}
+		nv, has := n[name]
+		if !has {
+			*out = append(*out, keyPath(path, name)+" removed")
+			continue
+		}
+		s.Properties[name].dropped(keyPath(path, name), ov, nv, out)
+	}
+}
+
+// keyPath is the dotted path of key under path
+func keyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,125 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAppSchema = `{
+	"type": "object",
+	"required": ["host", "port"],
+	"properties": {
+		"host": {"type": "string", "minLength": 1},
+		"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+		"mode": {"enum": ["dev", "prod"]},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"tls": {"type": "object", "properties": {"cert": {"type": "string"}}}
+	},
+	"additionalProperties": false
+}`
+
+func TestManagerSchemaRegistry(t *testing.T) {
+	ctx := context.Background()
+	fetches := 0
+	registry := SchemaRegistryFunc(func(_ context.Context, subject string) ([]byte, error) {
+		fetches++
+		if subject != "app-value" {
+			return nil, fmt.Errorf("%w: %s", ErrSubjectNotFound, subject)
+		}
+		return []byte(testAppSchema), nil
+	})
+	manager, _ := NewManager(NewMemoryStorage(), WithSchemaRegistry(registry))
+
+	_, err := manager.Create(ctx, "app", map[string]interface{}{"host": "h", "port": "80", "mode": "test", "extra": 1, "tags": []interface{}{"a", 2}})
+	if !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected ErrSchemaViolation, got %v", err)
+	}
+	for _, want := range []string{"port: expected integer, got string", "mode: not one of the allowed values", "extra: not allowed", "tags[1]: expected string, got number", `subject "app-value"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if _, err := manager.GetLatest(ctx, "app"); err == nil {
+		t.Fatal("a rejected create must not write")
+	}
+
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"host": "h", "port": 80, "tls": map[string]interface{}{"cert": "c"}}); err != nil {
+		t.Fatalf("valid create failed: %v", err)
+	}
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"host": "h", "port": 0.5, "tls": map[string]interface{}{"cert": "c"}}); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("expected a fractional port to fail, got %v", err)
+	}
+
+	// Dropping a declared property breaks readers of the schema
+	_, err = manager.Update(ctx, "app", map[string]interface{}{"host": "h", "port": 81, "tls": map[string]interface{}{}})
+	if !errors.Is(err, ErrSchemaViolation) || !strings.Contains(err.Error(), "tls.cert removed") {
+		t.Errorf("expected an incompatible update to fail, got %v", err)
+	}
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"host": "h", "port": 81}, WithSchemaOverride()); err != nil {
+		t.Errorf("override should allow dropping an optional property: %v", err)
+	}
+	if latest, _ := manager.GetLatest(ctx, "app"); latest.Meta.Version != 2 {
+		t.Errorf("expected rejected updates to leave v2 as head, got v%d", latest.Meta.Version)
+	}
+
+	// Ids without a subject are unchecked
+	if _, err := manager.Create(ctx, "other", map[string]interface{}{"anything": true}); err != nil {
+		t.Errorf("id without a schema should not be checked: %v", err)
+	}
+
+	before := fetches
+	manager.Update(ctx, "app", map[string]interface{}{"host": "h", "port": 82})
+	manager.Update(ctx, "other", map[string]interface{}{"anything": false})
+	if fetches != before {
+		t.Errorf("expected cached schemas to be reused, fetched %d more times", fetches-before)
+	}
+}
+
+func TestManagerSchemaRegistryFetch(t *testing.T) {
+	ctx := context.Background()
+	schema := `{"type": "object", "required": ["n"]}`
+	var fetchErr error
+	registry := SchemaRegistryFunc(func(_ context.Context, subject string) ([]byte, error) {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		envelope := fmt.Sprintf(`{"subject": %q, "version": 3, "id": 7, "schema": %q}`, subject, schema)
+		return []byte(envelope), nil
+	})
+	manager, _ := NewManager(NewMemoryStorage(),
+		WithSchemaRegistry(registry),
+		WithSchemaSubject(func(id string) string { return "configs." + id }),
+		WithSchemaCacheTTL(0))
+
+	_, err := manager.Create(ctx, "app", map[string]interface{}{"m": 1})
+	if !errors.Is(err, ErrSchemaViolation) || !strings.Contains(err.Error(), `subject "configs.app" v3: n: required`) {
+		t.Fatalf("expected the Confluent response to be unwrapped, got %v", err)
+	}
+
+	schema = `{"type": "object"}`
+	if _, err := manager.Create(ctx, "app", map[string]interface{}{"m": 1}); err != nil {
+		t.Fatalf("expected a zero TTL to refetch: %v", err)
+	}
+
+	fetchErr = errors.New("connection refused")
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"m": 2}); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected an unreachable registry to fail the write, got %v", err)
+	}
+	fetchErr = nil
+
+	schema = `{"type": "object", "properties": {"p": {"pattern": "["}}}`
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"m": 2}); err == nil {
+		t.Error("expected an invalid schema to fail the write")
+	}
+	if latest, _ := manager.GetLatest(ctx, "app"); latest.Meta.Version != 1 {
+		t.Errorf("failed fetches must not write, head is v%d", latest.Meta.Version)
+	}
+
+	if _, err := NewManager(NewMemoryStorage(), WithSchemaCacheTTL(-time.Second)); err == nil {
+		t.Error("expected a negative TTL to fail")
+	}
+}
@@ -0,0 +1,239 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Severity ranks a SelfTest finding
+type Severity int
+
+const (
+	// SeverityInfo is worth knowing but needs no action
+	SeverityInfo Severity = iota
+	// SeverityWarning is recoverable damage, such as a write interrupted
+	// before it was journaled, or a gap that reads work around
+	SeverityWarning
+	// SeverityCritical is lost or untrustworthy history: corrupt files,
+	// forked or broken chains, bad signatures
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// MarshalText encodes the severity by name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SelfTest check names, as used in SelfTestFinding.Check
+const (
+	CheckAudit       = "audit"       // journal and config reconciliation, chains, signatures
+	CheckForks       = "forks"       // competing versions in the journal
+	CheckGaps        = "gaps"        // missing versions in the journal or config files
+	CheckIntents     = "intents"     // writes interrupted before they were journaled
+	CheckReconstruct = "reconstruct" // journal and config file heads that disagree
+)
+
+// SelfTestFinding is one problem found by SelfTest. Version is 0 when it
+// concerns the whole id, and ID is empty when it concerns the store.
+type SelfTestFinding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	ID       string   `json:"id,omitempty"`
+	Version  uint64   `json:"v,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// SelfTestReport is the result of SelfTest. Audit is the underlying audit
+// report, nil if the audit itself could not run.
+type SelfTestReport struct {
+	Time     time.Time         `json:"time"`
+	IDs      int               `json:"ids"`
+	Findings []SelfTestFinding `json:"findings,omitempty"`
+	Audit    *AuditReport      `json:"audit,omitempty"`
+}
+
+// OK reports whether the self-test found nothing above SeverityInfo
+func (r *SelfTestReport) OK() bool {
+	return r.Worst() < SeverityWarning
+}
+
+// Worst returns the highest severity found, SeverityInfo when there are no
+// findings
+func (r *SelfTestReport) Worst() Severity {
+	worst := SeverityInfo
+	for _, f := range r.Findings {
+		worst = max(worst, f.Severity)
+	}
+	return worst
+}
+
+// auditSeverity ranks the issue kinds of Audit
+var auditSeverity = map[AuditIssueKind]Severity{
+	AuditJournalUnreadable: SeverityCritical,
+	AuditMissingConfig:     SeverityWarning, // embedded configs and MaterializeConfigs cover it
+	AuditOrphanConfig:      SeverityWarning, // a crash between save and journal append
+	AuditInvalidConfig:     SeverityCritical,
+	AuditChecksumMismatch:  SeverityCritical,
+	AuditBadSignature:      SeverityCritical,
+	AuditBrokenChain:       SeverityCritical,
+}
+
+// SelfTest runs every integrity check on the store and consolidates the
+// results by severity: the store Audit (journal and config reconciliation,
+// chain validation, and signatures under trustedKeys, defaulting as in
+// Audit), fork detection (one version journaled with different checksums),
+// gap detection (versions missing from the journal or the config files) and
+// pending intents (see Recover) and VerifyReconstructable on every journaled
+// id. A fork or gap also shows up as a broken chain in the audit; its own
+// finding says what broke it. Likewise an id whose chain or head file cannot
+// be read only gets a reconstruct finding when both sources can be read and
+// disagree.
+//
+// SelfTest only reads. Corruption is reported rather than returned, and a
+// check that cannot run becomes a critical finding while the others go on,
+// so err is only ErrClosed. It is meant to run on a schedule or after an
+// incident.
+func (m *Manager) SelfTest(ctx context.Context, trustedKeys ...string) (*SelfTestReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	report := &SelfTestReport{Time: time.Now().UTC()}
+	add := func(check string, severity Severity, id string, version uint64, format string, args ...any) {
+		report.Findings = append(report.Findings, SelfTestFinding{
+			Check: check, Severity: severity, ID: id, Version: version, Detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	audit, err := m.audit(ctx, trustedKeys)
+	if err != nil {
+		add(CheckAudit, SeverityCritical, "", 0, "audit could not run: %v", err)
+	} else {
+		report.Audit = audit
+		report.IDs = audit.IDs
+		for _, issue := range audit.Issues {
+			add(CheckAudit, auditSeverity[issue.Kind], issue.ID, issue.Version, "%s: %s", issue.Kind, issue.Detail)
+		}
+		if audit.Unverified > 0 {
+			add(CheckAudit, SeverityInfo, "", 0, "%d signatures not verified: no trusted key", audit.Unverified)
+		}
+	}
+
+	byID, err := m.auditJournal(ctx, &AuditReport{})
+	if err != nil {
+		add(CheckForks, SeverityCritical, "", 0, "journal could not be read: %v", err)
+	}
+	roots, err := m.journal.readRoots(ctx)
+	if err != nil {
+		add(CheckGaps, SeverityWarning, "", 0, "chain roots could not be read: %v", err)
+	}
+
+	ids, err := m.configIDs(ctx)
+	if err != nil {
+		add(CheckGaps, SeverityCritical, "", 0, "config ids could not be listed: %v", err)
+	}
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if report.Audit == nil {
+		report.IDs = len(ids)
+	}
+
+	for _, id := range ids {
+		selfTestJournal(id, byID[id], roots, add)
+		files, err := m.versionFiles(ctx, id)
+		if err != nil {
+			add(CheckGaps, SeverityCritical, id, 0, "config files could not be listed: %v", err)
+			continue
+		}
+		for i := 1; i < len(files); i++ {
+			if files[i].version != files[i-1].version+1 {
+				add(CheckGaps, SeverityWarning, id, files[i-1].version+1,
+					"%s missing", versionRange("config file", files[i-1].version+1, files[i].version-1))
+			}
+		}
+		if len(byID[id]) > 0 {
+			if err := m.verifyReconstructable(ctx, id, byID[id]); errors.Is(err, ErrNotReconstructable) {
+				add(CheckReconstruct, SeverityCritical, id, 0, "%v", err)
+			}
+		}
+	}
+
+	records, err := m.readIntents(ctx)
+	if err != nil {
+		add(CheckIntents, SeverityWarning, "", 0, "intent log could not be read: %v", err)
+	}
+	for _, rec := range pendingIntents(records) {
+		for _, w := range rec.Writes {
+			add(CheckIntents, SeverityWarning, w.ID, w.Version, "write %s never completed; run Recover", rec.Tx)
+		}
+	}
+	return report, nil
+}
+
+// selfTestJournal reports forks and gaps in the journal entries of id
+func selfTestJournal(id string, entries []*JournalEntry, roots map[string]ChainRoot, add func(string, Severity, string, uint64, string, ...any)) {
+	if len(entries) == 0 {
+		return
+	}
+
+	byVersion := make(map[uint64][]string)
+	for _, entry := range entries {
+		byVersion[entry.Version] = append(byVersion[entry.Version], entry.CS)
+	}
+	versions := make([]uint64, 0, len(byVersion))
+	for v := range byVersion {
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+
+	for _, v := range versions {
+		checksums := slices.Compact(slices.Sorted(slices.Values(byVersion[v])))
+		switch {
+		case len(checksums) > 1:
+			add(CheckForks, SeverityCritical, id, v, "%d competing versions: %v", len(checksums), checksums)
+		case len(byVersion[v]) > 1:
+			add(CheckForks, SeverityInfo, id, v, "journaled %d times", len(byVersion[v]))
+		}
+	}
+
+	if first := versions[0]; first > 1 {
+		if root, ok := roots[id]; !ok || root.Version != first {
+			add(CheckGaps, SeverityWarning, id, first, "journal starts at v%d without a recorded pruned boundary", first)
+		}
+	}
+	for i := 1; i < len(versions); i++ {
+		if versions[i] != versions[i-1]+1 {
+			add(CheckGaps, SeverityCritical, id, versions[i-1]+1,
+				"%s missing", versionRange("journal entry", versions[i-1]+1, versions[i]-1))
+		}
+	}
+}
+
+// versionRange names the versions from to to, as "what v3" or "what v3-v5"
+func versionRange(what string, from, to uint64) string {
+	if from == to {
+		return fmt.Sprintf("%s v%d", what, from)
+	}
+	return fmt.Sprintf("%s v%d-v%d", what, from, to)
+}
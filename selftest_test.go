@@ -0,0 +1,69 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer), WithIntentLog())
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+	manager.Create(ctx, "db", map[string]interface{}{"host": "a"})
+
+	report, err := manager.SelfTest(ctx)
+	if err != nil {
+		t.Fatalf("SelfTest failed: %v", err)
+	}
+	if !report.OK() || len(report.Findings) != 0 || report.IDs != 2 {
+		t.Fatalf("expected a clean store, got %+v", report.Findings)
+	}
+
+	// A fork on db, a gap in app's config files, and an unfinished write
+	fork := &Config{Content: json.RawMessage(`{"host":"b"}`)}
+	fork.UpdateMeta()
+	manager.journal.Append(ctx, &JournalEntry{ID: "db", Version: 1, CS: fork.Meta.CS, Time: fork.Meta.Time, Operation: "create"})
+	storage.Delete(ctx, "configs/app/v2.json")
+	manager.appendIntent(ctx, IntentRecord{Tx: "tx-1", Phase: IntentBegin, Writes: []IntentWrite{{ID: "app", Version: 4}}})
+
+	before, _ := storage.List(ctx, "")
+	report, err = manager.SelfTest(ctx)
+	if err != nil {
+		t.Fatalf("SelfTest failed: %v", err)
+	}
+	if after, _ := storage.List(ctx, ""); len(after) != len(before) {
+		t.Error("SelfTest changed the store")
+	}
+	if report.OK() || report.Worst() != SeverityCritical {
+		t.Fatalf("expected critical findings, got %+v", report.Findings)
+	}
+
+	found := make(map[string]SelfTestFinding)
+	for _, f := range report.Findings {
+		found[f.Check+"/"+f.ID] = f
+	}
+	if f, ok := found[CheckForks+"/db"]; !ok || f.Severity != SeverityCritical || f.Version != 1 {
+		t.Errorf("expected db fork at v1, got %+v", f)
+	}
+	if f, ok := found[CheckGaps+"/app"]; !ok || f.Version != 2 || f.Severity != SeverityWarning {
+		t.Errorf("expected app file gap at v2, got %+v", f)
+	}
+	if f, ok := found[CheckIntents+"/app"]; !ok || f.Version != 4 {
+		t.Errorf("expected pending intent for app v4, got %+v", f)
+	}
+	if f, ok := found[CheckAudit+"/app"]; !ok || f.Severity != SeverityWarning {
+		t.Errorf("expected app's missing config as a warning, got %+v", f)
+	}
+
+	data, _ := json.Marshal(report.Findings[0])
+	if !strings.Contains(string(data), `"severity":"`) {
+		t.Errorf("expected severity by name, got %s", data)
+	}
+}
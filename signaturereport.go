@@ -0,0 +1,156 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SignatureIssueKind classifies a version listed by VerifyAllSignatures
+type SignatureIssueKind string
+
+const (
+	// SignatureUnsigned is a version stored without a signature
+	SignatureUnsigned SignatureIssueKind = "unsigned"
+	// SignatureInvalid is a signed version that verifies under none of the
+	// trusted keys
+	SignatureInvalid SignatureIssueKind = "invalid"
+	// SignatureUnreadable is a config file that cannot be read or whose
+	// checksum does not match its content, so its signature cannot be checked
+	SignatureUnreadable SignatureIssueKind = "unreadable"
+)
+
+// SignatureIssue is one version of a SignatureReport that did not verify
+type SignatureIssue struct {
+	ID      string             `json:"id"`
+	Version uint64             `json:"v"`
+	Kind    SignatureIssueKind `json:"kind"`
+	Detail  string             `json:"detail,omitempty"`
+}
+
+// SignatureReport is the result of VerifyAllSignatures. Versions counts every
+// config file checked; each is either Valid, Unsigned, Invalid or Unreadable,
+// and all but the valid ones are listed in Issues, ordered by id and version.
+type SignatureReport struct {
+	Time        time.Time        `json:"time"`
+	TrustedKeys []string         `json:"trusted_keys"`
+	IDs         int              `json:"ids"`
+	Versions    int              `json:"versions"`
+	Valid       int              `json:"valid"`
+	Unsigned    int              `json:"unsigned"`
+	Invalid     int              `json:"invalid"`
+	Unreadable  int              `json:"unreadable"`
+	Issues      []SignatureIssue `json:"issues,omitempty"`
+}
+
+// OK reports whether every version carries a valid signature
+func (r *SignatureReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// VerifyAllSignatures checks the signature of every stored version of every
+// config against trustedKeys (the config store's verify keys, or else the
+// signer's own key, when none are given). It fails only when there is no key
+// to verify against, when listing storage fails, or when ctx is done; a
+// version that is unsigned, does not verify or cannot be read is recorded in
+// the report and the sweep goes on. Versions are loaded and verified on
+// GOMAXPROCS goroutines. Unlike Audit, the journal is not consulted.
+func (m *Manager) VerifyAllSignatures(ctx context.Context, trustedKeys []string) (*SignatureReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	if len(trustedKeys) == 0 {
+		trustedKeys = m.defaultTrustedKeys()
+	}
+	if len(trustedKeys) == 0 {
+		return nil, errors.New("no trusted keys to verify signatures against")
+	}
+
+	ids, err := m.configIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var issues []SignatureIssue
+	for _, id := range ids {
+		versions, err := m.configStore.ListVersions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			issues = append(issues, SignatureIssue{ID: id, Version: v})
+		}
+	}
+
+	m.verifySignatureSweep(ctx, issues, trustedKeys)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &SignatureReport{
+		Time:        time.Now().UTC(),
+		TrustedKeys: trustedKeys,
+		IDs:         len(ids),
+		Versions:    len(issues),
+	}
+	for _, issue := range issues {
+		switch issue.Kind {
+		case "":
+			report.Valid++
+			continue
+		case SignatureUnsigned:
+			report.Unsigned++
+		case SignatureInvalid:
+			report.Invalid++
+		case SignatureUnreadable:
+			report.Unreadable++
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+	return report, nil
+}
+
+// verifySignatureSweep loads and verifies the version named by each of
+// issues on up to GOMAXPROCS goroutines, setting the Kind and Detail of those
+// that do not verify and leaving valid ones with an empty Kind
+func (m *Manager) verifySignatureSweep(ctx context.Context, issues []SignatureIssue, trustedKeys []string) {
+	workers := min(runtime.GOMAXPROCS(0), len(issues))
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				issue := &issues[i]
+				cfg, err := loadConfigAtPath(ctx, m.storage, m.configStore.makeKey(issue.ID, issue.Version))
+				if err == nil {
+					err = cfg.Validate()
+				}
+				switch {
+				case err != nil:
+					issue.Kind, issue.Detail = SignatureUnreadable, err.Error()
+				case cfg.Meta.Signature == "":
+					issue.Kind = SignatureUnsigned
+				case !verifiesUnderAny(cfg, trustedKeys):
+					issue.Kind, issue.Detail = SignatureInvalid, "signature not valid for any trusted key"
+				}
+			}
+		}()
+	}
+
+	for i := range issues {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,78 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestManagerVerifyAllSignatures(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	signer, _ := NewSigner()
+	outsider, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+	other, _ := NewManager(storage, WithSigner(outsider), WithJournalPath("other.jsonl"))
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 3})
+	other.Create(ctx, "db", map[string]interface{}{"host": "a"})
+	unsigned, _ := newConfig(map[string]interface{}{"stray": true}, "")
+	manager.configStore.Save(ctx, "stray", unsigned)
+
+	report, err := manager.VerifyAllSignatures(ctx, []string{signer.PublicKey(), outsider.PublicKey()})
+	if err != nil {
+		t.Fatalf("VerifyAllSignatures failed: %v", err)
+	}
+	if report.IDs != 3 || report.Versions != 5 || report.Valid != 4 || report.Unsigned != 1 {
+		t.Errorf("unexpected counts with both keys trusted: %+v", report)
+	}
+
+	storage.Write(ctx, "configs/app/v2.json", []byte(`not json`))
+
+	// Only the manager's own key by default
+	report, err = manager.VerifyAllSignatures(ctx, nil)
+	if err != nil {
+		t.Fatalf("VerifyAllSignatures failed on a damaged store: %v", err)
+	}
+	if report.Valid != 2 || report.Unsigned != 1 || report.Invalid != 1 || report.Unreadable != 1 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+	want := []SignatureIssue{
+		{ID: "app", Version: 2, Kind: SignatureUnreadable},
+		{ID: "db", Version: 1, Kind: SignatureInvalid},
+		{ID: "stray", Version: 1, Kind: SignatureUnsigned},
+	}
+	var got []SignatureIssue
+	for _, issue := range report.Issues {
+		got = append(got, SignatureIssue{ID: issue.ID, Version: issue.Version, Kind: issue.Kind})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %+v, want %+v", got, want)
+	}
+	if report.OK() {
+		t.Error("report with issues is OK")
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("report does not marshal: %v", err)
+	}
+	var decoded SignatureReport
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded.Issues, report.Issues) {
+		t.Errorf("report does not round-trip: %s", data)
+	}
+
+	keyless, _ := NewManager(storage, WithJournalPath("keyless.jsonl"))
+	if _, err := keyless.VerifyAllSignatures(ctx, nil); err == nil {
+		t.Error("expected an error without trusted keys")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := manager.VerifyAllSignatures(canceled, nil); err == nil {
+		t.Error("expected a canceled sweep to fail")
+	}
+}
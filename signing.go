@@ -1,11 +1,14 @@
 package viracochan
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -17,6 +20,29 @@ const (
 	SignatureAlgorithmV2 = "vc-schnorr-secp256k1-v2"
 )
 
+// signingFormats maps every SigAlg identifier to the payload builder used for
+// it. Meta.SigAlg records the message format a config was signed with and
+// verification always rebuilds that exact format, so introducing a new one
+// never invalidates existing signatures: register it here and point
+// currentSignatureAlgorithm at it.
+//
+// A builder is given the digest of cfg's content when its caller already
+// computed it for this very write, or "" to hash cfg.Content itself.
+// Verification always passes "", so it covers the bytes present.
+var signingFormats = map[string]func(cfg *Config, contentHash string) ([]byte, error){
+	SignatureAlgorithmV2: makeSigningPayloadV2,
+}
+
+// currentSignatureAlgorithm is the format used for new signatures
+var currentSignatureAlgorithm = SignatureAlgorithmV2
+
+// isNativeSignatureAlgorithm reports whether alg is a registered signing
+// format, as opposed to a legacy (empty) or unknown one
+func isNativeSignatureAlgorithm(alg string) bool {
+	_, ok := signingFormats[alg]
+	return ok
+}
+
 // ErrUnsupportedSignatureAlgorithm is returned when a config's SigAlg field
 // contains an unrecognised algorithm identifier.
 var ErrUnsupportedSignatureAlgorithm = errors.New("unsupported signature algorithm")
@@ -25,6 +51,9 @@ var ErrUnsupportedSignatureAlgorithm = errors.New("unsupported signature algorit
 type Signer struct {
 	privateKey string
 	publicKey  string
+	remote     RemoteSignFunc // set by NewRemoteSigner instead of privateKey
+
+	participants []string // keys combined by AggregateSigners
 }
 
 // NewSigner creates new signer with generated keypair.
@@ -58,20 +87,32 @@ func (s *Signer) PublicKey() string {
 	return s.publicKey
 }
 
-// Sign signs a config using the native v0.2.0 signature format.
+// Sign signs a config using the current native signature format and records
+// that format in Meta.SigAlg.
 func (s *Signer) Sign(cfg *Config) error {
+	return s.sign(cfg, "")
+}
+
+// sign is Sign given the digest of cfg's content, which a writer that has
+// just hashed the content passes on rather than have it hashed again
+func (s *Signer) sign(cfg *Config, contentHash string) error {
 	if cfg.Meta.CS == "" {
 		return errors.New("config must have checksum before signing")
 	}
 
-	hash := makeSigningHashV2(cfg)
+	alg := currentSignatureAlgorithm
+	payload, err := signingFormats[alg](cfg, contentHash)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(payload)
 	sig, err := s.signHash(hash[:])
 	if err != nil {
 		return err
 	}
 
 	cfg.Meta.Signature = sig
-	cfg.Meta.SigAlg = SignatureAlgorithmV2
+	cfg.Meta.SigAlg = alg
 	return nil
 }
 
@@ -81,35 +122,145 @@ func (s *Signer) Verify(cfg *Config, publicKey string) error {
 }
 
 // VerifyConfigSignature verifies a config's signature without requiring a
-// Signer instance. Only the public key is needed for verification.
+// Signer instance. Only the public key is needed for verification. The
+// message is rebuilt in the format named by Meta.SigAlg.
 func VerifyConfigSignature(cfg *Config, publicKey string) error {
 	if cfg.Meta.Signature == "" {
 		return errors.New("config has no signature")
 	}
 
-	switch cfg.Meta.SigAlg {
-	case SignatureAlgorithmV2:
-		hash := makeSigningHashV2(cfg)
-		return verifyHash(hash[:], cfg.Meta.Signature, publicKey)
-	default:
+	format, ok := signingFormats[cfg.Meta.SigAlg]
+	if !ok {
 		return fmt.Errorf("%w: %q", ErrUnsupportedSignatureAlgorithm, cfg.Meta.SigAlg)
 	}
+	payload, err := format(cfg, "")
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(payload)
+	return verifyHash(hash[:], cfg.Meta.Signature, publicKey)
 }
 
-func makeSigningPayloadV2(cfg *Config) []byte {
-	contentHash := sha256.Sum256(cfg.Content)
-	return []byte(fmt.Sprintf("viracochan:sig:v2:%s:%d:%s:%s",
+var (
+	// ErrMalformedConfig is returned by VerifyConfigBytes when data is not a
+	// config document
+	ErrMalformedConfig = errors.New("malformed config")
+	// ErrInvalidSignature is returned by VerifyConfigBytes when a signature
+	// does not verify under the given key
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// VerifyConfigBytes decodes a config document (as stored or exported),
+// validates its checksum and verifies its signature under publicKey, with no
+// storage or Manager involved. Failures are distinguishable with errors.Is:
+// ErrMalformedConfig for undecodable data, ErrChecksumMismatch for content or
+// metadata that does not match the checksum, ErrUnsignedConfig when there is
+// no signature and ErrInvalidSignature when it does not verify. The decoded
+// config is returned only when every check passes.
+//
+// Signatures cover the exact content bytes. Documents whose whitespace was
+// changed, such as Export's indented output, are also accepted when their
+// compacted content verifies.
+func VerifyConfigBytes(data []byte, publicKey string) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedConfig, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Meta.Signature == "" {
+		return nil, fmt.Errorf("%w: version %d", ErrUnsignedConfig, cfg.Meta.Version)
+	}
+	err := VerifyConfigSignature(&cfg, publicKey)
+	if err != nil && !errors.Is(err, ErrUnsupportedSignatureAlgorithm) {
+		var compact bytes.Buffer
+		if json.Compact(&compact, cfg.Content) == nil && !bytes.Equal(compact.Bytes(), cfg.Content) {
+			compacted := cfg
+			compacted.Content = compact.Bytes()
+			if VerifyConfigSignature(&compacted, publicKey) == nil {
+				return &compacted, nil
+			}
+		}
+		err = fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ErrStaleSignature is returned by VerifyFresh when a validly signed config
+// is older than the accepted maximum age.
+var ErrStaleSignature = errors.New("stale signature")
+
+// VerifyFresh verifies cfg's signature and rejects it when Meta.Time is more
+// than maxAge in the past, or when its signed expiry has passed.
+//
+// Meta.Time is part of the signed message, so a replayed old version cannot
+// be made to look recent without the private key. Freshness only bounds how
+// long a captured version stays acceptable: within maxAge a replay still
+// succeeds, and the check relies on the verifier's clock being reasonably
+// accurate. It does not prove that no newer version exists; followers that
+// need that must compare versions against a trusted source.
+func VerifyFresh(cfg *Config, publicKey string, maxAge time.Duration) error {
+	if err := VerifyConfigSignature(cfg, publicKey); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if age := now.Sub(cfg.Meta.Time); age > maxAge {
+		return fmt.Errorf("%w: version %d signed %s ago, max %s", ErrStaleSignature, cfg.Meta.Version, age.Round(time.Millisecond), maxAge)
+	}
+	if cfg.Expired(now) {
+		return fmt.Errorf("%w: version %d at %s", ErrExpired, cfg.Meta.Version, cfg.Meta.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// makeSigningPayloadV2 builds the v2 signed message. Content and annotation
+// digests use the config's hash algorithm; the message itself is always
+// reduced with SHA-256 because Schnorr signs 32-byte digests.
+func makeSigningPayloadV2(cfg *Config, contentHash string) ([]byte, error) {
+	if contentHash == "" {
+		var err error
+		if contentHash, err = digestHex(cfg.Meta.HashAlg, cfg.Content); err != nil {
+			return nil, err
+		}
+	}
+	payload := fmt.Sprintf("viracochan:sig:v2:%s:%d:%s:%s",
 		cfg.Meta.CS,
 		cfg.Meta.Version,
 		cfg.Meta.Time.UTC().Format(time.RFC3339Nano),
-		hex.EncodeToString(contentHash[:])))
+		contentHash)
+
+	// Annotations are appended only when present so signatures over
+	// unannotated configs keep their original payload.
+	if len(cfg.Meta.Annotations) > 0 {
+		canonical, _ := canonicalJSON(cfg.Meta.Annotations) // string map always canonicalizes
+		annotationsHash, err := digestHex(cfg.Meta.HashAlg, canonical)
+		if err != nil {
+			return nil, err
+		}
+		payload += ":" + annotationsHash
+	}
+
+	return []byte(payload), nil
 }
 
-func makeSigningHashV2(cfg *Config) [32]byte {
-	return sha256.Sum256(makeSigningPayloadV2(cfg))
+func makeSigningHashV2(cfg *Config) ([32]byte, error) {
+	payload, err := makeSigningPayloadV2(cfg, "")
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(payload), nil
 }
 
 func (s *Signer) signHash(hash []byte) (string, error) {
+	if s.remote != nil {
+		return s.signRemote(hash)
+	}
+
 	priv, err := decodePrivateKey(s.privateKey)
 	if err != nil {
 		return "", err
@@ -203,7 +354,8 @@ func (sc *SignedConfig) VerifySignature(publicKey string) error {
 	return sc.signer.Verify(sc.Config, publicKey)
 }
 
-// VerifyChainSignatures verifies all signatures in a config chain.
+// VerifyChainSignatures verifies all signatures in a config chain. Unsigned
+// versions are skipped; see VerifyChainSignaturesPolicy to reject them.
 func VerifyChainSignatures(configs []*Config, publicKey string) error {
 	for i, cfg := range configs {
 		if cfg.Meta.Signature == "" {
@@ -217,3 +369,126 @@ func VerifyChainSignatures(configs []*Config, publicKey string) error {
 
 	return nil
 }
+
+// UnsignedPolicy says which versions of a chain must carry a signature
+type UnsignedPolicy int
+
+const (
+	// AllowUnsigned skips unsigned versions, as VerifyChainSignatures does
+	AllowUnsigned UnsignedPolicy = iota
+	// RequireAllSigned rejects the chain if any version is unsigned
+	RequireAllSigned
+	// RequireLatestSigned allows unsigned history but requires the latest
+	// (highest) version to be signed
+	RequireLatestSigned
+)
+
+// VerifyChainSignaturesStrict is VerifyChainSignaturesPolicy with
+// RequireAllSigned
+func VerifyChainSignaturesStrict(configs []*Config, publicKey string) error {
+	return VerifyChainSignaturesPolicy(configs, publicKey, RequireAllSigned)
+}
+
+// VerifyChainSignaturesPolicy verifies every signature in a config chain like
+// VerifyChainSignatures and additionally enforces policy on unsigned
+// versions. A violation wraps ErrUnsignedConfig and names the first unsigned
+// version that breaks the policy.
+func VerifyChainSignaturesPolicy(configs []*Config, publicKey string, policy UnsignedPolicy) error {
+	switch policy {
+	case AllowUnsigned:
+	case RequireAllSigned:
+		for _, cfg := range configs {
+			if cfg.Meta.Signature == "" {
+				return fmt.Errorf("%w: version %d", ErrUnsignedConfig, cfg.Meta.Version)
+			}
+		}
+	case RequireLatestSigned:
+		var latest *Config
+		for _, cfg := range configs {
+			if latest == nil || cfg.Meta.Version > latest.Meta.Version {
+				latest = cfg
+			}
+		}
+		if latest != nil && latest.Meta.Signature == "" {
+			return fmt.Errorf("%w: latest version %d", ErrUnsignedConfig, latest.Meta.Version)
+		}
+	default:
+		return fmt.Errorf("unknown unsigned policy %d", policy)
+	}
+
+	return VerifyChainSignatures(configs, publicKey)
+}
+
+// VerifyChainSignaturesConcurrent verifies all signatures in a config chain
+// using up to workers goroutines (GOMAXPROCS when workers <= 0). The error
+// reported is always the one for the lowest failing index, matching what
+// VerifyChainSignatures would return for the same input.
+func VerifyChainSignaturesConcurrent(configs []*Config, publicKey string, workers int) error {
+	for i, err := range verifySignaturesConcurrent(configs, publicKey, workers, true) {
+		if err != nil {
+			return fmt.Errorf("signature verification failed at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// verifySignaturesConcurrent verifies each signed config on up to workers
+// goroutines and returns the per-index errors (nil for unsigned configs).
+// With stopAtFirst, configs after the lowest failing index may be left
+// unverified, reported as nil.
+func verifySignaturesConcurrent(configs []*Config, publicKey string, workers int, stopAtFirst bool) []error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(configs) {
+		workers = len(configs)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		firstIdx = len(configs)
+		errs     = make([]error, len(configs))
+		wg       sync.WaitGroup
+	)
+
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				skip := stopAtFirst && i > firstIdx
+				mu.Unlock()
+				if skip || configs[i].Meta.Signature == "" {
+					continue
+				}
+
+				if err := VerifyConfigSignature(configs[i], publicKey); err != nil {
+					mu.Lock()
+					errs[i] = err
+					if i < firstIdx {
+						firstIdx = i
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range configs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if stopAtFirst {
+		for i := firstIdx + 1; i < len(errs); i++ {
+			errs[i] = nil
+		}
+	}
+	return errs
+}
@@ -1,10 +1,14 @@
 package viracochan
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSigner(t *testing.T) {
@@ -64,8 +68,11 @@ func TestSigningHashIsDeterministic(t *testing.T) {
 		t.Fatalf("UpdateMeta failed: %v", err)
 	}
 
-	hash1 := makeSigningHashV2(cfg)
-	hash2 := makeSigningHashV2(cfg)
+	hash1, err := makeSigningHashV2(cfg)
+	if err != nil {
+		t.Fatalf("makeSigningHashV2 failed: %v", err)
+	}
+	hash2, _ := makeSigningHashV2(cfg)
 	if hash1 != hash2 {
 		t.Fatal("signing hash is not deterministic for the same config")
 	}
@@ -193,6 +200,53 @@ func TestVerifyChainSignatures(t *testing.T) {
 	}
 }
 
+func TestVerifyChainSignaturesPolicy(t *testing.T) {
+	signer, _ := NewSigner()
+
+	// v1 and v2 unsigned, v3 and v4 signed
+	configs := make([]*Config, 4)
+	for i := range configs {
+		cfg := &Config{Content: json.RawMessage(fmt.Sprintf(`{"index": %d}`, i))}
+		if i > 0 {
+			cfg.Meta = configs[i-1].Meta
+		}
+		if err := cfg.UpdateMeta(); err != nil {
+			t.Fatalf("UpdateMeta failed: %v", err)
+		}
+		cfg.Meta.Signature, cfg.Meta.SigAlg = "", ""
+		if i >= 2 {
+			if err := signer.Sign(cfg); err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+		}
+		configs[i] = cfg
+	}
+	pub := signer.PublicKey()
+
+	if err := VerifyChainSignaturesPolicy(configs, pub, AllowUnsigned); err != nil {
+		t.Errorf("AllowUnsigned: %v", err)
+	}
+	if err := VerifyChainSignaturesPolicy(configs, pub, RequireLatestSigned); err != nil {
+		t.Errorf("RequireLatestSigned: %v", err)
+	}
+	err := VerifyChainSignaturesStrict(configs, pub)
+	if !errors.Is(err, ErrUnsignedConfig) || !strings.Contains(err.Error(), "version 1") {
+		t.Errorf("strict: expected ErrUnsignedConfig naming version 1, got %v", err)
+	}
+
+	if err := VerifyChainSignaturesPolicy(configs[:2], pub, RequireLatestSigned); !errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("RequireLatestSigned on unsigned head: expected ErrUnsignedConfig, got %v", err)
+	}
+	if err := VerifyChainSignaturesStrict(configs[2:], pub); err != nil {
+		t.Errorf("strict on fully signed chain: %v", err)
+	}
+
+	configs[3].Meta.Signature = "invalid"
+	if err := VerifyChainSignaturesPolicy(configs, pub, RequireLatestSigned); err == nil || errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("expected signature failure, got %v", err)
+	}
+}
+
 func TestSigningWithoutChecksum(t *testing.T) {
 	signer, err := NewSigner()
 	if err != nil {
@@ -269,3 +323,244 @@ func signLegacyFixture(cfg *Config, _ *Signer) error {
 	cfg.Meta.SigAlg = ""
 	return nil
 }
+
+func TestAnnotationsBoundBySignature(t *testing.T) {
+	signer, _ := NewSigner()
+
+	cfg := &Config{Content: json.RawMessage(`{"k": "v"}`)}
+	if err := cfg.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	cs := cfg.Meta.CS
+
+	cfg.Meta.Annotations = map[string]string{"reason": "hotfix"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("annotations must not affect checksum: %v", err)
+	}
+	if cs != cfg.Meta.CS {
+		t.Fatal("checksum changed")
+	}
+
+	if err := signer.Sign(cfg); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := VerifyConfigSignature(cfg, signer.PublicKey()); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	cfg.Meta.Annotations["reason"] = "tampered"
+	if err := VerifyConfigSignature(cfg, signer.PublicKey()); err == nil {
+		t.Error("expected tampered annotations to fail verification")
+	}
+
+	cfg.Meta.Annotations = nil
+	if err := VerifyConfigSignature(cfg, signer.PublicKey()); err == nil {
+		t.Error("expected stripped annotations to fail verification")
+	}
+}
+
+func TestVerifyChainSignaturesConcurrent(t *testing.T) {
+	signer, err := NewSigner()
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+
+	configs := make([]*Config, 40)
+	for i := range configs {
+		cfg := &Config{
+			Content: json.RawMessage(fmt.Sprintf(`{"index": %d}`, i)),
+		}
+		if i > 0 {
+			cfg.Meta = configs[i-1].Meta
+		}
+		if err := cfg.UpdateMeta(); err != nil {
+			t.Fatalf("UpdateMeta failed: %v", err)
+		}
+		if err := signer.Sign(cfg); err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		configs[i] = cfg
+	}
+
+	for _, workers := range []int{0, 1, 4, 100} {
+		if err := VerifyChainSignaturesConcurrent(configs, signer.PublicKey(), workers); err != nil {
+			t.Errorf("workers=%d: verification failed: %v", workers, err)
+		}
+	}
+
+	configs[31].Meta.Signature = "invalid"
+	configs[7].Meta.Signature = "invalid"
+
+	want := VerifyChainSignatures(configs, signer.PublicKey())
+	for _, workers := range []int{0, 1, 4, 100} {
+		got := VerifyChainSignaturesConcurrent(configs, signer.PublicKey(), workers)
+		if got == nil || got.Error() != want.Error() {
+			t.Errorf("workers=%d: expected %q, got %v", workers, want, got)
+		}
+	}
+}
+
+func TestVerifyFresh(t *testing.T) {
+	signer, _ := NewSigner()
+
+	cfg := &Config{Content: json.RawMessage(`{"a":1}`)}
+	if err := cfg.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	signer.Sign(cfg)
+
+	if err := VerifyFresh(cfg, signer.PublicKey(), time.Minute); err != nil {
+		t.Errorf("fresh config rejected: %v", err)
+	}
+
+	old := &Config{Content: json.RawMessage(`{"a":1}`)}
+	old.UpdateMeta()
+	old.Meta.Time = time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond)
+	old.Meta.CS, _ = computeChecksum(old)
+	signer.Sign(old)
+
+	if err := VerifyFresh(old, signer.PublicKey(), time.Minute); !errors.Is(err, ErrStaleSignature) {
+		t.Errorf("expected ErrStaleSignature, got %v", err)
+	}
+
+	// Refreshing the timestamp of a replayed stale config breaks the signature
+	replayed := *old
+	replayed.Meta.Time = time.Now().UTC()
+	if err := VerifyFresh(&replayed, signer.PublicKey(), time.Minute); err == nil || errors.Is(err, ErrStaleSignature) {
+		t.Errorf("expected signature failure for altered timestamp, got %v", err)
+	}
+
+	other, _ := NewSigner()
+	if err := VerifyFresh(cfg, other.PublicKey(), time.Minute); err == nil {
+		t.Error("expected failure for foreign key")
+	}
+}
+
+func TestSignatureFormatEvolution(t *testing.T) {
+	signer, _ := NewSigner()
+
+	old := &Config{Content: json.RawMessage(`{"a":1}`)}
+	old.UpdateMeta()
+	if err := signer.Sign(old); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	// Introduce a hypothetical successor format and make it the default
+	const v3 = "vc-schnorr-secp256k1-test-v3"
+	signingFormats[v3] = func(cfg *Config, contentHash string) ([]byte, error) {
+		payload, err := makeSigningPayloadV2(cfg, contentHash)
+		return append([]byte("viracochan:sig:test-v3:"), payload...), err
+	}
+	currentSignatureAlgorithm = v3
+	defer func() {
+		delete(signingFormats, v3)
+		currentSignatureAlgorithm = SignatureAlgorithmV2
+	}()
+
+	fresh := &Config{Content: json.RawMessage(`{"a":2}`)}
+	fresh.UpdateMeta()
+	if err := signer.Sign(fresh); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if fresh.Meta.SigAlg != v3 {
+		t.Fatalf("expected new signatures to record %q, got %q", v3, fresh.Meta.SigAlg)
+	}
+
+	if err := VerifyConfigSignature(old, signer.PublicKey()); err != nil {
+		t.Errorf("v2 signature no longer verifies after adding v3: %v", err)
+	}
+	if err := VerifyConfigSignature(fresh, signer.PublicKey()); err != nil {
+		t.Errorf("v3 signature does not verify: %v", err)
+	}
+
+	// The recorded format is authoritative: relabelling breaks verification
+	relabelled := *old
+	relabelled.Meta.SigAlg = v3
+	if err := VerifyConfigSignature(&relabelled, signer.PublicKey()); err == nil {
+		t.Error("expected v2 signature relabelled as v3 to fail")
+	}
+}
+
+func TestVerifyConfigBytes(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage(), WithSigner(signer))
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	data, _ := manager.Export(ctx, "app")
+
+	cfg, err := VerifyConfigBytes(data, signer.PublicKey())
+	if err != nil || cfg.Meta.Version != 1 {
+		t.Fatalf("expected verified v1, got %v, %v", cfg, err)
+	}
+
+	other, _ := NewSigner()
+	if _, err := VerifyConfigBytes(data, other.PublicKey()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for wrong key, got %v", err)
+	}
+	if _, err := VerifyConfigBytes([]byte(`{"_meta":`), signer.PublicKey()); !errors.Is(err, ErrMalformedConfig) {
+		t.Errorf("expected ErrMalformedConfig, got %v", err)
+	}
+	tampered := bytes.Replace(data, []byte(`"n": 1`), []byte(`"n": 2`), 1)
+	if _, err := VerifyConfigBytes(tampered, signer.PublicKey()); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+
+	plain, _ := NewManager(NewMemoryStorage())
+	plain.Create(ctx, "app", map[string]interface{}{"n": 1})
+	unsigned, _ := plain.Export(ctx, "app")
+	if _, err := VerifyConfigBytes(unsigned, signer.PublicKey()); !errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("expected ErrUnsignedConfig, got %v", err)
+	}
+}
+
+func TestSigningHashesCurrentContent(t *testing.T) {
+	signer, _ := NewSigner()
+	cfg := &Config{}
+	if err := json.Unmarshal([]byte(`{"content":{"role":"user"}}`), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	if err := signer.Sign(cfg); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	// Decoding over the content reuses its backing array, so the new bytes
+	// sit at the same address with the same length
+	before := &cfg.Content[0]
+	if err := json.Unmarshal([]byte(`{"role":"root"}`), &cfg.Content); err != nil {
+		t.Fatal(err)
+	}
+	if &cfg.Content[0] != before {
+		t.Skip("content was not overwritten in place")
+	}
+	if err := VerifyConfigSignature(cfg, signer.PublicKey()); err == nil {
+		t.Error("expected content overwritten in place to fail verification")
+	}
+}
+
+func BenchmarkSignLargeConfig(b *testing.B) {
+	signer, _ := NewSigner()
+	items := make([]string, 0, 1<<16)
+	for i := 0; len(items) < cap(items); i++ {
+		items = append(items, fmt.Sprintf("item-%08d-%s", i, strings.Repeat("x", 48)))
+	}
+	content, _ := json.Marshal(map[string]interface{}{"items": items})
+	b.SetBytes(int64(len(content)))
+
+	cfg := &Config{Content: content}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cfg.UpdateMeta(); err != nil {
+			b.Fatal(err)
+		}
+		if err := signer.Sign(cfg); err != nil {
+			b.Fatal(err)
+		}
+		if err := VerifyConfigSignature(cfg, signer.PublicKey()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// snapshotPrefix is where named snapshots are kept in the config storage
+const snapshotPrefix = "snapshots"
+
+// SnapshotRef is the version of one config recorded by a snapshot
+type SnapshotRef struct {
+	Version uint64 `json:"v"`
+	CS      string `json:"cs"`
+}
+
+// Snapshot records the latest version of a set of configs at one moment
+type Snapshot struct {
+	Name    string                 `json:"name"`
+	Time    time.Time              `json:"time"`
+	Configs map[string]SnapshotRef `json:"configs"`
+}
+
+func (m *Manager) snapshotPath(name string) string {
+	return filepath.Join(snapshotPrefix, name+".json")
+}
+
+// CreateSnapshot records the latest version of each of ids (every config in
+// the store when none are given) under name, which must not be taken.
+// Snapshots are stored with the configs under snapshots/<name>.json.
+func (m *Manager) CreateSnapshot(ctx context.Context, name string, ids ...string) (snap *Snapshot, err error) {
+	ctx, call := m.begin(ctx, "create_snapshot", "")
+	defer func() { call.end(nil, err) }()
+
+	if err := DefaultIDValidator(name); err != nil {
+		return nil, fmt.Errorf("invalid snapshot name: %w", err)
+	}
+	for _, id := range ids {
+		if err := m.validateID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	if exists, err := m.storage.Exists(ctx, m.snapshotPath(name)); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("%w: snapshot %q already exists", ErrVersionConflict, name)
+	}
+
+	if len(ids) == 0 {
+		var err error
+		if ids, err = m.configIDs(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	snap = &Snapshot{Name: name, Time: time.Now().UTC(), Configs: make(map[string]SnapshotRef, len(ids))}
+	for _, id := range ids {
+		cfg, err := m.getLatest(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("config %q: %w", id, err)
+		}
+		snap.Configs[id] = SnapshotRef{Version: cfg.Meta.Version, CS: cfg.Meta.CS}
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.storage.Write(ctx, m.snapshotPath(name), data); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// GetSnapshot returns the snapshot stored under name
+func (m *Manager) GetSnapshot(ctx context.Context, name string) (*Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	return m.readSnapshot(ctx, name)
+}
+
+func (m *Manager) readSnapshot(ctx context.Context, name string) (*Snapshot, error) {
+	if err := DefaultIDValidator(name); err != nil {
+		return nil, fmt.Errorf("invalid snapshot name: %w", err)
+	}
+	data, err := m.storage.Read(ctx, m.snapshotPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %q: %w", name, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("snapshot %q: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// RollbackToSnapshot returns every config recorded by the named snapshot to
+// its recorded content, each as a new version continuing its chain (as
+// Rollback does), and returns the latest version of each. Configs still at
+// their recorded version are left alone.
+//
+// All recorded versions are loaded and checked against their recorded
+// checksums before anything is written, and the new versions are journaled
+// with a single append, so either every config rolls back or none does. With
+// WithIntentLog the whole rollback is one intent.
+func (m *Manager) RollbackToSnapshot(ctx context.Context, name string, opts ...WriteOption) (result map[string]*Config, err error) {
+	ctx, call := m.begin(ctx, "rollback_to_snapshot", "")
+	defer func() { call.end(nil, err) }()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	snap, err := m.readSnapshot(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(snap.Configs))
+	for id := range snap.Configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	wo := newWriteOptions(opts)
+	result = make(map[string]*Config, len(ids))
+	var changed []string
+	var errs []error
+	for _, id := range ids {
+		ref := snap.Configs[id]
+		target, err := m.configStore.Load(ctx, id, ref.Version)
+		if err == nil && target.Meta.CS != ref.CS {
+			err = fmt.Errorf("%w: recorded cs=%s, version %d has cs=%s", ErrChecksumMismatch, ref.CS, ref.Version, target.Meta.CS)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config %q: %w", id, err))
+			continue
+		}
+
+		latest, err := m.getLatest(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config %q: %w", id, err))
+			continue
+		}
+		if latest.Meta.CS == ref.CS {
+			result[id] = latest
+			continue
+		}
+
+		newCfg := &Config{Meta: latest.Meta, Content: target.Content}
+		newCfg.Meta.HashAlg = m.hashAlg
+		newCfg.Meta.ContentType = target.Meta.ContentType
+		err = newCfg.UpdateMeta()
+		if err == nil {
+			err = m.checkCrossRef(ctx, id, newCfg)
+		}
+		if err == nil {
+			err = m.seal(newCfg, wo)
+		}
+		if err == nil {
+			err = m.checkRegistry(ctx, id, newCfg)
+		}
+		if err == nil {
+			err = m.checkPolicies(id, newCfg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config %q: %w", id, err))
+			continue
+		}
+		result[id] = newCfg
+		changed = append(changed, id)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(changed) == 0 {
+		return result, nil
+	}
+
+	op := func(id string) string {
+		return fmt.Sprintf("rollback_to_v%d", snap.Configs[id].Version)
+	}
+	writes := make([]IntentWrite, 0, len(changed))
+	for _, id := range changed {
+		writes = append(writes, intentWrite(id, result[id], op(id), wo.message))
+	}
+	if err := m.checkFence(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.checkJournalCap(ctx, len(writes)); err != nil {
+		return nil, err
+	}
+	tx, err := m.beginIntent(ctx, writes)
+	if err != nil {
+		return nil, err
+	}
+
+	undo := func(ids []string) {
+		for _, id := range ids {
+			if err := m.configStore.Delete(ctx, id, result[id].Meta.Version); err != nil {
+				m.logger.Warn("rollback to snapshot: failed to remove partially written config", "id", id, "error", err)
+			}
+		}
+	}
+	entries := make([]*JournalEntry, 0, len(changed))
+	for i, id := range changed {
+		if err := m.configStore.Save(ctx, id, result[id]); err != nil {
+			undo(changed[:i])
+			return nil, fmt.Errorf("config %q: %w", id, err)
+		}
+		entry := m.journalEntry(id, result[id], op(id))
+		entry.Message = wo.message
+		entries = append(entries, entry)
+	}
+	if err := m.journal.AppendBatch(ctx, entries); err != nil {
+		undo(changed)
+		return nil, err
+	}
+	if err := m.commitIntent(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	for i, id := range changed {
+		m.cachePut(id, result[id])
+		m.indexPut(entries[i])
+	}
+	m.noteWrites(len(entries))
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRollbackToSnapshot(t *testing.T) {
+	ctx := context.Background()
+	storage := &journalWriteCounter{MemoryStorage: NewMemoryStorage(), path: "journal.jsonl"}
+	signer, _ := NewSigner()
+	manager, _ := NewManager(storage, WithSigner(signer))
+
+	a1, _ := manager.Create(ctx, "a", map[string]interface{}{"release": 1})
+	b1, _ := manager.Create(ctx, "b", map[string]interface{}{"release": 1})
+	c1, _ := manager.Create(ctx, "c", map[string]interface{}{"release": 1})
+
+	snap, err := manager.CreateSnapshot(ctx, "release-1")
+	if err != nil || len(snap.Configs) != 3 {
+		t.Fatalf("CreateSnapshot failed: %+v, %v", snap, err)
+	}
+	if _, err := manager.CreateSnapshot(ctx, "release-1"); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict for a taken name, got %v", err)
+	}
+
+	manager.Update(ctx, "a", map[string]interface{}{"release": 2})
+	manager.Update(ctx, "a", map[string]interface{}{"release": 3})
+	manager.Update(ctx, "b", map[string]interface{}{"release": 2})
+
+	// A failed journal append leaves every config where it was
+	storage.fail = true
+	if _, err := manager.RollbackToSnapshot(ctx, "release-1"); err == nil {
+		t.Fatal("expected rollback to fail on journal write")
+	}
+	storage.fail = false
+	if versions, _ := manager.configStore.ListVersions(ctx, "a"); len(versions) != 3 {
+		t.Errorf("failed rollback left files behind: %v", versions)
+	}
+
+	result, err := manager.RollbackToSnapshot(ctx, "release-1")
+	if err != nil {
+		t.Fatalf("RollbackToSnapshot failed: %v", err)
+	}
+	for id, want := range map[string]*Config{"a": a1, "b": b1, "c": c1} {
+		reopened, _ := NewManager(storage.MemoryStorage)
+		latest, err := reopened.GetLatest(ctx, id)
+		if err != nil || !latest.ContentEqual(want) || !latest.Equal(result[id]) {
+			t.Errorf("%s: expected snapshot content, got %v, %v", id, latest, err)
+		}
+		if err := reopened.ValidateChain(ctx, id); err != nil {
+			t.Errorf("%s: chain invalid: %v", id, err)
+		}
+	}
+	if result["a"].Meta.Version != 4 || result["b"].Meta.Version != 3 || result["c"].Meta.Version != 1 {
+		t.Errorf("unexpected versions a=%d b=%d c=%d", result["a"].Meta.Version, result["b"].Meta.Version, result["c"].Meta.Version)
+	}
+}
+
+func TestRollbackToSnapshotChecksFirst(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	manager.Create(ctx, "a", map[string]interface{}{"release": 1})
+	b1, _ := manager.Create(ctx, "b", map[string]interface{}{"release": 1})
+	manager.CreateSnapshot(ctx, "s", "a", "b")
+	manager.Update(ctx, "a", map[string]interface{}{"release": 2})
+	manager.Update(ctx, "b", map[string]interface{}{"release": 2})
+
+	// The recorded version of b no longer holds the recorded content
+	forged := *b1
+	forged.Content = []byte(`{"release":9}`)
+	forged.Meta.CS, _ = computeChecksum(&forged)
+	manager.configStore.Save(ctx, "b", &forged)
+
+	if _, err := manager.RollbackToSnapshot(ctx, "s"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if latest, _ := manager.GetLatest(ctx, "a"); latest.Meta.Version != 2 {
+		t.Errorf("a was rolled back despite the failed check: v%d", latest.Meta.Version)
+	}
+
+	if _, err := manager.RollbackToSnapshot(ctx, "missing"); err == nil {
+		t.Error("expected unknown snapshot to fail")
+	}
+}
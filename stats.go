@@ -0,0 +1,64 @@
+package viracochan
+
+import "time"
+
+// ManagerStats is an in-memory snapshot of a manager's counters, returned by
+// Stats. Counts are since the manager was created.
+type ManagerStats struct {
+	Time time.Time `json:"time"`
+
+	// CacheEntries is the number of latest versions cached; CacheHits and
+	// CacheMisses count cache lookups, those of write paths included
+	CacheEntries int    `json:"cache_entries"`
+	CacheHits    uint64 `json:"cache_hits"`
+	CacheMisses  uint64 `json:"cache_misses"`
+
+	// KnownIDs is the number of ids the manager has read or written and not
+	// since removed
+	KnownIDs int `json:"known_ids"`
+
+	// JournalEntries and JournalBytes are the journal's size as of the
+	// manager's last append, count (see Status) or rewrite of it, such as a
+	// compaction; both are 0 before the first. Appends by other processes
+	// show up at the next one.
+	JournalEntries int   `json:"journal_entries"`
+	JournalBytes   int64 `json:"journal_bytes"`
+
+	// Writes counts the versions written; LastWrite and LastCompaction are
+	// zero when there has been none
+	Writes         uint64    `json:"writes"`
+	LastWrite      time.Time `json:"last_write"`
+	LastCompaction time.Time `json:"last_compaction"`
+}
+
+// Stats returns a snapshot of the manager's cache, journal and write
+// counters. It reads only in-memory counters, never storage, so it is cheap
+// enough to poll and safe to call concurrently with anything, Close
+// included. For figures read from storage see Status and Usage, and for
+// continuous export WithMetrics.
+func (m *Manager) Stats() ManagerStats {
+	m.cacheMu.Lock()
+	cached, known := len(m.cache), len(m.highWater)
+	m.cacheMu.Unlock()
+
+	return ManagerStats{
+		Time:           time.Now().UTC(),
+		CacheEntries:   cached,
+		CacheHits:      m.cacheHits.Load(),
+		CacheMisses:    m.cacheMisses.Load(),
+		KnownIDs:       known,
+		JournalEntries: int(m.journal.entries.Load()),
+		JournalBytes:   m.journal.bytes.Load(),
+		Writes:         m.writes.Load(),
+		LastWrite:      unixNanoTime(m.lastWrite.Load()),
+		LastCompaction: unixNanoTime(m.lastCompaction.Load()),
+	}
+}
+
+// unixNanoTime converts Unix nanoseconds to a UTC time, 0 to the zero time
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
@@ -0,0 +1,74 @@
+package viracochan
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestManagerStats(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	if stats := manager.Stats(); stats.Writes != 0 || !stats.LastWrite.IsZero() || stats.JournalEntries != 0 {
+		t.Errorf("unexpected stats for a new manager: %+v", stats)
+	}
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Create(ctx, "db", map[string]interface{}{"host": "a"})
+	manager.GetLatest(ctx, "app")
+	manager.GetLatest(ctx, "app")
+
+	stats := manager.Stats()
+	if stats.Writes != 3 || stats.LastWrite.IsZero() || !stats.LastCompaction.IsZero() {
+		t.Errorf("unexpected write stats: %+v", stats)
+	}
+	if stats.CacheEntries != 2 || stats.KnownIDs != 2 || stats.CacheHits < 2 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+	data, _ := storage.Read(ctx, "journal.jsonl")
+	if stats.JournalEntries != 3 || stats.JournalBytes != int64(len(data)) {
+		t.Errorf("journal stats %d entries, %d bytes; journal holds %d bytes", stats.JournalEntries, stats.JournalBytes, len(data))
+	}
+
+	// Another manager's writes show up at this one's next count
+	other, _ := NewManager(storage)
+	other.Update(ctx, "db", map[string]interface{}{"host": "b"})
+	if _, err := manager.Status(ctx); err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if stats := manager.Stats(); stats.JournalEntries != 4 {
+		t.Errorf("expected the recount to see 4 entries, got %d", stats.JournalEntries)
+	}
+
+	misses := manager.Stats().CacheMisses
+	manager.GetLatest(ctx, "missing")
+	if manager.Stats().CacheMisses != misses+1 {
+		t.Error("a lookup of an uncached id should count as a miss")
+	}
+
+	if _, err := manager.Compact(ctx); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if manager.Stats().LastCompaction.IsZero() {
+		t.Error("expected the compaction time to be recorded")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			manager.Stats()
+			manager.GetLatest(ctx, "app")
+		}()
+	}
+	wg.Wait()
+
+	manager.Close()
+	if stats := manager.Stats(); stats.Writes != 3 {
+		t.Errorf("Stats after Close: %+v", stats)
+	}
+}
@@ -3,15 +3,27 @@ package viracochan
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
 )
 
-// Storage defines interface for filesystem-like operations
+// Storage defines interface for filesystem-like operations.
+//
+// Implementations are expected to follow these semantics, which
+// viracochantest.StorageConformanceTest checks:
+//   - Read of a missing path returns an error matching os.ErrNotExist
+//   - empty writes are stored and read back as zero-length data
+//   - Write overwrites existing data
+//   - List treats prefix as a directory: "a" matches "a/x" but not "ab/x";
+//     a missing prefix yields an empty list without error
+//   - Delete of a missing path is not an error
 type Storage interface {
 	Read(ctx context.Context, path string) ([]byte, error)
 	Write(ctx context.Context, path string, data []byte) error
@@ -20,10 +32,59 @@ type Storage interface {
 	Exists(ctx context.Context, path string) (bool, error)
 }
 
+// PathLocker is implemented by storages that hand out one lock per path.
+// Every Journal over such a storage at a path shares the lock, so journals
+// of several managers over one store serialise their appends and rewrites.
+// Wrapping storages pass the call on to their backend; PathLock returns nil
+// if there is no lock to share.
+type PathLocker interface {
+	PathLock(path string) sync.Locker
+}
+
+// pathLock returns the lock of path shared through storage, nil if it does
+// not share one
+func pathLock(storage Storage, path string) sync.Locker {
+	if pl, ok := storage.(PathLocker); ok {
+		return pl.PathLock(path)
+	}
+	return nil
+}
+
+// pathLocks hands out one lock per path for PathLocker implementations; the
+// zero value is ready to use. Locks are few, one per journal path, and live
+// as long as the storage.
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (p *pathLocks) lock(path string) sync.Locker {
+	path = filepath.Clean(path)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.locks == nil {
+		p.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := p.locks[path]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.locks[path] = lock
+	}
+	return lock
+}
+
 // FileStorage implements Storage using local filesystem
 type FileStorage struct {
-	root string
-	mu   sync.RWMutex
+	root  string
+	mu    sync.RWMutex
+	paths pathLocks
+}
+
+// PathLock returns the lock journals over fs share for path. Journals of
+// another FileStorage over the same directory do not share it.
+func (fs *FileStorage) PathLock(path string) sync.Locker {
+	return fs.paths.lock(path)
 }
 
 // NewFileStorage creates new filesystem storage
@@ -79,7 +140,10 @@ func (fs *FileStorage) Write(ctx context.Context, path string, data []byte) erro
 
 // atomicWriteFile writes data to a temp file in the same directory and renames
 // it to the target path. On POSIX systems os.Rename is atomic within the same
-// filesystem, so readers never see a partially-written file.
+// filesystem, so readers never see a partially-written file. The data is
+// synced before the rename and the directory after it, so once it returns the
+// file survives a crash; the manager relies on this to publish a version's
+// journal entry only after its config file is on disk.
 func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
 	dir := filepath.Dir(path)
 	tmp, err := os.CreateTemp(dir, ".viracochan-*.tmp")
@@ -89,6 +153,9 @@ func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
 	tmpPath := tmp.Name()
 
 	_, writeErr := tmp.Write(data)
+	if writeErr == nil {
+		writeErr = tmp.Sync()
+	}
 	if closeErr := tmp.Close(); writeErr == nil {
 		writeErr = closeErr
 	}
@@ -102,10 +169,40 @@ func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
 		return err
 	}
 
-	return os.Rename(tmpPath, path)
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes a rename into dir durable. Platforms that cannot sync a
+// directory (Windows) are skipped.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir) // #nosec G304 - dir of a validated path
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
 func (fs *FileStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	stats, err := fs.ListStat(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, st := range stats {
+		paths = append(paths, st.Path)
+	}
+	return paths, nil
+}
+
+// ListStat implements StatLister from the same walk as List
+func (fs *FileStorage) ListStat(ctx context.Context, prefix string) ([]FileStat, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -121,7 +218,7 @@ func (fs *FileStorage) List(ctx context.Context, prefix string) ([]string, error
 		return nil, err
 	}
 
-	var paths []string
+	var stats []FileStat
 
 	err = filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -132,12 +229,12 @@ func (fs *FileStorage) List(ctx context.Context, prefix string) ([]string, error
 			if err != nil {
 				return err
 			}
-			paths = append(paths, rel)
+			stats = append(stats, FileStat{Path: rel, Size: info.Size()})
 		}
 		return nil
 	})
 
-	return paths, err
+	return stats, err
 }
 
 func (fs *FileStorage) Delete(ctx context.Context, path string) error {
@@ -149,7 +246,10 @@ func (fs *FileStorage) Delete(ctx context.Context, path string) error {
 		return err
 	}
 
-	return os.Remove(fullPath)
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 func (fs *FileStorage) Exists(ctx context.Context, path string) (bool, error) {
@@ -170,8 +270,14 @@ func (fs *FileStorage) Exists(ctx context.Context, path string) (bool, error) {
 
 // MemoryStorage implements Storage in memory
 type MemoryStorage struct {
-	data map[string][]byte
-	mu   sync.RWMutex
+	data  map[string][]byte
+	mu    sync.RWMutex
+	paths pathLocks
+}
+
+// PathLock returns the lock journals over ms share for path
+func (ms *MemoryStorage) PathLock(path string) sync.Locker {
+	return ms.paths.lock(path)
 }
 
 // NewMemoryStorage creates new in-memory storage
@@ -200,19 +306,43 @@ func (ms *MemoryStorage) Write(ctx context.Context, path string, data []byte) er
 	return nil
 }
 
+// List returns matching paths in sorted order, like FileStorage's lexical
+// walk, so iteration over a MemoryStorage is reproducible
 func (ms *MemoryStorage) List(ctx context.Context, prefix string) ([]string, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
+	// Match on directory boundaries like FileStorage does
+	dir := strings.TrimSuffix(prefix, "/")
 	var paths []string
 	for path := range ms.data {
-		if strings.HasPrefix(path, prefix) {
+		if dir == "" || path == dir || strings.HasPrefix(path, dir+"/") {
 			paths = append(paths, path)
 		}
 	}
+	sort.Strings(paths)
 	return paths, nil
 }
 
+// ListStat implements StatLister, in List's order
+func (ms *MemoryStorage) ListStat(ctx context.Context, prefix string) ([]FileStat, error) {
+	paths, err := ms.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	stats := make([]FileStat, 0, len(paths))
+	for _, path := range paths {
+		if data, ok := ms.data[path]; ok {
+			stats = append(stats, FileStat{Path: path, Size: int64(len(data))})
+		}
+	}
+	return stats, nil
+}
+
 func (ms *MemoryStorage) Delete(ctx context.Context, path string) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
@@ -229,18 +359,86 @@ func (ms *MemoryStorage) Exists(ctx context.Context, path string) (bool, error)
 	return ok, nil
 }
 
+// Snapshot returns a deep copy of the storage contents. Later writes to the
+// storage do not affect the snapshot and vice versa.
+func (ms *MemoryStorage) Snapshot() map[string][]byte {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return copyMemoryData(ms.data)
+}
+
+// Restore replaces the storage contents with a deep copy of snapshot.
+func (ms *MemoryStorage) Restore(snapshot map[string][]byte) {
+	data := copyMemoryData(snapshot)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.data = data
+}
+
+// Clone returns an independent MemoryStorage holding a copy of the current
+// contents.
+func (ms *MemoryStorage) Clone() *MemoryStorage {
+	return &MemoryStorage{data: ms.Snapshot()}
+}
+
+func copyMemoryData(src map[string][]byte) map[string][]byte {
+	dst := make(map[string][]byte, len(src))
+	for path, data := range src {
+		dst[path] = append([]byte(nil), data...)
+	}
+	return dst
+}
+
+var (
+	ErrUnsignedConfig     = errors.New("config is not signed")
+	ErrUntrustedSignature = errors.New("signature not valid for any trusted key")
+)
+
 // ConfigStorage wraps Storage with Config-specific operations
 type ConfigStorage struct {
 	storage Storage
 	prefix  string
+
+	verifyKeys   []string
+	strictVerify bool
+	pretty       bool   // indent version files; see WithPrettyStorage
+	keyframes    int    // diff versions between keyframes; see WithDeltaStorage
+	compression  string // codec of full version files; see WithCompression
+}
+
+// ConfigStorageOption configures ConfigStorage
+type ConfigStorageOption func(*ConfigStorage)
+
+// WithVerifyKeys makes Load verify the signature of every signed config
+// against the trusted public keys, refusing configs that verify under none of
+// them. Unsigned configs are still returned unless WithStrictVerify is set.
+func WithVerifyKeys(keys []string) ConfigStorageOption {
+	return func(cs *ConfigStorage) {
+		cs.verifyKeys = append([]string(nil), keys...)
+	}
+}
+
+// WithStrictVerify makes Load reject unsigned configs when verification keys
+// are configured.
+func WithStrictVerify() ConfigStorageOption {
+	return func(cs *ConfigStorage) {
+		cs.strictVerify = true
+	}
 }
 
 // NewConfigStorage creates storage wrapper for configs
-func NewConfigStorage(storage Storage, prefix string) *ConfigStorage {
-	return &ConfigStorage{
+func NewConfigStorage(storage Storage, prefix string, opts ...ConfigStorageOption) *ConfigStorage {
+	cs := &ConfigStorage{
 		storage: storage,
 		prefix:  prefix,
 	}
+	for _, opt := range opts {
+		opt(cs)
+	}
+	return cs
 }
 
 func (cs *ConfigStorage) makeKey(id string, version uint64) string {
@@ -249,13 +447,28 @@ func (cs *ConfigStorage) makeKey(id string, version uint64) string {
 
 func (cs *ConfigStorage) Save(ctx context.Context, id string, cfg *Config) error {
 	key := cs.makeKey(id, cfg.Meta.Version)
-	data, err := json.Marshal(cfg)
+	var data []byte
+	var err error
+	if delta := cs.deltaFor(ctx, id, cfg); delta != nil {
+		data, err = cs.encode(configFile{Meta: cfg.Meta, Delta: delta})
+	} else {
+		data, err = cs.encodeFull(cfg)
+	}
 	if err != nil {
 		return err
 	}
 	return cs.storage.Write(ctx, key, data)
 }
 
+// encode marshals a version file, indented if the store is pretty
+func (cs *ConfigStorage) encode(v any) ([]byte, error) {
+	if cs.pretty {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return append(data, '\n'), err
+	}
+	return json.Marshal(v)
+}
+
 func (cs *ConfigStorage) Load(ctx context.Context, id string, version uint64) (*Config, error) {
 	key := cs.makeKey(id, version)
 	data, err := cs.storage.Read(ctx, key)
@@ -263,8 +476,8 @@ func (cs *ConfigStorage) Load(ctx context.Context, id string, version uint64) (*
 		return nil, err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	cfg, err := decodeConfigFile(ctx, cs.storage, key, data)
+	if err != nil {
 		return nil, err
 	}
 
@@ -275,9 +488,34 @@ func (cs *ConfigStorage) Load(ctx context.Context, id string, version uint64) (*
 		}
 	}
 
-	return &cfg, nil
+	if err := cs.verifySignature(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// verifySignature enforces the configured trusted keys on cfg; it is a no-op
+// when no keys are configured
+func (cs *ConfigStorage) verifySignature(cfg *Config) error {
+	if len(cs.verifyKeys) == 0 {
+		return nil
+	}
+
+	if cfg.Meta.Signature == "" {
+		if cs.strictVerify {
+			return fmt.Errorf("%w: version %d", ErrUnsignedConfig, cfg.Meta.Version)
+		}
+		return nil
+	}
+
+	if verifiesUnderAny(cfg, cs.verifyKeys) {
+		return nil
+	}
+	return fmt.Errorf("%w: version %d", ErrUntrustedSignature, cfg.Meta.Version)
 }
 
+// ListVersions returns the stored versions of id in ascending order
 func (cs *ConfigStorage) ListVersions(ctx context.Context, id string) ([]uint64, error) {
 	prefix := filepath.Join(cs.prefix, id)
 	paths, err := cs.storage.List(ctx, prefix)
@@ -295,9 +533,37 @@ func (cs *ConfigStorage) ListVersions(ctx context.Context, id string) ([]uint64,
 			}
 		}
 	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
 	return versions, nil
 }
 
+// Delete removes the file of one version of id. A version that is already
+// gone is not an error, even on backends that report missing paths.
+func (cs *ConfigStorage) Delete(ctx context.Context, id string, version uint64) error {
+	err := cs.storage.Delete(ctx, cs.makeKey(id, version))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// DeleteAll removes the files of every version of id. Content blobs written
+// by CompactToBlobs may be shared with other versions and are kept.
+func (cs *ConfigStorage) DeleteAll(ctx context.Context, id string) error {
+	versions, err := cs.ListVersions(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if err := cs.Delete(ctx, id, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (cs *ConfigStorage) LoadLatest(ctx context.Context, id string) (*Config, error) {
 	versions, err := cs.ListVersions(ctx, id)
 	if err != nil {
@@ -3,6 +3,7 @@ package viracochan
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -319,3 +320,176 @@ func TestStorageEdgeCases(t *testing.T) {
 		t.Error("Overwrite did not update content")
 	}
 }
+
+func TestMemoryStorageSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	storage.Write(ctx, "a.txt", []byte("one"))
+	snapshot := storage.Snapshot()
+
+	storage.Write(ctx, "a.txt", []byte("two"))
+	storage.Write(ctx, "b.txt", []byte("new"))
+
+	if string(snapshot["a.txt"]) != "one" {
+		t.Errorf("snapshot affected by later write: %s", snapshot["a.txt"])
+	}
+	if _, ok := snapshot["b.txt"]; ok {
+		t.Error("snapshot should not contain files written after it")
+	}
+
+	storage.Restore(snapshot)
+	snapshot["a.txt"][0] = 'X'
+
+	data, err := storage.Read(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("Read after restore failed: %v", err)
+	}
+	if string(data) != "one" {
+		t.Errorf("expected restored content 'one', got %q", data)
+	}
+	if exists, _ := storage.Exists(ctx, "b.txt"); exists {
+		t.Error("restore should drop files absent from snapshot")
+	}
+}
+
+func TestMemoryStorageClone(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	storage.Write(ctx, "shared.txt", []byte("base"))
+
+	clone := storage.Clone()
+	clone.Write(ctx, "shared.txt", []byte("forked"))
+	storage.Write(ctx, "only-origin.txt", []byte("x"))
+
+	data, _ := storage.Read(ctx, "shared.txt")
+	if string(data) != "base" {
+		t.Errorf("write to clone leaked into origin: %q", data)
+	}
+	if exists, _ := clone.Exists(ctx, "only-origin.txt"); exists {
+		t.Error("write to origin leaked into clone")
+	}
+}
+
+func TestConfigStorageVerifyKeys(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	trusted, _ := NewSigner()
+	untrusted, _ := NewSigner()
+
+	signed := &Config{Content: json.RawMessage(`{"signed":true}`)}
+	signed.UpdateMeta()
+	trusted.Sign(signed)
+
+	forged := &Config{Content: json.RawMessage(`{"forged":true}`)}
+	forged.UpdateMeta()
+	untrusted.Sign(forged)
+
+	unsigned := &Config{Content: json.RawMessage(`{"unsigned":true}`)}
+	unsigned.UpdateMeta()
+
+	plain := NewConfigStorage(storage, "configs")
+	plain.Save(ctx, "signed", signed)
+	plain.Save(ctx, "forged", forged)
+	plain.Save(ctx, "unsigned", unsigned)
+
+	verifying := NewConfigStorage(storage, "configs", WithVerifyKeys([]string{trusted.PublicKey()}))
+	if _, err := verifying.Load(ctx, "signed", 1); err != nil {
+		t.Errorf("trusted signature should load: %v", err)
+	}
+	if _, err := verifying.Load(ctx, "forged", 1); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected ErrUntrustedSignature, got %v", err)
+	}
+	if _, err := verifying.Load(ctx, "unsigned", 1); err != nil {
+		t.Errorf("unsigned config should load in lenient mode: %v", err)
+	}
+
+	strict := NewConfigStorage(storage, "configs", WithVerifyKeys([]string{trusted.PublicKey()}), WithStrictVerify())
+	if _, err := strict.Load(ctx, "unsigned", 1); !errors.Is(err, ErrUnsignedConfig) {
+		t.Errorf("expected ErrUnsignedConfig in strict mode, got %v", err)
+	}
+	if _, err := strict.LoadLatest(ctx, "signed"); err != nil {
+		t.Errorf("strict store should load trusted config: %v", err)
+	}
+}
+
+func TestConfigStorageListVersionsIDBoundary(t *testing.T) {
+	ctx := context.Background()
+	configStore := NewConfigStorage(NewMemoryStorage(), "configs")
+
+	app := &Config{Content: json.RawMessage(`{"app":1}`)}
+	app.UpdateMeta()
+	configStore.Save(ctx, "app", app)
+
+	app2 := &Config{Content: json.RawMessage(`{"app2":1}`)}
+	app2.UpdateMeta()
+	app2.UpdateMeta()
+	configStore.Save(ctx, "app2", app2)
+
+	versions, err := configStore.ListVersions(ctx, "app")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if !reflect.DeepEqual(versions, []uint64{1}) {
+		t.Errorf("ListVersions(app) leaked versions of app2: %v", versions)
+	}
+}
+
+func TestConfigStorageDelete(t *testing.T) {
+	ctx := context.Background()
+	configStore := NewConfigStorage(NewMemoryStorage(), "configs")
+
+	cfg := &Config{Content: json.RawMessage(`{"n":1}`)}
+	for i := 0; i < 3; i++ {
+		cfg.UpdateMeta()
+		configStore.Save(ctx, "app", cfg)
+	}
+	other := &Config{Content: json.RawMessage(`{"n":1}`)}
+	other.UpdateMeta()
+	configStore.Save(ctx, "app2", other)
+
+	if err := configStore.Delete(ctx, "app", 2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := configStore.Delete(ctx, "app", 2); err != nil {
+		t.Errorf("Delete of a missing version should succeed, got %v", err)
+	}
+	if versions, _ := configStore.ListVersions(ctx, "app"); !reflect.DeepEqual(versions, []uint64{1, 3}) {
+		t.Errorf("expected [1 3] after delete, got %v", versions)
+	}
+
+	if err := configStore.DeleteAll(ctx, "app"); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+	if versions, _ := configStore.ListVersions(ctx, "app"); len(versions) != 0 {
+		t.Errorf("expected no versions after DeleteAll, got %v", versions)
+	}
+	if versions, _ := configStore.ListVersions(ctx, "app2"); len(versions) != 1 {
+		t.Errorf("DeleteAll(app) touched app2: %v", versions)
+	}
+	if err := configStore.DeleteAll(ctx, "missing"); err != nil {
+		t.Errorf("DeleteAll of a missing id should succeed, got %v", err)
+	}
+}
+
+func TestMemoryStorageListSorted(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	for _, path := range []string{"c/2", "a/1", "c/10", "b/1", "a/0", "c/1"} {
+		storage.Write(ctx, path, []byte("x"))
+	}
+
+	want := []string{"a/0", "a/1", "b/1", "c/1", "c/10", "c/2"}
+	for i := 0; i < 10; i++ {
+		paths, err := storage.List(ctx, "")
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if !reflect.DeepEqual(paths, want) {
+			t.Fatalf("expected sorted %v, got %v", want, paths)
+		}
+	}
+	if paths, _ := storage.List(ctx, "c"); !reflect.DeepEqual(paths, want[3:]) {
+		t.Errorf("expected sorted %v under c, got %v", want[3:], paths)
+	}
+}
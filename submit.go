@@ -0,0 +1,100 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Submit appends cfg, a version built and signed outside the manager, as the
+// next version of id. cfg must carry a valid checksum and chain onto the
+// current head (see NextOf): the next version number, the head's checksum as
+// PrevCS and no earlier timestamp. For a new id it must be a v1 with no
+// PrevCS. Submit never re-signs or alters cfg; a stale or forked submission
+// fails with ErrVersionConflict or ErrInvalidChain and writes nothing.
+//
+// The signature must satisfy the manager's own verification, as for any
+// load or write: the config store's WithVerifyKeys settings and
+// WithRequireSignature. trustedKeys, if given, add a requirement on top: the
+// signature must also verify under one of them. The submission then goes through the same checks as Update (cross-references,
+// content type, policies, quota) before it is saved and journaled.
+//
+// Unlike Import, which adopts existing history, Submit is an ingest gate:
+// signing happens elsewhere and the manager enforces integrity.
+func (m *Manager) Submit(ctx context.Context, id string, cfg *Config, trustedKeys ...string) (err error) {
+	ctx, call := m.begin(ctx, "submit", id)
+	defer func() { call.end(cfg, err) }()
+
+	if err := m.validateID(id); err != nil {
+		return err
+	}
+	if cfg == nil {
+		return errors.New("config is nil")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	current, err := m.getLatest(ctx, id)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if cfg.Meta.Version != 1 || cfg.Meta.PrevCS != "" {
+			return m.chainFailure("submit", fmt.Errorf("%w: %q does not exist, submission must be a v1 without prev_cs", ErrInvalidChain, id))
+		}
+	case err != nil:
+		return err
+	case cfg.Meta.Version <= current.Meta.Version:
+		return fmt.Errorf("%w: %q is at version %d, submission is version %d", ErrVersionConflict, id, current.Meta.Version, cfg.Meta.Version)
+	default:
+		if err := cfg.nextOf(current, m.clockSkew); err != nil {
+			return m.chainFailure("submit", fmt.Errorf("%w: submission does not continue %q: %v", ErrInvalidChain, id, err))
+		}
+	}
+
+	if len(trustedKeys) > 0 {
+		if cfg.Meta.Signature == "" {
+			return fmt.Errorf("%w: version %d", ErrUnsignedConfig, cfg.Meta.Version)
+		}
+		if !verifiesUnderAny(cfg, trustedKeys) {
+			return fmt.Errorf("%w: version %d", ErrUntrustedSignature, cfg.Meta.Version)
+		}
+	}
+	if err := m.configStore.verifySignature(cfg); err != nil {
+		return err
+	}
+	if err := m.checkSigned(cfg); err != nil {
+		return err
+	}
+
+	if err := m.checkCrossRef(ctx, id, cfg); err != nil {
+		return err
+	}
+	if err := validateContent(cfg); err != nil {
+		return err
+	}
+	if err := m.checkRegistry(ctx, id, cfg); err != nil {
+		return err
+	}
+	if err := m.checkPolicies(id, cfg); err != nil {
+		return err
+	}
+	prune, err := m.checkQuota(ctx, id, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := m.persist(ctx, id, cfg, "submit", ""); err != nil {
+		return err
+	}
+	m.pruneVersions(ctx, id, prune)
+	return nil
+}
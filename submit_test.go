@@ -0,0 +1,107 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// externalNext builds and signs the successor of head outside any manager
+func externalNext(t *testing.T, head *Config, content string, signer *Signer) *Config {
+	t.Helper()
+	next := &Config{Meta: head.Meta, Content: json.RawMessage(content)}
+	if err := next.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	if err := signer.Sign(next); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return next
+}
+
+func TestSubmit(t *testing.T) {
+	ctx := context.Background()
+	pipeline, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage())
+
+	genesis := &Config{Content: json.RawMessage(`{"n":1}`)}
+	if err := genesis.UpdateMeta(); err != nil {
+		t.Fatalf("UpdateMeta failed: %v", err)
+	}
+	pipeline.Sign(genesis)
+	if err := manager.Submit(ctx, "app", genesis, pipeline.PublicKey()); err != nil {
+		t.Fatalf("Submit of v1 failed: %v", err)
+	}
+
+	v2 := externalNext(t, genesis, `{"n":2}`, pipeline)
+	if err := manager.Submit(ctx, "app", v2, pipeline.PublicKey()); err != nil {
+		t.Fatalf("Submit of v2 failed: %v", err)
+	}
+	latest, _ := manager.GetLatest(ctx, "app")
+	if latest.Meta.CS != v2.Meta.CS || VerifyConfigSignature(latest, pipeline.PublicKey()) != nil {
+		t.Errorf("submitted version not stored as signed: %+v", latest.Meta)
+	}
+	entries, _ := manager.journal.FindByID(ctx, "app")
+	if entries[len(entries)-1].Operation != "submit" {
+		t.Errorf("expected a submit entry, got %q", entries[len(entries)-1].Operation)
+	}
+
+	other, _ := NewSigner()
+	for name, tt := range map[string]struct {
+		cfg  *Config
+		keys []string
+		want error
+	}{
+		"resubmitted":     {v2, []string{pipeline.PublicKey()}, ErrVersionConflict},
+		"forked":          {externalNext(t, genesis, `{"n":3}`, pipeline), nil, ErrVersionConflict},
+		"skipped version": {externalNext(t, externalNext(t, v2, `{}`, pipeline), `{}`, pipeline), nil, ErrInvalidChain},
+		"untrusted":       {externalNext(t, v2, `{"n":3}`, other), []string{pipeline.PublicKey()}, ErrUntrustedSignature},
+		"tampered": {func() *Config {
+			c := externalNext(t, v2, `{"n":3}`, pipeline)
+			c.Content = json.RawMessage(`{"n":4}`)
+			return c
+		}(), nil, ErrChecksumMismatch},
+	} {
+		if err := manager.Submit(ctx, "app", tt.cfg, tt.keys...); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", name, tt.want, err)
+		}
+	}
+
+	if err := manager.Submit(ctx, "new", v2); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("expected ErrInvalidChain for a v2 of a new id, got %v", err)
+	}
+	if latest, _ := manager.GetLatest(ctx, "app"); latest.Meta.Version != 2 {
+		t.Errorf("rejected submissions changed the head to v%d", latest.Meta.Version)
+	}
+}
+
+func TestSubmitKeepsManagerVerification(t *testing.T) {
+	ctx := context.Background()
+	trusted, _ := NewSigner()
+	rogue, _ := NewSigner()
+	manager, _ := NewManager(NewMemoryStorage(), WithConfigStorageOptions(WithVerifyKeys([]string{trusted.PublicKey()})))
+
+	genesis := &Config{Content: json.RawMessage(`{"n":1}`)}
+	genesis.UpdateMeta()
+	rogue.Sign(genesis)
+
+	// The caller's keys narrow what the manager accepts, never widen it
+	if err := manager.Submit(ctx, "app", genesis, rogue.PublicKey()); !errors.Is(err, ErrUntrustedSignature) {
+		t.Fatalf("expected a key the manager does not trust to be rejected, got %v", err)
+	}
+	if _, err := manager.GetLatest(ctx, "app"); err == nil {
+		t.Fatal("a rejected submission must not write")
+	}
+
+	trusted.Sign(genesis)
+	if err := manager.Submit(ctx, "app", genesis, rogue.PublicKey()); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("expected the caller's keys to still be required, got %v", err)
+	}
+	if err := manager.Submit(ctx, "app", genesis, trusted.PublicKey()); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if _, err := manager.GetLatest(ctx, "app"); err != nil {
+		t.Errorf("GetLatest of the submitted version failed: %v", err)
+	}
+}
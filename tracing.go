@@ -0,0 +1,169 @@
+package viracochan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Span attribute keys set by the library
+const (
+	AttrOp      = "viracochan.op"      // operation name, as in MetricOperations
+	AttrID      = "viracochan.id"      // config id of a manager operation
+	AttrVersion = "viracochan.version" // version read or written
+	AttrPath    = "viracochan.path"    // storage path or list prefix
+	AttrBytes   = "viracochan.bytes"   // bytes read or written
+)
+
+// Attribute is a key/value pair attached to a span. Value is a string or an
+// int64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts spans around manager and storage operations. It is the part
+// of OpenTelemetry's trace.Tracer the library uses, so an adapter takes a
+// few lines (see the README) while the library itself has no tracing
+// dependency.
+type Tracer interface {
+	// Start starts a span named name as a child of any span in ctx and
+	// returns a context carrying it
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is an operation started by a Tracer
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// End ends the span. A non-nil err is recorded on the span and marks it
+	// as failed.
+	End(err error)
+}
+
+// WithTracer traces manager operations (the ones counted by
+// MetricOperations, which include every write) as spans named
+// "viracochan.<op>" with the config id and version, and every call they
+// make to the journal and config storages, and the read replicas, as a
+// child span named "viracochan.storage.<op>" with the path and byte count.
+// Operations spanning many ids, such as compact, have no id. Spans are
+// children of the span in the context passed in. Without a tracer nothing is
+// traced and no spans are allocated.
+func WithTracer(tracer Tracer) ManagerOption {
+	return func(m *Manager) error {
+		m.tracer = tracer
+		return nil
+	}
+}
+
+// setupTracing wraps the storages in tracedStorage once the options are
+// applied
+func (m *Manager) setupTracing() {
+	if m.tracer == nil {
+		return
+	}
+	m.storage = &tracedStorage{storage: m.storage, tracer: m.tracer}
+	m.configStore.storage = &tracedStorage{storage: m.configStore.storage, tracer: m.tracer}
+	m.journal.storage = &tracedStorage{storage: m.journal.storage, tracer: m.tracer}
+	for i, storage := range m.replicaStorages {
+		m.replicaStorages[i] = &tracedStorage{storage: storage, tracer: m.tracer}
+	}
+}
+
+// operation is one manager call being measured and traced
+type operation struct {
+	m     *Manager
+	name  string
+	start time.Time
+	span  Span
+}
+
+// begin starts measuring the manager call name on id. It is ended with
+//
+//	ctx, call := m.begin(ctx, "create", id)
+//	defer func() { call.end(cfg, err) }()
+func (m *Manager) begin(ctx context.Context, name, id string) (context.Context, operation) {
+	op := operation{m: m, name: name, start: time.Now()}
+	if m.tracer != nil {
+		ctx, op.span = m.tracer.Start(ctx, "viracochan."+name, Attribute{AttrOp, name}, Attribute{AttrID, id})
+	}
+	return ctx, op
+}
+
+// end records the outcome of the call, with cfg the version it read or
+// wrote, if any
+func (op operation) end(cfg *Config, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	op.m.metrics.IncCounter(MetricOperations, 1, "op", op.name, "result", result)
+	op.m.metrics.ObserveHistogram(MetricOperationDuration, time.Since(op.start).Seconds(), "op", op.name)
+
+	if op.span == nil {
+		return
+	}
+	if cfg != nil && err == nil {
+		op.span.SetAttributes(Attribute{AttrVersion, int64(cfg.Meta.Version)})
+	}
+	op.span.End(err)
+}
+
+// tracedStorage starts a span around every call to storage. It lists with
+// sizes like storage does, so Usage is unaffected.
+type tracedStorage struct {
+	storage Storage
+	tracer  Tracer
+}
+
+func (ts *tracedStorage) start(ctx context.Context, op, path string) (context.Context, Span) {
+	return ts.tracer.Start(ctx, "viracochan.storage."+op, Attribute{AttrOp, op}, Attribute{AttrPath, path})
+}
+
+func (ts *tracedStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	ctx, span := ts.start(ctx, "read", path)
+	data, err := ts.storage.Read(ctx, path)
+	span.SetAttributes(Attribute{AttrBytes, int64(len(data))})
+	span.End(err)
+	return data, err
+}
+
+func (ts *tracedStorage) Write(ctx context.Context, path string, data []byte) error {
+	ctx, span := ts.start(ctx, "write", path)
+	span.SetAttributes(Attribute{AttrBytes, int64(len(data))})
+	err := ts.storage.Write(ctx, path, data)
+	span.End(err)
+	return err
+}
+
+func (ts *tracedStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	ctx, span := ts.start(ctx, "list", prefix)
+	paths, err := ts.storage.List(ctx, prefix)
+	span.End(err)
+	return paths, err
+}
+
+func (ts *tracedStorage) Delete(ctx context.Context, path string) error {
+	ctx, span := ts.start(ctx, "delete", path)
+	err := ts.storage.Delete(ctx, path)
+	span.End(err)
+	return err
+}
+
+func (ts *tracedStorage) Exists(ctx context.Context, path string) (bool, error) {
+	ctx, span := ts.start(ctx, "exists", path)
+	ok, err := ts.storage.Exists(ctx, path)
+	span.End(err)
+	return ok, err
+}
+
+// PathLock passes on the wrapped storage's lock of path, see PathLocker
+func (ts *tracedStorage) PathLock(path string) sync.Locker {
+	return pathLock(ts.storage, path)
+}
+
+func (ts *tracedStorage) ListStat(ctx context.Context, prefix string) ([]FileStat, error) {
+	ctx, span := ts.start(ctx, "list_stat", prefix)
+	stats, err := listStat(ctx, ts.storage, prefix)
+	span.End(err)
+	return stats, err
+}
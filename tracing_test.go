@@ -0,0 +1,224 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+)
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type spanKey struct{}
+
+func (rt *recordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	for _, a := range attrs {
+		span.attrs[a.Key] = a.Value
+	}
+	rt.mu.Lock()
+	rt.spans = append(rt.spans, span)
+	rt.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), &tracedSpan{rt: rt, span: span}
+}
+
+func (rt *recordingTracer) named(name string) []*recordedSpan {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var out []*recordedSpan
+	for _, s := range rt.spans {
+		if s.name == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+type tracedSpan struct {
+	rt   *recordingTracer
+	span *recordedSpan
+}
+
+func (s *tracedSpan) SetAttributes(attrs ...Attribute) {
+	s.rt.mu.Lock()
+	defer s.rt.mu.Unlock()
+	for _, a := range attrs {
+		s.span.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *tracedSpan) End(err error) {
+	s.rt.mu.Lock()
+	defer s.rt.mu.Unlock()
+	s.span.err = err
+	s.span.ended = true
+}
+
+func TestManagerTracing(t *testing.T) {
+	ctx := context.Background()
+	tracer := &recordingTracer{}
+	manager, err := NewManager(NewMemoryStorage(), WithTracer(tracer))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	parentCtx, parent := tracer.Start(ctx, "request")
+	if _, err := manager.Create(parentCtx, "app", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	parent.End(nil)
+	if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": 2}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := manager.Get(ctx, "app", 9); err == nil {
+		t.Fatal("expected Get of a missing version to fail")
+	}
+
+	create := tracer.named("viracochan.create")
+	if len(create) != 1 {
+		t.Fatalf("expected one create span, got %d", len(create))
+	}
+	if s := create[0]; s.parent != "request" || !s.ended || s.err != nil ||
+		s.attrs[AttrID] != "app" || s.attrs[AttrVersion] != int64(1) || s.attrs[AttrOp] != "create" {
+		t.Errorf("unexpected create span %+v", s)
+	}
+
+	update := tracer.named("viracochan.update")
+	if len(update) != 1 || update[0].attrs[AttrVersion] != int64(2) {
+		t.Fatalf("unexpected update spans %+v", update)
+	}
+
+	writes := tracer.named("viracochan.storage.write")
+	if len(writes) == 0 {
+		t.Fatal("expected storage write spans")
+	}
+	for _, w := range writes {
+		if w.parent != "viracochan.create" && w.parent != "viracochan.update" {
+			t.Errorf("storage write %v is not a child of a manager span (parent %q)", w.attrs[AttrPath], w.parent)
+		}
+		if n, _ := w.attrs[AttrBytes].(int64); n <= 0 {
+			t.Errorf("storage write %v has no byte count", w.attrs[AttrPath])
+		}
+	}
+
+	get := tracer.named("viracochan.get")
+	if len(get) != 1 || !errors.Is(get[0].err, os.ErrNotExist) {
+		t.Fatalf("expected one failed get span, got %+v", get)
+	}
+	if _, ok := get[0].attrs[AttrVersion]; ok {
+		t.Error("failed get span carries a version")
+	}
+	var failedRead bool
+	for _, r := range tracer.named("viracochan.storage.read") {
+		if r.parent == "viracochan.get" && r.err != nil {
+			failedRead = true
+		}
+	}
+	if !failedRead {
+		t.Error("expected the failed storage read under the get span to record its error")
+	}
+}
+
+func TestManagerTracingUsage(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	plain, err := NewManager(storage)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if _, err := plain.Create(ctx, "app", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	traced, err := NewManager(storage, WithTracer(&recordingTracer{}))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	want, err := plain.Usage(ctx, "")
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	got, err := traced.Usage(ctx, "")
+	if err != nil {
+		t.Fatalf("traced Usage failed: %v", err)
+	}
+	if *got != *want {
+		t.Errorf("tracing changed usage: %+v, want %+v", got, want)
+	}
+}
+
+func TestManagerTracingWrites(t *testing.T) {
+	ctx := context.Background()
+	tracer := &recordingTracer{}
+	manager, _ := NewManager(NewMemoryStorage(), WithTracer(tracer))
+
+	steps := []struct {
+		span string
+		run  func() error
+	}{
+		{"create_or_get", func() error {
+			_, err := manager.CreateOrGet(ctx, "app", map[string]interface{}{"n": 1})
+			return err
+		}},
+		{"create_batch", func() error {
+			_, err := manager.CreateBatch(ctx, map[string]interface{}{"a": 1, "b": 2})
+			return err
+		}},
+		{"import_as_new", func() error {
+			_, err := manager.ImportAsNew(ctx, "copy", []byte(`{"n":1}`))
+			return err
+		}},
+		{"import_legacy", func() error {
+			_, err := manager.ImportLegacy(ctx, "legacy", map[string]interface{}{"n": 1})
+			return err
+		}},
+		{"promote", func() error { return manager.Promote(ctx, "app", 1, "stable") }},
+		{"create_snapshot", func() error {
+			_, err := manager.CreateSnapshot(ctx, "snap", "app")
+			return err
+		}},
+		{"rollback_to_snapshot", func() error {
+			_, err := manager.RollbackToSnapshot(ctx, "snap")
+			return err
+		}},
+		{"reset", func() error {
+			_, err := manager.Reset(ctx, "legacy", map[string]interface{}{"n": 2}, ConfirmReset())
+			return err
+		}},
+		{"compact", func() error {
+			_, err := manager.Compact(ctx)
+			return err
+		}},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s failed: %v", step.span, err)
+		}
+		spans := tracer.named("viracochan." + step.span)
+		if len(spans) != 1 || !spans[0].ended || spans[0].attrs[AttrOp] != step.span {
+			t.Errorf("expected one ended %s span, got %+v", step.span, spans)
+		}
+	}
+
+	// Every storage write happens inside some manager span
+	for _, w := range tracer.named("viracochan.storage.write") {
+		if w.parent == "" {
+			t.Errorf("storage write %v is outside any manager span", w.attrs[AttrPath])
+		}
+	}
+}
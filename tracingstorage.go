@@ -0,0 +1,84 @@
+package viracochan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StorageSink receives one call made through a TracingStorage: the operation
+// ("read", "write", "list", "list_stat", "delete" or "exists"), the path or
+// list prefix, the bytes read or written (0 for the others), how long the
+// call took and the error it returned
+type StorageSink func(op, path string, bytes int, dur time.Duration, err error)
+
+// TracingStorage wraps a Storage and reports every call to a sink. Calls and
+// their results pass through unchanged.
+type TracingStorage struct {
+	backend Storage
+	sink    StorageSink
+}
+
+// NewTracingStorage wraps backend so every call is reported to sink once it
+// returns. The sink runs on the calling goroutine, so it must be quick and
+// safe for concurrent use; the wrapper itself only adds two clock reads per
+// call. Listing with sizes goes through backend's ListStat when it has one,
+// so Usage reads no more than without the wrapper.
+//
+// It is meant for debugging a backend: logging each call, counting, or
+// watching sizes grow, as the journal does on every append. Spans per
+// manager operation come from WithTracer instead.
+func NewTracingStorage(backend Storage, sink StorageSink) Storage {
+	return &TracingStorage{backend: backend, sink: sink}
+}
+
+func (ts *TracingStorage) report(op, path string, bytes int, start time.Time, err error) {
+	ts.sink(op, path, bytes, time.Since(start), err)
+}
+
+func (ts *TracingStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	start := time.Now()
+	data, err := ts.backend.Read(ctx, path)
+	ts.report("read", path, len(data), start, err)
+	return data, err
+}
+
+func (ts *TracingStorage) Write(ctx context.Context, path string, data []byte) error {
+	start := time.Now()
+	err := ts.backend.Write(ctx, path, data)
+	ts.report("write", path, len(data), start, err)
+	return err
+}
+
+func (ts *TracingStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	start := time.Now()
+	paths, err := ts.backend.List(ctx, prefix)
+	ts.report("list", prefix, 0, start, err)
+	return paths, err
+}
+
+func (ts *TracingStorage) Delete(ctx context.Context, path string) error {
+	start := time.Now()
+	err := ts.backend.Delete(ctx, path)
+	ts.report("delete", path, 0, start, err)
+	return err
+}
+
+func (ts *TracingStorage) Exists(ctx context.Context, path string) (bool, error) {
+	start := time.Now()
+	ok, err := ts.backend.Exists(ctx, path)
+	ts.report("exists", path, 0, start, err)
+	return ok, err
+}
+
+// PathLock passes on the backend's lock of path, see PathLocker
+func (ts *TracingStorage) PathLock(path string) sync.Locker {
+	return pathLock(ts.backend, path)
+}
+
+func (ts *TracingStorage) ListStat(ctx context.Context, prefix string) ([]FileStat, error) {
+	start := time.Now()
+	stats, err := listStat(ctx, ts.backend, prefix)
+	ts.report("list_stat", prefix, 0, start, err)
+	return stats, err
+}
@@ -0,0 +1,104 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type storageCall struct {
+	op, path string
+	bytes    int
+	failed   bool
+}
+
+func TestTracingStorage(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var calls []storageCall
+	storage := NewTracingStorage(NewMemoryStorage(), func(op, path string, bytes int, dur time.Duration, err error) {
+		if dur < 0 {
+			t.Errorf("%s %s: negative duration %v", op, path, dur)
+		}
+		mu.Lock()
+		calls = append(calls, storageCall{op, path, bytes, err != nil})
+		mu.Unlock()
+	})
+
+	if err := storage.Write(ctx, "a/x", []byte("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if data, err := storage.Read(ctx, "a/x"); err != nil || string(data) != "hello" {
+		t.Fatalf("Read returned %q, %v", data, err)
+	}
+	if _, err := storage.Read(ctx, "a/missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a not-found error to pass through, got %v", err)
+	}
+	if paths, err := storage.List(ctx, "a"); err != nil || len(paths) != 1 {
+		t.Fatalf("List returned %v, %v", paths, err)
+	}
+	if ok, err := storage.Exists(ctx, "a/x"); err != nil || !ok {
+		t.Fatalf("Exists returned %v, %v", ok, err)
+	}
+	if err := storage.Delete(ctx, "a/x"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	want := []storageCall{
+		{"write", "a/x", 5, false},
+		{"read", "a/x", 5, false},
+		{"read", "a/missing", 0, true},
+		{"list", "a", 0, false},
+		{"exists", "a/x", 0, false},
+		{"delete", "a/x", 0, false},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("expected %d calls, got %+v", len(want), calls)
+	}
+	for i, c := range calls {
+		if c != want[i] {
+			t.Errorf("call %d: got %+v, want %+v", i, c, want[i])
+		}
+	}
+}
+
+func TestTracingStorageJournalReads(t *testing.T) {
+	ctx := context.Background()
+	var journalReads []int
+	storage := NewTracingStorage(NewMemoryStorage(), func(op, path string, bytes int, _ time.Duration, err error) {
+		if op == "read" && path == "journal.jsonl" && err == nil {
+			journalReads = append(journalReads, bytes)
+		}
+	})
+	manager, err := NewManager(storage)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	manager.Create(ctx, "app", map[string]interface{}{"n": 0})
+	for i := 1; i <= 5; i++ {
+		if _, err := manager.Update(ctx, "app", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	if len(journalReads) < 5 {
+		t.Fatalf("expected journal reads on every append, got %v", journalReads)
+	}
+	for i := 1; i < len(journalReads); i++ {
+		if journalReads[i] < journalReads[i-1] {
+			t.Errorf("journal reads should grow with each append: %v", journalReads)
+			break
+		}
+	}
+
+	tracedUsage, err := manager.Usage(ctx, "")
+	if err != nil {
+		t.Fatalf("Usage through the wrapper failed: %v", err)
+	}
+	if tracedUsage.Versions != 6 {
+		t.Errorf("expected 6 versions, got %+v", tracedUsage)
+	}
+}
@@ -0,0 +1,184 @@
+package viracochan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStat is one path of a StatLister listing with its size in bytes
+type FileStat struct {
+	Path string
+	Size int64
+}
+
+// StatLister is an optional Storage extension that lists paths together with
+// their sizes, following List's prefix semantics. Footprint queries such as
+// Manager.Usage use it to avoid reading content; on storages without it they
+// read every file instead.
+type StatLister interface {
+	ListStat(ctx context.Context, prefix string) ([]FileStat, error)
+}
+
+// listStat lists prefix with sizes, through StatLister when storage has it.
+// Paths that vanish between List and Read are skipped.
+func listStat(ctx context.Context, storage Storage, prefix string) ([]FileStat, error) {
+	if sl, ok := storage.(StatLister); ok {
+		return sl.ListStat(ctx, prefix)
+	}
+
+	paths, err := storage.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]FileStat, 0, len(paths))
+	for _, path := range paths {
+		data, err := storage.Read(ctx, path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, FileStat{Path: path, Size: int64(len(data))})
+	}
+	return stats, nil
+}
+
+// UsageInfo is the storage footprint reported by Manager.Usage
+type UsageInfo struct {
+	ID              string // empty for the whole store
+	IDs             int    // ids with at least one version file
+	Versions        int    // version files
+	ConfigBytes     int64  // bytes of version files
+	BlobBytes       int64  // bytes of content blobs; store-wide only
+	JournalBytes    int64  // journal bytes attributable to ID, or the whole journal
+	AvgVersionBytes int64  // ConfigBytes / Versions
+}
+
+// Usage reports the storage footprint of id: its version files, their
+// average size, and the journal lines recording it (each line with its
+// newline). An empty id reports the whole store instead, including content
+// blobs and the full journal file but not sidecars such as the intent log.
+// Sizes come from StatLister when the storage implements it; only the
+// per-id journal attribution reads the journal. An id without versions
+// reports zero usage rather than an error. A whole-store report also sets
+// the MetricStoreBytes and MetricConfigs gauges.
+func (m *Manager) Usage(ctx context.Context, id string) (*UsageInfo, error) {
+	if id != "" {
+		if err := m.validateID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	prefix := m.configStore.prefix
+	if id != "" {
+		prefix = filepath.Join(prefix, id)
+	}
+	stats, err := listStat(ctx, m.storage, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &UsageInfo{ID: id}
+	root := filepath.Clean(m.configStore.prefix) + string(filepath.Separator)
+	seen := make(map[string]bool)
+	for _, st := range stats {
+		rest := strings.TrimPrefix(filepath.Clean(st.Path), root)
+		owner, name, nested := strings.Cut(rest, string(filepath.Separator))
+		if !nested || !isVersionFile(name) {
+			continue
+		}
+		info.Versions++
+		info.ConfigBytes += st.Size
+		if !seen[owner] {
+			seen[owner] = true
+			info.IDs++
+		}
+	}
+	if info.Versions > 0 {
+		info.AvgVersionBytes = info.ConfigBytes / int64(info.Versions)
+	}
+
+	if id != "" {
+		info.JournalBytes, err = m.journalBytesFor(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+
+	blobs, err := listStat(ctx, m.storage, blobPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range blobs {
+		info.BlobBytes += st.Size
+	}
+
+	journal, err := listStat(ctx, m.journal.storage, m.journal.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range journal {
+		if filepath.Clean(st.Path) == filepath.Clean(m.journal.path) {
+			info.JournalBytes += st.Size
+		}
+	}
+
+	m.metrics.SetGauge(MetricStoreBytes, float64(info.ConfigBytes+info.BlobBytes+info.JournalBytes))
+	m.metrics.SetGauge(MetricConfigs, float64(info.IDs))
+	return info, nil
+}
+
+// isVersionFile reports whether name is a version file name, v<N>.json
+func isVersionFile(name string) bool {
+	digits, ok := strings.CutPrefix(name, "v")
+	digits, ok2 := strings.CutSuffix(digits, ".json")
+	if !ok || !ok2 || digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// journalBytesFor sums the journal lines, newline included, whose entry is
+// for id. Unparseable lines are attributed to no id.
+func (m *Manager) journalBytesFor(ctx context.Context, id string) (int64, error) {
+	data, err := m.journal.storage.Read(ctx, m.journal.path)
+	if err != nil {
+		if isMissingJournalError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var total int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.ID == id {
+			total += int64(len(scanner.Bytes())) + 1
+		}
+	}
+	return total, scanner.Err()
+}
@@ -0,0 +1,62 @@
+package viracochan
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUsage(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	manager, _ := NewManager(storage)
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	manager.Create(ctx, "db", map[string]interface{}{"host": "localhost"})
+
+	app, err := manager.Usage(ctx, "app")
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	wantBytes := int64(len(mustRead(t, storage, "configs/app/v1.json")) + len(mustRead(t, storage, "configs/app/v2.json")))
+	if app.Versions != 2 || app.IDs != 1 || app.ConfigBytes != wantBytes || app.AvgVersionBytes != wantBytes/2 {
+		t.Errorf("unexpected app usage %+v, want %d config bytes", app, wantBytes)
+	}
+
+	db, _ := manager.Usage(ctx, "db")
+	journal := int64(len(mustRead(t, storage, "journal.jsonl")))
+	if app.JournalBytes == 0 || app.JournalBytes+db.JournalBytes != journal {
+		t.Errorf("journal bytes %d + %d should cover the journal's %d", app.JournalBytes, db.JournalBytes, journal)
+	}
+
+	total, err := manager.Usage(ctx, "")
+	if err != nil {
+		t.Fatalf("Usage of the store failed: %v", err)
+	}
+	if total.IDs != 2 || total.Versions != 3 || total.ConfigBytes != app.ConfigBytes+db.ConfigBytes || total.JournalBytes != journal {
+		t.Errorf("unexpected store usage %+v", total)
+	}
+
+	if none, err := manager.Usage(ctx, "missing"); err != nil || none.Versions != 0 || none.JournalBytes != 0 {
+		t.Errorf("expected zero usage for a missing id, got %+v, %v", none, err)
+	}
+
+	// Storages without StatLister fall back to reading
+	reader, _ := NewManager(NewRetryingStorage(storage, RetryPolicy{}))
+	if got, _ := reader.Usage(ctx, ""); *got != *total {
+		t.Errorf("fallback usage %+v differs from %+v", got, total)
+	}
+}
+
+func TestFSStorageListStat(t *testing.T) {
+	storage := NewFSStorage(fstest.MapFS{
+		"configs/app/v1.json": {Data: []byte("12345")},
+		"other.json":          {Data: []byte("1")},
+	})
+
+	stats, err := storage.ListStat(context.Background(), "configs")
+	if err != nil || len(stats) != 1 || stats[0].Path != "configs/app/v1.json" || stats[0].Size != 5 {
+		t.Errorf("unexpected stats %+v, %v", stats, err)
+	}
+}
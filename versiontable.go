@@ -0,0 +1,77 @@
+package viracochan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// VersionRow is one version of an id as listed by VersionTable
+type VersionRow struct {
+	Version uint64    `json:"version"`
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	CS      string    `json:"cs"`
+	PrevCS  string    `json:"prev_cs"`
+	Message string    `json:"message,omitempty"`
+	// Signed is true when the version's config carries a signature, verified
+	// or not
+	Signed bool `json:"signed"`
+	// Signer is the candidate key the signature verifies under, empty when
+	// it is unsigned, verifies under none, or its config could not be read
+	Signer string `json:"signer,omitempty"`
+}
+
+// VersionTable lists every journaled version of id in version order, with
+// its operation, checksums and signer, reading the journal once. Signatures
+// are checked against candidateKeys, falling back to the config store's
+// verify keys or the signer's key when none are given, using the embedded
+// config or else the config file. Entries are listed as journaled, without
+// validating the chain; see ValidateChain and Audit for that.
+func (m *Manager) VersionTable(ctx context.Context, id string, candidateKeys []string) ([]VersionRow, error) {
+	if err := m.validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	entries, err := m.journal.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w for %q", ErrNoJournalEntries, id)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Version < entries[j].Version
+	})
+
+	keys := candidateKeys
+	if len(keys) == 0 {
+		keys = m.defaultTrustedKeys()
+	}
+
+	rows := make([]VersionRow, 0, len(entries))
+	for _, entry := range entries {
+		row := VersionRow{
+			Version: entry.Version,
+			Time:    entry.Time,
+			Op:      entry.Operation,
+			CS:      entry.CS,
+			PrevCS:  entry.PrevCS,
+			Message: entry.Message,
+		}
+		if cfg, err := m.entryConfig(ctx, entry); err == nil && cfg.Meta.Signature != "" {
+			row.Signed = true
+			row.Signer = verifyingKey(cfg, keys)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
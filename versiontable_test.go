@@ -0,0 +1,65 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVersionTable(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := NewSigner()
+	bob, _ := NewSigner()
+	storage := NewMemoryStorage()
+	// Managers sharing storage must not serve each other stale heads
+	fresh := WithCacheTTL(time.Nanosecond)
+	ma, _ := NewManager(storage, WithSigner(alice), fresh)
+	mb, _ := NewManager(storage, WithSigner(bob), fresh)
+	plain, _ := NewManager(storage, fresh)
+
+	ma.Create(ctx, "app", map[string]interface{}{"n": 1})
+	mb.Update(ctx, "app", map[string]interface{}{"n": 2}, WithMessage("bump"))
+	plain.Update(ctx, "app", map[string]interface{}{"n": 3})
+	ma.Rollback(ctx, "app", 1)
+
+	rows, err := plain.VersionTable(ctx, "app", []string{alice.PublicKey(), bob.PublicKey()})
+	if err != nil {
+		t.Fatalf("VersionTable failed: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+
+	want := []struct {
+		op     string
+		signer string
+		signed bool
+	}{
+		{"create", alice.PublicKey(), true},
+		{"update", bob.PublicKey(), true},
+		{"update", "", false},
+		{"rollback_to_v1", alice.PublicKey(), true},
+	}
+	for i, row := range rows {
+		if row.Version != uint64(i+1) || row.Op != want[i].op || row.Signer != want[i].signer || row.Signed != want[i].signed {
+			t.Errorf("row %d: got %+v", i, row)
+		}
+		if i > 0 && row.PrevCS != rows[i-1].CS {
+			t.Errorf("row %d: prev_cs does not link to row %d", i, i-1)
+		}
+	}
+	if rows[1].Message != "bump" {
+		t.Errorf("expected message on v2, got %q", rows[1].Message)
+	}
+
+	// Without candidates, the manager's own key is used
+	rows, _ = mb.VersionTable(ctx, "app", nil)
+	if rows[0].Signer != "" || !rows[0].Signed || rows[1].Signer != bob.PublicKey() {
+		t.Errorf("expected only bob's version verified, got %+v", rows[:2])
+	}
+
+	if _, err := plain.VersionTable(ctx, "missing", nil); !errors.Is(err, ErrNoJournalEntries) {
+		t.Errorf("expected ErrNoJournalEntries, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package viracochantest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/source-c/viracochan"
+)
+
+// ChainOption configures BuildChain
+type ChainOption func(*chainOptions)
+
+type chainOptions struct {
+	start time.Time
+	step  time.Duration
+}
+
+// WithTimes stamps the first version of a chain with start and each later
+// one step after the version before it, so fixtures get the same timestamps
+// on every run. step must not be negative.
+func WithTimes(start time.Time, step time.Duration) ChainOption {
+	return func(o *chainOptions) {
+		o.start = start
+		o.step = step
+	}
+}
+
+// BuildChain returns a valid chain of configs holding contents in order, as
+// v1 to vN, each linked to the one before it with genuine checksums, and
+// signed by signer unless it is nil. The configs are built in memory, without
+// a manager or storage, and pass Config.NextOf, Config.Validate and
+// viracochan.VerifyChainSignatures. Timestamps come from the process's
+// version clock, as for Manager writes, unless WithTimes fixes them.
+func BuildChain(contents []interface{}, signer *viracochan.Signer, opts ...ChainOption) ([]*viracochan.Config, error) {
+	var o chainOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.step < 0 {
+		return nil, errors.New("chain time step must not be negative")
+	}
+
+	chain := make([]*viracochan.Config, 0, len(contents))
+	var prev *viracochan.Config
+	for i, content := range contents {
+		data, err := json.Marshal(content)
+		if err != nil {
+			return nil, fmt.Errorf("content %d: %w", i, err)
+		}
+
+		cfg := &viracochan.Config{Content: data}
+		if prev != nil {
+			cfg.Meta = prev.Meta
+		}
+		if o.start.IsZero() {
+			err = cfg.UpdateMeta()
+		} else {
+			err = cfg.UpdateMetaAt(o.start.Add(time.Duration(i) * o.step))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("content %d: %w", i, err)
+		}
+		if signer != nil {
+			if err := signer.Sign(cfg); err != nil {
+				return nil, fmt.Errorf("content %d: %w", i, err)
+			}
+		}
+
+		chain = append(chain, cfg)
+		prev = cfg
+	}
+	return chain, nil
+}
+
+// ChainEntries returns the journal entries of chain under id, in chain order
+// and with the configs embedded, as a Manager would have journaled them: v1
+// as "create" and the rest as "update". Shuffled, they make Resequence
+// fixtures whose checksums ValidateChain really checks.
+func ChainEntries(id string, chain []*viracochan.Config) []*viracochan.JournalEntry {
+	entries := make([]*viracochan.JournalEntry, len(chain))
+	for i, cfg := range chain {
+		op := "update"
+		if cfg.Meta.Version == 1 {
+			op = "create"
+		}
+		entries[i] = viracochan.NewJournalEntry(id, op, cfg)
+	}
+	return entries
+}
@@ -0,0 +1,113 @@
+package viracochantest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/source-c/viracochan"
+)
+
+func TestBuildChain(t *testing.T) {
+	signer, err := viracochan.NewSigner()
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	contents := []interface{}{
+		map[string]interface{}{"n": 1},
+		map[string]interface{}{"n": 2},
+		map[string]interface{}{"n": 3},
+		map[string]interface{}{"n": 4},
+	}
+
+	chain, err := BuildChain(contents, signer)
+	if err != nil {
+		t.Fatalf("BuildChain failed: %v", err)
+	}
+	if len(chain) != len(contents) {
+		t.Fatalf("got %d configs, want %d", len(chain), len(contents))
+	}
+	for i, cfg := range chain {
+		if cfg.Meta.Version != uint64(i+1) {
+			t.Errorf("config %d is v%d", i, cfg.Meta.Version)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("v%d invalid: %v", cfg.Meta.Version, err)
+		}
+		if i > 0 {
+			if err := cfg.NextOf(chain[i-1]); err != nil {
+				t.Errorf("v%d does not follow v%d: %v", cfg.Meta.Version, i, err)
+			}
+		}
+	}
+	if err := viracochan.VerifyChainSignaturesStrict(chain, signer.PublicKey()); err != nil {
+		t.Errorf("chain signatures: %v", err)
+	}
+
+	unsigned, err := BuildChain(contents[:2], nil)
+	if err != nil || unsigned[1].Meta.Signature != "" {
+		t.Errorf("BuildChain without a signer: %v, %+v", err, unsigned)
+	}
+}
+
+func TestBuildChainWithTimes(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	contents := []interface{}{"a", "b", "c"}
+
+	first, err := BuildChain(contents, nil, WithTimes(start, time.Minute))
+	if err != nil {
+		t.Fatalf("BuildChain failed: %v", err)
+	}
+	second, _ := BuildChain(contents, nil, WithTimes(start, time.Minute))
+	for i, cfg := range first {
+		if want := start.Add(time.Duration(i) * time.Minute); !cfg.Meta.Time.Equal(want) {
+			t.Errorf("v%d stamped %s, want %s", cfg.Meta.Version, cfg.Meta.Time, want)
+		}
+		if cfg.Meta.CS != second[i].Meta.CS {
+			t.Errorf("v%d checksum differs between runs", cfg.Meta.Version)
+		}
+	}
+
+	if _, err := BuildChain(contents, nil, WithTimes(start, -time.Second)); err == nil {
+		t.Error("expected a negative step to be rejected")
+	}
+}
+
+func TestChainEntries(t *testing.T) {
+	chain, err := BuildChain([]interface{}{"a", "b", "c", "d", "e"}, nil)
+	if err != nil {
+		t.Fatalf("BuildChain failed: %v", err)
+	}
+	entries := ChainEntries("cfg", chain)
+	if entries[0].Operation != "create" || entries[1].Operation != "update" {
+		t.Errorf("operations %q, %q", entries[0].Operation, entries[1].Operation)
+	}
+
+	journal := viracochan.NewJournal(viracochan.NewMemoryStorage(), "journal.jsonl")
+	scattered := []*viracochan.JournalEntry{entries[3], entries[0], entries[4], entries[2], entries[1]}
+	ordered, err := journal.Resequence(scattered)
+	if err != nil {
+		t.Fatalf("Resequence failed: %v", err)
+	}
+	if !reflect.DeepEqual(ordered, entries) {
+		t.Error("Resequence did not restore chain order")
+	}
+	if err := journal.ValidateChain(ordered); err != nil {
+		t.Errorf("ValidateChain failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := journal.AppendBatch(ctx, ChainEntries("other", chain)); err != nil {
+		t.Fatalf("AppendBatch failed: %v", err)
+	}
+	if found, _ := journal.FindByID(ctx, "other"); len(found) != len(chain) {
+		t.Errorf("journaled %d entries, want %d", len(found), len(chain))
+	}
+
+	// The embedded configs carry real checksums, so tampering is caught
+	entries[2].Config.Content = []byte(`"tampered"`)
+	if err := journal.ValidateChain(ordered); err == nil {
+		t.Error("expected ValidateChain to catch tampered content")
+	}
+}
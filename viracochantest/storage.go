@@ -0,0 +1,231 @@
+// Package viracochantest provides helpers for testing code built on
+// viracochan, including a conformance suite for third-party Storage backends
+// and builders of valid config chains for fixtures.
+package viracochantest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/source-c/viracochan"
+)
+
+// StorageConformanceTest verifies that storages produced by newStorage
+// satisfy the viracochan.Storage contract. Each subtest receives a fresh,
+// empty storage.
+func StorageConformanceTest(t *testing.T, newStorage func() viracochan.Storage) {
+	t.Helper()
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, s viracochan.Storage)
+	}{
+		{"ReadMissing", testReadMissing},
+		{"WriteRead", testWriteRead},
+		{"EmptyWrite", testEmptyWrite},
+		{"Overwrite", testOverwrite},
+		{"DataIsolation", testDataIsolation},
+		{"ListPrefixBoundary", testListPrefixBoundary},
+		{"ListMissingPrefix", testListMissingPrefix},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStorage())
+		})
+	}
+}
+
+func testReadMissing(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.Read(ctx, "missing/file.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Read of missing path: expected error matching os.ErrNotExist, got %v", err)
+	}
+
+	exists, err := s.Exists(ctx, "missing/file.json")
+	if err != nil {
+		t.Errorf("Exists of missing path returned error: %v", err)
+	}
+	if exists {
+		t.Error("Exists reported missing path as present")
+	}
+}
+
+func testWriteRead(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+	data := []byte(`{"key":"value"}`)
+
+	if err := s.Write(ctx, "nested/dir/file.json", data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	got, err := s.Read(ctx, "nested/dir/file.json")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Read returned %q, want %q", got, data)
+	}
+
+	exists, err := s.Exists(ctx, "nested/dir/file.json")
+	if err != nil || !exists {
+		t.Errorf("Exists after write: exists=%v err=%v", exists, err)
+	}
+}
+
+func testEmptyWrite(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+
+	if err := s.Write(ctx, "empty.json", []byte{}); err != nil {
+		t.Fatalf("empty Write failed: %v", err)
+	}
+
+	got, err := s.Read(ctx, "empty.json")
+	if err != nil {
+		t.Fatalf("Read of empty file failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected zero-length data, got %d bytes", len(got))
+	}
+
+	exists, err := s.Exists(ctx, "empty.json")
+	if err != nil || !exists {
+		t.Errorf("empty file should exist: exists=%v err=%v", exists, err)
+	}
+}
+
+func testOverwrite(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+
+	if err := s.Write(ctx, "file.json", []byte("a much longer initial value")); err != nil {
+		t.Fatalf("initial Write failed: %v", err)
+	}
+	if err := s.Write(ctx, "file.json", []byte("short")); err != nil {
+		t.Fatalf("overwrite failed: %v", err)
+	}
+
+	got, err := s.Read(ctx, "file.json")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "short" {
+		t.Errorf("overwrite not applied, got %q", got)
+	}
+}
+
+func testDataIsolation(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+	data := []byte("original")
+
+	if err := s.Write(ctx, "file.json", data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	data[0] = 'X'
+
+	got, err := s.Read(ctx, "file.json")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "original" {
+		t.Errorf("mutating the written slice changed stored data: %q", got)
+	}
+
+	got[0] = 'Y'
+	again, _ := s.Read(ctx, "file.json")
+	if string(again) != "original" {
+		t.Errorf("mutating a read result changed stored data: %q", again)
+	}
+}
+
+func testListPrefixBoundary(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+
+	for _, path := range []string{"dir/a.json", "dir/sub/b.json", "dir2/c.json", "dirfile.json"} {
+		if err := s.Write(ctx, path, []byte("x")); err != nil {
+			t.Fatalf("Write %s failed: %v", path, err)
+		}
+	}
+
+	want := []string{"dir/a.json", "dir/sub/b.json"}
+	for _, prefix := range []string{"dir", "dir/"} {
+		got, err := s.List(ctx, prefix)
+		if err != nil {
+			t.Fatalf("List(%q) failed: %v", prefix, err)
+		}
+		if !equalPaths(got, want) {
+			t.Errorf("List(%q) = %v, want %v", prefix, got, want)
+		}
+	}
+
+	all, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List(\"\") failed: %v", err)
+	}
+	if len(all) != 4 {
+		t.Errorf("List(\"\") should return every path, got %v", all)
+	}
+}
+
+func testListMissingPrefix(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+
+	got, err := s.List(ctx, "never/created")
+	if err != nil {
+		t.Errorf("List of missing prefix returned error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("List of missing prefix returned %v", got)
+	}
+}
+
+func testDelete(t *testing.T, s viracochan.Storage) {
+	ctx := context.Background()
+
+	if err := s.Write(ctx, "dir/file.json", []byte("x")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := s.Delete(ctx, "dir/file.json"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if exists, _ := s.Exists(ctx, "dir/file.json"); exists {
+		t.Error("path still exists after Delete")
+	}
+	if _, err := s.Read(ctx, "dir/file.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Read after Delete: expected os.ErrNotExist, got %v", err)
+	}
+	if paths, _ := s.List(ctx, "dir"); len(paths) != 0 {
+		t.Errorf("List after Delete returned %v", paths)
+	}
+}
+
+func testDeleteMissing(t *testing.T, s viracochan.Storage) {
+	if err := s.Delete(context.Background(), "never/written.json"); err != nil {
+		t.Errorf("Delete of missing path should be tolerated, got %v", err)
+	}
+}
+
+func equalPaths(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	normalized := make([]string, len(got))
+	for i, p := range got {
+		normalized[i] = filepath.ToSlash(p)
+	}
+	sort.Strings(normalized)
+	for i := range want {
+		if normalized[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
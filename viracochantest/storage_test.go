@@ -0,0 +1,29 @@
+package viracochantest
+
+import (
+	"testing"
+
+	"github.com/source-c/viracochan"
+)
+
+func TestMemoryStorageConformance(t *testing.T) {
+	StorageConformanceTest(t, func() viracochan.Storage {
+		return viracochan.NewMemoryStorage()
+	})
+}
+
+func TestFileStorageConformance(t *testing.T) {
+	StorageConformanceTest(t, func() viracochan.Storage {
+		fs, err := viracochan.NewFileStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFileStorage failed: %v", err)
+		}
+		return fs
+	})
+}
+
+func TestRetryingStorageConformance(t *testing.T) {
+	StorageConformanceTest(t, func() viracochan.Storage {
+		return viracochan.NewRetryingStorage(viracochan.NewMemoryStorage(), viracochan.RetryPolicy{})
+	})
+}
@@ -0,0 +1,155 @@
+package viracochan
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// ConfigDiff is the change from one version of a config to the next, as
+// delivered by WatchDiffs. A diff from empty, for a config's first version or
+// the replayed current one, has FromVersion 0 and lists every top-level key
+// as added.
+type ConfigDiff struct {
+	ID          string    `json:"id"`
+	FromVersion uint64    `json:"from_version"`
+	FromCS      string    `json:"from_cs,omitempty"`
+	ToVersion   uint64    `json:"to_version"`
+	ToCS        string    `json:"to_cs"`
+	Time        time.Time `json:"time"`
+	Changes     []Change  `json:"changes"`
+}
+
+// WatchDiffs is like Watch but delivers what changed rather than the new
+// config: the Diff between consecutive versions, one per version. Versions
+// written between two polls are loaded so that each diff covers a single
+// step; if one cannot be loaded, or a reset replaced the lineage, the diff
+// spans from the version last delivered. With WatchReplay the first value is
+// the current config as a diff from empty, so a late subscriber can
+// initialize from the channel alone. WatchFilter and WatchMaxErrors apply as
+// in WatchErr; unlike WatchErr, read errors are retried forever by default.
+//
+//	diffs, err := manager.WatchDiffs(ctx, "app", time.Second, viracochan.WatchReplay())
+//	for d := range diffs {
+//		for _, c := range d.Changes {
+//			apply(c.Path, c.New)
+//		}
+//	}
+func (m *Manager) WatchDiffs(ctx context.Context, id string, interval time.Duration, opts ...WatchOption) (<-chan *ConfigDiff, error) {
+	var wo watchOptions
+	for _, opt := range opts {
+		opt(&wo)
+	}
+
+	last, err := m.startWatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *ConfigDiff, 1)
+	if last != nil && wo.replay {
+		if d, err := configDiff(id, nil, last); err != nil {
+			m.logger.Warn("watch: diff failed", "id", id, "version", last.Meta.Version, "error", err)
+		} else {
+			// Channel is fresh and buffered, so this never blocks
+			ch <- d
+		}
+	}
+
+	go func() {
+		defer m.workers.Done()
+		defer close(ch)
+
+		m.pollWatch(ctx, id, interval, wo, last, func(old, cfg *Config) bool {
+			for _, d := range m.versionDiffs(ctx, id, old, cfg) {
+				select {
+				case ch <- d:
+				case <-ctx.Done():
+					wo.end(ctx.Err())
+					return false
+				case <-m.done:
+					wo.end(ErrClosed)
+					return false
+				}
+			}
+			return true
+		})
+	}()
+
+	return ch, nil
+}
+
+// versionDiffs returns the diffs from old (nil for empty) to each version of
+// id up to cfg, falling back to a single diff from old when the versions in
+// between cannot be loaded or do not chain from it
+func (m *Manager) versionDiffs(ctx context.Context, id string, old, cfg *Config) []*ConfigDiff {
+	steps := []*Config{cfg}
+	if old != nil && cfg.Meta.Version > old.Meta.Version+1 {
+		between := make([]*Config, 0, cfg.Meta.Version-old.Meta.Version)
+		prev := old
+		for v := old.Meta.Version + 1; v < cfg.Meta.Version; v++ {
+			next, err := m.Get(ctx, id, v)
+			if err != nil || next.Meta.PrevCS != prev.Meta.CS {
+				between = nil
+				break
+			}
+			between = append(between, next)
+			prev = next
+		}
+		if between != nil && cfg.Meta.PrevCS == prev.Meta.CS {
+			steps = append(between, cfg)
+		}
+	}
+
+	diffs := make([]*ConfigDiff, 0, len(steps))
+	for _, next := range steps {
+		d, err := configDiff(id, old, next)
+		if err != nil {
+			m.logger.Warn("watch: diff failed", "id", id, "version", next.Meta.Version, "error", err)
+		} else {
+			diffs = append(diffs, d)
+		}
+		old = next
+	}
+	return diffs
+}
+
+// configDiff returns the diff from old to cfg, from empty content if old is
+// nil
+func configDiff(id string, old, cfg *Config) (*ConfigDiff, error) {
+	d := &ConfigDiff{
+		ID:        id,
+		ToVersion: cfg.Meta.Version,
+		ToCS:      cfg.Meta.CS,
+		Time:      cfg.Meta.Time,
+	}
+
+	var err error
+	if old != nil {
+		d.FromVersion, d.FromCS = old.Meta.Version, old.Meta.CS
+		d.Changes, err = Diff(old.Content, cfg.Content)
+	} else {
+		d.Changes, err = diffFromEmpty(cfg.Content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// diffFromEmpty lists content as added: each top-level key of an object, any
+// other value whole at the root
+func diffFromEmpty(content json.RawMessage) ([]Change, error) {
+	if jsonType(content) == "object" {
+		return Diff(json.RawMessage("{}"), content)
+	}
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	if err := appendChange(&changes, nil, ChangeAdded, nil, v); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
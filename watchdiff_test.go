@@ -0,0 +1,97 @@
+package viracochan
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func nextDiff(t *testing.T, ch <-chan *ConfigDiff) *ConfigDiff {
+	t.Helper()
+	select {
+	case d, ok := <-ch:
+		if !ok {
+			t.Fatal("diff channel closed")
+		}
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no diff delivered")
+	}
+	return nil
+}
+
+func TestManagerWatchDiffs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager, _ := NewManager(NewMemoryStorage())
+	defer manager.Close()
+
+	v1, _ := manager.Create(ctx, "app", map[string]interface{}{"host": "a", "port": 1})
+
+	diffs, err := manager.WatchDiffs(ctx, "app", 20*time.Millisecond, WatchReplay())
+	if err != nil {
+		t.Fatalf("WatchDiffs failed: %v", err)
+	}
+	initial := nextDiff(t, diffs)
+	if initial.FromVersion != 0 || initial.ToVersion != 1 || initial.ToCS != v1.Meta.CS || len(initial.Changes) != 2 {
+		t.Fatalf("unexpected initial diff %+v", initial)
+	}
+	for _, c := range initial.Changes {
+		if c.Kind != ChangeAdded {
+			t.Errorf("initial diff should only add keys, got %+v", c)
+		}
+	}
+
+	manager.Update(ctx, "app", map[string]interface{}{"host": "a", "port": 2})
+	d := nextDiff(t, diffs)
+	if d.FromVersion != 1 || d.ToVersion != 2 || len(d.Changes) != 1 || d.Changes[0].Path != "port" || string(d.Changes[0].New) != "2" {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+
+	// Versions written between polls still arrive one step at a time
+	manager.Update(ctx, "app", map[string]interface{}{"host": "b", "port": 2})
+	manager.Update(ctx, "app", map[string]interface{}{"host": "b", "port": 2, "tls": true})
+	d3, d4 := nextDiff(t, diffs), nextDiff(t, diffs)
+	if d3.FromVersion != 2 || d3.ToVersion != 3 || len(d3.Changes) != 1 || d3.Changes[0].Path != "host" {
+		t.Errorf("unexpected diff to v3 %+v", d3)
+	}
+	if d4.FromVersion != 3 || d4.ToVersion != 4 || len(d4.Changes) != 1 || d4.Changes[0].Kind != ChangeAdded {
+		t.Errorf("unexpected diff to v4 %+v", d4)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-diffs:
+		if ok {
+			t.Error("expected no diff after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("diff channel not closed after cancellation")
+	}
+}
+
+func TestManagerWatchDiffsWithoutReplay(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := NewManager(NewMemoryStorage())
+	defer manager.Close()
+
+	manager.Create(ctx, "app", map[string]interface{}{"n": 1})
+	diffs, _ := manager.WatchDiffs(ctx, "app", 10*time.Millisecond)
+	later, _ := manager.WatchDiffs(ctx, "later", 10*time.Millisecond)
+
+	manager.Update(ctx, "app", map[string]interface{}{"n": 2})
+	if d := nextDiff(t, diffs); d.FromVersion != 1 || d.ToVersion != 2 {
+		t.Errorf("expected the first diff to be the update, got %+v", d)
+	}
+
+	// A config created after subscribing starts from empty
+	manager.Create(ctx, "later", []interface{}{"a"})
+	d := nextDiff(t, later)
+	if d.FromVersion != 0 || d.ToVersion != 1 || len(d.Changes) != 1 || d.Changes[0].Path != "" || d.Changes[0].Kind != ChangeAdded {
+		t.Errorf("unexpected diff for a new config %+v", d)
+	}
+
+	if _, err := manager.WatchDiffs(ctx, "bad/../id", time.Second); err == nil {
+		t.Error("expected an invalid id to fail")
+	}
+}
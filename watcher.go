@@ -0,0 +1,121 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrWatchFailed is returned by Watcher.Err when the watch gave up after too
+// many consecutive read errors
+var ErrWatchFailed = errors.New("watch failed")
+
+// DefaultWatchMaxErrors is the number of consecutive read errors after which
+// a Watcher gives up, unless WatchMaxErrors says otherwise
+const DefaultWatchMaxErrors = 5
+
+// WatchOption configures WatchErr and WatchDiffs
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	replay    bool
+	predicate func(old, new *Config) bool
+	maxErrors int         // consecutive errors before giving up; 0 never does
+	stop      func(error) // records why the watch ended
+}
+
+func (o watchOptions) end(err error) {
+	if o.stop != nil {
+		o.stop(err)
+	}
+}
+
+// WatchReplay delivers the current config first, as WatchWithReplay does.
+// WatchDiffs delivers it as a diff from empty content.
+func WatchReplay() WatchOption {
+	return func(o *watchOptions) {
+		o.replay = true
+	}
+}
+
+// WatchFilter only emits the versions predicate accepts, as WatchWhere does
+func WatchFilter(predicate func(old, new *Config) bool) WatchOption {
+	return func(o *watchOptions) {
+		o.predicate = predicate
+	}
+}
+
+// WatchMaxErrors sets the number of consecutive read errors after which the
+// watch gives up. Zero or less retries forever.
+func WatchMaxErrors(n int) WatchOption {
+	return func(o *watchOptions) {
+		o.maxErrors = max(n, 0)
+	}
+}
+
+// Watcher is a running watch started by WatchErr. C receives new versions
+// and is closed when the watch ends; Err then tells why.
+type Watcher struct {
+	C <-chan *Config
+
+	done <-chan struct{}
+	mu   sync.Mutex
+	err  error
+}
+
+// Err returns why C was closed: the context's error, ErrClosed if the manager
+// was closed, or an error wrapping ErrWatchFailed and the last read error if
+// storage kept failing. It returns nil while the watch is running.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Done returns a channel that is closed once the watcher goroutine has
+// exited, as WatchWithDone does
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// WatchErr is like Watch but tells a quiet config from a failing storage.
+// Read errors are retried on the next tick; after DefaultWatchMaxErrors
+// consecutive ones (see WatchMaxErrors) the watch gives up, closes C, and
+// Err reports the last error. A missing or expired config is not an error,
+// and any successful read resets the count.
+//
+//	w, err := manager.WatchErr(ctx, "app", time.Second)
+//	for cfg := range w.C {
+//		apply(cfg)
+//	}
+//	if err := w.Err(); errors.Is(err, viracochan.ErrWatchFailed) {
+//		log.Printf("config watch stopped: %v", err)
+//	}
+func (m *Manager) WatchErr(ctx context.Context, id string, interval time.Duration, opts ...WatchOption) (*Watcher, error) {
+	wo := watchOptions{maxErrors: DefaultWatchMaxErrors}
+	for _, opt := range opts {
+		opt(&wo)
+	}
+
+	w := &Watcher{}
+	wo.stop = func(err error) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.err = err
+	}
+
+	ch, done, err := m.watch(ctx, id, interval, wo)
+	if err != nil {
+		return nil, err
+	}
+	w.C, w.done = ch, done
+	return w, nil
+}
+
+// watchFailure reports whether err from a watch poll counts towards giving
+// up. A config that does not exist yet or has expired is a valid state.
+func watchFailure(err error) bool {
+	return !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrExpired)
+}
@@ -0,0 +1,93 @@
+package viracochan
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// outageStorage fails every read while down is set
+type outageStorage struct {
+	*MemoryStorage
+	down atomic.Bool
+}
+
+func (s *outageStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	if s.down.Load() {
+		return nil, errTransient
+	}
+	return s.MemoryStorage.Read(ctx, path)
+}
+
+func TestManagerWatchErr(t *testing.T) {
+	ctx := context.Background()
+	storage := &outageStorage{MemoryStorage: NewMemoryStorage()}
+	manager, _ := NewManager(storage, WithCacheTTL(time.Nanosecond))
+	defer manager.Close()
+
+	manager.Create(ctx, "app", map[string]interface{}{"v": 1})
+
+	w, err := manager.WatchErr(ctx, "app", 5*time.Millisecond, WatchReplay(), WatchMaxErrors(3))
+	if err != nil {
+		t.Fatalf("WatchErr failed: %v", err)
+	}
+	if cfg := <-w.C; cfg.Meta.Version != 1 {
+		t.Fatalf("expected replayed v1, got v%d", cfg.Meta.Version)
+	}
+
+	manager.Update(ctx, "app", map[string]interface{}{"v": 2})
+	select {
+	case cfg := <-w.C:
+		if cfg.Meta.Version != 2 {
+			t.Errorf("expected v2, got v%d", cfg.Meta.Version)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no emission for v2")
+	}
+	if err := w.Err(); err != nil {
+		t.Errorf("expected no error while running, got %v", err)
+	}
+
+	storage.down.Store(true)
+	select {
+	case _, ok := <-w.C:
+		if ok {
+			t.Fatal("expected channel closed after the outage")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch did not give up")
+	}
+	<-w.Done()
+	if err := w.Err(); !errors.Is(err, ErrWatchFailed) || !errors.Is(err, errTransient) {
+		t.Errorf("expected ErrWatchFailed wrapping the read error, got %v", err)
+	}
+}
+
+func TestManagerWatchErrEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	manager, _ := NewManager(NewMemoryStorage())
+
+	// A missing config is not a failure
+	w, err := manager.WatchErr(ctx, "missing", 2*time.Millisecond, WatchMaxErrors(1))
+	if err != nil {
+		t.Fatalf("WatchErr failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := w.Err(); err != nil {
+		t.Fatalf("expected a missing config to be retried, got %v", err)
+	}
+
+	cancel()
+	<-w.Done()
+	if err := w.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	w, _ = manager.WatchErr(context.Background(), "missing", time.Millisecond)
+	manager.Close()
+	if err := w.Err(); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}